@@ -1,35 +1,334 @@
+// Command db-migrate migrates the database the environment configures.
+//
+// Usage:
+//
+//	db-migrate [-dry-run] [-yes] <command> [arguments]
+//
+// The commands are:
+//
+//	up [N]      apply every pending migration, or the next N; the default command
+//	down [N]    revert every migration, or the last N
+//	goto V      migrate up or down to version V
+//	version     print the version of the database
+//	force V     set the version to V without running migrations, to clear a dirty state;
+//	            use "force -- -1" to mark no migration applied
+//	create NAME scaffold a timestamped up and down migration for every dialect
+//	lint        report destructive statements, statements locking tables in use, missing down
+//	            or dialect files and misnamed triggers; exits 1 on errors but not on warnings
+//
+// The migrations are those built into the binary, or those in DB_MIGRATIONS_FOLDER when it is
+// set. create needs DB_MIGRATIONS_FOLDER to name the migrations folder of the source tree.
+//
+// lint skips statements preceded by a "-- lint:ignore RULE[,RULE] reason" comment.
+//
+// Every command but version and lint asks for confirmation unless -yes is given. With -dry-run they print
+// the SQL they would run, or the change they would make, and change nothing.
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/rs/zerolog"
 
 	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db/migrations"
 	"github.com/kylep342/mendel/pkg/logger"
 )
 
+// errAborted is returned when the confirmation prompt is declined
+var errAborted = errors.New("aborted; pass -yes to skip the confirmation")
+
+// migrator runs one command against the database and migrations of env
+type migrator struct {
+	logger zerolog.Logger
+	env    *constants.EnvConfig
+	m      *migrate.Migrate
+	src    source.Driver
+	dryRun bool
+	yes    bool
+}
+
 func main() {
+	flags := flag.NewFlagSet(constants.AppDbMigrate, flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print what the command would do without doing it")
+	yes := flags.Bool("yes", false, "skip the confirmation prompt")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [-dry-run] [-yes] up [N] | down [N] | goto V | version | force V | create NAME | lint\n", constants.AppDbMigrate)
+		flags.PrintDefaults()
+	}
+	args := parseArgs(flags, os.Args[1:])
+	if len(args) == 0 {
+		args = []string{"up"}
+	}
+
 	logger := logger.NewLogger(constants.AppDbMigrate)
 	env := constants.Env(logger)
+	r := &migrator{logger: logger, env: env, dryRun: *dryRun, yes: *yes}
 
-	logger.Info().Str("database", env.Database.Name).Msg("Migrating database")
-	m, err := migrate.New(
-		"file://"+env.Database.MigrationsFolder,
-		env.DBUrl(),
-	)
-	if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to create a database migration instance")
+	if args[0] == "create" {
+		if len(args) != 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		if err := r.create(args[1]); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to create the migration")
+		}
+		return
 	}
 
-	err = m.Up()
-	if err == migrate.ErrNoChange {
-		version, _, _ := m.Version()
-		logger.Info().Uint("migration", version).Msg("No migration necessary")
+	if args[0] == "lint" {
+		if len(args) != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+		failed, err := r.lint()
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to lint the migrations")
+		}
+		if failed {
+			os.Exit(1)
+		}
 		return
+	}
+
+	if env.Database.Dialect == constants.DialectMemory {
+		logger.Info().Msg("The in-memory database needs no migrations")
+		return
+	}
+
+	var err error
+	if r.src, err = migrations.Open(env); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to read the migrations")
+	}
+	defer r.src.Close()
+	if r.m, err = migrations.New(env); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create a database migration instance")
+	}
+	defer r.m.Close()
+
+	if err := r.run(args[0], args[1:]); errors.Is(err, errUsage) {
+		flags.Usage()
+		os.Exit(2)
+	} else if err != nil {
+		logger.Fatal().Err(err).Str("command", args[0]).Msg("Failed to migrate database")
+	}
+}
+
+// errUsage is returned for a command or arguments db-migrate does not know
+var errUsage = errors.New("invalid usage")
+
+// run runs the command cmd with its arguments args
+func (r *migrator) run(cmd string, args []string) error {
+	if cmd == "version" {
+		if len(args) != 0 {
+			return errUsage
+		}
+		return r.version()
+	}
+
+	if len(args) > 1 {
+		return errUsage
+	}
+	n, all := 0, len(args) == 0
+	if !all {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			return errUsage
+		}
+	}
+
+	from, dirty, err := r.current()
+	if err != nil {
+		return err
+	}
+	var to int
+	switch {
+	case cmd == "force" && !all:
+		return r.force(from, n)
+	case cmd == "goto" && !all:
+		to = n
+	case cmd == "up" && all:
+		to, err = migrations.Last(r.src)
+	case cmd == "down" && all:
+		to = migrations.NilVersion
+	case cmd == "up" && n > 0:
+		to, err = migrations.Walk(r.src, from, n)
+	case cmd == "down" && n > 0:
+		to, err = migrations.Walk(r.src, from, -n)
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	return r.migrate(from, dirty, to)
+}
+
+// current returns the version of the database, or migrations.NilVersion if it has none, and
+// whether a migration to it failed
+func (r *migrator) current() (int, bool, error) {
+	version, dirty, err := r.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return migrations.NilVersion, false, nil
 	} else if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to migrate database")
+		return 0, false, err
 	}
+	return int(version), dirty, nil
+}
 
-	logger.Info().Str("database", env.Database.Name).Msg("Database migrated")
+// version prints the version of the database
+func (r *migrator) version() error {
+	version, dirty, err := r.current()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		fmt.Printf("%d (dirty)\n", version)
+	} else {
+		fmt.Println(version)
+	}
+	return nil
+}
+
+// migrate moves the database from version from to version to
+func (r *migrator) migrate(from int, dirty bool, to int) error {
+	if dirty {
+		return fmt.Errorf("a migration to version %d failed; fix the database, then force a version: %w", from, migrate.ErrDirty{Version: from})
+	}
+	steps, err := migrations.Plan(r.src, from, to)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		r.logger.Info().Int("migration", from).Msg("No migration necessary")
+		return nil
+	}
+
+	if r.dryRun {
+		for _, step := range steps {
+			fmt.Printf("-- %s\n", step.Name())
+			if step.SQL == "" {
+				fmt.Println("-- (no SQL)")
+			}
+			fmt.Println(strings.TrimRight(step.SQL, "\n"))
+			fmt.Println()
+		}
+		return nil
+	}
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.Name()
+	}
+	if !r.confirm(fmt.Sprintf("Migrate %s from version %d to %d by running %s?", r.env.Database.Name, from, to, strings.Join(names, ", "))) {
+		return errAborted
+	}
+
+	r.logger.Info().Str("database", r.env.Database.Name).Int("from", from).Int("to", to).Msg("Migrating database")
+	if to == migrations.NilVersion {
+		err = r.m.Down()
+	} else {
+		err = r.m.Migrate(uint(to))
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	r.logger.Info().Str("database", r.env.Database.Name).Int("migration", to).Msg("Database migrated")
+	return nil
+}
+
+// force sets the version of the database at from to version without running migrations
+func (r *migrator) force(from, version int) error {
+	if version < migrations.NilVersion {
+		return errUsage
+	}
+	if r.dryRun {
+		fmt.Printf("-- would set the version of %s from %d to %d and clear its dirty state\n", r.env.Database.Name, from, version)
+		return nil
+	}
+	if !r.confirm(fmt.Sprintf("Set the version of %s from %d to %d without running migrations?", r.env.Database.Name, from, version)) {
+		return errAborted
+	}
+	if err := r.m.Force(version); err != nil {
+		return err
+	}
+	r.logger.Info().Str("database", r.env.Database.Name).Int("migration", version).Msg("Database version forced")
+	return nil
+}
+
+// create scaffolds the migration name in the migrations folder
+func (r *migrator) create(name string) error {
+	if r.env.Database.MigrationsFolder == "" {
+		return errors.New("set DB_MIGRATIONS_FOLDER to the migrations folder of the source tree")
+	}
+	files, err := migrations.Scaffold(r.env.Database.MigrationsFolder, name, time.Now())
+	if err != nil {
+		return err
+	}
+	if r.dryRun {
+		for _, file := range files {
+			fmt.Printf("-- would create %s\n", file)
+		}
+		return nil
+	}
+	if !r.confirm(fmt.Sprintf("Create %s?", strings.Join(files, ", "))) {
+		return errAborted
+	}
+	if err := migrations.Create(files); err != nil {
+		return err
+	}
+	for _, file := range files {
+		r.logger.Info().Str("file", file).Msg("Migration created")
+	}
+	return nil
+}
+
+// lint prints what migrations.Lint finds and reports whether it found an error
+func (r *migrator) lint() (bool, error) {
+	findings, err := migrations.Lint(migrations.Files(r.env))
+	if err != nil {
+		return false, err
+	}
+	errs := 0
+	for _, f := range findings {
+		fmt.Println(f)
+		if f.Severity == migrations.SeverityError {
+			errs++
+		}
+	}
+	fmt.Printf("%d errors, %d warnings\n", errs, len(findings)-errs)
+	return errs > 0, nil
+}
+
+// confirm asks question on the terminal and reports whether it was answered yes
+func (r *migrator) confirm(question string) bool {
+	if r.yes {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// parseArgs parses the flags of flags wherever they appear in args, so they may follow the
+// command, and returns the other arguments
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		if args = flags.Args(); len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
 }
@@ -0,0 +1,99 @@
+// Command db-seed loads fixture sets of species, cultivars and plant pedigrees into the database
+// the environment configures.
+//
+// Usage:
+//
+//	db-seed [-workspace ID] [-file PATH]... [SET...]
+//	db-seed -list
+//
+// SET names a fixture set built into the binary; "demo" is loaded when neither a set nor a file
+// is given, and -list prints the sets. -file loads a set from a YAML or JSON file instead, named
+// after the file. The sets are written through the same stores the server uses, into the
+// workspace -workspace identifies, the default workspace unless given.
+//
+// Running db-seed again is safe: every record has an ID derived from its workspace, set and key,
+// so records seeded before are restored from the trash or updated to match their fixture rather
+// than added again. Records a set does not name are left alone.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kylep342/mendel/internal/app"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db/seed"
+	"github.com/kylep342/mendel/pkg/logger"
+)
+
+func main() {
+	flags := flag.NewFlagSet(constants.AppDbSeed, flag.ExitOnError)
+	workspaceID := flags.String("workspace", workspace.DefaultID, "ID of the workspace to seed")
+	list := flags.Bool("list", false, "print the fixture sets built into db-seed")
+	var files []string
+	flags.Func("file", "load a fixture set from a YAML or JSON `path`; may be repeated", func(path string) error {
+		files = append(files, path)
+		return nil
+	})
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [-workspace ID] [-file PATH]... [SET...] | -list\n", constants.AppDbSeed)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+
+	if *list {
+		fmt.Println(strings.Join(seed.Names(), "\n"))
+		return
+	}
+
+	logger := logger.NewLogger(constants.AppDbSeed)
+	env := constants.Env(logger)
+
+	names := flags.Args()
+	if len(names) == 0 && len(files) == 0 {
+		names = []string{"demo"}
+	}
+	var sets []*seed.Set
+	for _, name := range names {
+		set, err := seed.Open(name)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to read the fixture set")
+		}
+		sets = append(sets, set)
+	}
+	for _, file := range files {
+		set, err := seed.Load(file)
+		if err != nil {
+			logger.Fatal().Err(err).Str("file", file).Msg("Failed to read the fixture set")
+		}
+		sets = append(sets, set)
+	}
+
+	if env.Database.Dialect == constants.DialectMemory {
+		logger.Fatal().Msg("The in-memory database is emptied when db-seed exits; seed a Postgres or SQLite database")
+	}
+	a := app.App{}
+	a.Connect(logger, env)
+	if a.Schema != nil && a.Schema.ReadOnly {
+		logger.Fatal().Msg("Refusing to seed a database whose schema does not match; run db-migrate first")
+	}
+
+	seeder := a.Seeder()
+	for _, set := range sets {
+		result, err := seeder.Seed(context.Background(), set, *workspaceID)
+		if err != nil {
+			logger.Fatal().Err(err).Str("set", set.Name).Msg("Failed to seed the fixture set")
+		}
+		logger.Info().
+			Str("set", set.Name).
+			Str("workspace", *workspaceID).
+			Stringer(constants.TablePlantSpecies, result.Species).
+			Stringer(constants.TablePlantCultivar, result.Cultivars).
+			Stringer(constants.TablePlant, result.Plants).
+			Msg("Seeded the fixture set")
+	}
+}
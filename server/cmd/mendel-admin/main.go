@@ -0,0 +1,204 @@
+// Command mendel-admin backs up and restores the database the environment configures.
+//
+// Usage:
+//
+//	mendel-admin backup [-o FILE]
+//	mendel-admin restore [-merge] [-on-conflict fail|skip|replace] FILE
+//	mendel-admin verify FILE
+//
+// The commands are:
+//
+//	backup   write an archive of the records of every component to FILE, by default
+//	         mendel-<timestamp>.tar.gz in the working directory
+//	restore  write the records of the archive FILE into the database, which must hold no more
+//	         than its migrations created unless -merge is given. With -merge, -on-conflict says
+//	         what happens to a record whose ID a stored record has: fail, the default, rolls the
+//	         restore back, skip keeps the stored record and replace overwrites it.
+//	verify   check the archive FILE against its manifest and checksums; needs no database
+//
+// Archives are read and written through the stores the server uses, so an archive of one
+// database can be restored into another of any dialect, once it is migrated to at least the
+// schema version the archive was read from. Sessions and the history of records are not
+// archived. See package archive for the layout of an archive.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/kylep342/mendel/internal/app"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/db/archive"
+	"github.com/kylep342/mendel/pkg/logger"
+)
+
+// errUsage is returned for a command or arguments mendel-admin does not know
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	flags := flag.NewFlagSet(constants.AppMendelAdmin, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s backup [-o FILE] | restore [-merge] [-on-conflict fail|skip|replace] FILE | verify FILE\n", constants.AppMendelAdmin)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	logger := logger.NewLogger(constants.AppMendelAdmin)
+	cmd, args := flags.Arg(0), flags.Args()[1:]
+	var err error
+	switch cmd {
+	case "backup":
+		err = backup(logger, args)
+	case "restore":
+		err = restore(logger, args)
+	case "verify":
+		err = verify(logger, args)
+	default:
+		err = errUsage
+	}
+	if errors.Is(err, errUsage) {
+		flags.Usage()
+		os.Exit(2)
+	} else if err != nil {
+		logger.Fatal().Err(err).Str("command", cmd).Msg("Failed to " + cmd + " the database")
+	}
+}
+
+// connect returns the archiver of the database the environment configures, refusing databases
+// it cannot archive
+func connect(logger zerolog.Logger) (*archive.Archiver, error) {
+	env := constants.Env(logger)
+	if env.Database.Dialect == constants.DialectMemory {
+		return nil, errors.New("the in-memory database is emptied when mendel-admin exits; use a Postgres or SQLite database")
+	}
+	a := app.App{}
+	a.Connect(logger, env)
+	if a.Schema != nil && a.Schema.ReadOnly {
+		return nil, errors.New("the database schema does not match; run db-migrate first")
+	}
+	return a.Archiver(env), nil
+}
+
+// backup writes an archive of the database. It writes to a temporary file beside the archive,
+// renamed once complete, so a failed backup leaves no partial archive behind.
+func backup(logger zerolog.Logger, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := flags.String("o", "", "`path` of the archive; mendel-<timestamp>.tar.gz when unset")
+	_ = flags.Parse(args)
+	if flags.NArg() != 0 {
+		return errUsage
+	}
+	if *out == "" {
+		*out = "mendel-" + time.Now().UTC().Format("20060102T150405Z") + ".tar.gz"
+	}
+
+	ar, err := connect(logger)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(*out), "."+filepath.Base(*out)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	m, err := ar.Backup(context.Background(), f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), *out)
+	}
+	if err != nil {
+		return err
+	}
+	for _, c := range m.Components {
+		logger.Info().Str("component", c.Component).Int("records", c.Records).Msg("Backed up the component")
+	}
+	logger.Info().Str("file", *out).Int("schema", m.Schema).Str("dialect", m.Dialect).Msg("Wrote the archive")
+	return nil
+}
+
+// restore writes the records of an archive into the database
+func restore(logger zerolog.Logger, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	merge := flags.Bool("merge", false, "restore into a database that holds records")
+	conflict := flags.String("on-conflict", string(db.ConflictFail), "with -merge, what to do with a record whose ID is taken: fail, skip or replace")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 || !slices.Contains(db.Conflicts, db.Conflict(*conflict)) {
+		return errUsage
+	}
+	if !*merge && *conflict != string(db.ConflictFail) {
+		return errors.New("-on-conflict needs -merge")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ar, err := connect(logger)
+	if err != nil {
+		return err
+	}
+
+	m, counts, err := ar.Restore(context.Background(), f, archive.Merge{Enabled: *merge, Conflict: db.Conflict(*conflict)})
+	if err != nil {
+		return err
+	}
+	for _, c := range m.Components {
+		logger.Info().Str("component", c.Component).Stringer("records", counts[c.Component]).Msg("Restored the component")
+	}
+	logger.Info().
+		Str("file", flags.Arg(0)).
+		Time("created_at", m.CreatedAt).
+		Int("schema", m.Schema).
+		Str("dialect", m.Dialect).
+		Msg("Restored the archive")
+	return nil
+}
+
+// verify checks an archive against its manifest and checksums
+func verify(logger zerolog.Logger, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	m, err := archive.Verify(f)
+	if err != nil {
+		return err
+	}
+	for _, c := range m.Components {
+		logger.Info().Str("component", c.Component).Int("records", c.Records).Msg("Verified the component")
+	}
+	logger.Info().
+		Str("file", args[0]).
+		Time("created_at", m.CreatedAt).
+		Str("server", m.Server).
+		Int("schema", m.Schema).
+		Str("dialect", m.Dialect).
+		Msg("The archive is intact")
+	return nil
+}
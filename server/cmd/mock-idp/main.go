@@ -0,0 +1,36 @@
+// Command mock-idp serves an OpenID Connect identity provider for trying single sign-on locally.
+// It signs in whoever is named on its login page, without a password. Run mendel-server with
+//
+//	OIDC_ISSUER=http://localhost:8081 OIDC_CLIENT_ID=mendel OIDC_REDIRECT_URL=http://localhost:8080/auth/oidc/callback
+//
+// and visit http://localhost:8080/auth/oidc/login.
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/pkg/logger"
+	"github.com/kylep342/mendel/pkg/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8081", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:8081", "URL the provider is reached at")
+	clientID := flag.String("client-id", "mendel", "ID of the one client allowed")
+	clientSecret := flag.String("client-secret", "", "secret of the client; not checked when empty")
+	flag.Parse()
+
+	logger := logger.NewLogger(constants.AppMockIdP)
+	idp, err := oidctest.New(*clientID, *clientSecret)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create the identity provider")
+	}
+	idp.Issuer = *issuer
+
+	logger.Info().Str("issuer", *issuer).Str("client_id", *clientID).Msg("Mock identity provider listening")
+	if err := http.ListenAndServe(*addr, idp); err != nil {
+		logger.Fatal().Err(err).Msg("Mock identity provider stopped")
+	}
+}
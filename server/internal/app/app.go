@@ -4,6 +4,7 @@ package app
 
 import (
 	"context"
+	"database/sql"
 	"net/http"
 
 	"github.com/gin-contrib/cors"
@@ -14,37 +15,85 @@ import (
 
 	"github.com/rs/zerolog"
 
+	"github.com/kylep342/mendel/internal/components/app/audit"
+	"github.com/kylep342/mendel/internal/components/app/auth"
+	"github.com/kylep342/mendel/internal/components/app/share"
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
 	"github.com/kylep342/mendel/internal/components/plants/plant"
 	"github.com/kylep342/mendel/internal/components/plants/plant_cultivar"
 	"github.com/kylep342/mendel/internal/components/plants/plant_species"
 	"github.com/kylep342/mendel/internal/constants"
 	"github.com/kylep342/mendel/internal/db"
 	"github.com/kylep342/mendel/internal/handlers"
+	"github.com/kylep342/mendel/internal/openapi"
 	"github.com/kylep342/mendel/pkg/responses"
 )
 
 // App is the singleton struct with components to run mendel
 type App struct {
-	DB     *pgxpool.Pool
-	Logger zerolog.Logger
-	Router *gin.Engine
+	DB      *pgxpool.Pool
+	SQLite  *sql.DB
+	Memory  *db.Memory
+	Tx      db.Transactor
+	Logger  zerolog.Logger
+	Router  *gin.Engine
+	OpenAPI *openapi.Document
+	Schema  *handlers.Schema
+
+	// purgers are the tables with a trash, in the order their routes were registered
+	purgers []db.Purger
 }
 
 // Run starts the app and performs a graceful shutdown.
 func (a *App) Run(env *constants.EnvConfig) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.purgeTrash(ctx, env)
+
 	RunServer(a.Router, env)
 }
 
 // Initialize creates the application's components.
 func (a *App) Initialize(logger zerolog.Logger, env *constants.EnvConfig) {
-	var err error
+	a.Connect(logger, env)
+
+	// Router setup
+	a.Router = gin.Default()
+	a.setupMiddleware(env)
+	a.InitializeRoutes(env)
+}
 
+// Connect opens the configured database and checks its schema is the one the stores are written
+// against, migrating it first when Database.AutoMigrate is set.
+func (a *App) Connect(logger zerolog.Logger, env *constants.EnvConfig) {
 	a.Logger = logger
 
+	switch env.Database.Dialect {
+	case constants.DialectSQLite:
+		a.connectSQLite(env)
+		a.Tx = db.SQLTransactor{Conn: a.SQLite}
+	case constants.DialectMemory:
+		a.Logger.Warn().Msg("Using the in-memory database; records are lost on shutdown")
+		a.Memory = db.NewMemory()
+		a.Tx = a.Memory
+	default:
+		a.connectPostgres(env)
+		a.Tx = db.PoolTransactor{Conn: a.DB}
+	}
+	if a.Memory == nil {
+		if env.Database.AutoMigrate {
+			a.migrate(env)
+		}
+		a.checkSchema(env)
+	}
+}
+
+// connectPostgres opens the pool of connections to Postgres
+func (a *App) connectPostgres(env *constants.EnvConfig) {
 	ctx, cancel := context.WithTimeout(context.Background(), env.Server.ReadTimeout)
 	defer cancel()
 
-	// Postgres pgx setup
 	pgConf, err := pgxpool.ParseConfig(env.DBUrl())
 	if err != nil {
 		a.Logger.Fatal().Err(err).Msg("failed to parse connection string")
@@ -61,60 +110,306 @@ func (a *App) Initialize(logger zerolog.Logger, env *constants.EnvConfig) {
 	if err != nil {
 		a.Logger.Fatal().Err(err).Msg("failed to set search_path")
 	}
+}
 
-	// Router setup
-	a.Router = gin.Default()
-	a.setupMiddleware(env)
-	a.InitializeRoutes(env)
+// connectSQLite opens the SQLite database at Database.SQLitePath
+func (a *App) connectSQLite(env *constants.EnvConfig) {
+	var err error
+	a.SQLite, err = db.OpenSQLite(env.Database.SQLitePath)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Msg("Failed to open database")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), env.Server.ReadTimeout)
+	defer cancel()
+
+	if err := a.SQLite.PingContext(ctx); err != nil {
+		a.Logger.Fatal().Err(err).Str("path", env.Database.SQLitePath).Msg("Failed to open database")
+	}
+}
+
+// readOnly reports whether the database schema is one the server may only read, see
+// Database.SchemaMismatch
+func (a *App) readOnly() bool {
+	return a.Schema != nil && a.Schema.ReadOnly
+}
+
+// bootstrapUser creates the user Auth.BootstrapUsername names, if set and missing, reporting
+// whether it did
+func (a *App) bootstrapUser(env *constants.EnvConfig, h *handlers.AuthHandler) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), env.Server.WriteTimeout)
+	defer cancel()
+
+	created, err := h.Bootstrap(ctx)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Msg("Failed to create the bootstrap user")
+	}
+	if created {
+		a.Logger.Info().Str("username", env.Auth.BootstrapUsername).Msg("Created the bootstrap user")
+	}
+	return created
+}
+
+// bootstrapWorkspace creates the default workspace if it is missing, making the user username
+// names a member when set
+func (a *App) bootstrapWorkspace(env *constants.EnvConfig, h *handlers.WorkspaceHandler, username string) {
+	ctx, cancel := context.WithTimeout(context.Background(), env.Server.WriteTimeout)
+	defer cancel()
+
+	if err := h.Bootstrap(ctx, username); err != nil {
+		a.Logger.Fatal().Err(err).Msg("Failed to create the default workspace")
+	}
+}
+
+// shareSigner returns the signer of share link tokens, keyed by Auth.ShareKey or, when it is
+// unset, by a random key
+func (a *App) shareSigner(env *constants.EnvConfig) *share.Signer {
+	if env.Auth.ShareKey != "" {
+		return share.NewSigner([]byte(env.Auth.ShareKey))
+	}
+	signer, err := share.NewRandomSigner()
+	if err != nil {
+		a.Logger.Fatal().Err(err).Msg("Failed to create a key for share links")
+	}
+	a.Logger.Warn().Msg("AUTH_SHARE_KEY is not set; share links stop working when the server restarts")
+	return signer
+}
+
+// sqlPinger adapts a *sql.DB to handlers.Pinger
+type sqlPinger struct {
+	*sql.DB
+}
+
+func (p sqlPinger) Ping(ctx context.Context) error {
+	return p.PingContext(ctx)
+}
+
+// database returns what the health check pings for the configured dialect
+func (a *App) database() handlers.Pinger {
+	switch {
+	case a.SQLite != nil:
+		return sqlPinger{a.SQLite}
+	case a.Memory != nil:
+		return a.Memory
+	}
+	return a.DB
+}
+
+// tables are the constructors of a component's store for each dialect
+type tables[T any] struct {
+	postgres func(conn db.Querier) db.CRUDTable[T]
+	sqlite   func(conn db.SQLQuerier) db.CRUDTable[T]
+	memory   func(mem *db.Memory) db.CRUDTable[T]
+}
+
+var plantSpeciesTables = tables[plant_species.PlantSpecies]{
+	postgres: func(conn db.Querier) db.CRUDTable[plant_species.PlantSpecies] {
+		return plant_species.NewStore(conn)
+	},
+	sqlite: func(conn db.SQLQuerier) db.CRUDTable[plant_species.PlantSpecies] {
+		return plant_species.NewSQLiteStore(conn)
+	},
+	memory: func(mem *db.Memory) db.CRUDTable[plant_species.PlantSpecies] {
+		return plant_species.NewMemoryStore(mem)
+	},
+}
+
+var plantCultivarTables = tables[plant_cultivar.PlantCultivar]{
+	postgres: func(conn db.Querier) db.CRUDTable[plant_cultivar.PlantCultivar] {
+		return plant_cultivar.NewStore(conn)
+	},
+	sqlite: func(conn db.SQLQuerier) db.CRUDTable[plant_cultivar.PlantCultivar] {
+		return plant_cultivar.NewSQLiteStore(conn)
+	},
+	memory: func(mem *db.Memory) db.CRUDTable[plant_cultivar.PlantCultivar] {
+		return plant_cultivar.NewMemoryStore(mem)
+	},
+}
+
+var plantTables = tables[plant.Plant]{
+	postgres: func(conn db.Querier) db.CRUDTable[plant.Plant] {
+		return plant.NewStore(conn)
+	},
+	sqlite: func(conn db.SQLQuerier) db.CRUDTable[plant.Plant] { return plant.NewSQLiteStore(conn) },
+	memory: func(mem *db.Memory) db.CRUDTable[plant.Plant] {
+		return plant.NewMemoryStore(mem)
+	},
+}
+
+// userStore returns the store of users for the configured dialect
+func (a *App) userStore() user.Table {
+	return newStore(a,
+		func(conn db.Querier) user.Table { return user.NewStore(conn) },
+		func(conn db.SQLQuerier) user.Table { return user.NewSQLiteStore(conn) },
+		func(mem *db.Memory) user.Table { return user.NewMemoryStore(mem) },
+	)
+}
+
+// newTable returns the store for the configured dialect
+func newTable[T any](a *App, t tables[T]) db.CRUDTable[T] {
+	return newStore(a, t.postgres, t.sqlite, t.memory)
+}
+
+// newStore calls the constructor for the configured dialect
+func newStore[S any](a *App, postgres func(conn db.Querier) S, sqlite func(conn db.SQLQuerier) S, memory func(mem *db.Memory) S) S {
+	switch {
+	case a.SQLite != nil:
+		return sqlite(a.SQLite)
+	case a.Memory != nil:
+		return memory(a.Memory)
+	}
+	return postgres(a.DB)
 }
 
 func (a *App) setupMiddleware(env *constants.EnvConfig) {
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{env.App.WebHost}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept"}
-	a.Router.Use(cors.New(config))
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", handlers.WorkspaceHeader, handlers.RequestIDHeader}
+	config.ExposeHeaders = []string{handlers.WorkspaceHeader, handlers.RequestIDHeader}
+	// the web app sends its session cookie with every request
+	config.AllowCredentials = true
+	a.Router.Use(cors.New(config), handlers.RequestID)
 }
 
 // InitializeRoutes creates all endpoints for the api
 func (a *App) InitializeRoutes(env *constants.EnvConfig) {
 	a.Logger.Info().Msg("Initializing routes")
+	a.OpenAPI = openapi.NewDocument("Mendel API", constants.APIVersion)
 
 	a.Router.GET("/", func(c *gin.Context) {
 		responses.RespondData(c, "ok", http.StatusOK)
 	})
 
-	internalHandler := handlers.NewInternalHandler(a.DB, env)
+	internalHandler := handlers.NewInternalHandler(a.database(), env)
+	internalHandler.Schema = a.Schema
+	if a.readOnly() {
+		a.Router.Use(internalHandler.ReadOnly)
+	}
 	internalHandler.RegisterRoutes(a.Router, constants.RouteIndex)
+	internalHandler.Describe(a.OpenAPI, constants.RouteIndex)
+
+	users := a.userStore()
+	authHandler := handlers.NewAuthHandler(a.Tx, env, users, newStore(a, auth.NewStores, auth.NewSQLiteStores, auth.NewMemoryStores))
+	authHandler.ReadOnly = a.readOnly()
+	authHandler.RegisterRoutes(a.Router, constants.RouteAuth)
+	authHandler.Describe(a.OpenAPI, constants.RouteAuth)
+	// a read-only schema may be behind the stores, so nothing is written to it at startup
+	bootstrapped := false
+	if !a.readOnly() {
+		bootstrapped = a.bootstrapUser(env, authHandler)
+	}
+	if authHandler.OIDC != nil {
+		a.Logger.Info().Str("issuer", env.OIDC.Issuer).Msg("Single sign-on enabled")
+	}
+
+	// records are only served to authenticated requests
+	api := a.Router.Group("")
+	if env.Auth.Enabled {
+		api.Use(authHandler.Authenticate)
+	} else {
+		a.Logger.Warn().Msg("Authentication disabled; every record route is open to anyone")
+	}
+
+	workspaceHandler := handlers.NewWorkspaceHandler(a.Tx, env, users, newStore(a, workspace.NewStores, workspace.NewSQLiteStores, workspace.NewMemoryStores))
+	workspaceHandler.RegisterRoutes(api, constants.RouteWorkspace)
+	workspaceHandler.Describe(a.OpenAPI, constants.RouteWorkspace)
+	newMember := ""
+	if bootstrapped {
+		newMember = env.Auth.BootstrapUsername
+	}
+	if !a.readOnly() {
+		a.bootstrapWorkspace(env, workspaceHandler, newMember)
+	}
+	authHandler.Access = workspaceHandler
+
+	// records are only served from the workspace a request is scoped to, as the role of its user there allows
+	scoped := api.Group("", workspaceHandler.Scope)
+
+	auditHandler := handlers.NewAuditHandler(env, newStore(a, audit.NewStore, audit.NewSQLiteStore, audit.NewMemoryStore))
+	auditHandler.Access = workspaceHandler
+	auditHandler.RegisterRoutes(scoped, constants.RouteAudit)
+	auditHandler.Describe(a.OpenAPI, constants.RouteAudit)
 
 	plantSpeciesHandler := handlers.NewCRUDHandler(
-		a.DB,
+		a.Tx,
 		env,
 		func() *plant_species.PlantSpecies { return &plant_species.PlantSpecies{} },
-		func(p *pgxpool.Pool) db.CRUDTable[plant_species.PlantSpecies] {
-			return &plant_species.Store{Conn: p}
-		},
+		newTable(a, plantSpeciesTables),
 	)
-	plantSpeciesHandler.RegisterRoutes(a.Router, constants.RoutePlantSpecies)
+	plantSpeciesHandler.Access, plantSpeciesHandler.Component = workspaceHandler, constants.TablePlantSpecies
+	plantSpeciesHandler.Audit = auditHandler.Table
+	plantSpeciesHandler.RegisterRoutes(scoped, constants.RoutePlantSpecies)
+	plantSpeciesHandler.Describe(a.OpenAPI, constants.RoutePlantSpecies)
+	a.trackTrash(plantSpeciesHandler.Table)
 
 	plantCultivarHandler := handlers.NewCRUDHandler(
-		a.DB,
+		a.Tx,
 		env,
 		func() *plant_cultivar.PlantCultivar { return &plant_cultivar.PlantCultivar{} },
-		func(p *pgxpool.Pool) db.CRUDTable[plant_cultivar.PlantCultivar] {
-			return &plant_cultivar.Store{Conn: p}
-		},
+		newTable(a, plantCultivarTables),
 	)
-	plantCultivarHandler.RegisterRoutes(a.Router, constants.RoutePlantCultivar)
+	plantCultivarHandler.Access, plantCultivarHandler.Component = workspaceHandler, constants.TablePlantCultivar
+	plantCultivarHandler.Audit = auditHandler.Table
+	plantCultivarHandler.RegisterRoutes(scoped, constants.RoutePlantCultivar)
+	plantCultivarHandler.Describe(a.OpenAPI, constants.RoutePlantCultivar)
+	a.trackTrash(plantCultivarHandler.Table)
 
 	plantHandler := handlers.NewCRUDHandler(
-		a.DB,
+		a.Tx,
 		env,
 		func() *plant.Plant { return &plant.Plant{} },
-		func(p *pgxpool.Pool) db.CRUDTable[plant.Plant] {
-			return &plant.Store{Conn: p}
-		},
+		newTable(a, plantTables),
 	)
-	plantHandler.RegisterRoutes(a.Router, constants.RoutePlant)
+	plantHandler.Access, plantHandler.Component = workspaceHandler, constants.TablePlant
+	plantHandler.Audit = auditHandler.Table
+	plantHandler.RegisterRoutes(scoped, constants.RoutePlant)
+	plantHandler.Describe(a.OpenAPI, constants.RoutePlant)
+	a.trackTrash(plantHandler.Table)
+
+	// shared records are served to anyone holding a share link's token
+	shareHandler := handlers.NewShareHandler(a.Tx, env, newStore(a, share.NewStore, share.NewSQLiteStore, share.NewMemoryStore), a.shareSigner(env))
+	shareHandler.Access = workspaceHandler
+	shareHandler.Sources[constants.TablePlantSpecies] = plantSpeciesHandler.ShareSource()
+	shareHandler.Sources[constants.TablePlantCultivar] = plantCultivarHandler.ShareSource()
+	shareHandler.Sources[constants.TablePlant] = plantHandler.ShareSource()
+	shareHandler.RegisterRoutes(scoped, constants.RouteShare)
+	shareHandler.Describe(a.OpenAPI, constants.RouteShare)
+	shareHandler.RegisterPublicRoutes(a.Router, constants.RouteSharePublic)
+	shareHandler.DescribePublic(a.OpenAPI, constants.RouteSharePublic)
+
+	for _, route := range []string{constants.RoutePlantSpecies, constants.RoutePlantCultivar, constants.RoutePlant, constants.RouteAudit, constants.RouteShare} {
+		a.OpenAPI.AddParameter(route,
+			openapi.HeaderParam(handlers.WorkspaceHeader, "ID of the workspace to use; defaults to the oldest you are a member of"),
+			map[string]openapi.Response{
+				"400": openapi.ErrorResponse("the workspace ID is not a UUID"),
+				"403": openapi.ErrorResponse("not a member of the workspace, or your role there does not allow it"),
+			})
+	}
+
+	userHandler := handlers.NewCRUDHandler(
+		a.Tx,
+		env,
+		func() *user.User { return &user.User{} },
+		db.CRUDTable[user.User](users),
+	)
+	userHandler.Access, userHandler.Component = workspaceHandler, constants.TableUser
+	userHandler.Audit = auditHandler.Table
+	userHandler.RegisterRoutes(api, constants.RouteUser)
+	userHandler.Describe(a.OpenAPI, constants.RouteUser)
+	if env.Auth.Enabled {
+		a.OpenAPI.AddResponses(constants.RouteUser, map[string]openapi.Response{
+			"403": openapi.ErrorResponse("your role in the default workspace does not allow it"),
+		})
+	}
+
+	if env.Auth.Enabled {
+		for _, route := range []string{constants.RoutePlantSpecies, constants.RoutePlantCultivar, constants.RoutePlant, constants.RouteUser, constants.RouteWorkspace, constants.RouteAudit, constants.RouteShare} {
+			a.OpenAPI.Secure(route, handlers.SecuritySession, handlers.SecurityToken)
+		}
+	}
+
+	openAPIHandler := handlers.NewOpenAPIHandler(a.OpenAPI)
+	openAPIHandler.RegisterRoutes(a.Router, constants.RouteIndex)
 	a.Logger.Info().Msg("Routes initialized")
 }
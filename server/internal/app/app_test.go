@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/components/plants/plant_species"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/handlers"
+)
+
+// newTestApp returns an App over an in-memory database holding a plant species long in the
+// trash, with its routes not yet initialized
+func newTestApp(t *testing.T, schema *handlers.Schema) (*App, *constants.EnvConfig) {
+	gin.SetMode(gin.TestMode)
+	env := &constants.EnvConfig{}
+	env.Server.ReadTimeout = 5 * time.Second
+	env.Server.WriteTimeout = 5 * time.Second
+	env.Database.Dialect = constants.DialectMemory
+	env.Database.TrashRetention = time.Hour
+	env.Database.TrashPurgeEvery = time.Hour
+	env.Auth.PasswordMinLength = 12
+	env.Auth.BootstrapUsername = "admin"
+	env.Auth.BootstrapPassword = "correct horse battery"
+
+	mem := db.NewMemory()
+	deleted := time.Now().Add(-48 * time.Hour)
+	_, err := plant_species.NewMemoryStore(mem).Load(context.Background(), &plant_species.PlantSpecies{
+		ID:          "00000000-0000-0000-0000-0000000000a1",
+		WorkspaceID: workspace.DefaultID,
+		Name:        "tomato",
+		Taxon:       "Solanum lycopersicum",
+		DeletedAt:   &deleted,
+	}, db.ConflictFail)
+	require.NoError(t, err)
+
+	return &App{Memory: mem, Tx: mem, Logger: zerolog.Nop(), Router: gin.New(), Schema: schema}, env
+}
+
+// records counts the users, workspaces and plant species of a
+func records(t *testing.T, a *App) (users, workspaces, species int) {
+	ctx := context.Background()
+	all, err := a.userStore().GetAll(ctx)
+	require.NoError(t, err)
+	require.NoError(t, db.NewMemoryStore[workspace.Workspace](a.Memory, constants.TableWorkspace).Dump(ctx, func(workspace.Workspace) error {
+		workspaces++
+		return nil
+	}))
+	require.NoError(t, plant_species.NewMemoryStore(a.Memory).Dump(ctx, func(plant_species.PlantSpecies) error {
+		species++
+		return nil
+	}))
+	return len(all), workspaces, species
+}
+
+func TestApp_ReadOnly(t *testing.T) {
+	a, env := newTestApp(t, &handlers.Schema{Version: 9, Expected: 10, ReadOnly: true})
+
+	a.InitializeRoutes(env)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	a.purgeTrash(ctx, env)
+	require.NoError(t, ctx.Err(), "purging returns at once")
+
+	users, workspaces, species := records(t, a)
+	assert.Zero(t, users, "no bootstrap user is created")
+	assert.Zero(t, workspaces, "no default workspace is created")
+	assert.Equal(t, 1, species, "the trash is not purged")
+}
+
+func TestApp_Writable(t *testing.T) {
+	a, env := newTestApp(t, &handlers.Schema{Version: 10, Expected: 10})
+
+	a.InitializeRoutes(env)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	a.purgeTrash(ctx, env)
+
+	users, workspaces, species := records(t, a)
+	assert.Equal(t, 1, users)
+	assert.Equal(t, 1, workspaces)
+	assert.Zero(t, species)
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+
+	"github.com/kylep342/mendel/internal/components/app/audit"
+	"github.com/kylep342/mendel/internal/components/app/auth"
+	"github.com/kylep342/mendel/internal/components/app/share"
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/components/plants/plant"
+	"github.com/kylep342/mendel/internal/components/plants/plant_cultivar"
+	"github.com/kylep342/mendel/internal/components/plants/plant_species"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/db/archive"
+)
+
+// querySnapshot makes a Postgres transaction read every table from the same snapshot
+const querySnapshot = `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`
+
+// Archiver returns an archiver backing up and restoring every component through the stores
+// records are served from. Sessions are not archived, so users sign in again after a restore.
+func (a *App) Archiver(env *constants.EnvConfig) *archive.Archiver {
+	authStores := newStore(a, auth.NewStores, auth.NewSQLiteStores, auth.NewMemoryStores)
+	workspaces := newStore(a, workspace.NewStores, workspace.NewSQLiteStores, workspace.NewMemoryStores)
+
+	ar := &archive.Archiver{
+		Tx: a.Tx,
+		Components: []archive.Component{
+			archive.Of[user.User](constants.TableUser, a.userStore(), archive.Options[user.User]{}),
+			archive.Of[auth.Credential](constants.TableCredential, authStores.Credentials, archive.Options[auth.Credential]{}),
+			archive.Of[auth.Identity](constants.TableIdentity, authStores.Identities, archive.Options[auth.Identity]{}),
+			archive.Of[auth.APIToken](constants.TableAPIToken, authStores.Tokens, archive.Options[auth.APIToken]{}),
+			archive.Of[workspace.Workspace](constants.TableWorkspace, workspaces.Workspaces, archive.Options[workspace.Workspace]{
+				Preset: []string{workspace.DefaultID},
+			}),
+			archive.Of[workspace.Member](constants.TableWorkspaceMember, workspaces.Members, archive.Options[workspace.Member]{}),
+			archive.Of[plant_species.PlantSpecies](constants.TablePlantSpecies, newTable(a, plantSpeciesTables), archive.Options[plant_species.PlantSpecies]{}),
+			archive.Of[plant_cultivar.PlantCultivar](constants.TablePlantCultivar, newTable(a, plantCultivarTables), archive.Options[plant_cultivar.PlantCultivar]{}),
+			archive.Of[plant.Plant](constants.TablePlant, newTable(a, plantTables), archive.Options[plant.Plant]{
+				Parents: plantParents,
+			}),
+			archive.Of[share.Share](constants.TableShare, newStore(a, share.NewStore, share.NewSQLiteStore, share.NewMemoryStore), archive.Options[share.Share]{}),
+			archive.Of[audit.Entry](constants.TableAudit, newStore(a, audit.NewStore, audit.NewSQLiteStore, audit.NewMemoryStore), archive.Options[audit.Entry]{
+				Immutable: true,
+			}),
+		},
+		Server:  constants.APIVersion,
+		Dialect: env.Database.Dialect,
+	}
+	if a.Schema != nil {
+		ar.Schema = a.Schema.Version
+	}
+	if a.DB != nil {
+		ar.Snapshot = func(ctx context.Context) error {
+			_, err := db.Conn(ctx, a.DB).Exec(ctx, querySnapshot)
+			return err
+		}
+	}
+	return ar
+}
+
+// plantParents returns the IDs of the plants p was bred from
+func plantParents(p plant.Plant) []string {
+	var parents []string
+	for _, id := range []*string{p.SeedID, p.PollenID} {
+		if id != nil {
+			parents = append(parents, *id)
+		}
+	}
+	return parents
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db/migrations"
+)
+
+// migrate applies the pending migrations built into the binary. On Postgres it holds an advisory
+// lock while it does, so that replicas starting together migrate one at a time and the others find
+// nothing left to do.
+func (a *App) migrate(env *constants.EnvConfig) {
+	ctx := context.Background()
+
+	if a.DB != nil {
+		conn, err := a.DB.Acquire(ctx)
+		if err != nil {
+			a.Logger.Fatal().Err(err).Msg("Failed to acquire a connection to migrate the database")
+		}
+		defer conn.Release()
+
+		a.Logger.Info().Int64("lock", migrations.LockID).Msg("Waiting for the migration lock")
+		if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrations.LockID); err != nil {
+			a.Logger.Fatal().Err(err).Msg("Failed to take the migration lock")
+		}
+		defer func() {
+			if _, err := conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, migrations.LockID); err != nil {
+				a.Logger.Error().Err(err).Msg("Failed to release the migration lock")
+			}
+		}()
+	}
+
+	m, err := migrations.New(env)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Msg("Failed to create a database migration instance")
+	}
+	defer m.Close()
+
+	version, err := migrations.Up(m)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Msg("Failed to migrate database")
+	}
+	a.Logger.Info().Str("database", env.Database.Name).Uint("migration", version).Msg("Database migrated")
+}
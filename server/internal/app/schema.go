@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db/migrations"
+	"github.com/kylep342/mendel/internal/handlers"
+)
+
+// checkSchema compares the version of the database schema, as migrate records it in
+// schema_migrations, with the version of the migrations built into the binary. On a mismatch it
+// refuses to start or, when Database.SchemaMismatch allows, serves read-only.
+func (a *App) checkSchema(env *constants.EnvConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), env.Server.ReadTimeout)
+	defer cancel()
+
+	expected, err := migrations.Version(env.Database.Dialect)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Msg("Failed to read the migrations built into the server")
+	}
+	version, dirty, err := a.schemaVersion(ctx)
+	if err != nil {
+		a.Logger.Fatal().Err(err).Msg("Failed to read the version of the database schema")
+	}
+	a.Schema = &handlers.Schema{Version: version, Expected: expected, Dirty: dirty}
+	if a.Schema.Compatible() {
+		a.Logger.Info().Int("schema", version).Msg("Database schema is up to date")
+		return
+	}
+
+	log := a.Logger.Error().Int("schema", version).Int("expected", expected).Bool("dirty", dirty)
+	if env.Database.SchemaMismatch != constants.SchemaMismatchReadOnly {
+		log.Msg("The database schema is not the version this server was built for; run db-migrate or deploy the matching server")
+		a.Logger.Fatal().Msg("Refusing to serve")
+	}
+	a.Schema.ReadOnly = true
+	log.Msg("The database schema is not the version this server was built for; serving read-only")
+}
+
+// schemaVersion returns the version schema_migrations records and whether its migration failed
+// part way, or migrations.NilVersion when no migration was applied
+func (a *App) schemaVersion(ctx context.Context) (int, bool, error) {
+	var version int64
+	var exists, dirty bool
+	var err error
+	switch {
+	case a.SQLite != nil:
+		err = a.SQLite.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&exists)
+		if err == nil && exists {
+			err = a.SQLite.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+		}
+	default:
+		err = a.DB.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+		if err == nil && exists {
+			err = a.DB.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+		}
+	}
+	if err == nil && !exists || errors.Is(err, sql.ErrNoRows) {
+		return migrations.NilVersion, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return int(version), dirty, nil
+}
@@ -0,0 +1,17 @@
+package app
+
+import (
+	"github.com/kylep342/mendel/internal/db/seed"
+)
+
+// Seeder returns a seeder writing fixture sets through the stores records are served from
+func (a *App) Seeder() *seed.Seeder {
+	return &seed.Seeder{
+		Tx: a.Tx,
+		Stores: seed.Stores{
+			Species:   newTable(a, plantSpeciesTables),
+			Cultivars: newTable(a, plantCultivarTables),
+			Plants:    newTable(a, plantTables),
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// trackTrash registers table for purging if it soft deletes its records
+func (a *App) trackTrash(table any) {
+	if p, ok := table.(db.Purger); ok {
+		a.purgers = append(a.purgers, p)
+	}
+}
+
+// purgeTrash permanently removes records that have been in the trash longer than
+// Database.TrashRetention, checking every Database.TrashPurgeEvery until ctx is done. Nothing is
+// purged while the schema is read-only.
+func (a *App) purgeTrash(ctx context.Context, env *constants.EnvConfig) {
+	if env.Database.TrashRetention <= 0 || env.Database.TrashPurgeEvery <= 0 {
+		a.Logger.Info().Msg("Trash purging disabled")
+		return
+	}
+	if a.readOnly() {
+		a.Logger.Warn().Msg("Trash purging disabled while the database schema is read-only")
+		return
+	}
+
+	ticker := time.NewTicker(env.Database.TrashPurgeEvery)
+	defer ticker.Stop()
+
+	for {
+		a.purgeTrashOnce(ctx, time.Now().Add(-env.Database.TrashRetention), env.Server.WriteTimeout)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrashOnce purges every tracked table of records deleted before cutoff.
+// Tables are purged in reverse registration order so children go before their parents.
+func (a *App) purgeTrashOnce(ctx context.Context, cutoff time.Time, timeout time.Duration) {
+	for i := len(a.purgers) - 1; i >= 0; i-- {
+		ctxPurge, cancel := context.WithTimeout(ctx, timeout)
+		n, err := a.purgers[i].Purge(ctxPurge, cutoff)
+		cancel()
+		if err != nil {
+			a.Logger.Error().Err(err).Msg("Failed to purge trash")
+			continue
+		}
+		if n > 0 {
+			a.Logger.Info().Int64("purged", n).Time("cutoff", cutoff).Msg("Purged trash")
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+const tableAudit = constants.SchemaMendelCore + "." + constants.TableAudit
+
+// Filter narrows the entries Find returns; zero fields match every entry
+//
+//	Since, Until: bound when the entries were written, inclusively
+//	Limit: the most entries to return
+type Filter struct {
+	Component string
+	RecordID  string
+	ActorID   string
+	RequestID string
+	Action    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// Table is implemented by the audit store of every dialect.
+// It only adds and finds entries, so the log cannot be rewritten through it.
+type Table interface {
+	Create(ctx context.Context, item *Entry) error
+	// Find retrieves the entries of the workspace of ctx that match f, newest first
+	Find(ctx context.Context, f Filter) ([]Entry, error)
+}
+
+// where returns the conditions selecting the entries that match f, and their arguments, with
+// parameters named by param from 1. The workspace is the parameter after the arguments.
+func (f Filter) where(param func(n int) string) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, cond+" "+param(len(args)))
+	}
+	for _, eq := range []struct{ col, value string }{
+		{"component", f.Component},
+		{"record_id", f.RecordID},
+		{"actor_id", f.ActorID},
+		{"request_id", f.RequestID},
+		{"action", f.Action},
+	} {
+		if eq.value != "" {
+			add(eq.col+" =", eq.value)
+		}
+	}
+	if !f.Since.IsZero() {
+		add("created_at >=", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("created_at <=", f.Until)
+	}
+	conds = append(conds, db.ColumnWorkspaceID+" = "+param(len(args)+1))
+	return strings.Join(conds, " AND "), args
+}
+
+// query returns the query finding the entries that match f in table, and its arguments but the
+// workspace, which comes last
+func (f Filter) query(table string, param func(n int) string) (string, []any) {
+	where, args := f.where(param)
+	query := `SELECT ` + columnsEntry + ` FROM ` + table + ` WHERE ` + where + ` ORDER BY created_at DESC, id DESC`
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+	return query, args
+}
+
+const columnsEntry = `id, workspace_id, actor_id, component, record_id, action, request_id, changes, created_at`
+
+// NewStore creates the Postgres store for the audit log
+func NewStore(conn db.Querier) Table {
+	return &Store{db.NewStore[Entry](conn, tableAudit)}
+}
+
+// Store handles all database operations for Entry
+type Store struct {
+	*db.Store[Entry]
+}
+
+// Find retrieves the entries of the workspace of ctx that match f, newest first
+func (s *Store) Find(ctx context.Context, f Filter) ([]Entry, error) {
+	query, args := f.query(s.Table, func(n int) string { return fmt.Sprintf("$%d", n) })
+	args, err := s.Scope(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, query, args...)
+}
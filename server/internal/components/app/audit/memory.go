@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// NewMemoryStore creates the in-memory store for the audit log
+func NewMemoryStore(mem *db.Memory) Table {
+	return &MemoryStore{db.NewMemoryStore[Entry](mem, constants.TableAudit)}
+}
+
+// MemoryStore handles all in-memory operations for Entry
+type MemoryStore struct {
+	*db.MemoryStore[Entry]
+}
+
+// Find retrieves the entries of the workspace of ctx that match f, newest first
+func (s *MemoryStore) Find(ctx context.Context, f Filter) ([]Entry, error) {
+	all, err := s.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	for _, e := range all {
+		if f.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.After(entries[j].CreatedAt)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	if f.Limit > 0 && len(entries) > f.Limit {
+		entries = entries[:f.Limit]
+	}
+	return entries, nil
+}
+
+// matches reports whether e is one of the entries f selects
+func (f Filter) matches(e Entry) bool {
+	actor := ""
+	if e.ActorID != nil {
+		actor = *e.ActorID
+	}
+	for _, cond := range [][2]string{
+		{f.Component, e.Component},
+		{f.RecordID, e.RecordID},
+		{f.ActorID, actor},
+		{f.RequestID, e.RequestID},
+		{f.Action, e.Action},
+	} {
+		if cond[0] != "" && cond[0] != cond[1] {
+			return false
+		}
+	}
+	return (f.Since.IsZero() || !e.CreatedAt.Before(f.Since)) && (f.Until.IsZero() || !e.CreatedAt.After(f.Until))
+}
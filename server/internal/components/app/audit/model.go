@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Actions an entry records
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+// Actions lists every action an entry may record
+var Actions = []string{ActionCreate, ActionUpdate, ActionDelete, ActionRestore}
+
+// Entry records a write to a record: who made it, when, in which request, and what it changed.
+// Entries are scoped to the workspace of the record; they are never changed once written.
+//
+//	ActorID: the user who made the write, or nil without authentication
+//	Component: the component of the record, named by its table
+//	RequestID: the X-Request-ID of the request that made the write
+//	Changes: the fields the write changed
+type Entry struct {
+	ID          string    `db:"id" json:"id"`
+	WorkspaceID string    `db:"workspace_id" json:"workspace_id"`
+	ActorID     *string   `db:"actor_id" json:"actor_id"`
+	Component   string    `db:"component" json:"component"`
+	RecordID    string    `db:"record_id" json:"record_id"`
+	Action      string    `db:"action" json:"action"`
+	RequestID   string    `db:"request_id" json:"request_id"`
+	Changes     Changes   `db:"changes" json:"changes"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+func (e *Entry) GetID() string   { return e.ID }
+func (e *Entry) SetID(id string) { e.ID = id }
+
+// Change is the JSON value of a field before and after a write.
+// A side is absent when the field was null or the record did not exist.
+type Change struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// Changes maps the JSON names of the fields a write changed to how they changed
+type Changes map[string]Change
+
+// Diff returns the fields whose JSON differs between before and after, either of which is nil
+// for a record that did not exist
+func Diff(before, after any) (Changes, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Changes{}
+	for name, value := range b {
+		if !bytes.Equal(value, a[name]) {
+			changes[name] = Change{Before: value, After: a[name]}
+		}
+	}
+	for name, value := range a {
+		if _, ok := b[name]; !ok {
+			changes[name] = Change{After: value}
+		}
+	}
+	return changes, nil
+}
+
+// jsonFields returns the JSON fields of v that are not null, compacted
+func jsonFields(v any) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range fields {
+		if string(value) == "null" {
+			delete(fields, name)
+			continue
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, value); err != nil {
+			return nil, err
+		}
+		fields[name] = compact.Bytes()
+	}
+	return fields, nil
+}
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// NewSQLiteStore creates the SQLite store for the audit log
+func NewSQLiteStore(conn db.SQLQuerier) Table {
+	return &SQLiteStore{db.NewSQLiteStore[Entry](conn, constants.TableAudit)}
+}
+
+// SQLiteStore handles all SQLite operations for Entry
+type SQLiteStore struct {
+	*db.SQLiteStore[Entry]
+}
+
+// Find retrieves the entries of the workspace of ctx that match f, newest first
+func (s *SQLiteStore) Find(ctx context.Context, f Filter) ([]Entry, error) {
+	query, args := f.query(s.Table, func(n int) string { return fmt.Sprintf("?%d", n) })
+	args, err := s.Scope(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, query, args...)
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/db/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) (Table, workspace.Stores){
+		"sqlite": func(t *testing.T) (Table, workspace.Stores) {
+			conn := dbtest.OpenSQLite(t)
+			return NewSQLiteStore(conn), workspace.NewSQLiteStores(conn)
+		},
+		"memory": func(t *testing.T) (Table, workspace.Stores) {
+			mem := db.NewMemory()
+			return NewMemoryStore(mem), workspace.NewMemoryStores(mem)
+		},
+	}
+	for name, newStores := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			table, ws := newStores(t)
+
+			other := &workspace.Workspace{Name: "other"}
+			require.NoError(t, ws.Workspaces.Create(ctx, other))
+			inDefault := db.WithWorkspace(ctx, workspace.DefaultID)
+			inOther := db.WithWorkspace(ctx, other.ID)
+
+			actor := uuid.NewString()
+			record := uuid.NewString()
+			write := func(ctx context.Context, action, recordID string) *Entry {
+				e := &Entry{
+					ActorID:   &actor,
+					Component: "plant",
+					RecordID:  recordID,
+					Action:    action,
+					RequestID: "req-" + action,
+					Changes:   Changes{"name": {After: json.RawMessage(`"a"`)}},
+				}
+				require.NoError(t, table.Create(ctx, e))
+				time.Sleep(time.Millisecond)
+				return e
+			}
+			created := write(inDefault, ActionCreate, record)
+			updated := write(inDefault, ActionUpdate, record)
+			write(inDefault, ActionCreate, uuid.NewString())
+			write(inOther, ActionCreate, record)
+
+			_, err := table.Find(ctx, Filter{})
+			assert.ErrorIs(t, err, db.ErrNoWorkspace)
+
+			entries, err := table.Find(inDefault, Filter{RecordID: record})
+			require.NoError(t, err)
+			require.Len(t, entries, 2, "entries of other workspaces are not found")
+			assert.Equal(t, []string{updated.ID, created.ID}, []string{entries[0].ID, entries[1].ID}, "newest first")
+			assert.Equal(t, workspace.DefaultID, entries[0].WorkspaceID)
+			assert.Equal(t, actor, *entries[0].ActorID)
+			assert.Equal(t, "req-update", entries[0].RequestID)
+			assert.JSONEq(t, `"a"`, string(entries[0].Changes["name"].After))
+			assert.False(t, entries[0].CreatedAt.IsZero())
+
+			entries, err = table.Find(inDefault, Filter{Action: ActionCreate})
+			require.NoError(t, err)
+			assert.Len(t, entries, 2)
+
+			entries, err = table.Find(inDefault, Filter{Limit: 1})
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+
+			entries, err = table.Find(inDefault, Filter{ActorID: uuid.NewString()})
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+
+			entries, err = table.Find(inDefault, Filter{Since: updated.CreatedAt, RecordID: record})
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, updated.ID, entries[0].ID)
+
+			entries, err = table.Find(inDefault, Filter{Until: created.CreatedAt})
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, created.ID, entries[0].ID)
+		})
+	}
+}
+
+func TestSQLiteStore_Immutable(t *testing.T) {
+	conn := dbtest.OpenSQLite(t)
+	table := NewSQLiteStore(conn)
+	ctx := db.WithWorkspace(context.Background(), workspace.DefaultID)
+
+	e := &Entry{Component: "plant", RecordID: uuid.NewString(), Action: ActionCreate, Changes: Changes{}}
+	require.NoError(t, table.Create(ctx, e))
+	_, err := conn.Exec(`UPDATE audit_log SET action = 'delete' WHERE id = ?`, e.ID)
+	assert.ErrorContains(t, err, "audit log entries cannot be changed")
+}
+
+func TestDiff(t *testing.T) {
+	type record struct {
+		Name   string            `json:"name"`
+		Parent *string           `json:"parent"`
+		Traits map[string]string `json:"traits"`
+	}
+	parent := "p"
+
+	changes, err := Diff(nil, &record{Name: "a", Traits: map[string]string{"x": "1"}})
+	require.NoError(t, err)
+	assert.Equal(t, Changes{
+		"name":   {After: json.RawMessage(`"a"`)},
+		"traits": {After: json.RawMessage(`{"x":"1"}`)},
+	}, changes, "null fields are absent")
+
+	changes, err = Diff(&record{Name: "a", Traits: map[string]string{"x": "1"}}, &record{Name: "a", Parent: &parent, Traits: map[string]string{"x": "2"}})
+	require.NoError(t, err)
+	assert.Equal(t, Changes{
+		"parent": {After: json.RawMessage(`"p"`)},
+		"traits": {Before: json.RawMessage(`{"x":"1"}`), After: json.RawMessage(`{"x":"2"}`)},
+	}, changes, "unchanged fields are left out")
+
+	changes, err = Diff(&record{Name: "a"}, (*record)(nil))
+	require.NoError(t, err)
+	assert.Equal(t, Changes{"name": {Before: json.RawMessage(`"a"`)}}, changes)
+}
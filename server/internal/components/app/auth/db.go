@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+const (
+	tableCredentials = constants.SchemaMendelCore + "." + constants.TableCredential
+	tableSessions    = constants.SchemaMendelCore + "." + constants.TableSession
+	tableAPITokens   = constants.SchemaMendelCore + "." + constants.TableAPIToken
+	tableIdentities  = constants.SchemaMendelCore + "." + constants.TableIdentity
+
+	// queryDeleteExpiredSessions removes the sessions that expired before $1
+	queryDeleteExpiredSessions = `DELETE FROM ` + tableSessions + ` WHERE expires_at < $1`
+	// queryDeleteUserSessions removes the sessions of the user $1 but the session $2
+	queryDeleteUserSessions = `DELETE FROM ` + tableSessions + ` WHERE user_id = $1 AND id::text <> $2`
+	// queryRecordTokenUse sets the last use of the API token identified by $1 to now
+	queryRecordTokenUse = `
+		WITH touched AS (
+			UPDATE ` + tableAPITokens + ` SET last_used = NOW() WHERE id = $1 RETURNING 1
+		)
+		SELECT count(*) FROM touched`
+)
+
+// SessionTable is implemented by the session store of every dialect
+type SessionTable interface {
+	db.CRUDTable[Session]
+	GetByTokenHash(ctx context.Context, hash string) (Session, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+	DeleteByUser(ctx context.Context, userID, keepID string) (int64, error)
+}
+
+// APITokenTable is implemented by the API token store of every dialect
+type APITokenTable interface {
+	db.CRUDTable[APIToken]
+	GetByTokenHash(ctx context.Context, hash string) (APIToken, error)
+	GetByUser(ctx context.Context, userID string) ([]APIToken, error)
+	RecordUse(ctx context.Context, id string) error
+}
+
+// IdentityTable is implemented by the identity store of every dialect
+type IdentityTable interface {
+	db.CRUDTable[Identity]
+	GetBySubject(ctx context.Context, issuer, subject string) (Identity, error)
+}
+
+// Stores are the tables authentication reads and writes
+type Stores struct {
+	Credentials db.CRUDTable[Credential]
+	Sessions    SessionTable
+	Tokens      APITokenTable
+	Identities  IdentityTable
+}
+
+// NewStores creates the Postgres stores for authentication
+func NewStores(conn db.Querier) Stores {
+	return Stores{
+		Credentials: db.NewStore[Credential](conn, tableCredentials),
+		Sessions:    &SessionStore{db.NewStore[Session](conn, tableSessions)},
+		Tokens:      &APITokenStore{db.NewStore[APIToken](conn, tableAPITokens)},
+		Identities:  &IdentityStore{db.NewStore[Identity](conn, tableIdentities)},
+	}
+}
+
+// SessionStore handles all database operations for Session
+type SessionStore struct {
+	*db.Store[Session]
+}
+
+// GetByTokenHash retrieves the session whose secret hashes to `hash`
+func (s *SessionStore) GetByTokenHash(ctx context.Context, hash string) (Session, error) {
+	return s.SelectOne(ctx, s.Queries.GetAll+` WHERE token_hash = $1`, hash)
+}
+
+// DeleteExpired removes the sessions that expired before now, returning how many there were
+func (s *SessionStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := s.Querier(ctx).Exec(ctx, queryDeleteExpiredSessions, now)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteByUser removes the sessions of the user identified by `userID` but the one identified by
+// `keepID`, which may be empty, returning how many there were
+func (s *SessionStore) DeleteByUser(ctx context.Context, userID, keepID string) (int64, error) {
+	tag, err := s.Querier(ctx).Exec(ctx, queryDeleteUserSessions, userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// APITokenStore handles all database operations for APIToken
+type APITokenStore struct {
+	*db.Store[APIToken]
+}
+
+// GetByTokenHash retrieves the API token whose secret hashes to `hash`
+func (s *APITokenStore) GetByTokenHash(ctx context.Context, hash string) (APIToken, error) {
+	return s.SelectOne(ctx, s.Queries.GetAll+` WHERE token_hash = $1`, hash)
+}
+
+// GetByUser retrieves the API tokens of the user identified by `userID`, newest first
+func (s *APITokenStore) GetByUser(ctx context.Context, userID string) ([]APIToken, error) {
+	return s.Select(ctx, s.Queries.GetAll+` WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+}
+
+// RecordUse sets the last use of the API token identified by argument `id` to now
+func (s *APITokenStore) RecordUse(ctx context.Context, id string) error {
+	var n int
+	if err := s.Querier(ctx).QueryRow(ctx, queryRecordTokenUse, id).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// IdentityStore handles all database operations for Identity
+type IdentityStore struct {
+	*db.Store[Identity]
+}
+
+// GetBySubject retrieves the identity `issuer` signs in as `subject`
+func (s *IdentityStore) GetBySubject(ctx context.Context, issuer, subject string) (Identity, error) {
+	return s.SelectOne(ctx, s.Queries.GetAll+` WHERE issuer = $1 AND subject = $2`, issuer, subject)
+}
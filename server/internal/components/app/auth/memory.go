@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// NewMemoryStores creates the in-memory stores for authentication
+func NewMemoryStores(mem *db.Memory) Stores {
+	return Stores{
+		Credentials: db.NewMemoryStore[Credential](mem, constants.TableCredential),
+		Sessions:    &MemorySessionStore{db.NewMemoryStore[Session](mem, constants.TableSession)},
+		Tokens:      &MemoryAPITokenStore{db.NewMemoryStore[APIToken](mem, constants.TableAPIToken)},
+		Identities:  &MemoryIdentityStore{db.NewMemoryStore[Identity](mem, constants.TableIdentity)},
+	}
+}
+
+// MemorySessionStore handles all in-memory operations for Session
+type MemorySessionStore struct {
+	*db.MemoryStore[Session]
+}
+
+// GetByTokenHash retrieves the session whose secret hashes to `hash`
+func (s *MemorySessionStore) GetByTokenHash(ctx context.Context, hash string) (Session, error) {
+	sessions, err := s.GetAll(ctx)
+	if err != nil {
+		return Session{}, err
+	}
+	for _, session := range sessions {
+		if session.TokenHash == hash {
+			return session, nil
+		}
+	}
+	return Session{}, sql.ErrNoRows
+}
+
+// DeleteExpired removes the sessions that expired before now, returning how many there were
+func (s *MemorySessionStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return s.deleteWhere(ctx, func(session Session) bool { return session.ExpiresAt.Before(now) })
+}
+
+// DeleteByUser removes the sessions of the user identified by `userID` but the one identified by
+// `keepID`, which may be empty, returning how many there were
+func (s *MemorySessionStore) DeleteByUser(ctx context.Context, userID, keepID string) (int64, error) {
+	return s.deleteWhere(ctx, func(session Session) bool {
+		return session.UserID == userID && session.ID != keepID
+	})
+}
+
+// deleteWhere removes the sessions match reports true for, returning how many there were
+func (s *MemorySessionStore) deleteWhere(ctx context.Context, match func(session Session) bool) (int64, error) {
+	sessions, err := s.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, session := range sessions {
+		if !match(session) {
+			continue
+		}
+		if err := s.Delete(ctx, session.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// MemoryAPITokenStore handles all in-memory operations for APIToken
+type MemoryAPITokenStore struct {
+	*db.MemoryStore[APIToken]
+}
+
+// GetByTokenHash retrieves the API token whose secret hashes to `hash`
+func (s *MemoryAPITokenStore) GetByTokenHash(ctx context.Context, hash string) (APIToken, error) {
+	tokens, err := s.GetAll(ctx)
+	if err != nil {
+		return APIToken{}, err
+	}
+	for _, token := range tokens {
+		if token.TokenHash == hash {
+			return token, nil
+		}
+	}
+	return APIToken{}, sql.ErrNoRows
+}
+
+// GetByUser retrieves the API tokens of the user identified by `userID`, newest first
+func (s *MemoryAPITokenStore) GetByUser(ctx context.Context, userID string) ([]APIToken, error) {
+	tokens, err := s.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mine := []APIToken{}
+	for _, token := range tokens {
+		if token.UserID == userID {
+			mine = append(mine, token)
+		}
+	}
+	sort.SliceStable(mine, func(i, j int) bool { return mine[i].CreatedAt.After(mine[j].CreatedAt) })
+	return mine, nil
+}
+
+// RecordUse sets the last use of the API token identified by argument `id` to now
+func (s *MemoryAPITokenStore) RecordUse(ctx context.Context, id string) error {
+	return s.Modify(ctx, id, func(t *APIToken) {
+		now := time.Now()
+		t.LastUsed = &now
+	})
+}
+
+// MemoryIdentityStore handles all in-memory operations for Identity
+type MemoryIdentityStore struct {
+	*db.MemoryStore[Identity]
+}
+
+// GetBySubject retrieves the identity `issuer` signs in as `subject`
+func (s *MemoryIdentityStore) GetBySubject(ctx context.Context, issuer, subject string) (Identity, error) {
+	identities, err := s.GetAll(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	for _, identity := range identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return Identity{}, sql.ErrNoRows
+}
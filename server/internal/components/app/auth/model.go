@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"slices"
+	"time"
+)
+
+// Scopes a personal API token may be granted
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// Credential holds the password of the user whose id it shares
+type Credential struct {
+	ID           string    `db:"id" json:"id"`
+	PasswordHash string    `db:"password_hash" json:"-"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Session is a login of the web app, identified by the secret in its cookie
+type Session struct {
+	ID        string    `db:"id" json:"id"`
+	UserID    string    `db:"user_id" json:"user_id"`
+	TokenHash string    `db:"token_hash" json:"-"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// APIToken is a personal access token a user creates for scripts.
+// It acts as its user, limited to its scopes, until it expires or is revoked.
+// LastUsed is only set by RecordUse.
+type APIToken struct {
+	ID        string     `db:"id" json:"id"`
+	UserID    string     `db:"user_id" json:"user_id"`
+	Name      string     `db:"name" json:"name" validate:"required,max=100"`
+	TokenHash string     `db:"token_hash" json:"-"`
+	Scopes    []string   `db:"scopes" json:"scopes" validate:"required,min=1,dive,oneof=read write"`
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at"`
+	LastUsed  *time.Time `db:"last_used,readonly" json:"last_used"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+}
+
+// Identity links a user to the subject an OpenID Connect provider signs them in as
+type Identity struct {
+	ID        string    `db:"id" json:"id"`
+	UserID    string    `db:"user_id" json:"user_id"`
+	Issuer    string    `db:"issuer" json:"issuer"`
+	Subject   string    `db:"subject" json:"subject"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Expired reports whether the token can no longer be used at now
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// HasScope reports whether the token was granted scope
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+func (c *Credential) GetID() string   { return c.ID }
+func (c *Credential) SetID(id string) { c.ID = id }
+func (s *Session) GetID() string      { return s.ID }
+func (s *Session) SetID(id string)    { s.ID = id }
+func (t *APIToken) GetID() string     { return t.ID }
+func (t *APIToken) SetID(id string)   { t.ID = id }
+func (i *Identity) GetID() string     { return i.ID }
+func (i *Identity) SetID(id string)   { i.ID = id }
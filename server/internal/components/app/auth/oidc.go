@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrProviderUnavailable is returned when the identity provider cannot be discovered
+	ErrProviderUnavailable = errors.New("identity provider unavailable")
+	// ErrSignIn wraps every reason a sign in through the identity provider was refused
+	ErrSignIn = errors.New("sign in failed")
+)
+
+// OIDC signs users in through an OpenID Connect identity provider with the authorization code flow
+// and PKCE. The provider is discovered on first use, so the server starts while it is unreachable.
+type OIDC struct {
+	Issuer string
+
+	config oauth2.Config
+
+	mu       sync.Mutex
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDC is the constructor for OIDC. The openid scope is always requested.
+func NewOIDC(issuer, clientID, clientSecret, redirectURL string, scopes []string) *OIDC {
+	if !slices.Contains(scopes, oidc.ScopeOpenID) {
+		scopes = append([]string{oidc.ScopeOpenID}, scopes...)
+	}
+	return &OIDC{
+		Issuer: issuer,
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+		},
+	}
+}
+
+// OIDCFlow is what a sign in remembers between leaving for the identity provider and returning
+//
+//	State: binds the callback to the browser that started the sign in
+//	Nonce: binds the ID token to the sign in
+//	Verifier: the PKCE code verifier, whose S256 challenge goes with the authorization request
+//	Redirect: the path of the web app to return to once signed in
+type OIDCFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+}
+
+// NewOIDCFlow starts a sign in that returns to redirect
+func NewOIDCFlow(redirect string) (OIDCFlow, error) {
+	state, _, err := NewToken("")
+	if err != nil {
+		return OIDCFlow{}, err
+	}
+	nonce, _, err := NewToken("")
+	if err != nil {
+		return OIDCFlow{}, err
+	}
+	return OIDCFlow{State: state, Nonce: nonce, Verifier: oauth2.GenerateVerifier(), Redirect: redirect}, nil
+}
+
+// Claims are what an ID token says about who signed in
+type Claims struct {
+	Issuer            string `json:"iss"`
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	Name              string `json:"name"`
+
+	// Raw holds every claim, for reading the one Env.OIDC.UsernameClaim names
+	Raw map[string]any `json:"-"`
+}
+
+// AuthCodeURL returns the identity provider's authorization URL for flow.
+// loginHint, when set, suggests who to sign in as.
+func (o *OIDC) AuthCodeURL(ctx context.Context, flow OIDCFlow, loginHint string) (string, error) {
+	if _, err := o.discover(ctx); err != nil {
+		return "", err
+	}
+	opts := []oauth2.AuthCodeOption{oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier)}
+	if loginHint != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("login_hint", loginHint))
+	}
+	return o.config.AuthCodeURL(flow.State, opts...), nil
+}
+
+// Exchange redeems the authorization code the identity provider returned for flow, and returns the
+// claims of the ID token once its signature, issuer, audience, expiry and nonce are checked
+func (o *OIDC) Exchange(ctx context.Context, flow OIDCFlow, code string) (Claims, error) {
+	verifier, err := o.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	token, err := o.config.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: redeeming the authorization code: %w", ErrSignIn, err)
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return Claims{}, fmt.Errorf("%w: the identity provider returned no ID token", ErrSignIn)
+	}
+	idToken, err := verifier.Verify(ctx, raw)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrSignIn, err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return Claims{}, fmt.Errorf("%w: ID token nonce does not match", ErrSignIn)
+	}
+
+	var claims Claims
+	if err := idToken.Claims(&claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrSignIn, err)
+	}
+	if err := idToken.Claims(&claims.Raw); err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrSignIn, err)
+	}
+	return claims, nil
+}
+
+// discover fetches the provider's configuration and keys the first time it succeeds
+func (o *OIDC) discover(ctx context.Context) (*oidc.IDTokenVerifier, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.verifier != nil {
+		return o.verifier, nil
+	}
+	provider, err := oidc.NewProvider(ctx, o.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	o.config.Endpoint = provider.Endpoint()
+	o.verifier = provider.Verifier(&oidc.Config{ClientID: o.config.ClientID})
+	return o.verifier, nil
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery staple")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$"))
+
+	again, err := HashPassword("correct horse battery staple")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again, "hashes are salted")
+
+	ok, err := VerifyPassword(hash, "correct horse battery staple")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = VerifyPassword(hash, "Correct horse battery staple")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, bad := range []string{"", "plain", "$2a$10$bcrypt", strings.Replace(hash, "v=19", "v=16", 1), strings.Replace(hash, "t=3", "t=x", 1)} {
+		_, err := VerifyPassword(bad, "correct horse battery staple")
+		assert.ErrorIs(t, err, ErrMalformedHash, bad)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	secret, hash, err := NewToken(TokenPrefix)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, TokenPrefix))
+	assert.Len(t, secret, len(TokenPrefix)+43)
+	assert.Equal(t, HashToken(secret), hash)
+	assert.Len(t, hash, 64)
+
+	other, _, err := NewToken(TokenPrefix)
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+}
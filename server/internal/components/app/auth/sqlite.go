@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+const (
+	// queryDeleteExpiredSessionsSQLite is queryDeleteExpiredSessions for SQLite
+	queryDeleteExpiredSessionsSQLite = `DELETE FROM ` + constants.TableSession + ` WHERE expires_at < ?1`
+	// queryDeleteUserSessionsSQLite is queryDeleteUserSessions for SQLite
+	queryDeleteUserSessionsSQLite = `DELETE FROM ` + constants.TableSession + ` WHERE user_id = ?1 AND id <> ?2`
+	// queryRecordTokenUseSQLite is queryRecordTokenUse for SQLite, taking the time of the use as ?2
+	queryRecordTokenUseSQLite = `UPDATE ` + constants.TableAPIToken + ` SET last_used = ?2 WHERE id = ?1`
+)
+
+// NewSQLiteStores creates the SQLite stores for authentication
+func NewSQLiteStores(conn db.SQLQuerier) Stores {
+	return Stores{
+		Credentials: db.NewSQLiteStore[Credential](conn, constants.TableCredential),
+		Sessions:    &SQLiteSessionStore{db.NewSQLiteStore[Session](conn, constants.TableSession)},
+		Tokens:      &SQLiteAPITokenStore{db.NewSQLiteStore[APIToken](conn, constants.TableAPIToken)},
+		Identities:  &SQLiteIdentityStore{db.NewSQLiteStore[Identity](conn, constants.TableIdentity)},
+	}
+}
+
+// SQLiteSessionStore handles all SQLite operations for Session
+type SQLiteSessionStore struct {
+	*db.SQLiteStore[Session]
+}
+
+// GetByTokenHash retrieves the session whose secret hashes to `hash`
+func (s *SQLiteSessionStore) GetByTokenHash(ctx context.Context, hash string) (Session, error) {
+	return s.SelectOne(ctx, s.Queries.GetAll+` WHERE token_hash = ?1`, hash)
+}
+
+// DeleteExpired removes the sessions that expired before now, returning how many there were
+func (s *SQLiteSessionStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return s.Exec(ctx, queryDeleteExpiredSessionsSQLite, now)
+}
+
+// DeleteByUser removes the sessions of the user identified by `userID` but the one identified by
+// `keepID`, which may be empty, returning how many there were
+func (s *SQLiteSessionStore) DeleteByUser(ctx context.Context, userID, keepID string) (int64, error) {
+	return s.Exec(ctx, queryDeleteUserSessionsSQLite, userID, keepID)
+}
+
+// SQLiteAPITokenStore handles all SQLite operations for APIToken
+type SQLiteAPITokenStore struct {
+	*db.SQLiteStore[APIToken]
+}
+
+// GetByTokenHash retrieves the API token whose secret hashes to `hash`
+func (s *SQLiteAPITokenStore) GetByTokenHash(ctx context.Context, hash string) (APIToken, error) {
+	return s.SelectOne(ctx, s.Queries.GetAll+` WHERE token_hash = ?1`, hash)
+}
+
+// GetByUser retrieves the API tokens of the user identified by `userID`, newest first
+func (s *SQLiteAPITokenStore) GetByUser(ctx context.Context, userID string) ([]APIToken, error) {
+	return s.Select(ctx, s.Queries.GetAll+` WHERE user_id = ?1 ORDER BY created_at DESC`, userID)
+}
+
+// RecordUse sets the last use of the API token identified by argument `id` to now
+func (s *SQLiteAPITokenStore) RecordUse(ctx context.Context, id string) error {
+	n, err := s.Exec(ctx, queryRecordTokenUseSQLite, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SQLiteIdentityStore handles all SQLite operations for Identity
+type SQLiteIdentityStore struct {
+	*db.SQLiteStore[Identity]
+}
+
+// GetBySubject retrieves the identity `issuer` signs in as `subject`
+func (s *SQLiteIdentityStore) GetBySubject(ctx context.Context, issuer, subject string) (Identity, error) {
+	return s.SelectOne(ctx, s.Queries.GetAll+` WHERE issuer = ?1 AND subject = ?2`, issuer, subject)
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/db/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) (user.Table, Stores){
+		"sqlite": func(t *testing.T) (user.Table, Stores) {
+			conn := dbtest.OpenSQLite(t)
+			return user.NewSQLiteStore(conn), NewSQLiteStores(conn)
+		},
+		"memory": func(t *testing.T) (user.Table, Stores) {
+			mem := db.NewMemory()
+			return user.NewMemoryStore(mem), NewMemoryStores(mem)
+		},
+	}
+	for name, newStores := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			users, s := newStores(t)
+			alice := &user.User{Username: "alice", Enabled: true}
+			bob := &user.User{Username: "bob", Enabled: true}
+			require.NoError(t, users.Create(ctx, alice))
+			require.NoError(t, users.Create(ctx, bob))
+
+			cred := &Credential{ID: alice.ID, PasswordHash: "hash"}
+			require.NoError(t, s.Credentials.Create(ctx, cred))
+			got, err := s.Credentials.GetByID(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "hash", got.PasswordHash)
+
+			now := time.Now()
+			live := &Session{UserID: alice.ID, TokenHash: "live", ExpiresAt: now.Add(time.Hour)}
+			expired := &Session{UserID: alice.ID, TokenHash: "expired", ExpiresAt: now.Add(-time.Hour)}
+			other := &Session{UserID: bob.ID, TokenHash: "other", ExpiresAt: now.Add(time.Hour)}
+			for _, session := range []*Session{live, expired, other} {
+				require.NoError(t, s.Sessions.Create(ctx, session))
+			}
+			if name == "sqlite" {
+				// the in-memory database has no constraints; secrets are random, so hashes do not collide
+				assert.ErrorIs(t, s.Sessions.Create(ctx, &Session{UserID: bob.ID, TokenHash: "live", ExpiresAt: now}), db.ErrDuplicate)
+			}
+
+			found, err := s.Sessions.GetByTokenHash(ctx, "live")
+			require.NoError(t, err)
+			assert.Equal(t, live.ID, found.ID)
+			assert.WithinDuration(t, live.ExpiresAt, found.ExpiresAt, time.Millisecond)
+			_, err = s.Sessions.GetByTokenHash(ctx, "missing")
+			assert.ErrorIs(t, err, sql.ErrNoRows)
+
+			n, err := s.Sessions.DeleteExpired(ctx, now)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), n)
+
+			second := &Session{UserID: alice.ID, TokenHash: "second", ExpiresAt: now.Add(time.Hour)}
+			require.NoError(t, s.Sessions.Create(ctx, second))
+			n, err = s.Sessions.DeleteByUser(ctx, alice.ID, live.ID)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), n)
+			_, err = s.Sessions.GetByID(ctx, live.ID)
+			assert.NoError(t, err, "the kept session remains")
+			_, err = s.Sessions.GetByID(ctx, other.ID)
+			assert.NoError(t, err, "other users' sessions remain")
+
+			older := &APIToken{UserID: alice.ID, Name: "older", TokenHash: "t1", Scopes: []string{ScopeRead}}
+			require.NoError(t, s.Tokens.Create(ctx, older))
+			time.Sleep(2 * time.Millisecond)
+			newer := &APIToken{UserID: alice.ID, Name: "newer", TokenHash: "t2", Scopes: []string{ScopeRead, ScopeWrite}, ExpiresAt: &now}
+			require.NoError(t, s.Tokens.Create(ctx, newer))
+			require.NoError(t, s.Tokens.Create(ctx, &APIToken{UserID: bob.ID, Name: "bob's", TokenHash: "t3", Scopes: []string{ScopeRead}}))
+
+			mine, err := s.Tokens.GetByUser(ctx, alice.ID)
+			require.NoError(t, err)
+			require.Len(t, mine, 2)
+			assert.Equal(t, []string{"newer", "older"}, []string{mine[0].Name, mine[1].Name})
+			assert.Equal(t, []string{ScopeRead, ScopeWrite}, mine[0].Scopes)
+			assert.True(t, mine[0].Expired(now))
+			assert.False(t, mine[1].Expired(now))
+
+			require.NoError(t, s.Tokens.RecordUse(ctx, older.ID))
+			token, err := s.Tokens.GetByTokenHash(ctx, "t1")
+			require.NoError(t, err)
+			require.NotNil(t, token.LastUsed)
+			assert.True(t, token.HasScope(ScopeRead))
+			assert.False(t, token.HasScope(ScopeWrite))
+			assert.ErrorIs(t, s.Tokens.RecordUse(ctx, "00000000-0000-0000-0000-000000000000"), sql.ErrNoRows)
+
+			identity := &Identity{UserID: alice.ID, Issuer: "https://idp.example", Subject: "1234"}
+			require.NoError(t, s.Identities.Create(ctx, identity))
+			linked, err := s.Identities.GetBySubject(ctx, "https://idp.example", "1234")
+			require.NoError(t, err)
+			assert.Equal(t, alice.ID, linked.UserID)
+			_, err = s.Identities.GetBySubject(ctx, "https://other.example", "1234")
+			assert.ErrorIs(t, err, sql.ErrNoRows, "subjects are only unique per issuer")
+			if name == "sqlite" {
+				assert.ErrorIs(t, s.Identities.Create(ctx, &Identity{UserID: bob.ID, Issuer: "https://idp.example", Subject: "1234"}), db.ErrDuplicate)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package share
+
+import (
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+const tableShares = constants.SchemaMendelCore + "." + constants.TableShare
+
+// NewStore creates the Postgres store for shares
+func NewStore(conn db.Querier) db.CRUDTable[Share] {
+	return db.NewStore[Share](conn, tableShares)
+}
@@ -0,0 +1,11 @@
+package share
+
+import (
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// NewMemoryStore creates the in-memory store for shares
+func NewMemoryStore(mem *db.Memory) db.CRUDTable[Share] {
+	return db.NewMemoryStore[Share](mem, constants.TableShare)
+}
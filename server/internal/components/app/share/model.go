@@ -0,0 +1,32 @@
+package share
+
+import "time"
+
+// Share is a read-only link to a record of a workspace, or a plant and its ancestors, for anyone
+// holding its token, without an account. Its token is signed rather than stored, so revoking a
+// share marks it rather than deleting it.
+//
+//	CreatedBy: the user who shared the record, or nil without authentication
+//	Component: the component of the record, named by its table
+//	Lineage: whether the record's seed and pollen ancestors are shared too
+//	Fields: the JSON names of the fields shown; every field when empty
+type Share struct {
+	ID          string     `db:"id" json:"id"`
+	WorkspaceID string     `db:"workspace_id" json:"workspace_id"`
+	CreatedBy   *string    `db:"created_by" json:"created_by"`
+	Component   string     `db:"component" json:"component"`
+	RecordID    string     `db:"record_id" json:"record_id"`
+	Lineage     bool       `db:"lineage" json:"lineage"`
+	Fields      []string   `db:"fields" json:"fields"`
+	ExpiresAt   time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt   *time.Time `db:"revoked_at" json:"revoked_at"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+}
+
+func (s *Share) GetID() string   { return s.ID }
+func (s *Share) SetID(id string) { s.ID = id }
+
+// Active reports whether the share's token may still be used at now
+func (s *Share) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,11 @@
+package share
+
+import (
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// NewSQLiteStore creates the SQLite store for shares
+func NewSQLiteStore(conn db.SQLQuerier) db.CRUDTable[Share] {
+	return db.NewSQLiteStore[Share](conn, constants.TableShare)
+}
@@ -0,0 +1,62 @@
+package share
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/db/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) (db.CRUDTable[Share], workspace.Stores){
+		"sqlite": func(t *testing.T) (db.CRUDTable[Share], workspace.Stores) {
+			conn := dbtest.OpenSQLite(t)
+			return NewSQLiteStore(conn), workspace.NewSQLiteStores(conn)
+		},
+		"memory": func(t *testing.T) (db.CRUDTable[Share], workspace.Stores) {
+			mem := db.NewMemory()
+			return NewMemoryStore(mem), workspace.NewMemoryStores(mem)
+		},
+	}
+	for name, newStores := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			table, ws := newStores(t)
+
+			other := &workspace.Workspace{Name: "other"}
+			require.NoError(t, ws.Workspaces.Create(ctx, other))
+			inDefault := db.WithWorkspace(ctx, workspace.DefaultID)
+
+			expires := time.Now().Add(time.Hour)
+			s := &Share{Component: "plant", RecordID: uuid.NewString(), Lineage: true, Fields: []string{"generation"}, ExpiresAt: expires}
+			require.NoError(t, table.Create(inDefault, s))
+			assert.Equal(t, workspace.DefaultID, s.WorkspaceID)
+
+			got, err := table.GetByID(inDefault, s.ID)
+			require.NoError(t, err)
+			assert.True(t, got.Lineage)
+			assert.Equal(t, []string{"generation"}, got.Fields)
+			assert.True(t, got.ExpiresAt.Equal(expires))
+			assert.True(t, got.Active(time.Now()))
+			assert.False(t, got.Active(expires))
+
+			now := time.Now()
+			got.RevokedAt = &now
+			require.NoError(t, table.Update(inDefault, &got))
+			got, err = table.GetByID(inDefault, s.ID)
+			require.NoError(t, err)
+			assert.NotNil(t, got.RevokedAt)
+			assert.False(t, got.Active(time.Now()), "revoked shares are not active")
+
+			_, err = table.GetByID(db.WithWorkspace(ctx, other.ID), s.ID)
+			assert.ErrorIs(t, err, sql.ErrNoRows, "shares of other workspaces are not found")
+		})
+	}
+}
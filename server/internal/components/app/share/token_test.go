@@ -0,0 +1,39 @@
+package share
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner([]byte("key"))
+	s := &Share{ID: uuid.NewString(), WorkspaceID: uuid.NewString()}
+
+	token, err := signer.Sign(s)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, TokenPrefix))
+
+	id, workspaceID, err := signer.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, s.ID, id)
+	assert.Equal(t, s.WorkspaceID, workspaceID)
+
+	other, err := NewRandomSigner()
+	require.NoError(t, err)
+	_, _, err = other.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "tokens of other keys are refused")
+
+	tampered := []byte(token)
+	tampered[len(TokenPrefix)] ^= 1
+	for _, bad := range []string{"", token[len(TokenPrefix):], string(tampered), token[:len(token)-2], token + "A", "mendel_pat_" + token[len(TokenPrefix):]} {
+		_, _, err := signer.Verify(bad)
+		assert.ErrorIs(t, err, ErrInvalidToken, bad)
+	}
+
+	_, err = signer.Sign(&Share{ID: "1", WorkspaceID: s.WorkspaceID})
+	assert.Error(t, err)
+}
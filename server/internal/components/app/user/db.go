@@ -0,0 +1,54 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+const (
+	tableUsers = constants.SchemaMendelCore + "." + constants.TableUser
+
+	// queryRecordLogin sets the last login of the user identified by $1 to now
+	queryRecordLogin = `
+		WITH touched AS (
+			UPDATE ` + tableUsers + ` SET last_login = NOW() WHERE id = $1 RETURNING 1
+		)
+		SELECT count(*) FROM touched`
+)
+
+// Table is implemented by the user store of every dialect
+type Table interface {
+	db.CRUDTable[User]
+	GetByUsername(ctx context.Context, username string) (User, error)
+	RecordLogin(ctx context.Context, id string) error
+}
+
+// Store handles all database operations for User
+type Store struct {
+	*db.Store[User]
+}
+
+// NewStore creates a user Store
+func NewStore(conn db.Querier) *Store {
+	return &Store{db.NewStore[User](conn, tableUsers)}
+}
+
+// GetByUsername retrieves the user named `username`, ignoring case
+func (s *Store) GetByUsername(ctx context.Context, username string) (User, error) {
+	return s.SelectOne(ctx, s.Queries.GetAll+` WHERE lower(username) = lower($1)`, username)
+}
+
+// RecordLogin sets the last login of the user identified by argument `id` to now
+func (s *Store) RecordLogin(ctx context.Context, id string) error {
+	var n int
+	if err := s.Querier(ctx).QueryRow(ctx, queryRecordLogin, id).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
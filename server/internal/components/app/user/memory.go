@@ -0,0 +1,80 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// MemoryStore handles all in-memory operations for User.
+// It checks usernames are unique itself, as the in-memory database has no constraints.
+type MemoryStore struct {
+	*db.MemoryStore[User]
+	mem *db.Memory
+}
+
+// NewMemoryStore creates a user MemoryStore
+func NewMemoryStore(mem *db.Memory) *MemoryStore {
+	return &MemoryStore{MemoryStore: db.NewMemoryStore[User](mem, constants.TableUser), mem: mem}
+}
+
+// Create adds item, failing with db.ErrDuplicate if its username is taken
+func (s *MemoryStore) Create(ctx context.Context, item *User) error {
+	return s.mem.InTx(ctx, func(ctx context.Context) error {
+		if err := s.checkUsername(ctx, item); err != nil {
+			return err
+		}
+		return s.MemoryStore.Create(ctx, item)
+	})
+}
+
+// Update changes item, failing with db.ErrDuplicate if its username is taken by another user
+func (s *MemoryStore) Update(ctx context.Context, item *User) error {
+	return s.mem.InTx(ctx, func(ctx context.Context) error {
+		if err := s.checkUsername(ctx, item); err != nil {
+			return err
+		}
+		return s.MemoryStore.Update(ctx, item)
+	})
+}
+
+// GetByUsername retrieves the user named `username`, ignoring case
+func (s *MemoryStore) GetByUsername(ctx context.Context, username string) (User, error) {
+	users, err := s.GetAll(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	for _, u := range users {
+		if strings.EqualFold(u.Username, username) {
+			return u, nil
+		}
+	}
+	return User{}, sql.ErrNoRows
+}
+
+// RecordLogin sets the last login of the user identified by argument `id` to now
+func (s *MemoryStore) RecordLogin(ctx context.Context, id string) error {
+	return s.Modify(ctx, id, func(u *User) {
+		now := time.Now()
+		u.LastLogin = &now
+	})
+}
+
+// checkUsername fails with db.ErrDuplicate if another user has item's username, ignoring case
+func (s *MemoryStore) checkUsername(ctx context.Context, item *User) error {
+	users, err := s.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.ID != item.ID && strings.EqualFold(u.Username, item.Username) {
+			return fmt.Errorf("%w: username %s is taken", db.ErrDuplicate, item.Username)
+		}
+	}
+	return nil
+}
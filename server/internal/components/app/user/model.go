@@ -0,0 +1,67 @@
+package user
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kylep342/mendel/internal/components"
+)
+
+// usernamePattern allows letters, digits, dots, dashes and underscores, starting with a letter or digit
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// usernameInvalid matches the runs of characters usernamePattern does not allow
+var usernameInvalid = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Bounds of a username's length, as User validates it
+const (
+	UsernameMinLength = 3
+	UsernameMaxLength = 49
+)
+
+// UsernameFrom makes a valid username of name, which another system chose, by replacing the
+// characters usernames do not allow with dashes. It returns "" when too little of name is left.
+func UsernameFrom(name string) string {
+	name = usernameInvalid.ReplaceAllString(name, "-")
+	name = strings.TrimLeft(name, "._-")
+	if len(name) > UsernameMaxLength {
+		name = name[:UsernameMaxLength]
+	}
+	if len(name) < UsernameMinLength {
+		return ""
+	}
+	return name
+}
+
+// User is an account of the web app.
+// Usernames are unique regardless of case. LastLogin is only set by RecordLogin.
+type User struct {
+	ID          string      `db:"id" json:"id"`
+	Username    string      `db:"username" json:"username" validate:"required,min=3,max=49"`
+	Enabled     bool        `db:"enabled" json:"enabled"`
+	WebSettings WebSettings `db:"web_settings" json:"web_settings"`
+	LastLogin   *time.Time  `db:"last_login,readonly" json:"last_login"`
+	CreatedAt   time.Time   `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time   `db:"updated_at" json:"updated_at"`
+}
+
+// WebSettings are a user's preferences for the web app, stored as a JSON document.
+// Unset settings fall back to the web app's defaults.
+type WebSettings struct {
+	Theme    string `json:"theme,omitempty" validate:"omitempty,oneof=system light dark"`
+	Locale   string `json:"locale,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Timezone string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	PageSize int    `json:"page_size,omitempty" validate:"omitempty,min=10,max=500"`
+}
+
+func (u *User) GetID() string { return u.ID }
+
+func (u *User) SetID(id string) { u.ID = id }
+
+func (u *User) Validate() []components.FieldError {
+	if u.Username != "" && !usernamePattern.MatchString(u.Username) {
+		return []components.FieldError{{Field: "username", Message: "must start with a letter or digit and hold only letters, digits, '.', '-' and '_'"}}
+	}
+	return nil
+}
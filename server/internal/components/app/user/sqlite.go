@@ -0,0 +1,40 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// queryRecordLoginSQLite is queryRecordLogin for SQLite, taking the time of the login as ?2
+const queryRecordLoginSQLite = `UPDATE ` + constants.TableUser + ` SET last_login = ?2 WHERE id = ?1`
+
+// SQLiteStore handles all SQLite operations for User
+type SQLiteStore struct {
+	*db.SQLiteStore[User]
+}
+
+// NewSQLiteStore creates a user SQLiteStore
+func NewSQLiteStore(conn db.SQLQuerier) *SQLiteStore {
+	return &SQLiteStore{db.NewSQLiteStore[User](conn, constants.TableUser)}
+}
+
+// GetByUsername retrieves the user named `username`, ignoring case
+func (s *SQLiteStore) GetByUsername(ctx context.Context, username string) (User, error) {
+	return s.SelectOne(ctx, s.Queries.GetAll+` WHERE lower(username) = lower(?1)`, username)
+}
+
+// RecordLogin sets the last login of the user identified by argument `id` to now
+func (s *SQLiteStore) RecordLogin(ctx context.Context, id string) error {
+	n, err := s.Exec(ctx, queryRecordLoginSQLite, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
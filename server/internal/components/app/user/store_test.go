@@ -0,0 +1,101 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/kylep342/mendel/internal/components"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/db/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Table{
+		"sqlite": func(t *testing.T) Table { return NewSQLiteStore(dbtest.OpenSQLite(t)) },
+		"memory": func(t *testing.T) Table { return NewMemoryStore(db.NewMemory()) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newStore(t)
+
+			alice := &User{Username: "Alice", Enabled: true, WebSettings: WebSettings{Theme: "dark", PageSize: 50}}
+			require.NoError(t, s.Create(ctx, alice))
+			assert.Nil(t, alice.LastLogin)
+
+			got, err := s.GetByID(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, WebSettings{Theme: "dark", PageSize: 50}, got.WebSettings)
+
+			assert.ErrorIs(t, s.Create(ctx, &User{Username: "alice"}), db.ErrDuplicate, "usernames are unique regardless of case")
+
+			byName, err := s.GetByUsername(ctx, "ALICE")
+			require.NoError(t, err)
+			assert.Equal(t, alice.ID, byName.ID)
+			_, err = s.GetByUsername(ctx, "nobody")
+			assert.ErrorIs(t, err, sql.ErrNoRows)
+
+			require.NoError(t, s.RecordLogin(ctx, alice.ID))
+			got, err = s.GetByID(ctx, alice.ID)
+			require.NoError(t, err)
+			require.NotNil(t, got.LastLogin)
+			lastLogin := *got.LastLogin
+
+			got.Username = "ALICE"
+			got.LastLogin = nil
+			require.NoError(t, s.Update(ctx, &got), "a user may change the case of their own username")
+			require.NotNil(t, got.LastLogin, "updates keep last_login")
+			assert.True(t, lastLogin.Equal(*got.LastLogin))
+
+			bob := &User{Username: "bob"}
+			require.NoError(t, s.Create(ctx, bob))
+			bob.Username = "alice"
+			assert.ErrorIs(t, s.Update(ctx, bob), db.ErrDuplicate)
+
+			assert.ErrorIs(t, s.RecordLogin(ctx, "00000000-0000-0000-0000-000000000000"), sql.ErrNoRows)
+		})
+	}
+}
+
+func TestUser_Validate(t *testing.T) {
+	valid := User{Username: "a.b-c_1", WebSettings: WebSettings{Theme: "light", Locale: "en-US", Timezone: "Europe/Berlin", PageSize: 25}}
+	assert.NoError(t, components.Validate(&valid))
+
+	invalid := User{Username: ".ab", WebSettings: WebSettings{Theme: "pink", Locale: "not a locale", Timezone: "Mars/Base", PageSize: 1}}
+	err := components.Validate(&invalid)
+	var fields *components.ValidationError
+	require.ErrorAs(t, err, &fields)
+	var names []string
+	for _, f := range fields.Fields {
+		names = append(names, f.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"username",
+		"web_settings.theme",
+		"web_settings.locale",
+		"web_settings.timezone",
+		"web_settings.page_size",
+	}, names)
+}
+
+func TestUsernameFrom(t *testing.T) {
+	for name, want := range map[string]string{
+		"alice":                 "alice",
+		"Jane Doe":              "Jane-Doe",
+		"jane@example.com":      "jane-example.com",
+		"_.émile":               "mile",
+		"ab":                    "",
+		"__":                    "",
+		strings.Repeat("x", 60): strings.Repeat("x", UsernameMaxLength),
+	} {
+		got := UsernameFrom(name)
+		assert.Equal(t, want, got, name)
+		if got != "" {
+			assert.NoError(t, components.Validate(&User{Username: got}), name)
+		}
+	}
+}
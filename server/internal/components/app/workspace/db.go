@@ -0,0 +1,50 @@
+package workspace
+
+import (
+	"context"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+const (
+	tableWorkspaces = constants.SchemaMendelCore + "." + constants.TableWorkspace
+	tableMembers    = constants.SchemaMendelCore + "." + constants.TableWorkspaceMember
+
+	columnsMember = `id, workspace_id, user_id, role, created_at`
+
+	// queryGetUserMemberships retrieves the memberships of the user $1 in every workspace, oldest first
+	queryGetUserMemberships = `SELECT ` + columnsMember + ` FROM ` + tableMembers + ` WHERE user_id = $1 ORDER BY created_at, id`
+)
+
+// MemberTable is implemented by the member store of every dialect
+type MemberTable interface {
+	db.CRUDTable[Member]
+	// GetByUser retrieves the memberships of the user identified by `userID` in every workspace,
+	// oldest first, whichever workspace the context is scoped to
+	GetByUser(ctx context.Context, userID string) ([]Member, error)
+}
+
+// Stores are the tables of workspaces and their members
+type Stores struct {
+	Workspaces db.CRUDTable[Workspace]
+	Members    MemberTable
+}
+
+// NewStores creates the Postgres stores for workspaces
+func NewStores(conn db.Querier) Stores {
+	return Stores{
+		Workspaces: db.NewStore[Workspace](conn, tableWorkspaces),
+		Members:    &MemberStore{db.NewStore[Member](conn, tableMembers)},
+	}
+}
+
+// MemberStore handles all database operations for Member
+type MemberStore struct {
+	*db.Store[Member]
+}
+
+// GetByUser retrieves the memberships of the user identified by `userID` in every workspace, oldest first
+func (s *MemberStore) GetByUser(ctx context.Context, userID string) ([]Member, error) {
+	return s.Select(ctx, queryGetUserMemberships, userID)
+}
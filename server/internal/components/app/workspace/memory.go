@@ -0,0 +1,69 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// NewMemoryStores creates the in-memory stores for workspaces
+func NewMemoryStores(mem *db.Memory) Stores {
+	workspaces := db.NewMemoryStore[Workspace](mem, constants.TableWorkspace)
+	return Stores{
+		Workspaces: workspaces,
+		Members: &MemoryMemberStore{
+			MemoryStore: db.NewMemoryStore[Member](mem, constants.TableWorkspaceMember),
+			mem:         mem,
+			workspaces:  workspaces,
+		},
+	}
+}
+
+// MemoryMemberStore handles all in-memory operations for Member.
+// It checks a user is a member of a workspace once itself, as the in-memory database has no constraints.
+type MemoryMemberStore struct {
+	*db.MemoryStore[Member]
+	mem        *db.Memory
+	workspaces *db.MemoryStore[Workspace]
+}
+
+// Create adds item, failing with db.ErrDuplicate if its user is already a member
+func (s *MemoryMemberStore) Create(ctx context.Context, item *Member) error {
+	return s.mem.InTx(ctx, func(ctx context.Context) error {
+		members, err := s.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range members {
+			if m.UserID == item.UserID {
+				return fmt.Errorf("%w: user %s is already a member", db.ErrDuplicate, item.UserID)
+			}
+		}
+		return s.MemoryStore.Create(ctx, item)
+	})
+}
+
+// GetByUser retrieves the memberships of the user identified by `userID` in every workspace, oldest first
+func (s *MemoryMemberStore) GetByUser(ctx context.Context, userID string) ([]Member, error) {
+	workspaces, err := s.workspaces.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mine := []Member{}
+	for _, w := range workspaces {
+		members, err := s.GetAll(db.WithWorkspace(ctx, w.ID))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			if m.UserID == userID {
+				mine = append(mine, m)
+			}
+		}
+	}
+	sort.SliceStable(mine, func(i, j int) bool { return mine[i].CreatedAt.Before(mine[j].CreatedAt) })
+	return mine, nil
+}
@@ -0,0 +1,35 @@
+package workspace
+
+import (
+	"time"
+)
+
+// The default workspace holds the records created before workspaces existed, and scopes every
+// request when authentication is disabled
+const (
+	DefaultID   = "00000000-0000-0000-0000-000000000001"
+	DefaultName = "Default"
+)
+
+// Workspace holds one team's species, cultivars and plants
+type Workspace struct {
+	ID        string    `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name" validate:"required,max=255"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Member lets a user use the records of a workspace as their role allows.
+// Like the records, members are scoped to the workspace of the context they are written with.
+type Member struct {
+	ID          string    `db:"id" json:"id"`
+	WorkspaceID string    `db:"workspace_id" json:"workspace_id"`
+	UserID      string    `db:"user_id" json:"user_id" validate:"required,uuid"`
+	Role        Role      `db:"role" json:"role" validate:"required,oneof=owner breeder observer guest"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+func (w *Workspace) GetID() string   { return w.ID }
+func (w *Workspace) SetID(id string) { w.ID = id }
+func (m *Member) GetID() string      { return m.ID }
+func (m *Member) SetID(id string)    { m.ID = id }
@@ -0,0 +1,109 @@
+package workspace
+
+import (
+	"slices"
+
+	"github.com/kylep342/mendel/internal/constants"
+)
+
+// Role is what a member may do in their workspace
+type Role string
+
+const (
+	// RoleOwner may do anything, including managing the workspace's members
+	RoleOwner Role = "owner"
+	// RoleBreeder may read, write and share the workspace's records, and see its members
+	RoleBreeder Role = "breeder"
+	// RoleObserver may read the workspace's records, and see its members and share links
+	RoleObserver Role = "observer"
+	// RoleGuest may only read the workspace's records
+	RoleGuest Role = "guest"
+)
+
+// Roles lists every role, most powerful first
+var Roles = []Role{RoleOwner, RoleBreeder, RoleObserver, RoleGuest}
+
+// Action is something done to the records of a component
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionRead   Action = "read"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Actions lists every action
+var Actions = []Action{ActionCreate, ActionRead, ActionUpdate, ActionDelete}
+
+// Components lists the components roles grant permissions on, named by their tables.
+// Users are global rather than scoped to a workspace: roles in the default workspace govern them.
+var Components = []string{
+	constants.TablePlantSpecies,
+	constants.TablePlantCultivar,
+	constants.TablePlant,
+	constants.TableWorkspaceMember,
+	constants.TableUser,
+	constants.TableAudit,
+	constants.TableShare,
+}
+
+// IsGlobal reports whether component is shared by every workspace, so the role of a member of
+// the default workspace governs it
+func IsGlobal(component string) bool {
+	return component == constants.TableUser
+}
+
+// Permissions maps components to the actions allowed on them
+type Permissions map[string][]Action
+
+// Allows reports whether action is allowed on component
+func (p Permissions) Allows(component string, action Action) bool {
+	return slices.Contains(p[component], action)
+}
+
+var (
+	everything = []Action{ActionCreate, ActionRead, ActionUpdate, ActionDelete}
+	readOnly   = []Action{ActionRead}
+)
+
+// RolePermissions are the permissions of each role
+var RolePermissions = map[Role]Permissions{
+	RoleOwner: {
+		constants.TablePlantSpecies:    everything,
+		constants.TablePlantCultivar:   everything,
+		constants.TablePlant:           everything,
+		constants.TableWorkspaceMember: everything,
+		constants.TableUser:            everything,
+		constants.TableAudit:           readOnly,
+		constants.TableShare:           everything,
+	},
+	RoleBreeder: {
+		constants.TablePlantSpecies:    everything,
+		constants.TablePlantCultivar:   everything,
+		constants.TablePlant:           everything,
+		constants.TableWorkspaceMember: readOnly,
+		constants.TableUser:            readOnly,
+		constants.TableAudit:           readOnly,
+		constants.TableShare:           everything,
+	},
+	RoleObserver: {
+		constants.TablePlantSpecies:    readOnly,
+		constants.TablePlantCultivar:   readOnly,
+		constants.TablePlant:           readOnly,
+		constants.TableWorkspaceMember: readOnly,
+		constants.TableUser:            readOnly,
+		constants.TableAudit:           readOnly,
+		constants.TableShare:           readOnly,
+	},
+	RoleGuest: {
+		constants.TablePlantSpecies:  readOnly,
+		constants.TablePlantCultivar: readOnly,
+		constants.TablePlant:         readOnly,
+	},
+}
+
+// Can reports whether members with the role may take action on component
+func (r Role) Can(component string, action Action) bool {
+	return RolePermissions[r].Allows(component, action)
+}
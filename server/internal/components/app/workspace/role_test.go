@@ -0,0 +1,31 @@
+package workspace
+
+import (
+	"testing"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRole_Can(t *testing.T) {
+	for _, role := range Roles {
+		assert.Contains(t, RolePermissions, role)
+		assert.True(t, role.Can(constants.TablePlant, ActionRead), "%s reads records", role)
+	}
+
+	assert.True(t, RoleOwner.Can(constants.TableWorkspaceMember, ActionDelete))
+	assert.True(t, RoleBreeder.Can(constants.TablePlant, ActionDelete))
+	assert.False(t, RoleBreeder.Can(constants.TableWorkspaceMember, ActionCreate))
+	assert.False(t, RoleObserver.Can(constants.TablePlantCultivar, ActionUpdate))
+	assert.True(t, RoleObserver.Can(constants.TableWorkspaceMember, ActionRead))
+	assert.False(t, RoleGuest.Can(constants.TableWorkspaceMember, ActionRead))
+	assert.False(t, RoleGuest.Can(constants.TableUser, ActionRead))
+	assert.True(t, RoleObserver.Can(constants.TableAudit, ActionRead))
+	assert.False(t, RoleOwner.Can(constants.TableAudit, ActionDelete), "the audit log is read only")
+	assert.False(t, RoleGuest.Can(constants.TableAudit, ActionRead))
+	assert.True(t, RoleBreeder.Can(constants.TableShare, ActionCreate))
+	assert.False(t, RoleObserver.Can(constants.TableShare, ActionCreate), "observers cannot share records")
+	assert.False(t, RoleGuest.Can(constants.TableShare, ActionRead))
+	assert.False(t, Role("admin").Can(constants.TablePlant, ActionRead), "unknown roles may do nothing")
+	assert.False(t, RoleOwner.Can("unknown", ActionRead))
+}
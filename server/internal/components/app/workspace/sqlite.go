@@ -0,0 +1,30 @@
+package workspace
+
+import (
+	"context"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// queryGetUserMembershipsSQLite is queryGetUserMemberships for SQLite
+const queryGetUserMembershipsSQLite = `SELECT ` + columnsMember + ` FROM ` + constants.TableWorkspaceMember +
+	` WHERE user_id = ?1 ORDER BY created_at, id`
+
+// NewSQLiteStores creates the SQLite stores for workspaces
+func NewSQLiteStores(conn db.SQLQuerier) Stores {
+	return Stores{
+		Workspaces: db.NewSQLiteStore[Workspace](conn, constants.TableWorkspace),
+		Members:    &SQLiteMemberStore{db.NewSQLiteStore[Member](conn, constants.TableWorkspaceMember)},
+	}
+}
+
+// SQLiteMemberStore handles all SQLite operations for Member
+type SQLiteMemberStore struct {
+	*db.SQLiteStore[Member]
+}
+
+// GetByUser retrieves the memberships of the user identified by `userID` in every workspace, oldest first
+func (s *SQLiteMemberStore) GetByUser(ctx context.Context, userID string) ([]Member, error) {
+	return s.Select(ctx, queryGetUserMembershipsSQLite, userID)
+}
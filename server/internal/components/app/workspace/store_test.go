@@ -0,0 +1,75 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/db/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) (user.Table, Stores){
+		"sqlite": func(t *testing.T) (user.Table, Stores) {
+			conn := dbtest.OpenSQLite(t)
+			return user.NewSQLiteStore(conn), NewSQLiteStores(conn)
+		},
+		"memory": func(t *testing.T) (user.Table, Stores) {
+			mem := db.NewMemory()
+			return user.NewMemoryStore(mem), NewMemoryStores(mem)
+		},
+	}
+	for name, newStores := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			users, s := newStores(t)
+			alice := &user.User{Username: "alice", Enabled: true}
+			bob := &user.User{Username: "bob", Enabled: true}
+			require.NoError(t, users.Create(ctx, alice))
+			require.NoError(t, users.Create(ctx, bob))
+
+			lab := &Workspace{Name: "lab"}
+			greenhouse := &Workspace{Name: "greenhouse"}
+			require.NoError(t, s.Workspaces.Create(ctx, lab))
+			require.NoError(t, s.Workspaces.Create(ctx, greenhouse))
+
+			inLab := db.WithWorkspace(ctx, lab.ID)
+			inGreenhouse := db.WithWorkspace(ctx, greenhouse.ID)
+			require.NoError(t, s.Members.Create(inLab, &Member{UserID: alice.ID, Role: RoleOwner}))
+			require.NoError(t, s.Members.Create(inLab, &Member{UserID: bob.ID, Role: RoleGuest}))
+			require.NoError(t, s.Members.Create(inGreenhouse, &Member{UserID: alice.ID, Role: RoleOwner}))
+			assert.ErrorIs(t, s.Members.Create(inLab, &Member{UserID: bob.ID, Role: RoleGuest}), db.ErrDuplicate)
+			assert.ErrorIs(t, s.Members.Create(ctx, &Member{UserID: bob.ID, Role: RoleGuest}), db.ErrNoWorkspace)
+
+			members, err := s.Members.GetAll(inGreenhouse)
+			require.NoError(t, err)
+			require.Len(t, members, 1)
+			assert.Equal(t, alice.ID, members[0].UserID)
+			assert.Equal(t, greenhouse.ID, members[0].WorkspaceID)
+
+			memberships, err := s.Members.GetByUser(ctx, alice.ID)
+			require.NoError(t, err)
+			require.Len(t, memberships, 2, "memberships are found whichever workspace the context is scoped to")
+			assert.Equal(t, lab.ID, memberships[0].WorkspaceID)
+			assert.Equal(t, greenhouse.ID, memberships[1].WorkspaceID)
+			assert.Equal(t, RoleOwner, memberships[1].Role)
+
+			memberships[1].Role = RoleBreeder
+			require.NoError(t, s.Members.Update(inGreenhouse, &memberships[1]))
+			m, err := s.Members.GetByID(inGreenhouse, memberships[1].ID)
+			require.NoError(t, err)
+			assert.Equal(t, RoleBreeder, m.Role)
+
+			assert.ErrorIs(t, s.Members.Delete(inGreenhouse, memberships[0].ID), sql.ErrNoRows,
+				"members are only removed from the workspace of the context")
+			require.NoError(t, s.Members.Delete(inLab, memberships[0].ID))
+			memberships, err = s.Members.GetByUser(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Len(t, memberships, 1)
+		})
+	}
+}
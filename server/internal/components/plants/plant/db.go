@@ -5,151 +5,137 @@ import (
 	"database/sql"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
 )
 
-// SQL queries for the plant table.
+// SQL queries for the plant table beyond those db.Store generates.
 // Using constants for table names and queries keeps them organized and easy to modify.
 const (
 	tablePlant = constants.SchemaMendelCore + ".plant"
 
-	queryCreatePlant = `
-		INSERT INTO ` + tablePlant + ` (cultivar_id, species_id, seed_id, pollen_id, generation, created_at, updated_at, genetics, labels)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id, created_at`
-
-	queryListPlants = `
-		SELECT id, cultivar_id, species_id, seed_id, pollen_id, generation, created_at, updated_at, genetics, labels
-		FROM ` + tablePlant
-
-	queryGetPlantByID = `
-		SELECT id, cultivar_id, species_id, seed_id, pollen_id, generation, created_at, updated_at, genetics, labels
-		FROM ` + tablePlant + ` WHERE id = $1`
-
-	queryUpdatePlant = `
-		UPDATE ` + tablePlant + `
-		SET cultivar_id = $2, species_id = $3, seed_id = $4, pollen_id = $5, generation = $6, created_at = $7, updated_at = $8, genetics = $9, labels = $10
-		WHERE id = $1
-		RETURNING id, cultivar_id, species_id, seed_id, pollen_id, generation, created_at, updated_at, genetics, labels`
-
-	queryDeletePlant = `DELETE FROM ` + tablePlant + ` WHERE id = $1`
+	columnsPlant = `id, workspace_id, cultivar_id, species_id, seed_id, pollen_id, generation, created_at, updated_at, genetics, labels, deleted_at`
+
+	// queryGetPlantLineage walks seed and pollen parents up from $1 within workspace $2, including deleted ancestors
+	queryGetPlantLineage = `
+		WITH RECURSIVE lineage AS (
+			SELECT ` + columnsPlant + ` FROM ` + tablePlant + ` WHERE id = $1 AND workspace_id = $2
+			UNION
+			SELECT p.id, p.workspace_id, p.cultivar_id, p.species_id, p.seed_id, p.pollen_id, p.generation, p.created_at, p.updated_at, p.genetics, p.labels, p.deleted_at
+			FROM ` + tablePlant + ` p
+			JOIN lineage l ON p.id = l.seed_id OR p.id = l.pollen_id
+			WHERE p.workspace_id = $2
+		)
+		SELECT ` + columnsPlant + ` FROM lineage ORDER BY generation DESC`
+
+	// queryImpactPlant lists the plant itself and the live plants bred from it, in workspace $2
+	queryImpactPlant = `
+		SELECT 'plant', p.id::text, 'removed' FROM ` + tablePlant + ` p
+		WHERE p.id = $1 AND p.workspace_id = $2 AND p.deleted_at IS NULL
+		UNION ALL
+		SELECT 'plant', c.id::text, 'orphaned' FROM ` + tablePlant + ` c
+		WHERE (c.seed_id = $1 OR c.pollen_id = $1) AND c.workspace_id = $2 AND c.deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM ` + tablePlant + ` p WHERE p.id = $1 AND p.deleted_at IS NULL)`
+
+	// queryRestorePlant only restores a plant whose cultivar and species are not in the trash
+	queryRestorePlant = `
+		WITH restored AS (
+			UPDATE ` + tablePlant + ` p SET deleted_at = NULL
+			WHERE p.id = $1 AND p.workspace_id = $2 AND p.deleted_at IS NOT NULL
+				AND NOT EXISTS (
+					SELECT 1 FROM ` + constants.SchemaMendelCore + `.plant_cultivar pc
+					WHERE pc.id = p.cultivar_id AND pc.deleted_at IS NOT NULL
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM ` + constants.SchemaMendelCore + `.plant_species ps
+					WHERE ps.id = p.species_id AND ps.deleted_at IS NOT NULL
+				)
+			RETURNING 1
+		)
+		SELECT count(*) FROM restored`
+
+	// queryPurgePlants skips plants that are still a seed or pollen parent so lineages stay intact
+	queryPurgePlants = `
+		DELETE FROM ` + tablePlant + ` p
+		WHERE p.deleted_at < $1
+			AND NOT EXISTS (
+				SELECT 1 FROM ` + tablePlant + ` c
+				WHERE c.seed_id = p.id OR c.pollen_id = p.id
+			)`
 )
 
+// queryGetPlantLineageAsOf walks seed and pollen parents up from $1 within workspace $3, as the plants were at $2
+// in the versions query given, including ancestors deleted by then
+func queryGetPlantLineageAsOf(versions string) string {
+	return `
+		WITH RECURSIVE plants AS (` + versions + `), lineage AS (
+			SELECT ` + columnsPlant + ` FROM plants WHERE id = $1 AND workspace_id = $3
+			UNION
+			SELECT p.id, p.workspace_id, p.cultivar_id, p.species_id, p.seed_id, p.pollen_id, p.generation, p.created_at, p.updated_at, p.genetics, p.labels, p.deleted_at
+			FROM plants p
+			JOIN lineage l ON p.id = l.seed_id OR p.id = l.pollen_id
+			WHERE p.workspace_id = $3
+		)
+		SELECT ` + columnsPlant + ` FROM lineage ORDER BY generation DESC`
+}
+
 // Store handles all database operations for the Plant entity.
-// It uses a pgxpool for connection management.
+// Deleting a plant only moves it to the trash; its descendants keep pointing at it
+// so their lineage stays intact.
 type Store struct {
-	Conn *pgxpool.Pool
+	*db.HistoryStore[Plant]
 }
 
 // NewStore creates a new Plant Store.
-func NewStore(pool *pgxpool.Pool) *Store {
-	return &Store{Conn: pool}
+func NewStore(conn db.Querier) *Store {
+	s := &Store{db.NewHistoryStore[Plant](conn, tablePlant)}
+	s.Queries.Restore = queryRestorePlant
+	s.Queries.Purge = queryPurgePlants
+	return s
 }
 
-// List retrieves all plants from the database.
-func (s *Store) GetAll(ctx context.Context) ([]Plant, error) {
-	rows, err := s.Conn.Query(ctx, queryListPlants)
+// GetLineage retrieves a plant and every seed and pollen ancestor, including deleted ones.
+func (s *Store) GetLineage(ctx context.Context, id string) ([]Plant, error) {
+	args, err := s.Scope(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var plants []Plant
-	for rows.Next() {
-		var p Plant
-		if err := rows.Scan(
-			&p.ID,
-			&p.CultivarID,
-			&p.SpeciesID,
-			&p.SeedID,
-			&p.PollenID,
-			&p.Generation,
-			&p.CreatedAt,
-			&p.UpdatedAt,
-			&p.Genetics,
-			&p.Labels,
-		); err != nil {
-			return nil, err
-		}
-		plants = append(plants, p)
-	}
-
-	if err = rows.Err(); err != nil {
+	plants, err := s.Select(ctx, queryGetPlantLineage, args...)
+	if err != nil {
 		return nil, err
 	}
-
+	if len(plants) == 0 {
+		return nil, sql.ErrNoRows
+	}
 	return plants, nil
 }
 
-// GetByID retrieves a single plant by its ID.
-func (s *Store) GetByID(ctx context.Context, id string) (Plant, error) {
-	var p Plant
-	err := s.Conn.QueryRow(ctx, queryGetPlantByID, id).Scan(
-		&p.ID,
-		&p.CultivarID,
-		&p.SpeciesID,
-		&p.SeedID,
-		&p.PollenID,
-		&p.Generation,
-		&p.CreatedAt,
-		&p.UpdatedAt,
-		&p.Genetics,
-		&p.Labels,
-	)
-	return p, err
-}
-
-// Create inserts a new plant record into the database.
-// It scans the RETURNING values back into the provided struct.
-func (s *Store) Create(ctx context.Context, p *Plant) error {
-	p.CreatedAt = sql.NullTime{Time: time.Now(), Valid: true}
-
-	err := s.Conn.QueryRow(ctx, queryCreatePlant,
-		p.CultivarID,
-		p.SpeciesID,
-		p.SeedID,
-		p.PollenID,
-		p.Generation,
-		p.CreatedAt,
-		p.UpdatedAt,
-		p.Genetics,
-		p.Labels,
-	).Scan(&p.ID, &p.CreatedAt)
-
-	return err
-}
-
-// Update modifies an existing plant record.
-// It scans the full updated record back into the provided struct.
-func (s *Store) Update(ctx context.Context, p *Plant) error {
-	return s.Conn.QueryRow(ctx, queryUpdatePlant,
-		p.ID,
-		p.CultivarID,
-		p.SpeciesID,
-		p.SeedID,
-		p.PollenID,
-		p.Generation,
-		p.CreatedAt,
-		p.UpdatedAt,
-		p.Genetics,
-		p.Labels,
-	).Scan(
-		&p.ID,
-		&p.CultivarID,
-		&p.SpeciesID,
-		&p.SeedID,
-		&p.PollenID,
-		&p.Generation,
-		&p.CreatedAt,
-		&p.UpdatedAt,
-		&p.Genetics,
-		&p.Labels,
-	)
+// GetImpact lists the records deleting a plant would affect.
+func (s *Store) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		return db.Impact{}, err
+	}
+	rows, err := s.Querier(ctx).Query(ctx, queryImpactPlant, args...)
+	if err != nil {
+		return db.Impact{}, err
+	}
+	return db.CollectImpact(rows)
 }
 
-// Delete removes a plant record from the database by its ID.
-func (s *Store) Delete(ctx context.Context, id string) error {
-	_, err := s.Conn.Exec(ctx, queryDeletePlant, id)
-	return err
+// GetLineageAsOf retrieves a plant and every seed and pollen ancestor as they were at `at`,
+// including ones deleted by then.
+func (s *Store) GetLineageAsOf(ctx context.Context, id string, at time.Time) ([]Plant, error) {
+	args, err := s.Scope(ctx, id, at)
+	if err != nil {
+		return nil, err
+	}
+	plants, err := s.Select(ctx, queryGetPlantLineageAsOf(s.Versions("$2")), args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(plants) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return plants, nil
 }
@@ -0,0 +1,164 @@
+package plant
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// MemoryStore handles all in-memory operations for the Plant entity, with the semantics of Store:
+// deleting a plant only moves it to the trash, a plant whose cultivar or species is in the trash
+// cannot be restored and a plant that is still a seed or pollen parent is never purged.
+type MemoryStore struct {
+	*db.MemoryHistoryStore[Plant]
+	mem *db.Memory
+}
+
+// NewMemoryStore creates a new Plant MemoryStore in mem.
+func NewMemoryStore(mem *db.Memory) *MemoryStore {
+	return &MemoryStore{db.NewMemoryHistoryStore[Plant](mem, constants.TablePlant), mem}
+}
+
+// Restore moves the plant identified by `id` out of the trash.
+// It returns db.ErrParentDeleted if its cultivar or species is in the trash.
+func (s *MemoryStore) Restore(ctx context.Context, id string) error {
+	return s.mem.InTx(ctx, func(ctx context.Context) error {
+		p, err := s.Trashed(ctx, id)
+		if err != nil {
+			return err
+		}
+		if s.mem.InTrash(constants.TablePlantCultivar, p.CultivarID) || s.mem.InTrash(constants.TablePlantSpecies, p.SpeciesID) {
+			return db.ErrParentDeleted
+		}
+		return s.MemoryHistoryStore.Restore(ctx, id)
+	})
+}
+
+// Trashed retrieves the plant identified by `id` if it is in the trash
+func (s *MemoryStore) Trashed(ctx context.Context, id string) (Plant, error) {
+	trash, err := s.GetTrash(ctx)
+	if err != nil {
+		return Plant{}, err
+	}
+	for _, p := range trash {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return Plant{}, sql.ErrNoRows
+}
+
+// Purge permanently removes plants deleted before `before`, skipping plants that are still a
+// seed or pollen parent so lineages stay intact.
+func (s *MemoryStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := s.mem.InTx(ctx, func(ctx context.Context) error {
+		parents := map[string]bool{}
+		err := s.Dump(ctx, func(p Plant) error {
+			for _, id := range []*string{p.SeedID, p.PollenID} {
+				if id != nil {
+					parents[*id] = true
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		n, err = s.PurgeWhere(ctx, before, func(p Plant) bool { return !parents[p.ID] })
+		return err
+	})
+	return n, err
+}
+
+// GetImpact lists the records deleting a plant would affect.
+func (s *MemoryStore) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	impact := db.Impact{Removed: map[string][]string{}, Orphaned: map[string][]string{}}
+	if err := s.Impact(ctx, impact, func(p Plant) bool { return p.ID == id }); err != nil {
+		return db.Impact{}, err
+	}
+	if impact.Total() == 0 {
+		return db.Impact{}, sql.ErrNoRows
+	}
+	return impact, nil
+}
+
+// Impact adds to impact the live plants of the workspace removed deletes, as removed, and the
+// live plants left bred from them, as orphaned.
+func (s *MemoryStore) Impact(ctx context.Context, impact db.Impact, removed func(p Plant) bool) error {
+	plants, err := s.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	gone := map[string]bool{}
+	for _, p := range plants {
+		if removed(p) {
+			gone[p.ID] = true
+			impact.Removed[constants.TablePlant] = append(impact.Removed[constants.TablePlant], p.ID)
+		}
+	}
+	for _, p := range plants {
+		if !gone[p.ID] && (p.SeedID != nil && gone[*p.SeedID] || p.PollenID != nil && gone[*p.PollenID]) {
+			impact.Orphaned[constants.TablePlant] = append(impact.Orphaned[constants.TablePlant], p.ID)
+		}
+	}
+	return nil
+}
+
+// GetLineage retrieves a plant and every seed and pollen ancestor, including deleted ones.
+func (s *MemoryStore) GetLineage(ctx context.Context, id string) ([]Plant, error) {
+	live, err := s.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trash, err := s.GetTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lineage(append(live, trash...), id)
+}
+
+// GetLineageAsOf retrieves a plant and every seed and pollen ancestor as they were at `at`,
+// including ones deleted by then.
+func (s *MemoryStore) GetLineageAsOf(ctx context.Context, id string, at time.Time) ([]Plant, error) {
+	plants, err := s.GetAllAsOfWithTrash(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	return lineage(plants, id)
+}
+
+// lineage returns the plant identified by `id` among plants and every seed and pollen ancestor
+// of it there, latest generation first
+func lineage(plants []Plant, id string) ([]Plant, error) {
+	byID := make(map[string]Plant, len(plants))
+	for _, p := range plants {
+		byID[p.ID] = p
+	}
+	var found []Plant
+	seen := map[string]bool{}
+	next := []string{id}
+	for len(next) > 0 {
+		p, ok := byID[next[0]]
+		next = next[1:]
+		if !ok || seen[p.ID] {
+			continue
+		}
+		seen[p.ID] = true
+		found = append(found, p)
+		for _, parent := range []*string{p.SeedID, p.PollenID} {
+			if parent != nil {
+				next = append(next, *parent)
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Generation > found[j].Generation })
+	return found, nil
+}
@@ -0,0 +1,53 @@
+package plant
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kylep342/mendel/internal/db"
+)
+
+func TestMemoryStore_Lineage(t *testing.T) {
+	ctx := db.WithWorkspace(context.Background(), "00000000-0000-0000-0000-000000000001")
+	s := NewMemoryStore(db.NewMemory())
+	cultivar, species := "00000000-0000-0000-0000-0000000000c1", "00000000-0000-0000-0000-0000000000a1"
+
+	seed := &Plant{CultivarID: cultivar, SpeciesID: species}
+	pollen := &Plant{CultivarID: cultivar, SpeciesID: species}
+	require.NoError(t, s.Create(ctx, seed))
+	require.NoError(t, s.Create(ctx, pollen))
+	child := &Plant{CultivarID: cultivar, SpeciesID: species, SeedID: &seed.ID, PollenID: &pollen.ID, Generation: 1}
+	require.NoError(t, s.Create(ctx, child))
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+
+	impact, err := s.GetImpact(ctx, seed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"plant": {child.ID}}, impact.Orphaned)
+
+	require.NoError(t, s.Delete(ctx, seed.ID))
+	lineage, err := s.GetLineage(ctx, child.ID)
+	require.NoError(t, err)
+	require.Len(t, lineage, 3, "deleted ancestors are part of a lineage")
+	assert.Equal(t, child.ID, lineage[0].ID)
+
+	n, err := s.Purge(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, n, "seed and pollen parents are kept")
+
+	require.NoError(t, s.Delete(ctx, child.ID))
+	lineage, err = s.GetLineageAsOf(ctx, child.ID, before)
+	require.NoError(t, err)
+	assert.Len(t, lineage, 3)
+	for _, p := range lineage {
+		assert.Nil(t, p.DeletedAt, "the lineage is as it was then")
+	}
+
+	_, err = s.GetLineage(ctx, "00000000-0000-0000-0000-0000000000ff")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -2,21 +2,39 @@ package plant
 
 import (
 	"database/sql"
+	"time"
+
+	"github.com/kylep342/mendel/internal/components"
 )
 
 type Plant struct {
-	ID         string       `db:"id" json:"id"`
-	CultivarID string       `db:"cultivar_id" json:"cultivar_id"`
-	SpeciesID  string       `db:"species_id" json:"species_id"`
-	SeedID     string       `db:"seed_id" json:"seed_id"`
-	PollenID   string       `db:"pollen_id" json:"pollen_id"`
-	Generation uint32       `db:"generation" json:"generation"`
-	CreatedAt  sql.NullTime `db:"created_at" json:"created_at"`
-	UpdatedAt  sql.NullTime `db:"updated_at" json:"updated_at"`
-	Genetics   interface{}  `db:"genetics" json:"genetics"`
-	Labels     interface{}  `db:"labels" json:"labels"`
+	ID          string       `db:"id" json:"id"`
+	WorkspaceID string       `db:"workspace_id" json:"workspace_id"`
+	CultivarID  string       `db:"cultivar_id" json:"cultivar_id" validate:"required,uuid"`
+	SpeciesID   string       `db:"species_id" json:"species_id" validate:"required,uuid"`
+	SeedID      *string      `db:"seed_id" json:"seed_id" validate:"omitempty,uuid"`
+	PollenID    *string      `db:"pollen_id" json:"pollen_id" validate:"omitempty,uuid"`
+	Generation  uint32       `db:"generation" json:"generation"`
+	CreatedAt   sql.NullTime `db:"created_at" json:"created_at"`
+	UpdatedAt   sql.NullTime `db:"updated_at" json:"updated_at"`
+	Genetics    interface{}  `db:"genetics" json:"genetics"`
+	Labels      interface{}  `db:"labels" json:"labels"`
+	DeletedAt   *time.Time   `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
 func (p *Plant) GetID() string { return p.ID }
 
 func (p *Plant) SetID(id string) { p.ID = id }
+
+func (p *Plant) Validate() []components.FieldError {
+	var errs []components.FieldError
+	if p.ID != "" && p.SeedID != nil && *p.SeedID == p.ID {
+		errs = append(errs, components.FieldError{Field: "seed_id", Message: "a plant cannot be its own seed parent"})
+	}
+	if p.ID != "" && p.PollenID != nil && *p.PollenID == p.ID {
+		errs = append(errs, components.FieldError{Field: "pollen_id", Message: "a plant cannot be its own pollen parent"})
+	}
+	errs = append(errs, components.RequireObject("genetics", p.Genetics)...)
+	errs = append(errs, components.RequireObject("labels", p.Labels)...)
+	return errs
+}
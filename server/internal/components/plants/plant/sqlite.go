@@ -0,0 +1,130 @@
+package plant
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// SQLite has no schemas, so tables are named without mendel_core
+const (
+	// queryGetPlantLineageSQLite is queryGetPlantLineage for SQLite
+	queryGetPlantLineageSQLite = `
+		WITH RECURSIVE lineage AS (
+			SELECT ` + columnsPlant + ` FROM ` + constants.TablePlant + ` WHERE id = ?1 AND workspace_id = ?2
+			UNION
+			SELECT p.id, p.workspace_id, p.cultivar_id, p.species_id, p.seed_id, p.pollen_id, p.generation, p.created_at, p.updated_at, p.genetics, p.labels, p.deleted_at
+			FROM ` + constants.TablePlant + ` p
+			JOIN lineage l ON p.id = l.seed_id OR p.id = l.pollen_id
+			WHERE p.workspace_id = ?2
+		)
+		SELECT ` + columnsPlant + ` FROM lineage ORDER BY generation DESC`
+
+	// queryImpactPlantSQLite is queryImpactPlant for SQLite
+	queryImpactPlantSQLite = `
+		SELECT 'plant', p.id, 'removed' FROM ` + constants.TablePlant + ` p
+		WHERE p.id = ?1 AND p.workspace_id = ?2 AND p.deleted_at IS NULL
+		UNION ALL
+		SELECT 'plant', c.id, 'orphaned' FROM ` + constants.TablePlant + ` c
+		WHERE (c.seed_id = ?1 OR c.pollen_id = ?1) AND c.workspace_id = ?2 AND c.deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM ` + constants.TablePlant + ` p WHERE p.id = ?1 AND p.deleted_at IS NULL)`
+
+	// queryRestorePlantSQLite only restores a plant whose cultivar and species are not in the trash
+	queryRestorePlantSQLite = `
+		UPDATE ` + constants.TablePlant + ` SET deleted_at = NULL
+		WHERE id = ?1 AND workspace_id = ?2 AND deleted_at IS NOT NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM ` + constants.TablePlantCultivar + ` pc
+				WHERE pc.id = ` + constants.TablePlant + `.cultivar_id AND pc.deleted_at IS NOT NULL
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM ` + constants.TablePlantSpecies + ` ps
+				WHERE ps.id = ` + constants.TablePlant + `.species_id AND ps.deleted_at IS NOT NULL
+			)`
+
+	// queryPurgePlantsSQLite skips plants that are still a seed or pollen parent so lineages stay intact
+	queryPurgePlantsSQLite = `
+		DELETE FROM ` + constants.TablePlant + `
+		WHERE deleted_at < ?1
+			AND NOT EXISTS (
+				SELECT 1 FROM ` + constants.TablePlant + ` c
+				WHERE c.seed_id = ` + constants.TablePlant + `.id OR c.pollen_id = ` + constants.TablePlant + `.id
+			)`
+)
+
+// queryGetPlantLineageAsOfSQLite is queryGetPlantLineageAsOf for SQLite
+func queryGetPlantLineageAsOfSQLite(versions string) string {
+	return `
+		WITH RECURSIVE plants AS (` + versions + `), lineage AS (
+			SELECT ` + columnsPlant + ` FROM plants WHERE id = ?1 AND workspace_id = ?3
+			UNION
+			SELECT p.id, p.workspace_id, p.cultivar_id, p.species_id, p.seed_id, p.pollen_id, p.generation, p.created_at, p.updated_at, p.genetics, p.labels, p.deleted_at
+			FROM plants p
+			JOIN lineage l ON p.id = l.seed_id OR p.id = l.pollen_id
+			WHERE p.workspace_id = ?3
+		)
+		SELECT ` + columnsPlant + ` FROM lineage ORDER BY generation DESC`
+}
+
+// SQLiteStore handles all SQLite operations for the Plant entity.
+// As with Store, deleting a plant only moves it to the trash.
+type SQLiteStore struct {
+	*db.SQLiteHistoryStore[Plant]
+}
+
+// NewSQLiteStore creates a new Plant SQLiteStore.
+func NewSQLiteStore(conn db.SQLQuerier) *SQLiteStore {
+	s := &SQLiteStore{db.NewSQLiteHistoryStore[Plant](conn, constants.TablePlant)}
+	s.Queries.Restore = queryRestorePlantSQLite
+	s.Queries.Purge = queryPurgePlantsSQLite
+	return s
+}
+
+// GetLineage retrieves a plant and every seed and pollen ancestor, including deleted ones.
+func (s *SQLiteStore) GetLineage(ctx context.Context, id string) ([]Plant, error) {
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	plants, err := s.Select(ctx, queryGetPlantLineageSQLite, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(plants) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return plants, nil
+}
+
+// GetImpact lists the records deleting a plant would affect.
+func (s *SQLiteStore) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		return db.Impact{}, err
+	}
+	rows, err := s.Querier(ctx).QueryContext(ctx, queryImpactPlantSQLite, args...)
+	if err != nil {
+		return db.Impact{}, err
+	}
+	return db.CollectSQLImpact(rows)
+}
+
+// GetLineageAsOf retrieves a plant and every seed and pollen ancestor as they were at `at`,
+// including ones deleted by then.
+func (s *SQLiteStore) GetLineageAsOf(ctx context.Context, id string, at time.Time) ([]Plant, error) {
+	args, err := s.Scope(ctx, id, at)
+	if err != nil {
+		return nil, err
+	}
+	plants, err := s.Select(ctx, queryGetPlantLineageAsOfSQLite(s.Versions("?2")), args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(plants) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return plants, nil
+}
@@ -3,118 +3,96 @@ package plant_cultivar
 import (
 	"context"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
 )
 
 const (
 	// PlantCultivarTableName is the name of the table in the database
 	tablePlantCultivar = constants.SchemaMendelCore + ".plant_cultivar"
 
-	// queryCreatePlantCultivar is the query template literal to create a new plant cultivar
-	queryCreatePlantCultivar = `
-		INSERT INTO ` + tablePlantCultivar + `
-		(species_id, name, cultivar, genetics)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, updated_at
+	// queryDeletePlantCultivar is the query template literal to move a plant cultivar of workspace $2 and
+	// its plants to the trash
+	queryDeletePlantCultivar = `
+		WITH cultivar AS (
+			UPDATE ` + tablePlantCultivar + ` SET deleted_at = NOW()
+			WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NULL
+			RETURNING id, deleted_at
+		), plants AS (
+			UPDATE ` + constants.SchemaMendelCore + `.plant p SET deleted_at = cultivar.deleted_at
+			FROM cultivar
+			WHERE p.cultivar_id = cultivar.id AND p.deleted_at IS NULL
+		)
+		SELECT count(*) FROM cultivar
 	`
-	// queryGetAllPlantCultivars is the query template literal to get all plant cultivars
-	queryGetAllPlantCultivars = `
-		SELECT
-			id
-			, species_id
-			, name
-			, cultivar
-			, created_at
-			, updated_at
-			, genetics
-		FROM ` + tablePlantCultivar
-
-	// queryGetPlantCultivarByID is the query template literal to get a plant cultivar by ID
-	queryGetPlantCultivarByID = `
-		SELECT
-			id
-			, species_id
-			, name
-			, cultivar
-			, created_at
-			, updated_at
-			, genetics
-		FROM ` + tablePlantCultivar + ` WHERE id = $1
+	// queryRestorePlantCultivar is the query template literal to restore a plant cultivar, and the plants
+	// deleted with it, as long as its species is not in the trash
+	queryRestorePlantCultivar = `
+		WITH target AS (
+			SELECT pc.id, pc.deleted_at FROM ` + tablePlantCultivar + ` pc
+			JOIN ` + constants.SchemaMendelCore + `.plant_species ps ON ps.id = pc.species_id
+			WHERE pc.id = $1 AND pc.workspace_id = $2 AND pc.deleted_at IS NOT NULL AND ps.deleted_at IS NULL
+		), cultivar AS (
+			UPDATE ` + tablePlantCultivar + ` pc SET deleted_at = NULL
+			FROM target
+			WHERE pc.id = target.id
+			RETURNING pc.id
+		), plants AS (
+			UPDATE ` + constants.SchemaMendelCore + `.plant p SET deleted_at = NULL
+			FROM target
+			WHERE p.cultivar_id = target.id AND p.deleted_at = target.deleted_at
+		)
+		SELECT count(*) FROM cultivar
+	`
+	// queryImpactPlantCultivar is the query template literal to list what deleting a plant cultivar affects:
+	// the cultivar, its plants, and plants of other cultivars bred from those plants, all in workspace $2
+	queryImpactPlantCultivar = `
+		SELECT 'plant_cultivar', pc.id::text, 'removed' FROM ` + tablePlantCultivar + ` pc
+		WHERE pc.id = $1 AND pc.workspace_id = $2 AND pc.deleted_at IS NULL
+		UNION ALL
+		SELECT 'plant', p.id::text, 'removed' FROM ` + constants.SchemaMendelCore + `.plant p
+		WHERE p.cultivar_id = $1 AND p.workspace_id = $2 AND p.deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM ` + tablePlantCultivar + ` pc WHERE pc.id = $1 AND pc.deleted_at IS NULL)
+		UNION ALL
+		SELECT 'plant', c.id::text, 'orphaned' FROM ` + constants.SchemaMendelCore + `.plant c
+		WHERE c.cultivar_id <> $1 AND c.workspace_id = $2 AND c.deleted_at IS NULL
+			AND EXISTS (
+				SELECT 1 FROM ` + constants.SchemaMendelCore + `.plant p
+				WHERE p.cultivar_id = $1 AND p.deleted_at IS NULL AND (c.seed_id = p.id OR c.pollen_id = p.id)
+			)
 	`
-	// queryUpdatePlantCultivar is the query template literal to update a plant cultivar
-	queryUpdatePlantCultivar = `
-		UPDATE ` + tablePlantCultivar + `
-		SET
-			species_id = $2
-			, name = $3
-			, cultivar = $4
-			, genetics = $5
-		WHERE
-			id = $1
-		RETURNING
-			id
-			, species_id
-			, name
-			, cultivar
-			, created_at
-			, updated_at
-			, genetics
+	// queryPurgePlantCultivars is the query template literal to permanently remove plant cultivars
+	// deleted before $1 that no plant refers to anymore
+	queryPurgePlantCultivars = `
+		DELETE FROM ` + tablePlantCultivar + ` pc
+		WHERE pc.deleted_at < $1
+			AND NOT EXISTS (SELECT 1 FROM ` + constants.SchemaMendelCore + `.plant p WHERE p.cultivar_id = pc.id)
 	`
-	// queryDeletePlantCultivar is the query template literal to delete a plant cultivar
-	queryDeletePlantCultivar = `DELETE FROM ` + tablePlantCultivar + ` WHERE id = $1`
 )
 
+// Store handles all database operations for PlantCultivar
 type Store struct {
-	Conn *pgxpool.Pool
-}
-
-func NewStore(pool *pgxpool.Pool) *Store {
-	return &Store{Conn: pool}
+	*db.HistoryStore[PlantCultivar]
 }
 
-// Create inserts a new plant cultivar into the database
-func (s *Store) Create(ctx context.Context, pc *PlantCultivar) error {
-	err := s.Conn.QueryRow(ctx, queryCreatePlantCultivar, pc.SpeciesID, pc.Name, pc.Cultivar, pc.Genetics).Scan(&pc.ID, &pc.CreatedAt, &pc.UpdatedAt)
-	return err
+// NewStore creates a plant cultivar Store whose deletes cascade to plants
+func NewStore(conn db.Querier) *Store {
+	s := &Store{db.NewHistoryStore[PlantCultivar](conn, tablePlantCultivar)}
+	s.Queries.Delete = queryDeletePlantCultivar
+	s.Queries.Restore = queryRestorePlantCultivar
+	s.Queries.Purge = queryPurgePlantCultivars
+	return s
 }
 
-// GetAll retrieves all plant cultivars from the database
-func (s *Store) GetAll(ctx context.Context) ([]PlantCultivar, error) {
-	rows, err := s.Conn.Query(ctx, queryGetAllPlantCultivars)
+// GetImpact lists the records deleting a plant cultivar would affect
+func (s *Store) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	args, err := s.Scope(ctx, id)
 	if err != nil {
-		return nil, err
+		return db.Impact{}, err
 	}
-	defer rows.Close()
-
-	var Cultivars []PlantCultivar
-	for rows.Next() {
-		var pc PlantCultivar
-		if err := rows.Scan(&pc.ID, &pc.SpeciesID, &pc.Name, &pc.Cultivar, &pc.CreatedAt, &pc.UpdatedAt, &pc.Genetics); err != nil {
-			return nil, err
-		}
-		Cultivars = append(Cultivars, pc)
+	rows, err := s.Querier(ctx).Query(ctx, queryImpactPlantCultivar, args...)
+	if err != nil {
+		return db.Impact{}, err
 	}
-	return Cultivars, nil
-}
-
-// GetByID retrieves a plant cultivar identified by arg `id` from the database
-func (s *Store) GetByID(ctx context.Context, id string) (PlantCultivar, error) {
-	var pc PlantCultivar
-	err := s.Conn.QueryRow(ctx, queryGetPlantCultivarByID, id).Scan(&pc.ID, &pc.SpeciesID, &pc.Name, &pc.Cultivar, &pc.CreatedAt, &pc.UpdatedAt, &pc.Genetics)
-	return pc, err
-}
-
-// Update modifies an existing plant cultivar in the database
-func (s *Store) Update(ctx context.Context, pc *PlantCultivar) error {
-	err := s.Conn.QueryRow(ctx, queryUpdatePlantCultivar, pc.ID, pc.SpeciesID, pc.Name, pc.Cultivar, pc.Genetics).Scan(
-		&pc.ID, &pc.SpeciesID, &pc.Name, &pc.Cultivar, &pc.CreatedAt, &pc.UpdatedAt, &pc.Genetics,
-	)
-	return err
-}
-
-// Delete removes a plant cultivar from the database
-func (s *Store) Delete(ctx context.Context, id string) error {
-	_, err := s.Conn.Exec(ctx, queryDeletePlantCultivar, id)
-	return err
+	return db.CollectImpact(rows)
 }
@@ -0,0 +1,98 @@
+package plant_cultivar
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kylep342/mendel/internal/components/plants/plant"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// MemoryStore handles all in-memory operations for PlantCultivar, with the semantics of Store:
+// deletes and restores cascade to plants, a cultivar whose species is in the trash cannot be
+// restored and a cultivar plants still refer to is never purged
+type MemoryStore struct {
+	*db.MemoryHistoryStore[PlantCultivar]
+	mem    *db.Memory
+	plants *plant.MemoryStore
+}
+
+// NewMemoryStore creates a plant cultivar MemoryStore in mem whose deletes cascade to plants
+func NewMemoryStore(mem *db.Memory) *MemoryStore {
+	return &MemoryStore{db.NewMemoryHistoryStore[PlantCultivar](mem, constants.TablePlantCultivar), mem, plant.NewMemoryStore(mem)}
+}
+
+// Delete moves the plant cultivar identified by `id` and its plants to the trash
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	return s.mem.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.GetByID(ctx, id); err != nil {
+			return err
+		}
+		at := time.Now()
+		if err := s.TrashWhere(ctx, at, func(pc PlantCultivar) bool { return pc.ID == id }); err != nil {
+			return err
+		}
+		return s.plants.TrashWhere(ctx, at, func(p plant.Plant) bool { return p.CultivarID == id })
+	})
+}
+
+// Restore moves the plant cultivar identified by `id` out of the trash, along with the plants
+// deleted with it. It returns db.ErrParentDeleted if its species is in the trash.
+func (s *MemoryStore) Restore(ctx context.Context, id string) error {
+	return s.mem.InTx(ctx, func(ctx context.Context) error {
+		trash, err := s.GetTrash(ctx)
+		if err != nil {
+			return err
+		}
+		for _, pc := range trash {
+			if pc.ID != id {
+				continue
+			}
+			if s.mem.InTrash(constants.TablePlantSpecies, pc.SpeciesID) {
+				return db.ErrParentDeleted
+			}
+			if err := s.MemoryHistoryStore.Restore(ctx, id); err != nil {
+				return err
+			}
+			return s.plants.RestoreWhere(ctx, *pc.DeletedAt, func(p plant.Plant) bool { return p.CultivarID == id })
+		}
+		return sql.ErrNoRows
+	})
+}
+
+// Purge permanently removes plant cultivars deleted before `before` that no plant refers to
+func (s *MemoryStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := s.mem.InTx(ctx, func(ctx context.Context) error {
+		referred := map[string]bool{}
+		err := s.plants.Dump(ctx, func(p plant.Plant) error {
+			referred[p.CultivarID] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		n, err = s.PurgeWhere(ctx, before, func(pc PlantCultivar) bool { return !referred[pc.ID] })
+		return err
+	})
+	return n, err
+}
+
+// GetImpact lists the records deleting a plant cultivar would affect
+func (s *MemoryStore) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	impact := db.Impact{Removed: map[string][]string{}, Orphaned: map[string][]string{}}
+	if _, err := s.GetByID(ctx, id); err == nil {
+		impact.Removed[constants.TablePlantCultivar] = []string{id}
+		if err := s.plants.Impact(ctx, impact, func(p plant.Plant) bool { return p.CultivarID == id }); err != nil {
+			return db.Impact{}, err
+		}
+	} else if err != sql.ErrNoRows {
+		return db.Impact{}, err
+	}
+	if impact.Total() == 0 {
+		return db.Impact{}, sql.ErrNoRows
+	}
+	return impact, nil
+}
@@ -2,18 +2,26 @@ package plant_cultivar
 
 import (
 	"time"
+
+	"github.com/kylep342/mendel/internal/components"
 )
 
 type PlantCultivar struct {
-	ID        string      `db:"id" json:"id"`
-	SpeciesID string      `db:"species_id" json:"species_id"`
-	Name      string      `db:"name" json:"name"`
-	Cultivar  string      `db:"cultivar" json:"cultivar"`
-	CreatedAt time.Time   `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time   `db:"updated_at" json:"updated_at"`
-	Genetics  interface{} `db:"genetics" json:"genetics"`
+	ID          string      `db:"id" json:"id"`
+	WorkspaceID string      `db:"workspace_id" json:"workspace_id"`
+	SpeciesID   string      `db:"species_id" json:"species_id" validate:"required,uuid"`
+	Name        string      `db:"name" json:"name" validate:"required,max=255"`
+	Cultivar    string      `db:"cultivar" json:"cultivar" validate:"required,max=255"`
+	CreatedAt   time.Time   `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time   `db:"updated_at" json:"updated_at"`
+	Genetics    interface{} `db:"genetics" json:"genetics"`
+	DeletedAt   *time.Time  `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
 func (p *PlantCultivar) GetID() string { return p.ID }
 
 func (p *PlantCultivar) SetID(id string) { p.ID = id }
+
+func (p *PlantCultivar) Validate() []components.FieldError {
+	return components.RequireObject("genetics", p.Genetics)
+}
@@ -0,0 +1,70 @@
+package plant_cultivar
+
+import (
+	"context"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// SQLite has no schemas, so tables are named without mendel_core.
+// Deletes and restores cascade to plants through triggers, see the sqlite migrations.
+const (
+	// queryRestorePlantCultivarSQLite only restores a plant cultivar whose species is not in the trash
+	queryRestorePlantCultivarSQLite = `
+		UPDATE ` + constants.TablePlantCultivar + ` SET deleted_at = NULL
+		WHERE id = ?1 AND workspace_id = ?2 AND deleted_at IS NOT NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM ` + constants.TablePlantSpecies + ` ps
+				WHERE ps.id = ` + constants.TablePlantCultivar + `.species_id AND ps.deleted_at IS NOT NULL
+			)
+	`
+	// queryImpactPlantCultivarSQLite is queryImpactPlantCultivar for SQLite
+	queryImpactPlantCultivarSQLite = `
+		SELECT 'plant_cultivar', pc.id, 'removed' FROM ` + constants.TablePlantCultivar + ` pc
+		WHERE pc.id = ?1 AND pc.workspace_id = ?2 AND pc.deleted_at IS NULL
+		UNION ALL
+		SELECT 'plant', p.id, 'removed' FROM ` + constants.TablePlant + ` p
+		WHERE p.cultivar_id = ?1 AND p.workspace_id = ?2 AND p.deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM ` + constants.TablePlantCultivar + ` pc WHERE pc.id = ?1 AND pc.deleted_at IS NULL)
+		UNION ALL
+		SELECT 'plant', c.id, 'orphaned' FROM ` + constants.TablePlant + ` c
+		WHERE c.cultivar_id <> ?1 AND c.workspace_id = ?2 AND c.deleted_at IS NULL
+			AND EXISTS (
+				SELECT 1 FROM ` + constants.TablePlant + ` p
+				WHERE p.cultivar_id = ?1 AND p.deleted_at IS NULL AND (c.seed_id = p.id OR c.pollen_id = p.id)
+			)
+	`
+	// queryPurgePlantCultivarsSQLite is queryPurgePlantCultivars for SQLite
+	queryPurgePlantCultivarsSQLite = `
+		DELETE FROM ` + constants.TablePlantCultivar + `
+		WHERE deleted_at < ?1
+			AND NOT EXISTS (SELECT 1 FROM ` + constants.TablePlant + ` p WHERE p.cultivar_id = ` + constants.TablePlantCultivar + `.id)
+	`
+)
+
+// SQLiteStore handles all SQLite operations for PlantCultivar
+type SQLiteStore struct {
+	*db.SQLiteHistoryStore[PlantCultivar]
+}
+
+// NewSQLiteStore creates a plant cultivar SQLiteStore whose deletes cascade to plants
+func NewSQLiteStore(conn db.SQLQuerier) *SQLiteStore {
+	s := &SQLiteStore{db.NewSQLiteHistoryStore[PlantCultivar](conn, constants.TablePlantCultivar)}
+	s.Queries.Restore = queryRestorePlantCultivarSQLite
+	s.Queries.Purge = queryPurgePlantCultivarsSQLite
+	return s
+}
+
+// GetImpact lists the records deleting a plant cultivar would affect
+func (s *SQLiteStore) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		return db.Impact{}, err
+	}
+	rows, err := s.Querier(ctx).QueryContext(ctx, queryImpactPlantCultivarSQLite, args...)
+	if err != nil {
+		return db.Impact{}, err
+	}
+	return db.CollectSQLImpact(rows)
+}
@@ -3,95 +3,109 @@ package plant_species
 import (
 	"context"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
 )
 
 const (
 	// PlantSpeciesTableName is the name of the plant species table in the database
 	tablePlantSpecies = constants.SchemaMendelCore + ".plant_species"
 
-	// queryCreatePlantSpecies is the query template literal to create a new plant species
-	queryCreatePlantSpecies = `
-		INSERT INTO ` + tablePlantSpecies + `
-		(name, taxon)
-		VALUES ($1, $2)
-		RETURNING id, created_at, updated_at
+	// queryDeletePlantSpecies is the query template literal to move a plant species,
+	// its cultivars and its plants to the trash. Cultivars and plants always share their
+	// species' workspace, so only the species is matched against $2.
+	queryDeletePlantSpecies = `
+		WITH species AS (
+			UPDATE ` + tablePlantSpecies + ` SET deleted_at = NOW()
+			WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NULL
+			RETURNING id, deleted_at
+		), cultivars AS (
+			UPDATE ` + constants.SchemaMendelCore + `.plant_cultivar pc SET deleted_at = species.deleted_at
+			FROM species
+			WHERE pc.species_id = species.id AND pc.deleted_at IS NULL
+		), plants AS (
+			UPDATE ` + constants.SchemaMendelCore + `.plant p SET deleted_at = species.deleted_at
+			FROM species
+			WHERE p.species_id = species.id AND p.deleted_at IS NULL
+		)
+		SELECT count(*) FROM species
 	`
-
-	// queryGetAllPlantSpecies is the query template literal to get all plant species
-	queryGetAllPlantSpecies = `
-		SELECT id, name, taxon, created_at, updated_at
-		FROM ` + tablePlantSpecies
-
-	// queryGetByIDPlantSpecies is the query template literal to get a plant species by ID
-	queryGetByIDPlantSpecies = `
-		SELECT id, name, taxon, created_at, updated_at
-		FROM ` + tablePlantSpecies + ` WHERE id = $1
+	// queryRestorePlantSpecies is the query template literal to restore a plant species along
+	// with the cultivars and plants that were deleted with it
+	queryRestorePlantSpecies = `
+		WITH target AS (
+			SELECT id, deleted_at FROM ` + tablePlantSpecies + `
+			WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NOT NULL
+		), species AS (
+			UPDATE ` + tablePlantSpecies + ` ps SET deleted_at = NULL
+			FROM target
+			WHERE ps.id = target.id
+			RETURNING ps.id
+		), cultivars AS (
+			UPDATE ` + constants.SchemaMendelCore + `.plant_cultivar pc SET deleted_at = NULL
+			FROM target
+			WHERE pc.species_id = target.id AND pc.deleted_at = target.deleted_at
+		), plants AS (
+			UPDATE ` + constants.SchemaMendelCore + `.plant p SET deleted_at = NULL
+			FROM target
+			WHERE p.species_id = target.id AND p.deleted_at = target.deleted_at
+		)
+		SELECT count(*) FROM species
 	`
-
-	// queryUpdatePlantSpecies is the query template literal to update a plant species
-	queryUpdatePlantSpecies = `
-		UPDATE ` + tablePlantSpecies + `
-		SET name = $1, taxon = $2
-		WHERE id = $3
-		RETURNING id, name, taxon, created_at, updated_at
+	// queryImpactPlantSpecies is the query template literal to list what deleting a plant species affects:
+	// the species, its cultivars and plants, and plants elsewhere bred from those plants, all in workspace $2
+	queryImpactPlantSpecies = `
+		SELECT 'plant_species', ps.id::text, 'removed' FROM ` + tablePlantSpecies + ` ps
+		WHERE ps.id = $1 AND ps.workspace_id = $2 AND ps.deleted_at IS NULL
+		UNION ALL
+		SELECT 'plant_cultivar', pc.id::text, 'removed' FROM ` + constants.SchemaMendelCore + `.plant_cultivar pc
+		WHERE pc.species_id = $1 AND pc.workspace_id = $2 AND pc.deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM ` + tablePlantSpecies + ` ps WHERE ps.id = $1 AND ps.deleted_at IS NULL)
+		UNION ALL
+		SELECT 'plant', p.id::text, 'removed' FROM ` + constants.SchemaMendelCore + `.plant p
+		WHERE p.species_id = $1 AND p.workspace_id = $2 AND p.deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM ` + tablePlantSpecies + ` ps WHERE ps.id = $1 AND ps.deleted_at IS NULL)
+		UNION ALL
+		SELECT 'plant', c.id::text, 'orphaned' FROM ` + constants.SchemaMendelCore + `.plant c
+		WHERE c.species_id <> $1 AND c.workspace_id = $2 AND c.deleted_at IS NULL
+			AND EXISTS (
+				SELECT 1 FROM ` + constants.SchemaMendelCore + `.plant p
+				WHERE p.species_id = $1 AND p.deleted_at IS NULL AND (c.seed_id = p.id OR c.pollen_id = p.id)
+			)
+	`
+	// queryPurgePlantSpecies is the query template literal to permanently remove plant species
+	// deleted before $1 that no cultivar or plant refers to anymore
+	queryPurgePlantSpecies = `
+		DELETE FROM ` + tablePlantSpecies + ` ps
+		WHERE ps.deleted_at < $1
+			AND NOT EXISTS (SELECT 1 FROM ` + constants.SchemaMendelCore + `.plant_cultivar pc WHERE pc.species_id = ps.id)
+			AND NOT EXISTS (SELECT 1 FROM ` + constants.SchemaMendelCore + `.plant p WHERE p.species_id = ps.id)
 	`
-	// queryDeletePlantSpecies is the query template literal to delete a plant species
-	queryDeletePlantSpecies = `DELETE FROM ` + tablePlantSpecies + ` WHERE id = $1`
 )
 
+// Store handles all database operations for PlantSpecies
 type Store struct {
-	Conn *pgxpool.Pool
+	*db.HistoryStore[PlantSpecies]
 }
 
-func NewStore(pool *pgxpool.Pool) *Store {
-	return &Store{Conn: pool}
+// NewStore creates a plant species Store whose deletes cascade to cultivars and plants
+func NewStore(conn db.Querier) *Store {
+	s := &Store{db.NewHistoryStore[PlantSpecies](conn, tablePlantSpecies)}
+	s.Queries.Delete = queryDeletePlantSpecies
+	s.Queries.Restore = queryRestorePlantSpecies
+	s.Queries.Purge = queryPurgePlantSpecies
+	return s
 }
 
-// Create inserts a new plant species into the database
-func (s *Store) Create(ctx context.Context, ps *PlantSpecies) error {
-	query := queryCreatePlantSpecies
-	err := s.Conn.QueryRow(ctx, query, ps.Name, ps.Taxon).Scan(&ps.ID, &ps.CreatedAt, &ps.UpdatedAt)
-	return err
-}
-
-// GetAll retrieves all plant species from the database
-func (s *Store) GetAll(ctx context.Context) ([]PlantSpecies, error) {
-	rows, err := s.Conn.Query(ctx, queryGetAllPlantSpecies)
+// GetImpact lists the records deleting the plant species identified by argument `id` would affect
+func (s *Store) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	args, err := s.Scope(ctx, id)
 	if err != nil {
-		return nil, err
+		return db.Impact{}, err
 	}
-	defer rows.Close()
-
-	var Species []PlantSpecies
-	for rows.Next() {
-		var ps PlantSpecies
-		if err := rows.Scan(&ps.ID, &ps.Name, &ps.Taxon, &ps.CreatedAt, &ps.UpdatedAt); err != nil {
-			return nil, err
-		}
-		Species = append(Species, ps)
+	rows, err := s.Querier(ctx).Query(ctx, queryImpactPlantSpecies, args...)
+	if err != nil {
+		return db.Impact{}, err
 	}
-	return Species, nil
-}
-
-// GetByID retrieves a plant species identified by argument `id` from the database
-func (s *Store) GetByID(ctx context.Context, id string) (PlantSpecies, error) {
-	var ps PlantSpecies
-	err := s.Conn.QueryRow(ctx, queryGetByIDPlantSpecies, id).Scan(&ps.ID, &ps.Name, &ps.Taxon, &ps.CreatedAt, &ps.UpdatedAt)
-	return ps, err
-}
-
-// Update updates a plant species identified by argument `id` in the database
-func (s *Store) Update(ctx context.Context, ps *PlantSpecies) error {
-	err := s.Conn.QueryRow(ctx, queryUpdatePlantSpecies, ps.Name, ps.Taxon, ps.ID).Scan(
-		&ps.ID, &ps.Name, &ps.Taxon, &ps.CreatedAt, &ps.UpdatedAt,
-	)
-	return err
-}
-
-// Delete removes a plant species identified by argument `id` from the database
-func (s *Store) Delete(ctx context.Context, id string) error {
-	_, err := s.Conn.Exec(ctx, queryDeletePlantSpecies, id)
-	return err
+	return db.CollectImpact(rows)
 }
@@ -0,0 +1,125 @@
+package plant_species
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kylep342/mendel/internal/components/plants/plant"
+	"github.com/kylep342/mendel/internal/components/plants/plant_cultivar"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// MemoryStore handles all in-memory operations for PlantSpecies, with the semantics of Store:
+// deletes and restores cascade to cultivars and plants, and a species they still refer to is
+// never purged
+type MemoryStore struct {
+	*db.MemoryHistoryStore[PlantSpecies]
+	mem       *db.Memory
+	cultivars *plant_cultivar.MemoryStore
+	plants    *plant.MemoryStore
+}
+
+// NewMemoryStore creates a plant species MemoryStore in mem whose deletes cascade to cultivars and plants
+func NewMemoryStore(mem *db.Memory) *MemoryStore {
+	return &MemoryStore{
+		MemoryHistoryStore: db.NewMemoryHistoryStore[PlantSpecies](mem, constants.TablePlantSpecies),
+		mem:                mem,
+		cultivars:          plant_cultivar.NewMemoryStore(mem),
+		plants:             plant.NewMemoryStore(mem),
+	}
+}
+
+// Delete moves the plant species identified by `id`, its cultivars and its plants to the trash
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	return s.mem.InTx(ctx, func(ctx context.Context) error {
+		if _, err := s.GetByID(ctx, id); err != nil {
+			return err
+		}
+		at := time.Now()
+		if err := s.TrashWhere(ctx, at, func(ps PlantSpecies) bool { return ps.ID == id }); err != nil {
+			return err
+		}
+		if err := s.cultivars.TrashWhere(ctx, at, func(pc plant_cultivar.PlantCultivar) bool { return pc.SpeciesID == id }); err != nil {
+			return err
+		}
+		return s.plants.TrashWhere(ctx, at, func(p plant.Plant) bool { return p.SpeciesID == id })
+	})
+}
+
+// Restore moves the plant species identified by `id` out of the trash, along with the cultivars
+// and plants deleted with it
+func (s *MemoryStore) Restore(ctx context.Context, id string) error {
+	return s.mem.InTx(ctx, func(ctx context.Context) error {
+		trash, err := s.GetTrash(ctx)
+		if err != nil {
+			return err
+		}
+		for _, ps := range trash {
+			if ps.ID != id {
+				continue
+			}
+			if err := s.MemoryHistoryStore.Restore(ctx, id); err != nil {
+				return err
+			}
+			at := *ps.DeletedAt
+			if err := s.cultivars.RestoreWhere(ctx, at, func(pc plant_cultivar.PlantCultivar) bool { return pc.SpeciesID == id }); err != nil {
+				return err
+			}
+			return s.plants.RestoreWhere(ctx, at, func(p plant.Plant) bool { return p.SpeciesID == id })
+		}
+		return sql.ErrNoRows
+	})
+}
+
+// Purge permanently removes plant species deleted before `before` that no cultivar or plant refers to
+func (s *MemoryStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := s.mem.InTx(ctx, func(ctx context.Context) error {
+		referred := map[string]bool{}
+		err := s.cultivars.Dump(ctx, func(pc plant_cultivar.PlantCultivar) error {
+			referred[pc.SpeciesID] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = s.plants.Dump(ctx, func(p plant.Plant) error {
+			referred[p.SpeciesID] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		n, err = s.PurgeWhere(ctx, before, func(ps PlantSpecies) bool { return !referred[ps.ID] })
+		return err
+	})
+	return n, err
+}
+
+// GetImpact lists the records deleting the plant species identified by argument `id` would affect
+func (s *MemoryStore) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	impact := db.Impact{Removed: map[string][]string{}, Orphaned: map[string][]string{}}
+	if _, err := s.GetByID(ctx, id); err == nil {
+		impact.Removed[constants.TablePlantSpecies] = []string{id}
+		cultivars, err := s.cultivars.GetAll(ctx)
+		if err != nil {
+			return db.Impact{}, err
+		}
+		for _, pc := range cultivars {
+			if pc.SpeciesID == id {
+				impact.Removed[constants.TablePlantCultivar] = append(impact.Removed[constants.TablePlantCultivar], pc.ID)
+			}
+		}
+		if err := s.plants.Impact(ctx, impact, func(p plant.Plant) bool { return p.SpeciesID == id }); err != nil {
+			return db.Impact{}, err
+		}
+	} else if err != sql.ErrNoRows {
+		return db.Impact{}, err
+	}
+	if impact.Total() == 0 {
+		return db.Impact{}, sql.ErrNoRows
+	}
+	return impact, nil
+}
@@ -0,0 +1,76 @@
+package plant_species
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kylep342/mendel/internal/components/plants/plant"
+	"github.com/kylep342/mendel/internal/components/plants/plant_cultivar"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+func TestMemoryStore_Cascade(t *testing.T) {
+	ctx := db.WithWorkspace(context.Background(), "00000000-0000-0000-0000-000000000001")
+	mem := db.NewMemory()
+	species, cultivars, plants := NewMemoryStore(mem), plant_cultivar.NewMemoryStore(mem), plant.NewMemoryStore(mem)
+
+	ps := &PlantSpecies{Name: "tomato", Taxon: "Solanum lycopersicum"}
+	other := &PlantSpecies{Name: "pepper", Taxon: "Capsicum annuum"}
+	require.NoError(t, species.Create(ctx, ps))
+	require.NoError(t, species.Create(ctx, other))
+	pc := &plant_cultivar.PlantCultivar{SpeciesID: ps.ID, Name: "tomato", Cultivar: "Brandywine"}
+	oc := &plant_cultivar.PlantCultivar{SpeciesID: other.ID, Name: "pepper", Cultivar: "Jalapeño"}
+	require.NoError(t, cultivars.Create(ctx, pc))
+	require.NoError(t, cultivars.Create(ctx, oc))
+	parent := &plant.Plant{CultivarID: pc.ID, SpeciesID: ps.ID}
+	require.NoError(t, plants.Create(ctx, parent))
+	cross := &plant.Plant{CultivarID: oc.ID, SpeciesID: other.ID, SeedID: &parent.ID, Generation: 1}
+	require.NoError(t, plants.Create(ctx, cross))
+
+	impact, err := species.GetImpact(ctx, ps.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.Impact{
+		Removed: map[string][]string{
+			constants.TablePlantSpecies:  {ps.ID},
+			constants.TablePlantCultivar: {pc.ID},
+			constants.TablePlant:         {parent.ID},
+		},
+		Orphaned: map[string][]string{constants.TablePlant: {cross.ID}},
+	}, impact)
+
+	require.NoError(t, species.Delete(ctx, ps.ID))
+	_, err = cultivars.GetByID(ctx, pc.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "deleting a species deletes its cultivars")
+	_, err = plants.GetByID(ctx, parent.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "deleting a species deletes its plants")
+	_, err = species.GetImpact(ctx, ps.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	assert.ErrorIs(t, cultivars.Restore(ctx, pc.ID), db.ErrParentDeleted)
+	assert.ErrorIs(t, plants.Restore(ctx, parent.ID), db.ErrParentDeleted)
+
+	n, err := species.Purge(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, n, "species cultivars and plants refer to are kept")
+	n, err = cultivars.Purge(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, n, "cultivars plants refer to are kept")
+
+	require.NoError(t, species.Restore(ctx, ps.ID))
+	_, err = cultivars.GetByID(ctx, pc.ID)
+	require.NoError(t, err, "restoring a species restores the cultivars deleted with it")
+	_, err = plants.GetByID(ctx, parent.ID)
+	require.NoError(t, err, "restoring a species restores the plants deleted with it")
+
+	require.NoError(t, plants.Delete(ctx, parent.ID))
+	require.NoError(t, cultivars.Delete(ctx, pc.ID))
+	require.NoError(t, cultivars.Restore(ctx, pc.ID))
+	_, err = plants.GetByID(ctx, parent.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "plants deleted on their own stay in the trash")
+}
@@ -5,11 +5,13 @@ import (
 )
 
 type PlantSpecies struct {
-	ID        string    `db:"id" json:"id"`
-	Name      string    `db:"name" json:"name"`
-	Taxon     string    `db:"taxon" json:"taxon"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	ID          string     `db:"id" json:"id"`
+	WorkspaceID string     `db:"workspace_id" json:"workspace_id"`
+	Name        string     `db:"name" json:"name" validate:"required,max=255"`
+	Taxon       string     `db:"taxon" json:"taxon" validate:"required,max=255"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+	DeletedAt   *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
 func (p *PlantSpecies) GetID() string { return p.ID }
@@ -0,0 +1,65 @@
+package plant_species
+
+import (
+	"context"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// SQLite has no schemas, so tables are named without mendel_core.
+// Deletes and restores cascade to cultivars and plants through triggers, see the sqlite migrations.
+const (
+	// queryImpactPlantSpeciesSQLite is queryImpactPlantSpecies for SQLite
+	queryImpactPlantSpeciesSQLite = `
+		SELECT 'plant_species', ps.id, 'removed' FROM ` + constants.TablePlantSpecies + ` ps
+		WHERE ps.id = ?1 AND ps.workspace_id = ?2 AND ps.deleted_at IS NULL
+		UNION ALL
+		SELECT 'plant_cultivar', pc.id, 'removed' FROM ` + constants.TablePlantCultivar + ` pc
+		WHERE pc.species_id = ?1 AND pc.workspace_id = ?2 AND pc.deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM ` + constants.TablePlantSpecies + ` ps WHERE ps.id = ?1 AND ps.deleted_at IS NULL)
+		UNION ALL
+		SELECT 'plant', p.id, 'removed' FROM ` + constants.TablePlant + ` p
+		WHERE p.species_id = ?1 AND p.workspace_id = ?2 AND p.deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM ` + constants.TablePlantSpecies + ` ps WHERE ps.id = ?1 AND ps.deleted_at IS NULL)
+		UNION ALL
+		SELECT 'plant', c.id, 'orphaned' FROM ` + constants.TablePlant + ` c
+		WHERE c.species_id <> ?1 AND c.workspace_id = ?2 AND c.deleted_at IS NULL
+			AND EXISTS (
+				SELECT 1 FROM ` + constants.TablePlant + ` p
+				WHERE p.species_id = ?1 AND p.deleted_at IS NULL AND (c.seed_id = p.id OR c.pollen_id = p.id)
+			)
+	`
+	// queryPurgePlantSpeciesSQLite is queryPurgePlantSpecies for SQLite
+	queryPurgePlantSpeciesSQLite = `
+		DELETE FROM ` + constants.TablePlantSpecies + `
+		WHERE deleted_at < ?1
+			AND NOT EXISTS (SELECT 1 FROM ` + constants.TablePlantCultivar + ` pc WHERE pc.species_id = ` + constants.TablePlantSpecies + `.id)
+			AND NOT EXISTS (SELECT 1 FROM ` + constants.TablePlant + ` p WHERE p.species_id = ` + constants.TablePlantSpecies + `.id)
+	`
+)
+
+// SQLiteStore handles all SQLite operations for PlantSpecies
+type SQLiteStore struct {
+	*db.SQLiteHistoryStore[PlantSpecies]
+}
+
+// NewSQLiteStore creates a plant species SQLiteStore whose deletes cascade to cultivars and plants
+func NewSQLiteStore(conn db.SQLQuerier) *SQLiteStore {
+	s := &SQLiteStore{db.NewSQLiteHistoryStore[PlantSpecies](conn, constants.TablePlantSpecies)}
+	s.Queries.Purge = queryPurgePlantSpeciesSQLite
+	return s
+}
+
+// GetImpact lists the records deleting the plant species identified by argument `id` would affect
+func (s *SQLiteStore) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		return db.Impact{}, err
+	}
+	rows, err := s.Querier(ctx).QueryContext(ctx, queryImpactPlantSpeciesSQLite, args...)
+	if err != nil {
+		return db.Impact{}, err
+	}
+	return db.CollectSQLImpact(rows)
+}
@@ -0,0 +1,127 @@
+package components
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validator is implemented by models with rules beyond their `validate` struct tags
+type Validator interface {
+	Validate() []FieldError
+}
+
+// FieldError describes why a single field of a payload is invalid
+//
+//	Field: the json name of the field
+//	Message: a human readable reason
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every FieldError found on a payload
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+var validate = newValidate()
+
+// newValidate builds a validator that reports fields by their json names
+func newValidate() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+	return v
+}
+
+// Validate checks model against its `validate` struct tags and, if it implements
+// Validator, its own rules. It returns a *ValidationError listing every invalid field.
+func Validate(model any) error {
+	var fields []FieldError
+
+	if err := validate.Struct(model); err != nil {
+		var errs validator.ValidationErrors
+		if !errors.As(err, &errs) {
+			return err
+		}
+		for _, fe := range errs {
+			fields = append(fields, FieldError{Field: fieldPath(fe), Message: fieldMessage(fe)})
+		}
+	}
+
+	if v, ok := model.(Validator); ok {
+		fields = append(fields, v.Validate()...)
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// fieldPath strips the struct name from a namespace, e.g. Plant.labels.x -> labels.x
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", fe.Param())
+	case "timezone":
+		return "must be an IANA time zone, like Europe/Berlin"
+	case "bcp47_language_tag":
+		return "must be a BCP 47 language tag, like en-US"
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+}
+
+// RequireObject reports an error when value, a decoded JSON document, is set but is not an object
+func RequireObject(field string, value any) []FieldError {
+	if value == nil {
+		return nil
+	}
+	if _, ok := value.(map[string]any); !ok {
+		return []FieldError{{Field: field, Message: "must be a JSON object"}}
+	}
+	return nil
+}
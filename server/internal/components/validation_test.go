@@ -0,0 +1,38 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validatedModel struct {
+	Name     string `json:"name" validate:"required"`
+	ParentID string `json:"parent_id" validate:"omitempty,uuid"`
+	Labels   any    `json:"labels"`
+}
+
+func (m *validatedModel) Validate() []FieldError {
+	return RequireObject("labels", m.Labels)
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		m := &validatedModel{Name: "Tomato", Labels: map[string]any{"bed": "3"}}
+		assert.NoError(t, Validate(m))
+	})
+
+	t.Run("collects every invalid field", func(t *testing.T) {
+		m := &validatedModel{ParentID: "not-a-uuid", Labels: []any{"bed"}}
+
+		err := Validate(m)
+		var invalid *ValidationError
+		require.ErrorAs(t, err, &invalid)
+		assert.Equal(t, []FieldError{
+			{Field: "name", Message: "is required"},
+			{Field: "parent_id", Message: "must be a valid UUID"},
+			{Field: "labels", Message: "must be a JSON object"},
+		}, invalid.Fields)
+	})
+}
@@ -3,26 +3,54 @@ package constants
 const (
 	// Apps
 	AppDbMigrate    = "db-migrate"
+	AppDbSeed       = "db-seed"
+	AppMendelAdmin  = "mendel-admin"
 	AppMendelServer = "mendel-server"
+	AppMockIdP      = "mock-idp"
+	APIVersion      = "0.1.0"
 
 	// Environment/App class constants
 	EnvDevelopment = "development"
 	EnvStaging     = "staging"
 	EnvProduction  = "production"
 
+	// What the server does when the database schema is not the version it was built for
+	SchemaMismatchRefuse   = "refuse"
+	SchemaMismatchReadOnly = "read-only"
+
 	// Databse constants
-	SchemaMendelCore   = "mendel_core"
-	DBInitQuery        = `SET search_path TO ` + SchemaMendelCore + `, public;`
-	TablePlant         = "plant"
-	TablePlantCultivar = "plant_cultivar"
-	TablePlantSpecies  = "plant_species"
-	TableUser          = "user"
+	DialectMemory        = "memory"
+	DialectPostgres      = "postgres"
+	DialectSQLite        = "sqlite"
+	SchemaMendelCore     = "mendel_core"
+	DBInitQuery          = `SET search_path TO ` + SchemaMendelCore + `, public;`
+	TableAPIToken        = "api_tokens"
+	TableAudit           = "audit_log"
+	TableCredential      = "credentials"
+	TableIdentity        = "identities"
+	TablePlant           = "plant"
+	TablePlantCultivar   = "plant_cultivar"
+	TablePlantSpecies    = "plant_species"
+	TableSession         = "sessions"
+	TableShare           = "shares"
+	TableUser            = "users"
+	TableWorkspace       = "workspaces"
+	TableWorkspaceMember = "workspace_members"
 
 	// Routes
+	RouteAudit         = "/audit"
+	RouteAuth          = "/auth"
+	RouteDocs          = "/docs"
+	RouteDocsAssets    = "/docs/assets"
 	RouteEnv           = "/env"
 	RouteHealth        = "/health"
 	RouteIndex         = "/"
+	RouteOpenAPI       = "/openapi.json"
 	RoutePlant         = "/plant"
 	RoutePlantCultivar = "/plant-cultivar"
 	RoutePlantSpecies  = "/plant-species"
+	RouteShare         = "/shares"
+	RouteSharePublic   = "/public/shares"
+	RouteUser          = "/user"
+	RouteWorkspace     = "/workspaces"
 )
@@ -2,6 +2,7 @@ package constants
 
 import (
 	"fmt"
+	"path"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +23,8 @@ type EnvConfig struct {
 		WriteTimeout    time.Duration `json:"write_timeout" mapstructure:"writetimeout"`
 		IdleTimeout     time.Duration `json:"idle_timeout" mapstructure:"idletimeout"`
 		ShutdownTimeout time.Duration `json:"shutdown_timeout" mapstructure:"shutdowntimeout"`
+		StreamTimeout   time.Duration `json:"stream_timeout" mapstructure:"streamtimeout"`
+		MaxBodyBytes    int64         `json:"max_body_bytes" mapstructure:"maxbodybytes"`
 	} `json:"server" mapstructure:"server"`
 
 	// Database configuration fields
@@ -37,19 +40,65 @@ type EnvConfig struct {
 		MaxIdleConns     int           `json:"max_idle_conns" mapstructure:"maxidleconns"`
 		ConnMaxLifetime  time.Duration `json:"conn_max_lifetime" mapstructure:"connmaxlifetime"`
 		MigrationsFolder string        `json:"migrations_folder" mapstructure:"migrationsfolder"`
+		AutoMigrate      bool          `json:"auto_migrate" mapstructure:"automigrate"`
+		SchemaMismatch   string        `json:"schema_mismatch" mapstructure:"schemamismatch"`
+		SQLitePath       string        `json:"sqlite_path" mapstructure:"sqlitepath"`
+		TrashRetention   time.Duration `json:"trash_retention" mapstructure:"trashretention"`
+		TrashPurgeEvery  time.Duration `json:"trash_purge_every" mapstructure:"trashpurgeevery"`
 	} `json:"database" mapstructure:"database"`
 
 	// Application specific configuration
 	App struct {
-		Name                string `json:"name" mapstructure:"name"`
-		Environment         string `json:"environment" mapstructure:"environment"`
-		LogLevel            string `json:"log_level" mapstructure:"loglevel"`
-		EnableDebugFeatures bool   `json:"enable_debug_features" mapstructure:"enabledebugfeatures"`
-		WebHost             string `json:"web_host" mapstructure:"webhost"`
+		Name                   string `json:"name" mapstructure:"name"`
+		Environment            string `json:"environment" mapstructure:"environment"`
+		LogLevel               string `json:"log_level" mapstructure:"loglevel"`
+		EnableDebugFeatures    bool   `json:"enable_debug_features" mapstructure:"enabledebugfeatures"`
+		WebHost                string `json:"web_host" mapstructure:"webhost"`
+		DeleteConfirmThreshold int    `json:"delete_confirm_threshold" mapstructure:"deleteconfirmthreshold"`
+		BatchMaxItems          int    `json:"batch_max_items" mapstructure:"batchmaxitems"`
+		ImportMaxBytes         int64  `json:"import_max_bytes" mapstructure:"importmaxbytes"`
+		ImportMaxRows          int    `json:"import_max_rows" mapstructure:"importmaxrows"`
 	} `json:"app" mapstructure:"app"`
+
+	// Authentication configuration
+	//
+	//	Enabled: require a session or API token on every record route; always on in production
+	//	BootstrapUsername, BootstrapPassword: a user created at startup when none has the name
+	//	ShareKey: signs the tokens of share links; links stop working when it changes
+	//	ShareMaxTTL: the longest a share link may be valid for
+	Auth struct {
+		Enabled           bool          `json:"enabled" mapstructure:"enabled"`
+		SessionTTL        time.Duration `json:"session_ttl" mapstructure:"sessionttl"`
+		CookieSecure      bool          `json:"cookie_secure" mapstructure:"cookiesecure"`
+		PasswordMinLength int           `json:"password_min_length" mapstructure:"passwordminlength"`
+		BootstrapUsername string        `json:"bootstrap_username" mapstructure:"bootstrapusername"`
+		BootstrapPassword string        `json:"-" mapstructure:"bootstrappassword"`
+		ShareKey          string        `json:"-" mapstructure:"sharekey"`
+		ShareMaxTTL       time.Duration `json:"share_max_ttl" mapstructure:"sharemaxttl"`
+	} `json:"auth" mapstructure:"auth"`
+
+	// OpenID Connect single sign-on, offered when Issuer is set
+	//
+	//	Issuer: the identity provider, discovered at <Issuer>/.well-known/openid-configuration
+	//	ClientID, ClientSecret: this server's client at the identity provider; the secret may be
+	//		empty for public clients
+	//	RedirectURL: where the identity provider returns to, <server>/auth/oidc/callback
+	//	Scopes: requested besides openid
+	//	UsernameClaim: the ID token claim naming users created on their first sign in
+	OIDC struct {
+		Issuer        string   `json:"issuer" mapstructure:"issuer"`
+		ClientID      string   `json:"client_id" mapstructure:"clientid"`
+		ClientSecret  string   `json:"-" mapstructure:"clientsecret"`
+		RedirectURL   string   `json:"redirect_url" mapstructure:"redirecturl"`
+		Scopes        []string `json:"scopes" mapstructure:"scopes"`
+		UsernameClaim string   `json:"username_claim" mapstructure:"usernameclaim"`
+	} `json:"oidc" mapstructure:"oidc"`
 }
 
 func (e *EnvConfig) DBUrl() string {
+	if e.Database.Dialect == DialectSQLite {
+		return "sqlite://" + e.Database.SQLitePath
+	}
 	return fmt.Sprintf("%s://%s:%s@%s:%d/%s?sslmode=%s",
 		e.Database.Dialect,
 		e.Database.User,
@@ -61,12 +110,24 @@ func (e *EnvConfig) DBUrl() string {
 	)
 }
 
+// MigrationsPath is the folder holding the migrations for Database.Dialect, or "" when
+// Database.MigrationsFolder is unset and the migrations embedded in the binary are used.
+// SQLite's live in the sqlite subfolder of Database.MigrationsFolder.
+func (e *EnvConfig) MigrationsPath() string {
+	if e.Database.MigrationsFolder != "" && e.Database.Dialect == DialectSQLite {
+		return path.Join(e.Database.MigrationsFolder, DialectSQLite)
+	}
+	return e.Database.MigrationsFolder
+}
+
 // globalEnvConfig holds the loaded environment configuration (singleton).
 var (
 	globalEnvConfig *EnvConfig
 	loadConfigOnce  sync.Once
 
 	allowedEnvironments = []string{EnvDevelopment, EnvStaging, EnvProduction}
+	allowedDialects     = []string{DialectPostgres, DialectSQLite, DialectMemory}
+	allowedMismatches   = []string{SchemaMismatchRefuse, SchemaMismatchReadOnly}
 )
 
 func isValidValue(value string, allowedValues []string, caseSensitive bool) bool {
@@ -94,8 +155,10 @@ func loadEnv(logger zerolog.Logger) {
 	v.SetDefault("server.writetimeout", "15s")
 	v.SetDefault("server.idletimeout", "60s")
 	v.SetDefault("server.shutdowntimeout", "10s")
+	v.SetDefault("server.streamtimeout", "10m")
+	v.SetDefault("server.maxbodybytes", 1<<20)
 
-	v.SetDefault("database.dialect", "postgres")
+	v.SetDefault("database.dialect", DialectPostgres)
 	v.SetDefault("database.host", "localhost")
 	v.SetDefault("database.port", 5432)
 	v.SetDefault("database.user", "postgres")
@@ -104,13 +167,30 @@ func loadEnv(logger zerolog.Logger) {
 	v.SetDefault("database.maxopenconns", 25)
 	v.SetDefault("database.maxidleconns", 25)
 	v.SetDefault("database.connmaxlifetime", "5m")
-	v.SetDefault("database.migrationsfolder", "/app/internal/db/migrations")
+	v.SetDefault("database.automigrate", false)
+	v.SetDefault("database.schemamismatch", SchemaMismatchRefuse)
+	v.SetDefault("database.sqlitepath", "mendel.db")
+	v.SetDefault("database.trashretention", "720h")
+	v.SetDefault("database.trashpurgeevery", "1h")
 
 	v.SetDefault("app.name", "MendelApp")
 	v.SetDefault("app.environment", "development")
 	v.SetDefault("app.loglevel", "info")
 	v.SetDefault("app.enabledebugfeatures", false)
 	v.SetDefault("app.webhost", "http://localhost:5173")
+	v.SetDefault("app.deleteconfirmthreshold", 25)
+	v.SetDefault("app.batchmaxitems", 500)
+	v.SetDefault("app.importmaxbytes", 10<<20)
+	v.SetDefault("app.importmaxrows", 10000)
+
+	v.SetDefault("auth.enabled", true)
+	v.SetDefault("auth.sessionttl", "168h")
+	v.SetDefault("auth.cookiesecure", true)
+	v.SetDefault("auth.passwordminlength", 12)
+	v.SetDefault("auth.sharemaxttl", "720h")
+
+	v.SetDefault("oidc.scopes", "openid,profile,email")
+	v.SetDefault("oidc.usernameclaim", "preferred_username")
 
 	v.BindEnv("server.host", "SERVER_HOST")
 	v.BindEnv("server.port", "SERVER_PORT")
@@ -118,6 +198,8 @@ func loadEnv(logger zerolog.Logger) {
 	v.BindEnv("server.writetimeout", "SERVER_WRITE_TIMEOUT")
 	v.BindEnv("server.idletimeout", "SERVER_IDLE_TIMEOUT")
 	v.BindEnv("server.shutdowntimeout", "SERVER_SHUTDOWN_TIMEOUT")
+	v.BindEnv("server.streamtimeout", "SERVER_STREAM_TIMEOUT")
+	v.BindEnv("server.maxbodybytes", "SERVER_MAX_BODY_BYTES")
 
 	v.BindEnv("database.dialect", "DB_DIALECT")
 	v.BindEnv("database.host", "DB_HOST")
@@ -130,22 +212,65 @@ func loadEnv(logger zerolog.Logger) {
 	v.BindEnv("database.maxidleconns", "DB_MAX_IDLE_CONNS")
 	v.BindEnv("database.connmaxlifetime", "DB_CONN_MAX_LIFETIME")
 	v.BindEnv("database.migrationsfolder", "DB_MIGRATIONS_FOLDER")
+	v.BindEnv("database.automigrate", "DB_AUTO_MIGRATE")
+	v.BindEnv("database.schemamismatch", "DB_SCHEMA_MISMATCH")
+	v.BindEnv("database.sqlitepath", "DB_SQLITE_PATH")
+	v.BindEnv("database.trashretention", "DB_TRASH_RETENTION")
+	v.BindEnv("database.trashpurgeevery", "DB_TRASH_PURGE_EVERY")
 
 	v.BindEnv("app.name", "APP_NAME")
 	v.BindEnv("app.environment", "APP_ENV")
 	v.BindEnv("app.loglevel", "APP_LOG_LEVEL")
 	v.BindEnv("app.enabledebugfeatures", "APP_ENABLE_DEBUG_FEATURES")
 	v.BindEnv("app.webhost", "APP_WEB_HOST")
+	v.BindEnv("app.deleteconfirmthreshold", "APP_DELETE_CONFIRM_THRESHOLD")
+	v.BindEnv("app.batchmaxitems", "APP_BATCH_MAX_ITEMS")
+	v.BindEnv("app.importmaxbytes", "APP_IMPORT_MAX_BYTES")
+	v.BindEnv("app.importmaxrows", "APP_IMPORT_MAX_ROWS")
+
+	v.BindEnv("auth.enabled", "AUTH_ENABLED")
+	v.BindEnv("auth.sessionttl", "AUTH_SESSION_TTL")
+	v.BindEnv("auth.cookiesecure", "AUTH_COOKIE_SECURE")
+	v.BindEnv("auth.passwordminlength", "AUTH_PASSWORD_MIN_LENGTH")
+	v.BindEnv("auth.bootstrapusername", "AUTH_BOOTSTRAP_USERNAME")
+	v.BindEnv("auth.bootstrappassword", "AUTH_BOOTSTRAP_PASSWORD")
+	v.BindEnv("auth.sharekey", "AUTH_SHARE_KEY")
+	v.BindEnv("auth.sharemaxttl", "AUTH_SHARE_MAX_TTL")
+
+	v.BindEnv("oidc.issuer", "OIDC_ISSUER")
+	v.BindEnv("oidc.clientid", "OIDC_CLIENT_ID")
+	v.BindEnv("oidc.clientsecret", "OIDC_CLIENT_SECRET")
+	v.BindEnv("oidc.redirecturl", "OIDC_REDIRECT_URL")
+	v.BindEnv("oidc.scopes", "OIDC_SCOPES")
+	v.BindEnv("oidc.usernameclaim", "OIDC_USERNAME_CLAIM")
 
 	var cfg EnvConfig
 	if err := v.Unmarshal(&cfg); err != nil {
 		logger.Fatal().Err(err).Msg("Failed to unmarshal configuration")
 	}
 
-	if cfg.App.Environment == EnvProduction && cfg.Database.Password == "" {
+	if !isValidValue(cfg.Database.Dialect, allowedDialects, true) {
+		logger.Fatal().Msgf("Invalid DB_DIALECT value '%s'. Allowed values are: %v",
+			cfg.Database.Dialect, allowedDialects)
+	}
+
+	if !isValidValue(cfg.Database.SchemaMismatch, allowedMismatches, true) {
+		logger.Fatal().Msgf("Invalid DB_SCHEMA_MISMATCH value '%s'. Allowed values are: %v",
+			cfg.Database.SchemaMismatch, allowedMismatches)
+	}
+
+	if cfg.App.Environment == EnvProduction && cfg.Database.Dialect == DialectPostgres && cfg.Database.Password == "" {
 		logger.Fatal().Msg("Required configuration DB_PASSWORD is not set")
 	}
 
+	if cfg.App.Environment == EnvProduction && !cfg.Auth.Enabled {
+		logger.Fatal().Msg("AUTH_ENABLED cannot be false in production")
+	}
+
+	if cfg.OIDC.Issuer != "" && (cfg.OIDC.ClientID == "" || cfg.OIDC.RedirectURL == "") {
+		logger.Fatal().Msg("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER is set")
+	}
+
 	if !isValidValue(cfg.App.Environment, allowedEnvironments, true) {
 		logger.Fatal().Msgf("Invalid APP_ENV value '%s'. Allowed values are: %v",
 			cfg.App.Environment, allowedEnvironments)
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// dumpQuery returns the query selecting every record of table, oldest first
+func dumpQuery(fields *Fields, table string) string {
+	order := ColumnID
+	if fields.Has(ColumnCreatedAt) {
+		order = ColumnCreatedAt + ", " + ColumnID
+	}
+	return `SELECT ` + strings.Join(fields.Columns(), ", ") + ` FROM ` + table + ` ORDER BY ` + order
+}
+
+// loadQuery returns the query inserting every column of a record of table, with parameters
+// named by param, doing as conflict says when its ID is taken
+func loadQuery(fields *Fields, table string, conflict Conflict, param func(n int) string) string {
+	cols := fields.Columns()
+	params := make([]string, len(cols))
+	var sets []string
+	for i, col := range cols {
+		params[i] = param(i + 1)
+		if col != ColumnID {
+			sets = append(sets, col+" = excluded."+col)
+		}
+	}
+	query := `INSERT INTO ` + table + ` (` + strings.Join(cols, ", ") + `) VALUES (` + strings.Join(params, ", ") + `)`
+	if conflict == ConflictReplace && len(sets) > 0 {
+		return query + ` ON CONFLICT (` + ColumnID + `) DO UPDATE SET ` + strings.Join(sets, ", ")
+	}
+	return query + ` ON CONFLICT (` + ColumnID + `) DO NOTHING`
+}
+
+// loaded turns the number of records a load changed into whether it wrote the record, failing
+// with ErrConflict when it did not under ConflictFail
+func loaded(n int64, conflict Conflict) (bool, error) {
+	if n == 0 && conflict == ConflictFail {
+		return false, ErrConflict
+	}
+	return n > 0, nil
+}
+
+// archiveArgs returns the value of every column of item, in field order
+func archiveArgs[T any](fields *Fields, item *T) []any {
+	v := reflect.ValueOf(item).Elem()
+	cols := fields.Columns()
+	args := make([]any, len(cols))
+	for i, col := range cols {
+		args[i] = fields.Value(v, col)
+	}
+	return args
+}
+
+// Dump calls fn with every record of every workspace, in the trash or not, oldest first
+func (s *Store[T]) Dump(ctx context.Context, fn func(item T) error) error {
+	return s.Each(ctx, fn, dumpQuery(s.fields, s.Table))
+}
+
+// Load inserts item with every column as it is, doing as conflict says when its ID is taken.
+// Triggers still run, so a replaced record may have its updated_at set to now.
+func (s *Store[T]) Load(ctx context.Context, item *T, conflict Conflict) (bool, error) {
+	load := loadQuery(s.fields, s.Table, conflict, func(n int) string { return fmt.Sprintf("$%d", n) })
+	args := archiveArgs(s.fields, item)
+	tag, err := s.Querier(ctx).Exec(ctx, load, args...)
+	if err != nil {
+		return false, pgDuplicate(err)
+	}
+	return loaded(tag.RowsAffected(), conflict)
+}
+
+// Dump calls fn with every record of every workspace, in the trash or not, oldest first
+func (s *SQLiteStore[T]) Dump(ctx context.Context, fn func(item T) error) error {
+	return s.Each(ctx, fn, dumpQuery(s.fields, s.Table))
+}
+
+// Load inserts item with every column as it is, doing as conflict says when its ID is taken.
+// Triggers still run, so a system-versioned record starts its history now.
+func (s *SQLiteStore[T]) Load(ctx context.Context, item *T, conflict Conflict) (bool, error) {
+	load := loadQuery(s.fields, s.Table, conflict, func(n int) string { return fmt.Sprintf("?%d", n) })
+	args := archiveArgs(s.fields, item)
+	n, err := s.Exec(ctx, load, args...)
+	if err != nil {
+		return false, sqliteDuplicate(err)
+	}
+	return loaded(n, conflict)
+}
+
+// Dump calls fn with every record of every workspace, in the trash or not, in the order they
+// were created
+func (s *MemoryStore[T]) Dump(ctx context.Context, fn func(item T) error) error {
+	var items []T
+	s.mem.read(func() {
+		for _, id := range s.order {
+			items = append(items, s.records[id])
+		}
+	})
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load stores item with every column as it is, doing as conflict says when its ID is taken
+func (s *MemoryStore[T]) Load(ctx context.Context, item *T, conflict Conflict) (bool, error) {
+	id := s.fields.Field(reflect.ValueOf(item).Elem(), ColumnID).String()
+	written := false
+	err := s.mem.write(ctx, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, taken := s.records[id]
+		if taken && conflict != ConflictReplace {
+			_, err := loaded(0, conflict)
+			return err
+		}
+		if !taken {
+			s.order = append(s.order, id)
+		}
+		s.put(id, *item, time.Now())
+		written = true
+		return nil
+	})
+	return written, err
+}
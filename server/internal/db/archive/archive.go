@@ -0,0 +1,366 @@
+// Package archive backs up the records of every component to a portable archive and restores them.
+//
+// An archive is a gzipped tar of, in order:
+//
+//	manifest.json    the Manifest: the archive format, the schema the records were read from and
+//	                 the record count and SHA-256 checksum of every data file
+//	SHA256SUMS       the checksums of every other file, as sha256sum writes them
+//	data/NAME.jsonl  the records of each component, one JSON object of columns per line
+//
+// Records are read and written through the stores, so an archive of one database can be restored
+// into any other the server supports. Past versions of system-versioned records are not archived;
+// restored records start their history when they are restored.
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// Format is the version of the archive layout this package writes and reads
+const Format = 1
+
+// Files of an archive
+const (
+	ManifestFile  = "manifest.json"
+	ChecksumsFile = "SHA256SUMS"
+	DataDir       = "data"
+)
+
+// ErrNotEmpty is returned when restoring into a database that holds records, without merging
+var ErrNotEmpty = errors.New("the database is not empty")
+
+// Manifest describes an archive
+//
+//	Format: the Format it was written in
+//	Server: the version of the server that wrote it
+//	Schema: the version of the database schema its records were read from
+//	Dialect: the database they were read from
+type Manifest struct {
+	Format     int       `json:"format"`
+	CreatedAt  time.Time `json:"created_at"`
+	Server     string    `json:"server"`
+	Schema     int       `json:"schema"`
+	Dialect    string    `json:"dialect"`
+	Components []File    `json:"components"`
+}
+
+// File is the data file of a component in an archive
+type File struct {
+	Component string `json:"component"`
+	Path      string `json:"path"`
+	Records   int    `json:"records"`
+	SHA256    string `json:"sha256"`
+}
+
+// Archiver backs up and restores Components, which are ordered so that records only refer to
+// records of the same or earlier components
+type Archiver struct {
+	Tx         db.Transactor
+	Components []Component
+	// Snapshot, when set, runs first in the transaction a backup reads in, so that every
+	// component is read from the same snapshot of the database
+	Snapshot func(ctx context.Context) error
+
+	// Server, Schema and Dialect are recorded in the manifest of backups
+	Server  string
+	Schema  int
+	Dialect string
+}
+
+// Backup writes an archive of every component to w, reading them in a single transaction
+func (a *Archiver) Backup(ctx context.Context, w io.Writer) (*Manifest, error) {
+	m := &Manifest{Format: Format, CreatedAt: time.Now().UTC(), Server: a.Server, Schema: a.Schema, Dialect: a.Dialect}
+	var temps []*os.File
+	defer func() {
+		for _, f := range temps {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	err := db.InTx(ctx, a.Tx, func(ctx context.Context) error {
+		if a.Snapshot != nil {
+			if err := a.Snapshot(ctx); err != nil {
+				return err
+			}
+		}
+		for _, c := range a.Components {
+			f, err := os.CreateTemp("", "mendel-backup-*.jsonl")
+			if err != nil {
+				return err
+			}
+			temps = append(temps, f)
+			sum := sha256.New()
+			buf := bufio.NewWriter(io.MultiWriter(f, sum))
+			n, err := c.dump(ctx, buf)
+			if err == nil {
+				err = buf.Flush()
+			}
+			if err != nil {
+				return fmt.Errorf("backing up %s: %w", c.Name, err)
+			}
+			m.Components = append(m.Components, File{
+				Component: c.Name,
+				Path:      path.Join(DataDir, c.Name+".jsonl"),
+				Records:   n,
+				SHA256:    hex.EncodeToString(sum.Sum(nil)),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	manifest = append(manifest, '\n')
+	sums := checksum(manifest) + "  " + ManifestFile + "\n"
+	for _, f := range m.Components {
+		sums += f.SHA256 + "  " + f.Path + "\n"
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, size int64, r io.Reader) error {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: m.CreatedAt, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+	if err := add(ManifestFile, int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		return nil, err
+	}
+	if err := add(ChecksumsFile, int64(len(sums)), strings.NewReader(sums)); err != nil {
+		return nil, err
+	}
+	for i, f := range temps {
+		size, err := f.Seek(0, io.SeekCurrent)
+		if err == nil {
+			_, err = f.Seek(0, io.SeekStart)
+		}
+		if err == nil {
+			err = add(m.Components[i].Path, size, f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, gz.Close()
+}
+
+// Merge is how Restore writes records into a database that already holds some
+type Merge struct {
+	// Enabled restores into a database holding records; otherwise Restore fails with
+	// ErrNotEmpty unless it holds no more than its migrations created
+	Enabled bool
+	// Conflict is what happens to a record whose ID a stored record has; db.ConflictFail when unset
+	Conflict db.Conflict
+}
+
+// Restore writes the records of the archive r reads into the database in a single transaction,
+// returning its manifest and the Counts of each component it restored. It checks every file
+// against the manifest and its checksums, and rolls back if any does not match.
+func (a *Archiver) Restore(ctx context.Context, r io.Reader, merge Merge) (*Manifest, map[string]Counts, error) {
+	ar, err := open(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ar.manifest.Schema > a.Schema {
+		return nil, nil, fmt.Errorf("the archive was read from schema version %d, newer than the database's %d; migrate the database first", ar.manifest.Schema, a.Schema)
+	}
+	components := map[string]Component{}
+	for _, c := range a.Components {
+		components[c.Name] = c
+	}
+	for _, f := range ar.manifest.Components {
+		if _, ok := components[f.Component]; !ok {
+			return nil, nil, fmt.Errorf("the archive holds %s, which this server does not know", f.Component)
+		}
+	}
+
+	conflict := db.ConflictReplace
+	if merge.Enabled {
+		conflict = merge.Conflict
+		if conflict == "" {
+			conflict = db.ConflictFail
+		}
+	}
+	results := map[string]Counts{}
+	err = db.InTx(ctx, a.Tx, func(ctx context.Context) error {
+		if !merge.Enabled {
+			for _, c := range a.Components {
+				empty, err := c.empty(ctx)
+				if err != nil {
+					return err
+				}
+				if !empty {
+					return fmt.Errorf("%w: %s holds records; merge into it instead", ErrNotEmpty, c.Name)
+				}
+			}
+		}
+		return ar.each(func(f File, r io.Reader) error {
+			counts, err := components[f.Component].load(ctx, r, conflict)
+			if err != nil {
+				return err
+			}
+			if n := counts.Written + counts.Skipped; n != f.Records {
+				return fmt.Errorf("%s holds %d records, not the %d its manifest lists", f.Path, n, f.Records)
+			}
+			results[f.Component] = counts
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return ar.manifest, results, nil
+}
+
+// Verify checks the archive r reads against its manifest and checksums without restoring it
+func Verify(r io.Reader) (*Manifest, error) {
+	ar, err := open(r)
+	if err != nil {
+		return nil, err
+	}
+	err = ar.each(func(f File, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if n := bytes.Count(data, []byte("\n")); n != f.Records {
+			return fmt.Errorf("%s holds %d records, not the %d its manifest lists", f.Path, n, f.Records)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ar.manifest, nil
+}
+
+// reader reads an archive, which open has read the manifest and checksums of
+type reader struct {
+	tr       *tar.Reader
+	manifest *Manifest
+}
+
+// open starts reading the archive r reads, checking its format and that its manifest matches its
+// checksums
+func open(r io.Reader) (*reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not an archive: %w", err)
+	}
+	ar := &reader{tr: tar.NewReader(gz)}
+	manifest, err := ar.next(ManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := ar.next(ChecksumsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(manifest, &ar.manifest); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", ManifestFile, err)
+	}
+	if ar.manifest.Format != Format {
+		return nil, fmt.Errorf("the archive is in format %d; this server reads format %d", ar.manifest.Format, Format)
+	}
+	want := map[string]string{ManifestFile: checksum(manifest)}
+	for _, f := range ar.manifest.Components {
+		want[f.Path] = f.SHA256
+	}
+	listed := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(sums)), "\n") {
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, fmt.Errorf("reading %s: malformed line %q", ChecksumsFile, line)
+		}
+		listed[name] = sum
+	}
+	for name, sum := range want {
+		if listed[name] != sum {
+			return nil, fmt.Errorf("%s does not match %s", name, ChecksumsFile)
+		}
+	}
+	return ar, nil
+}
+
+// next reads the next file of the archive, which must be named name
+func (ar *reader) next(name string) ([]byte, error) {
+	hdr, err := ar.tr.Next()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("the archive has no %s", name)
+	} else if err != nil {
+		return nil, err
+	}
+	if hdr.Name != name {
+		return nil, fmt.Errorf("the archive has %s where %s belongs", hdr.Name, name)
+	}
+	return io.ReadAll(ar.tr)
+}
+
+// each calls fn with every data file of the archive, in the order of the manifest, and checks
+// each against its checksum once fn has read it
+func (ar *reader) each(fn func(f File, r io.Reader) error) error {
+	for _, f := range ar.manifest.Components {
+		hdr, err := ar.tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("the archive has no %s", f.Path)
+		} else if err != nil {
+			return err
+		}
+		if hdr.Name != f.Path {
+			return fmt.Errorf("the archive has %s where %s belongs", hdr.Name, f.Path)
+		}
+
+		sum := sha256.New()
+		r := io.TeeReader(ar.tr, sum)
+		if err := fn(f, r); err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return err
+		}
+		if got := hex.EncodeToString(sum.Sum(nil)); got != f.SHA256 {
+			return fmt.Errorf("%s does not match its checksum", f.Path)
+		}
+	}
+	if hdr, err := ar.tr.Next(); err == nil {
+		return fmt.Errorf("the archive holds %s, which its manifest does not list", hdr.Name)
+	} else if !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// checksum returns the hex SHA-256 checksum of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,287 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kylep342/mendel/internal/db"
+)
+
+type testPlant struct {
+	ID        string       `db:"id" json:"id"`
+	ParentID  *string      `db:"parent_id" json:"parent_id"`
+	Name      string       `db:"name" json:"name"`
+	Secret    string       `db:"secret" json:"-"`
+	CreatedAt sql.NullTime `db:"created_at" json:"created_at"`
+	DeletedAt *time.Time   `db:"deleted_at" json:"deleted_at"`
+}
+
+type testEntry struct {
+	ID        string    `db:"id"`
+	Action    string    `db:"action"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+const presetID = "00000000-0000-0000-0000-000000000001"
+
+// testDB is an in-memory database of plants and an immutable log
+type testDB struct {
+	mem     *db.Memory
+	plants  *db.MemorySoftDeleteStore[testPlant]
+	entries *db.MemoryStore[testEntry]
+}
+
+// newTestDB returns an empty testDB, holding only the preset plant its migrations would create
+func newTestDB(t *testing.T) *testDB {
+	mem := db.NewMemory()
+	d := &testDB{
+		mem:     mem,
+		plants:  db.NewMemorySoftDeleteStore[testPlant](mem, "plants"),
+		entries: db.NewMemoryStore[testEntry](mem, "entries"),
+	}
+	preset := testPlant{ID: presetID, Name: "preset"}
+	_, err := d.plants.Load(context.Background(), &preset, db.ConflictFail)
+	require.NoError(t, err)
+	return d
+}
+
+func (d *testDB) archiver() *Archiver {
+	return &Archiver{
+		Tx: d.mem,
+		Components: []Component{
+			Of[testPlant]("plants", d.plants, Options[testPlant]{
+				Preset: []string{presetID},
+				Parents: func(p testPlant) []string {
+					if p.ParentID == nil {
+						return nil
+					}
+					return []string{*p.ParentID}
+				},
+			}),
+			Of[testEntry]("entries", d.entries, Options[testEntry]{Immutable: true}),
+		},
+		Server:  "test",
+		Schema:  3,
+		Dialect: "memory",
+	}
+}
+
+// populate writes a pedigree of plants, one of them in the trash, and a log entry to d
+func (d *testDB) populate(t *testing.T) {
+	ctx := context.Background()
+	a := &testPlant{Name: "a", Secret: "hash"}
+	require.NoError(t, d.plants.Create(ctx, a))
+	b := &testPlant{ParentID: &a.ID, Name: "b"}
+	require.NoError(t, d.plants.Create(ctx, b))
+	require.NoError(t, d.plants.Delete(ctx, b.ID))
+	require.NoError(t, d.entries.Create(ctx, &testEntry{Action: "create"}))
+}
+
+// dump returns the rows of every component of d
+func (d *testDB) dump(t *testing.T) (plants []testPlant, entries []testEntry) {
+	ctx := context.Background()
+	require.NoError(t, d.plants.Dump(ctx, func(item testPlant) error {
+		plants = append(plants, item)
+		return nil
+	}))
+	require.NoError(t, d.entries.Dump(ctx, func(item testEntry) error {
+		entries = append(entries, item)
+		return nil
+	}))
+	return plants, entries
+}
+
+func backup(t *testing.T, d *testDB) []byte {
+	var buf bytes.Buffer
+	_, err := d.archiver().Backup(context.Background(), &buf)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestArchiver_BackupRestore(t *testing.T) {
+	ctx := context.Background()
+	from := newTestDB(t)
+	from.populate(t)
+
+	var buf bytes.Buffer
+	m, err := from.archiver().Backup(ctx, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, Format, m.Format)
+	assert.Equal(t, 3, m.Schema)
+	require.Len(t, m.Components, 2)
+	assert.Equal(t, File{Component: "plants", Path: "data/plants.jsonl", Records: 3, SHA256: m.Components[0].SHA256}, m.Components[0])
+	assert.Equal(t, 1, m.Components[1].Records)
+
+	verified, err := Verify(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, m.Components, verified.Components)
+
+	to := newTestDB(t)
+	restored, counts, err := to.archiver().Restore(ctx, bytes.NewReader(buf.Bytes()), Merge{})
+	require.NoError(t, err)
+	assert.Equal(t, m.Components, restored.Components)
+	assert.Equal(t, map[string]Counts{"plants": {Written: 3}, "entries": {Written: 1}}, counts)
+
+	wantPlants, wantEntries := from.dump(t)
+	plants, entries := to.dump(t)
+	require.Len(t, plants, len(wantPlants))
+	for i := range wantPlants {
+		assert.Equal(t, wantPlants[i].ID, plants[i].ID)
+		assert.Equal(t, wantPlants[i].ParentID, plants[i].ParentID)
+		assert.Equal(t, wantPlants[i].Secret, plants[i].Secret, "secrets are archived")
+		assert.Equal(t, wantPlants[i].CreatedAt.Valid, plants[i].CreatedAt.Valid)
+		assert.True(t, wantPlants[i].CreatedAt.Time.Equal(plants[i].CreatedAt.Time))
+		assert.Equal(t, wantPlants[i].DeletedAt == nil, plants[i].DeletedAt == nil, "the trash is archived")
+	}
+	require.Len(t, entries, 1)
+	assert.Equal(t, wantEntries[0].ID, entries[0].ID)
+}
+
+func TestArchiver_Restore_Merge(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	d.populate(t)
+	data := backup(t, d)
+
+	_, _, err := d.archiver().Restore(ctx, bytes.NewReader(data), Merge{})
+	assert.ErrorIs(t, err, ErrNotEmpty)
+
+	_, _, err = d.archiver().Restore(ctx, bytes.NewReader(data), Merge{Enabled: true})
+	assert.ErrorIs(t, err, db.ErrConflict, "merging fails on conflicts by default")
+
+	plants, _ := d.dump(t)
+	renamed := plants[1]
+	renamed.Name = "renamed"
+	_, err = d.plants.Load(ctx, &renamed, db.ConflictReplace)
+	require.NoError(t, err)
+
+	_, counts, err := d.archiver().Restore(ctx, bytes.NewReader(data), Merge{Enabled: true, Conflict: db.ConflictSkip})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Counts{"plants": {Skipped: 3}, "entries": {Skipped: 1}}, counts)
+	got, err := d.plants.GetByID(ctx, renamed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", got.Name)
+
+	_, counts, err = d.archiver().Restore(ctx, bytes.NewReader(data), Merge{Enabled: true, Conflict: db.ConflictReplace})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Counts{"plants": {Written: 3}, "entries": {Skipped: 1}}, counts, "immutable records are kept")
+	got, err = d.plants.GetByID(ctx, renamed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "a", got.Name)
+}
+
+func TestArchiver_Restore_NewerSchema(t *testing.T) {
+	d := newTestDB(t)
+	data := backup(t, d)
+
+	ar := newTestDB(t).archiver()
+	ar.Schema = 2
+	_, _, err := ar.Restore(context.Background(), bytes.NewReader(data), Merge{})
+	assert.ErrorContains(t, err, "migrate the database first")
+}
+
+// rewrite returns the archive data with the body of every file replaced by what fn returns for
+// it, and the file extra added when set
+func rewrite(t *testing.T, data []byte, fn func(name string, body []byte) []byte, extra string) []byte {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	write := func(name string, body []byte) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(body)
+		require.NoError(t, err)
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		write(hdr.Name, fn(hdr.Name, body))
+	}
+	if extra != "" {
+		write(extra, []byte("{}\n"))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	d := newTestDB(t)
+	d.populate(t)
+	data := backup(t, d)
+	keep := func(name string, body []byte) []byte { return body }
+
+	_, err := Verify(bytes.NewReader(rewrite(t, data, keep, "")))
+	require.NoError(t, err)
+
+	tampered := rewrite(t, data, func(name string, body []byte) []byte {
+		if name == "data/plants.jsonl" {
+			return bytes.Replace(body, []byte(`"hash"`), []byte(`"evil"`), 1)
+		}
+		return body
+	}, "")
+	_, err = Verify(bytes.NewReader(tampered))
+	assert.ErrorContains(t, err, "data/plants.jsonl does not match its checksum")
+	_, _, err = newTestDB(t).archiver().Restore(context.Background(), bytes.NewReader(tampered), Merge{})
+	assert.ErrorContains(t, err, "does not match its checksum")
+
+	manifest := rewrite(t, data, func(name string, body []byte) []byte {
+		if name == ManifestFile {
+			return bytes.Replace(body, []byte(`"records": 3`), []byte(`"records": 2`), 1)
+		}
+		return body
+	}, "")
+	_, err = Verify(bytes.NewReader(manifest))
+	assert.ErrorContains(t, err, "manifest.json does not match SHA256SUMS")
+
+	_, err = Verify(bytes.NewReader(rewrite(t, data, keep, "data/extra.jsonl")))
+	assert.ErrorContains(t, err, "data/extra.jsonl, which its manifest does not list")
+
+	_, err = Verify(bytes.NewReader([]byte("not an archive")))
+	assert.ErrorContains(t, err, "not an archive")
+}
+
+func TestRestore_Atomic(t *testing.T) {
+	d := newTestDB(t)
+	d.populate(t)
+	data := rewrite(t, backup(t, d), func(name string, body []byte) []byte {
+		if name == "data/entries.jsonl" {
+			return []byte("{\"bogus\": 1}\n")
+		}
+		return body
+	}, "")
+
+	to := newTestDB(t)
+	_, _, err := to.archiver().Restore(context.Background(), bytes.NewReader(data), Merge{})
+	assert.ErrorContains(t, err, "entries line 1: unknown column bogus")
+	plants, _ := to.dump(t)
+	assert.Len(t, plants, 1, "a failed restore is rolled back")
+}
+
+func TestParentsFirst(t *testing.T) {
+	items := []string{"c:b", "b:a", "x:unknown", "a:", "d:c"}
+	getID := func(item *string) string { return (*item)[:1] }
+	parents := func(item string) []string { return []string{item[2:]} }
+
+	var order []string
+	for _, i := range parentsFirst(items, getID, parents) {
+		order = append(order, getID(&items[i]))
+	}
+	assert.Equal(t, []string{"a", "b", "c", "x", "d"}, order)
+}
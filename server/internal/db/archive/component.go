@@ -0,0 +1,165 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// errNotEmpty stops checking a component for records once one is found
+var errNotEmpty = errors.New("not empty")
+
+// Options tune how the records of a component are archived
+type Options[T any] struct {
+	// Preset are the IDs of the records migrations create, which an empty database already holds
+	// and restoring into it replaces
+	Preset []string
+	// Immutable is set for components whose records never change once written, so restoring
+	// keeps a stored record rather than replacing it
+	Immutable bool
+	// Parents returns the IDs of the records of the same component item refers to, which
+	// restoring writes before item
+	Parents func(item T) []string
+}
+
+// Component is a table whose records an archive holds, one JSON object of columns per line
+type Component struct {
+	Name string
+
+	dump  func(ctx context.Context, w io.Writer) (int, error)
+	empty func(ctx context.Context) (bool, error)
+	load  func(ctx context.Context, r io.Reader, conflict db.Conflict) (Counts, error)
+}
+
+// Counts are how many records of a component restoring wrote, and how many it skipped because
+// their ID was taken
+type Counts struct {
+	Written int
+	Skipped int
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%d written, %d skipped", c.Written, c.Skipped)
+}
+
+// Of returns the Component named name, usually the table, whose records table stores.
+// table is the store of any dialect, which must implement db.ArchiveTable[T]; stores such as the
+// audit log's only expose it that way.
+func Of[T any](name string, table any, opts Options[T]) Component {
+	archived, ok := table.(db.ArchiveTable[T])
+	if !ok {
+		panic(fmt.Sprintf("archive: the store of %s, %T, cannot be archived", name, table))
+	}
+	fields := db.FieldsOf[T]()
+	getID := func(item *T) string {
+		return fmt.Sprint(fields.Value(reflect.ValueOf(item).Elem(), db.ColumnID))
+	}
+
+	c := Component{Name: name}
+	c.dump = func(ctx context.Context, w io.Writer) (int, error) {
+		var buf bytes.Buffer
+		n := 0
+		err := archived.Dump(ctx, func(item T) error {
+			buf.Reset()
+			if err := encodeRow(&buf, fields, &item); err != nil {
+				return fmt.Errorf("%s %s: %w", name, getID(&item), err)
+			}
+			n++
+			_, err := w.Write(buf.Bytes())
+			return err
+		})
+		return n, err
+	}
+	c.empty = func(ctx context.Context) (bool, error) {
+		err := archived.Dump(ctx, func(item T) error {
+			if slices.Contains(opts.Preset, getID(&item)) {
+				return nil
+			}
+			return errNotEmpty
+		})
+		if errors.Is(err, errNotEmpty) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	c.load = func(ctx context.Context, r io.Reader, conflict db.Conflict) (Counts, error) {
+		if opts.Immutable && conflict == db.ConflictReplace {
+			conflict = db.ConflictSkip
+		}
+		var counts Counts
+		write := func(item *T) error {
+			written, err := archived.Load(ctx, item, conflict)
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", name, getID(item), err)
+			}
+			if written {
+				counts.Written++
+			} else {
+				counts.Skipped++
+			}
+			return nil
+		}
+
+		var items []T
+		dec := json.NewDecoder(bufio.NewReader(r))
+		for line := 1; dec.More(); line++ {
+			var row map[string]json.RawMessage
+			if err := dec.Decode(&row); err != nil {
+				return counts, fmt.Errorf("%s line %d: %w", name, line, err)
+			}
+			var item T
+			if err := decodeRow(row, fields, &item); err != nil {
+				return counts, fmt.Errorf("%s line %d: %w", name, line, err)
+			}
+			if opts.Parents != nil {
+				items = append(items, item)
+			} else if err := write(&item); err != nil {
+				return counts, err
+			}
+		}
+
+		for _, i := range parentsFirst(items, getID, opts.Parents) {
+			if err := write(&items[i]); err != nil {
+				return counts, err
+			}
+		}
+		return counts, nil
+	}
+	return c
+}
+
+// parentsFirst orders the indexes of items so that each comes after those of its parents
+// among items. Parents outside items, and cycles, are left to the database to report.
+func parentsFirst[T any](items []T, getID func(item *T) string, parents func(item T) []string) []int {
+	index := make(map[string]int, len(items))
+	for i := range items {
+		index[getID(&items[i])] = i
+	}
+	order := make([]int, 0, len(items))
+	visited := make([]bool, len(items))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, parent := range parents(items[i]) {
+			if j, ok := index[parent]; ok {
+				visit(j)
+			}
+		}
+		order = append(order, i)
+	}
+	for i := range items {
+		visit(i)
+	}
+	return order
+}
@@ -0,0 +1,81 @@
+package archive
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/kylep342/mendel/internal/db"
+)
+
+var nullTimeType = reflect.TypeOf(sql.NullTime{})
+
+// encodeRow writes item to buf as a JSON object of its columns, named as in the database and in
+// field order, followed by a newline. Columns are encoded as their Go values are, whatever their
+// json tags say, so secrets such as password hashes are kept.
+func encodeRow[T any](buf *bytes.Buffer, fields *db.Fields, item *T) error {
+	v := reflect.ValueOf(item).Elem()
+	buf.WriteByte('{')
+	for i, col := range fields.Columns() {
+		value, err := encodeColumn(fields.Field(v, col))
+		if err != nil {
+			return fmt.Errorf("column %s: %w", col, err)
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(col)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+// encodeColumn returns the JSON of a column's field, writing a sql.NullTime as the time or null
+func encodeColumn(f reflect.Value) ([]byte, error) {
+	if f.Type() == nullTimeType {
+		t := f.Interface().(sql.NullTime)
+		if !t.Valid {
+			return []byte("null"), nil
+		}
+		return json.Marshal(t.Time)
+	}
+	return json.Marshal(f.Interface())
+}
+
+// decodeRow sets the fields of item from row, a JSON object of columns as encodeRow writes it.
+// Columns T does not have are an error; columns row lacks are left zero.
+func decodeRow[T any](row map[string]json.RawMessage, fields *db.Fields, item *T) error {
+	v := reflect.ValueOf(item).Elem()
+	for col, raw := range row {
+		if !fields.Has(col) {
+			return fmt.Errorf("unknown column %s", col)
+		}
+		if err := decodeColumn(fields.Field(v, col), raw); err != nil {
+			return fmt.Errorf("column %s: %w", col, err)
+		}
+	}
+	return nil
+}
+
+// decodeColumn sets a column's field from its JSON, reading a sql.NullTime from the time or null
+func decodeColumn(f reflect.Value, raw json.RawMessage) error {
+	if f.Type() == nullTimeType {
+		if string(raw) == "null" {
+			f.SetZero()
+			return nil
+		}
+		var t time.Time
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
+		return nil
+	}
+	return json.Unmarshal(raw, f.Addr().Interface())
+}
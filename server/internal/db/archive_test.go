@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive_Queries(t *testing.T) {
+	cols := "id, parent_id, name, labels, created_at, updated_at, deleted_at"
+	param := func(n int) string { return "?" }
+	values := `VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	assert.Equal(t, `SELECT `+cols+` FROM test ORDER BY created_at, id`, dumpQuery(FieldsOf[testRecord](), "test"))
+	assert.Equal(t, `SELECT id, name FROM test ORDER BY id`, dumpQuery(FieldsOf[testPlainRecord](), "test"))
+
+	load := loadQuery(FieldsOf[testRecord](), "test", ConflictReplace, param)
+	assert.Equal(t, `INSERT INTO test (`+cols+`) `+values+` ON CONFLICT (id) DO UPDATE SET `+
+		`parent_id = excluded.parent_id, name = excluded.name, labels = excluded.labels, `+
+		`created_at = excluded.created_at, updated_at = excluded.updated_at, deleted_at = excluded.deleted_at`, load)
+
+	for _, conflict := range []Conflict{ConflictFail, ConflictSkip} {
+		load := loadQuery(FieldsOf[testRecord](), "test", conflict, param)
+		assert.Equal(t, `INSERT INTO test (`+cols+`) `+values+` ON CONFLICT (id) DO NOTHING`, load)
+	}
+}
+
+// testArchive checks that the records of from can be loaded into to as they are, under every
+// Conflict policy
+func testArchive(t *testing.T, from, to interface {
+	CRUDTable[testRecord]
+	SoftDeleteTable[testRecord]
+	ArchiveTable[testRecord]
+}) {
+	ctx := context.Background()
+	parent := &testRecord{Name: "parent", Labels: map[string]any{"color": "red"}}
+	require.NoError(t, from.Create(ctx, parent))
+	child := &testRecord{ParentID: &parent.ID, Name: "child", Labels: map[string]any{}}
+	require.NoError(t, from.Create(ctx, child))
+	require.NoError(t, from.Delete(ctx, child.ID))
+
+	var dumped []testRecord
+	require.NoError(t, from.Dump(ctx, func(item testRecord) error {
+		dumped = append(dumped, item)
+		return nil
+	}))
+	require.Len(t, dumped, 2, "records in the trash are dumped")
+	assert.Equal(t, parent.ID, dumped[0].ID)
+	assert.NotNil(t, dumped[1].DeletedAt)
+
+	for i := range dumped {
+		written, err := to.Load(ctx, &dumped[i], ConflictFail)
+		require.NoError(t, err)
+		assert.True(t, written)
+	}
+	got, err := to.GetByID(ctx, parent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, dumped[0].Name, got.Name)
+	assert.Equal(t, dumped[0].Labels, got.Labels)
+	assert.True(t, dumped[0].CreatedAt.Equal(got.CreatedAt), "timestamps are kept")
+	trash, err := to.GetTrash(ctx)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, child.ID, trash[0].ID)
+
+	changed := dumped[0]
+	changed.Name = "changed"
+	_, err = to.Load(ctx, &changed, ConflictFail)
+	assert.ErrorIs(t, err, ErrConflict)
+	written, err := to.Load(ctx, &changed, ConflictSkip)
+	require.NoError(t, err)
+	assert.False(t, written)
+	got, err = to.GetByID(ctx, parent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "parent", got.Name)
+
+	written, err = to.Load(ctx, &changed, ConflictReplace)
+	require.NoError(t, err)
+	assert.True(t, written)
+	got, err = to.GetByID(ctx, parent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", got.Name)
+}
+
+func TestSQLiteStore_Archive(t *testing.T) {
+	testArchive(t,
+		NewSQLiteSoftDeleteStore[testRecord](openTestSQLite(t, testSQLiteTable), "test"),
+		NewSQLiteSoftDeleteStore[testRecord](openTestSQLite(t, testSQLiteTable), "test"),
+	)
+}
+
+func TestMemoryStore_Archive(t *testing.T) {
+	testArchive(t,
+		NewMemorySoftDeleteStore[testRecord](NewMemory(), "test"),
+		NewMemorySoftDeleteStore[testRecord](NewMemory(), "test"),
+	)
+}
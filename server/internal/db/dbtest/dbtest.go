@@ -0,0 +1,36 @@
+// Package dbtest opens databases for the tests of stores
+package dbtest
+
+import (
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/db/migrations"
+)
+
+// OpenSQLite opens a SQLite database in a temporary folder, with every sqlite migration built
+// into the binary applied, and closes it when the test ends
+func OpenSQLite(t testing.TB) *sql.DB {
+	t.Helper()
+	conn, err := db.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	files := migrations.Files(&constants.EnvConfig{})
+	ups, err := fs.Glob(files, constants.DialectSQLite+"/*.up.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, ups)
+	for _, up := range ups {
+		b, err := fs.ReadFile(files, up)
+		require.NoError(t, err)
+		_, err = conn.Exec(string(b))
+		require.NoError(t, err, up)
+	}
+	return conn
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Fields maps the columns of a model, named by `db` struct tags, to its struct fields.
+// A column tagged `db:"name,readonly"` is read like any other but never written from the model;
+// only queries written for it may change it.
+type Fields struct {
+	Type     reflect.Type
+	columns  []string
+	index    map[string][]int
+	readonly map[string]bool
+}
+
+var fieldsCache sync.Map // reflect.Type -> *Fields
+
+// FieldsOf returns the Fields of T, which must be a struct
+func FieldsOf[T any]() *Fields {
+	return fieldsOf(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func fieldsOf(t reflect.Type) *Fields {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.(*Fields)
+	}
+	if t.Kind() != reflect.Struct {
+		panic("db: " + t.String() + " is not a struct")
+	}
+
+	f := &Fields{Type: t, index: map[string][]int{}, readonly: map[string]bool{}}
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(sf.Tag.Get("db"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		f.columns = append(f.columns, name)
+		f.index[name] = sf.Index
+		for _, opt := range strings.Split(opts, ",") {
+			if opt == "readonly" {
+				f.readonly[name] = true
+			}
+		}
+	}
+
+	cached, _ := fieldsCache.LoadOrStore(t, f)
+	return cached.(*Fields)
+}
+
+// Columns lists every column in field order
+func (f *Fields) Columns() []string {
+	return f.columns
+}
+
+// Writable lists the columns a caller may set, in field order
+func (f *Fields) Writable() []string {
+	var cols []string
+	for _, col := range f.columns {
+		switch col {
+		case ColumnID, ColumnCreatedAt, ColumnUpdatedAt, ColumnDeletedAt, ColumnWorkspaceID:
+			continue
+		}
+		if f.readonly[col] {
+			continue
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// ReadOnly lists the columns tagged readonly, in field order
+func (f *Fields) ReadOnly() []string {
+	var cols []string
+	for _, col := range f.columns {
+		if f.readonly[col] {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// Has reports whether the model has column
+func (f *Fields) Has(column string) bool {
+	_, ok := f.index[column]
+	return ok
+}
+
+// FieldType returns the type of the field holding column
+func (f *Fields) FieldType(column string) reflect.Type {
+	return f.Type.FieldByIndex(f.index[column]).Type
+}
+
+// Field returns the field of v, a struct of the model's type, holding column
+func (f *Fields) Field(v reflect.Value, column string) reflect.Value {
+	return v.FieldByIndex(f.index[column])
+}
+
+// Value returns the value v, a struct of the model's type, holds for column, or nil if it has no such column
+func (f *Fields) Value(v reflect.Value, column string) any {
+	if !f.Has(column) {
+		return nil
+	}
+	return f.Field(v, column).Interface()
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Columns of system-versioned tables, maintained by triggers rather than written from a model.
+// A table's current records carry valid_from, when they were last written; its history table
+// holds every past version of them, each with the valid_from it had and the valid_to when it
+// stopped being current.
+const (
+	ColumnValidFrom = "valid_from"
+	ColumnValidTo   = "valid_to"
+)
+
+// HistorySuffix names the history table of a system-versioned table, after the table
+const HistorySuffix = "_history"
+
+// HistoryTable is implemented by a CRUDTable[T] that keeps every past version of its records
+//
+//	GetAllAsOf: the records GetAll would have returned at a time
+//	GetByIDAsOf: the record GetByID would have returned at a time
+type HistoryTable[T any] interface {
+	GetAllAsOf(ctx context.Context, at time.Time) ([]T, error)
+	GetByIDAsOf(ctx context.Context, id string, at time.Time) (T, error)
+}
+
+// LineageHistoryTable is implemented by a LineageTable[T] that keeps every past version of its records
+//
+//	GetLineageAsOf: the lineage GetLineage would have returned at a time, as the records were then
+type LineageHistoryTable[T any] interface {
+	GetLineageAsOf(ctx context.Context, id string, at time.Time) ([]T, error)
+}
+
+// versions returns a query selecting cols of the version of each record of table, in every
+// workspace and deleted or not, that was current at the time in the parameter at
+func versions(table, history, cols, at string) string {
+	return `SELECT ` + cols + ` FROM ` + table + ` WHERE ` + ColumnValidFrom + ` <= ` + at +
+		` UNION ALL SELECT ` + cols + ` FROM ` + history +
+		` WHERE ` + ColumnValidFrom + ` <= ` + at + ` AND ` + ColumnValidTo + ` > ` + at
+}
+
+// asOfQueries returns the GetAllAsOf and GetByIDAsOf queries selecting the live records of
+// versions, with parameters named by param
+func asOfQueries(fields *Fields, versions func(at string) string, param func(n int) string) (all, byID string) {
+	cols := strings.Join(fields.Columns(), ", ")
+	ws := func(n int) string {
+		if !fields.Has(ColumnWorkspaceID) {
+			return ""
+		}
+		return fmt.Sprintf(" AND %s = %s", ColumnWorkspaceID, param(n+1))
+	}
+	all = `SELECT ` + cols + ` FROM (` + versions(param(1)) + `) v WHERE deleted_at IS NULL` + ws(1)
+	byID = `SELECT ` + cols + ` FROM (` + versions(param(2)) + `) v WHERE id = ` + param(1) + ` AND deleted_at IS NULL` + ws(2)
+	return all, byID
+}
+
+// HistoryStore is a SoftDeleteStore[T] for a system-versioned table, whose triggers copy each
+// record to History as it is changed or deleted. T has neither valid_from nor valid_to.
+type HistoryStore[T any] struct {
+	*SoftDeleteStore[T]
+	History string
+}
+
+// NewHistoryStore creates a HistoryStore[T] for table, keeping past versions in its history table
+func NewHistoryStore[T any](conn Querier, table string) *HistoryStore[T] {
+	s := &HistoryStore[T]{SoftDeleteStore: NewSoftDeleteStore[T](conn, table), History: table + HistorySuffix}
+	s.Queries.GetAllAsOf, s.Queries.GetByIDAsOf = asOfQueries(s.fields, s.Versions, func(n int) string { return fmt.Sprintf("$%d", n) })
+	return s
+}
+
+// Versions returns a query selecting every column of the version of each record, in every
+// workspace and deleted or not, that was current at the time in the parameter at.
+// Queries of the past, such as lineages, select from it rather than the table.
+func (s *HistoryStore[T]) Versions(at string) string {
+	return versions(s.Table, s.History, strings.Join(s.fields.Columns(), ", "), at)
+}
+
+// GetAllAsOf retrieves the records that were live at `at`, as they were then
+func (s *HistoryStore[T]) GetAllAsOf(ctx context.Context, at time.Time) ([]T, error) {
+	args, err := s.Scope(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, s.Queries.GetAllAsOf, args...)
+}
+
+// GetByIDAsOf retrieves the record identified by `id` as it was at `at`, if it was live then
+func (s *HistoryStore[T]) GetByIDAsOf(ctx context.Context, id string, at time.Time) (T, error) {
+	args, err := s.Scope(ctx, id, at)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return s.SelectOne(ctx, s.Queries.GetByIDAsOf, args...)
+}
+
+// SQLiteHistoryStore is a SQLiteSoftDeleteStore[T] for a system-versioned table, like HistoryStore[T]
+type SQLiteHistoryStore[T any] struct {
+	*SQLiteSoftDeleteStore[T]
+	History string
+}
+
+// NewSQLiteHistoryStore creates a SQLiteHistoryStore[T] for table, keeping past versions in its history table
+func NewSQLiteHistoryStore[T any](conn SQLQuerier, table string) *SQLiteHistoryStore[T] {
+	s := &SQLiteHistoryStore[T]{SQLiteSoftDeleteStore: NewSQLiteSoftDeleteStore[T](conn, table), History: table + HistorySuffix}
+	s.Queries.GetAllAsOf, s.Queries.GetByIDAsOf = asOfQueries(s.fields, s.Versions, func(n int) string { return fmt.Sprintf("?%d", n) })
+	return s
+}
+
+// Versions returns a query selecting every column of the version of each record that was
+// current at the time in the parameter at, like HistoryStore.Versions
+func (s *SQLiteHistoryStore[T]) Versions(at string) string {
+	return versions(s.Table, s.History, strings.Join(s.fields.Columns(), ", "), at)
+}
+
+// GetAllAsOf retrieves the records that were live at `at`, as they were then
+func (s *SQLiteHistoryStore[T]) GetAllAsOf(ctx context.Context, at time.Time) ([]T, error) {
+	args, err := s.Scope(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, s.Queries.GetAllAsOf, args...)
+}
+
+// GetByIDAsOf retrieves the record identified by `id` as it was at `at`, if it was live then
+func (s *SQLiteHistoryStore[T]) GetByIDAsOf(ctx context.Context, id string, at time.Time) (T, error) {
+	args, err := s.Scope(ctx, id, at)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return s.SelectOne(ctx, s.Queries.GetByIDAsOf, args...)
+}
+
+// memoryVersion is a version of a record of a MemoryStore keeping history, current from validFrom
+// until validTo, or still current when validTo is zero
+type memoryVersion[T any] struct {
+	record    T
+	validFrom time.Time
+	validTo   time.Time
+}
+
+// MemoryHistoryStore is a MemorySoftDeleteStore[T] that keeps every past version of its records,
+// like HistoryStore[T]
+type MemoryHistoryStore[T any] struct {
+	*MemorySoftDeleteStore[T]
+}
+
+// NewMemoryHistoryStore creates a MemoryHistoryStore[T] for table in mem. T must have a deleted_at column.
+func NewMemoryHistoryStore[T any](mem *Memory, table string) *MemoryHistoryStore[T] {
+	s := &MemoryHistoryStore[T]{MemorySoftDeleteStore: NewMemorySoftDeleteStore[T](mem, table)}
+	mem.mu.Lock()
+	if s.versions == nil {
+		s.versions = map[string][]memoryVersion[T]{}
+	}
+	mem.mu.Unlock()
+	return s
+}
+
+// GetAllAsOf retrieves the records that were live at `at`, as they were then, in the order they were created
+func (s *MemoryHistoryStore[T]) GetAllAsOf(ctx context.Context, at time.Time) ([]T, error) {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	type created struct {
+		at     time.Time
+		record T
+	}
+	var found []created
+	s.mem.read(func() {
+		for _, versions := range s.versions {
+			if record, ok := s.asOf(versions, at); ok && s.isLive(record) && s.inWorkspace(record, workspace) {
+				found = append(found, created{versions[0].validFrom, record})
+			}
+		}
+	})
+	sort.SliceStable(found, func(i, j int) bool { return found[i].at.Before(found[j].at) })
+	items := make([]T, len(found))
+	for i, f := range found {
+		items[i] = f.record
+	}
+	return items, ctx.Err()
+}
+
+// GetByIDAsOf retrieves the record identified by `id` as it was at `at`, if it was live then
+func (s *MemoryHistoryStore[T]) GetByIDAsOf(ctx context.Context, id string, at time.Time) (T, error) {
+	var (
+		item T
+		ok   bool
+	)
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return item, err
+	}
+	s.mem.read(func() {
+		item, ok = s.asOf(s.versions[id], at)
+		ok = ok && s.isLive(item) && s.inWorkspace(item, workspace)
+	})
+	if !ok {
+		var zero T
+		return zero, sql.ErrNoRows
+	}
+	return item, ctx.Err()
+}
+
+// GetAllAsOfWithTrash retrieves the records that existed at `at`, as they were then, including
+// those in the trash then
+func (s *MemoryHistoryStore[T]) GetAllAsOfWithTrash(ctx context.Context, at time.Time) ([]T, error) {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var items []T
+	s.mem.read(func() {
+		for _, versions := range s.versions {
+			if record, ok := s.asOf(versions, at); ok && s.inWorkspace(record, workspace) {
+				items = append(items, record)
+			}
+		}
+	})
+	return items, ctx.Err()
+}
+
+// asOf returns the version of a record among its versions that was current at `at`
+func (s *MemoryHistoryStore[T]) asOf(versions []memoryVersion[T], at time.Time) (T, bool) {
+	for _, v := range versions {
+		if !v.validFrom.After(at) && (v.validTo.IsZero() || v.validTo.After(at)) {
+			return v.record, true
+		}
+	}
+	var zero T
+	return zero, false
+}
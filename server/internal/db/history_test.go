@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSpecies is a record of the plant_species table of the sqlite migrations
+type testSpecies struct {
+	ID          string     `db:"id"`
+	WorkspaceID string     `db:"workspace_id"`
+	Name        string     `db:"name"`
+	Taxon       string     `db:"taxon"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+	DeletedAt   *time.Time `db:"deleted_at"`
+}
+
+// testHistoryTable is the CRUDTable[T] and HistoryTable[T] of a history store
+type testHistoryTable interface {
+	CRUDTable[testSpecies]
+	HistoryTable[testSpecies]
+}
+
+func TestHistoryStore_Queries(t *testing.T) {
+	s := NewHistoryStore[testScopedRecord](nil, "mendel_core.scoped")
+	cols := "id, workspace_id, name, created_at, updated_at, deleted_at"
+	versions := "SELECT " + cols + " FROM mendel_core.scoped WHERE valid_from <= $1 " +
+		"UNION ALL SELECT " + cols + " FROM mendel_core.scoped_history WHERE valid_from <= $1 AND valid_to > $1"
+
+	assert.Equal(t, versions, s.Versions("$1"))
+	assert.Equal(t, "SELECT "+cols+" FROM ("+versions+") v WHERE deleted_at IS NULL AND workspace_id = $2", s.Queries.GetAllAsOf)
+	assert.Equal(t,
+		"SELECT "+cols+" FROM ("+s.Versions("$2")+") v WHERE id = $1 AND deleted_at IS NULL AND workspace_id = $3",
+		s.Queries.GetByIDAsOf,
+	)
+
+	sqlite := NewSQLiteHistoryStore[testScopedRecord](nil, "scoped")
+	assert.Contains(t, sqlite.Queries.GetByIDAsOf, "FROM scoped_history WHERE valid_from <= ?2 AND valid_to > ?2")
+}
+
+func TestHistoryStores(t *testing.T) {
+	const defaultWorkspace = "00000000-0000-0000-0000-000000000001"
+
+	stores := map[string]func(t *testing.T) testHistoryTable{
+		"sqlite": func(t *testing.T) testHistoryTable {
+			ups, err := filepath.Glob("migrations/sqlite/*.up.sql")
+			require.NoError(t, err)
+			sort.Strings(ups)
+			var schema []string
+			for _, up := range ups {
+				b, err := os.ReadFile(up)
+				require.NoError(t, err)
+				schema = append(schema, string(b))
+			}
+			return NewSQLiteHistoryStore[testSpecies](openTestSQLite(t, schema...), "plant_species")
+		},
+		"memory": func(t *testing.T) testHistoryTable {
+			return NewMemoryHistoryStore[testSpecies](NewMemory(), "plant_species")
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := WithWorkspace(context.Background(), defaultWorkspace)
+			s := newStore(t)
+			// tick waits long enough for writes either side of it to be told apart and
+			// returns a time between them
+			tick := func() time.Time {
+				time.Sleep(5 * time.Millisecond)
+				at := time.Now()
+				time.Sleep(5 * time.Millisecond)
+				return at
+			}
+
+			before := tick()
+			a := &testSpecies{Name: "a", Taxon: "first"}
+			require.NoError(t, s.Create(ctx, a))
+			b := &testSpecies{Name: "b", Taxon: "b"}
+			require.NoError(t, s.Create(ctx, b))
+			created := tick()
+			a.Taxon = "second"
+			require.NoError(t, s.Update(ctx, a))
+			updated := tick()
+			require.NoError(t, s.Delete(ctx, b.ID))
+			deleted := tick()
+			require.NoError(t, s.(SoftDeleteTable[testSpecies]).Restore(ctx, b.ID))
+
+			all, err := s.GetAllAsOf(ctx, before)
+			require.NoError(t, err)
+			assert.Empty(t, all, "nothing existed yet")
+
+			got, err := s.GetByIDAsOf(ctx, a.ID, created)
+			require.NoError(t, err)
+			assert.Equal(t, "first", got.Taxon)
+			got, err = s.GetByIDAsOf(ctx, a.ID, updated)
+			require.NoError(t, err)
+			assert.Equal(t, "second", got.Taxon)
+
+			all, err = s.GetAllAsOf(ctx, updated)
+			require.NoError(t, err)
+			assert.Len(t, all, 2)
+			all, err = s.GetAllAsOf(ctx, deleted)
+			require.NoError(t, err)
+			require.Len(t, all, 1, "records deleted then are left out")
+			assert.Equal(t, a.ID, all[0].ID)
+			_, err = s.GetByIDAsOf(ctx, b.ID, deleted)
+			assert.ErrorIs(t, err, sql.ErrNoRows)
+
+			all, err = s.GetAllAsOf(ctx, time.Now())
+			require.NoError(t, err)
+			assert.Len(t, all, 2, "restored records are current again")
+
+			_, err = s.GetAllAsOf(context.Background(), time.Now())
+			assert.ErrorIs(t, err, ErrNoWorkspace)
+			_, err = s.GetByIDAsOf(WithWorkspace(context.Background(), "other"), a.ID, time.Now())
+			assert.Error(t, err, "records of other workspaces are not found")
+		})
+	}
+}
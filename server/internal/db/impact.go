@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// EffectRemoved marks a record that a Delete moves to the trash
+	EffectRemoved = "removed"
+	// EffectOrphaned marks a live record left pointing at a removed record
+	EffectOrphaned = "orphaned"
+)
+
+// Impact lists the IDs of records a Delete would affect, grouped by table
+//
+//	Removed: records deleted along with the target, including the target itself
+//	Orphaned: records that stay but refer to a removed record, e.g. a plant whose seed parent is removed
+type Impact struct {
+	Removed  map[string][]string `json:"removed"`
+	Orphaned map[string][]string `json:"orphaned"`
+}
+
+// Total is the number of records affected
+func (i Impact) Total() int {
+	n := 0
+	for _, ids := range i.Removed {
+		n += len(ids)
+	}
+	for _, ids := range i.Orphaned {
+		n += len(ids)
+	}
+	return n
+}
+
+// impactRows is the part of pgx.Rows and *sql.Rows CollectImpact reads
+type impactRows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}
+
+// CollectImpact reads (table, id, effect) rows into an Impact.
+// It returns sql.ErrNoRows when there are none, since the target itself is always removed.
+func CollectImpact(rows pgx.Rows) (Impact, error) {
+	defer rows.Close()
+	return collectImpact(rows)
+}
+
+// CollectSQLImpact is CollectImpact for database/sql rows
+func CollectSQLImpact(rows *sql.Rows) (Impact, error) {
+	defer rows.Close()
+	return collectImpact(rows)
+}
+
+func collectImpact(rows impactRows) (Impact, error) {
+	impact := Impact{Removed: map[string][]string{}, Orphaned: map[string][]string{}}
+	found := false
+	for rows.Next() {
+		var table, id, effect string
+		if err := rows.Scan(&table, &id, &effect); err != nil {
+			return Impact{}, err
+		}
+		found = true
+		switch effect {
+		case EffectRemoved:
+			impact.Removed[table] = append(impact.Removed[table], id)
+		case EffectOrphaned:
+			impact.Orphaned[table] = append(impact.Orphaned[table], id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Impact{}, err
+	}
+	if !found {
+		return Impact{}, sql.ErrNoRows
+	}
+	return impact, nil
+}
@@ -0,0 +1,587 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Memory is an in-process database holding the records of MemoryStores, by table.
+// It is a Transactor: units of work run one at a time and are rolled back by restoring
+// every table to how it was when the unit began. Reads outside a unit of work may see
+// the uncommitted writes of one in progress.
+type Memory struct {
+	mu     sync.RWMutex // guards the records of every table
+	txMu   sync.Mutex   // serializes writes
+	tables map[string]memoryTable
+}
+
+// memoryTable is a table of a Memory, held by the first store created for it
+type memoryTable interface {
+	// snapshot copies the table's records, returning a func restoring them
+	snapshot() (restore func())
+	// inTrash reports whether the record identified by id is in the trash
+	inTrash(id string) bool
+}
+
+type memoryTxKey struct{}
+
+// NewMemory creates an empty Memory
+func NewMemory() *Memory {
+	return &Memory{tables: map[string]memoryTable{}}
+}
+
+// Ping always succeeds; it lets a Memory stand in for a database connection in health checks
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// InTx runs fn as a unit of work, restoring every table if fn returns an error or panics.
+// When ctx already carries a unit of work of m, fn runs as a nested unit of it.
+func (m *Memory) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.inTx(ctx) {
+		m.txMu.Lock()
+		defer m.txMu.Unlock()
+		ctx = context.WithValue(ctx, memoryTxKey{}, m)
+	}
+
+	restore := m.snapshot()
+	defer func() {
+		if p := recover(); p != nil {
+			restore()
+			panic(p)
+		}
+	}()
+
+	if err := fn(ctx); err != nil {
+		restore()
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) inTx(ctx context.Context) bool {
+	tx, _ := ctx.Value(memoryTxKey{}).(*Memory)
+	return tx == m
+}
+
+// snapshot copies every table, returning a func restoring them all
+func (m *Memory) snapshot() func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	restores := make([]func(), 0, len(m.tables))
+	for _, t := range m.tables {
+		restores = append(restores, t.snapshot())
+	}
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
+// InTrash reports whether the record of table identified by `id`, in any workspace, is in the
+// trash. It lets the stores of one table check the records of another they refer to without
+// knowing their type, as queries name other tables.
+func (m *Memory) InTrash(table, id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.tables[table]
+	return ok && t.inTrash(id)
+}
+
+// read runs fn holding a read lock on every table
+func (m *Memory) read(fn func()) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fn()
+}
+
+// write runs fn holding a write lock on every table, after any unit of work in progress
+// on another context has finished
+func (m *Memory) write(ctx context.Context, fn func() error) error {
+	if !m.inTx(ctx) {
+		m.txMu.Lock()
+		defer m.txMu.Unlock()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn()
+}
+
+// MemoryStore implements CRUDTable[T] in a Memory for any struct whose fields carry `db` tags.
+// It manages the same columns as Store[T]: ids are generated UUIDs, created_at and updated_at
+// are set on write, tables with deleted_at only ever see live records and tables with workspace_id
+// only ever see the records of the workspace carried by the context. The id and workspace_id
+// columns must be strings. Foreign keys are not enforced, and deletes only cascade through the
+// MemoryHooks of a MemorySoftDeleteStore.
+type MemoryStore[T any] struct {
+	Table string
+
+	mem    *Memory
+	fields *Fields
+	*memoryRecords[T]
+}
+
+// memoryRecords are the records of a table of a Memory, shared by every store of the table
+type memoryRecords[T any] struct {
+	records  map[string]T
+	order    []string                      // ids in insertion order
+	versions map[string][]memoryVersion[T] // every version of each record by id, oldest first, when keeping history
+}
+
+// NewMemoryStore creates a MemoryStore[T] for table in mem. T must have a string id column.
+// Stores created for the same table share its records, as stores of a database table do.
+func NewMemoryStore[T any](mem *Memory, table string) *MemoryStore[T] {
+	fields := FieldsOf[T]()
+	if !fields.Has(ColumnID) || fields.FieldType(ColumnID).Kind() != reflect.String {
+		panic(fmt.Sprintf("db: %T has no string %s column", *new(T), ColumnID))
+	}
+	if fields.Has(ColumnWorkspaceID) && fields.FieldType(ColumnWorkspaceID).Kind() != reflect.String {
+		panic(fmt.Sprintf("db: %T has a %s column that is not a string", *new(T), ColumnWorkspaceID))
+	}
+	s := &MemoryStore[T]{Table: table, mem: mem, fields: fields}
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if t, ok := mem.tables[table]; ok {
+		first, ok := t.(*MemoryStore[T])
+		if !ok {
+			panic(fmt.Sprintf("db: table %s holds %T, not %T", table, t, s))
+		}
+		s.memoryRecords = first.memoryRecords
+		return s
+	}
+	s.memoryRecords = &memoryRecords[T]{records: map[string]T{}}
+	mem.tables[table] = s
+	return s
+}
+
+func (s *MemoryStore[T]) inTrash(id string) bool {
+	record, ok := s.records[id]
+	return ok && !s.isLive(record)
+}
+
+func (s *MemoryStore[T]) snapshot() func() {
+	records := make(map[string]T, len(s.records))
+	for id, record := range s.records {
+		records[id] = record
+	}
+	order := append([]string(nil), s.order...)
+	var versions map[string][]memoryVersion[T]
+	if s.versions != nil {
+		versions = make(map[string][]memoryVersion[T], len(s.versions))
+		for id, v := range s.versions {
+			versions[id] = append([]memoryVersion[T](nil), v...)
+		}
+	}
+	return func() {
+		s.records, s.order, s.versions = records, order, versions
+	}
+}
+
+// GetAll retrieves all records from the table, in the order they were created
+func (s *MemoryStore[T]) GetAll(ctx context.Context) ([]T, error) {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := []T{}
+	s.mem.read(func() {
+		for _, id := range s.order {
+			if record := s.records[id]; s.isLive(record) && s.inWorkspace(record, workspace) {
+				items = append(items, record)
+			}
+		}
+	})
+	return items, ctx.Err()
+}
+
+// StreamAll calls fn with each record GetAll would return. The records are copied
+// first so a slow fn does not hold up writers.
+func (s *MemoryStore[T]) StreamAll(ctx context.Context, fn func(item T) error) error {
+	items, err := s.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByID retrieves the record identified by `id`
+func (s *MemoryStore[T]) GetByID(ctx context.Context, id string) (T, error) {
+	var (
+		item T
+		ok   bool
+	)
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return item, err
+	}
+	s.mem.read(func() {
+		item, ok = s.live(id, workspace)
+	})
+	if !ok {
+		return item, sql.ErrNoRows
+	}
+	return item, ctx.Err()
+}
+
+// GetByIDs retrieves the records identified by `ids`, skipping any that do not exist
+func (s *MemoryStore[T]) GetByIDs(ctx context.Context, ids []string) ([]T, error) {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := []T{}
+	s.mem.read(func() {
+		for _, id := range ids {
+			if item, ok := s.live(id, workspace); ok {
+				items = append(items, item)
+			}
+		}
+	})
+	return items, ctx.Err()
+}
+
+// Create inserts item, generating its id if empty, and writes the stored record back into it
+func (s *MemoryStore[T]) Create(ctx context.Context, item *T) error {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return err
+	}
+	return s.mem.write(ctx, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record := *item
+		v := reflect.ValueOf(&record).Elem()
+		id := s.fields.Field(v, ColumnID)
+		if id.String() == "" {
+			id.SetString(uuid.NewString())
+		}
+		if _, ok := s.records[id.String()]; ok {
+			return fmt.Errorf("%w: %s already has a record with id %s", ErrDuplicate, s.Table, id.String())
+		}
+
+		now := time.Now()
+		s.setTime(v, ColumnCreatedAt, now)
+		s.setTime(v, ColumnUpdatedAt, now)
+		s.clear(v, ColumnDeletedAt)
+		for _, col := range s.fields.ReadOnly() {
+			s.clear(v, col)
+		}
+		if s.fields.Has(ColumnWorkspaceID) {
+			s.fields.Field(v, ColumnWorkspaceID).SetString(workspace)
+		}
+
+		s.put(id.String(), record, now)
+		s.order = append(s.order, id.String())
+		*item = record
+		return nil
+	})
+}
+
+// Update writes every writable column of item and writes the stored record back into it
+func (s *MemoryStore[T]) Update(ctx context.Context, item *T) error {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return err
+	}
+	return s.mem.write(ctx, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record := *item
+		v := reflect.ValueOf(&record).Elem()
+		id := s.fields.Field(v, ColumnID).String()
+		existing, ok := s.live(id, workspace)
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		old := reflect.ValueOf(&existing).Elem()
+		for _, col := range append([]string{ColumnCreatedAt, ColumnDeletedAt, ColumnWorkspaceID}, s.fields.ReadOnly()...) {
+			if s.fields.Has(col) {
+				s.fields.Field(v, col).Set(s.fields.Field(old, col))
+			}
+		}
+		now := time.Now()
+		s.setTime(v, ColumnUpdatedAt, now)
+
+		s.put(id, record, now)
+		*item = record
+		return nil
+	})
+}
+
+// Modify applies fn to the live record identified by `id`, for changes Update does not make,
+// such as to readonly columns. fn must not change the id or workspace.
+func (s *MemoryStore[T]) Modify(ctx context.Context, id string, fn func(item *T)) error {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return err
+	}
+	return s.mem.write(ctx, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, ok := s.live(id, workspace)
+		if !ok {
+			return sql.ErrNoRows
+		}
+		fn(&record)
+		s.put(id, record, time.Now())
+		return nil
+	})
+}
+
+// Delete removes the record identified by `id`, or moves it to the trash if the table has deleted_at
+func (s *MemoryStore[T]) Delete(ctx context.Context, id string) error {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return err
+	}
+	return s.mem.write(ctx, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, ok := s.live(id, workspace)
+		if !ok {
+			return sql.ErrNoRows
+		}
+		if !s.fields.Has(ColumnDeletedAt) {
+			s.remove(id)
+			return nil
+		}
+		now := time.Now()
+		s.setTime(reflect.ValueOf(&record).Elem(), ColumnDeletedAt, now)
+		s.put(id, record, now)
+		return nil
+	})
+}
+
+// Workspace returns the workspace ctx is scoped to for tables with a workspace_id column, or
+// ErrNoWorkspace when it carries none. It returns "" for tables without the column.
+func (s *MemoryStore[T]) Workspace(ctx context.Context) (string, error) {
+	args, err := scope(ctx, s.fields)
+	if err != nil || len(args) == 0 {
+		return "", err
+	}
+	return args[0].(string), nil
+}
+
+// live returns the record identified by id if it exists in workspace, as Workspace returns it,
+// and is not in the trash. The caller must hold a lock on s.mem.
+func (s *MemoryStore[T]) live(id, workspace string) (T, bool) {
+	record, ok := s.records[id]
+	if !ok || !s.isLive(record) || !s.inWorkspace(record, workspace) {
+		var zero T
+		return zero, false
+	}
+	return record, true
+}
+
+// inWorkspace reports whether record belongs to workspace, as Workspace returns it
+func (s *MemoryStore[T]) inWorkspace(record T, workspace string) bool {
+	if !s.fields.Has(ColumnWorkspaceID) {
+		return true
+	}
+	return s.fields.Field(reflect.ValueOf(&record).Elem(), ColumnWorkspaceID).String() == workspace
+}
+
+func (s *MemoryStore[T]) isLive(record T) bool {
+	return s.deletedAt(record) == nil
+}
+
+// deletedAt returns when record was moved to the trash, or nil if it is live
+func (s *MemoryStore[T]) deletedAt(record T) *time.Time {
+	if !s.fields.Has(ColumnDeletedAt) {
+		return nil
+	}
+	switch t := s.fields.Value(reflect.ValueOf(&record).Elem(), ColumnDeletedAt).(type) {
+	case *time.Time:
+		return t
+	case time.Time:
+		if !t.IsZero() {
+			return &t
+		}
+	case sql.NullTime:
+		if t.Valid {
+			return &t.Time
+		}
+	}
+	return nil
+}
+
+// setTime sets column of v to t if the model has it as a time.Time, *time.Time or sql.NullTime
+func (s *MemoryStore[T]) setTime(v reflect.Value, column string, t time.Time) {
+	if !s.fields.Has(column) {
+		return
+	}
+	f := s.fields.Field(v, column)
+	switch f.Interface().(type) {
+	case time.Time:
+		f.Set(reflect.ValueOf(t))
+	case *time.Time:
+		f.Set(reflect.ValueOf(&t))
+	case sql.NullTime:
+		f.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
+	}
+}
+
+// clear zeroes column of v if the model has it
+func (s *MemoryStore[T]) clear(v reflect.Value, column string) {
+	if s.fields.Has(column) {
+		s.fields.Field(v, column).SetZero()
+	}
+}
+
+// put stores record as the current version of the record identified by id, written at now,
+// keeping the version it replaces when keeping history. The caller must hold a write lock on s.mem.
+func (s *MemoryStore[T]) put(id string, record T, now time.Time) {
+	s.records[id] = record
+	if s.versions != nil {
+		s.endVersion(id, now)
+		s.versions[id] = append(s.versions[id], memoryVersion[T]{record: record, validFrom: now})
+	}
+}
+
+// endVersion marks the current version of the record identified by id as replaced at now
+func (s *MemoryStore[T]) endVersion(id string, now time.Time) {
+	if versions := s.versions[id]; len(versions) > 0 && versions[len(versions)-1].validTo.IsZero() {
+		versions[len(versions)-1].validTo = now
+	}
+}
+
+// remove permanently deletes the record identified by id, keeping its versions when keeping
+// history. The caller must hold a write lock on s.mem.
+func (s *MemoryStore[T]) remove(id string) {
+	delete(s.records, id)
+	if s.versions != nil {
+		s.endVersion(id, time.Now())
+	}
+	for i, oid := range s.order {
+		if oid == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// MemorySoftDeleteStore is a MemoryStore[T] for models with a deleted_at column, adding the
+// SoftDeleteTable[T] methods
+type MemorySoftDeleteStore[T any] struct {
+	*MemoryStore[T]
+}
+
+// NewMemorySoftDeleteStore creates a MemorySoftDeleteStore[T] for table in mem. T must have a deleted_at column.
+func NewMemorySoftDeleteStore[T any](mem *Memory, table string) *MemorySoftDeleteStore[T] {
+	if !FieldsOf[T]().Has(ColumnDeletedAt) {
+		panic(fmt.Sprintf("db: %T has no %s column", *new(T), ColumnDeletedAt))
+	}
+	return &MemorySoftDeleteStore[T]{MemoryStore: NewMemoryStore[T](mem, table)}
+}
+
+// GetTrash retrieves all records in the trash
+func (s *MemorySoftDeleteStore[T]) GetTrash(ctx context.Context) ([]T, error) {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := []T{}
+	s.mem.read(func() {
+		for _, id := range s.order {
+			if record := s.records[id]; !s.isLive(record) && s.inWorkspace(record, workspace) {
+				items = append(items, record)
+			}
+		}
+	})
+	return items, ctx.Err()
+}
+
+// Restore moves the record identified by `id` out of the trash
+func (s *MemorySoftDeleteStore[T]) Restore(ctx context.Context, id string) error {
+	workspace, err := s.Workspace(ctx)
+	if err != nil {
+		return err
+	}
+	return s.mem.write(ctx, func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, ok := s.records[id]
+		if !ok || s.isLive(record) || !s.inWorkspace(record, workspace) {
+			return sql.ErrNoRows
+		}
+		s.clear(reflect.ValueOf(&record).Elem(), ColumnDeletedAt)
+		s.put(id, record, time.Now())
+		return nil
+	})
+}
+
+// Purge permanently removes records deleted before `before`, in every workspace
+func (s *MemorySoftDeleteStore[T]) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return s.PurgeWhere(ctx, before, func(T) bool { return true })
+}
+
+// PurgeWhere is Purge for the records match returns true for, which lets a store keep records
+// others still refer to
+func (s *MemorySoftDeleteStore[T]) PurgeWhere(ctx context.Context, before time.Time, match func(item T) bool) (int64, error) {
+	var n int64
+	err := s.mem.write(ctx, func() error {
+		for _, id := range append([]string(nil), s.order...) {
+			record := s.records[id]
+			if deleted := s.deletedAt(record); deleted != nil && deleted.Before(before) && match(record) {
+				s.remove(id)
+				n++
+			}
+		}
+		return ctx.Err()
+	})
+	return n, err
+}
+
+// TrashWhere moves the live records match returns true for, in every workspace, to the trash at
+// `at`. Stores call it within a unit of work to cascade a Delete, as triggers do in a database.
+func (s *MemorySoftDeleteStore[T]) TrashWhere(ctx context.Context, at time.Time, match func(item T) bool) error {
+	return s.mem.write(ctx, func() error {
+		for _, id := range s.order {
+			if record := s.records[id]; s.isLive(record) && match(record) {
+				s.setTime(reflect.ValueOf(&record).Elem(), ColumnDeletedAt, at)
+				s.put(id, record, at)
+			}
+		}
+		return ctx.Err()
+	})
+}
+
+// RestoreWhere moves the records match returns true for out of the trash, in every workspace,
+// if they were moved there at `at`. It undoes TrashWhere, leaving records deleted on their own.
+func (s *MemorySoftDeleteStore[T]) RestoreWhere(ctx context.Context, at time.Time, match func(item T) bool) error {
+	return s.mem.write(ctx, func() error {
+		now := time.Now()
+		for _, id := range s.order {
+			record := s.records[id]
+			if deleted := s.deletedAt(record); deleted != nil && deleted.Equal(at) && match(record) {
+				s.clear(reflect.ValueOf(&record).Elem(), ColumnDeletedAt)
+				s.put(id, record, now)
+			}
+		}
+		return ctx.Err()
+	})
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_CRUD(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore[testPlainRecord](NewMemory(), "test")
+
+	item := &testPlainRecord{Name: "a"}
+	require.NoError(t, s.Create(ctx, item))
+	assert.Len(t, item.ID, 36)
+
+	got, err := s.GetByID(ctx, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, *item, got)
+
+	item.Name = "b"
+	require.NoError(t, s.Update(ctx, item))
+	all, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []testPlainRecord{{ID: item.ID, Name: "b"}}, all)
+
+	assert.Error(t, s.Create(ctx, &testPlainRecord{ID: item.ID}))
+
+	require.NoError(t, s.Delete(ctx, item.ID))
+	_, err = s.GetByID(ctx, item.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.ErrorIs(t, s.Delete(ctx, item.ID), sql.ErrNoRows)
+	assert.ErrorIs(t, s.Update(ctx, item), sql.ErrNoRows)
+}
+
+func TestMemoryStore_Timestamps(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore[testRecord](NewMemory(), "test")
+
+	item := &testRecord{Name: "a"}
+	require.NoError(t, s.Create(ctx, item))
+	assert.False(t, item.CreatedAt.IsZero())
+	assert.Equal(t, item.CreatedAt, item.UpdatedAt)
+
+	created := item.CreatedAt
+	update := &testRecord{ID: item.ID, Name: "b"}
+	require.NoError(t, s.Update(ctx, update))
+	assert.Equal(t, created, update.CreatedAt)
+	assert.False(t, update.UpdatedAt.Before(created))
+}
+
+func TestMemoryStore_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore[testReadOnlyRecord](NewMemory(), "test")
+
+	seen := time.Now()
+	item := &testReadOnlyRecord{Name: "a", Seen: &seen}
+	require.NoError(t, s.Create(ctx, item))
+	assert.Nil(t, item.Seen, "readonly columns are not written on create")
+
+	require.NoError(t, s.Modify(ctx, item.ID, func(r *testReadOnlyRecord) { r.Seen = &seen }))
+	update := &testReadOnlyRecord{ID: item.ID, Name: "b"}
+	require.NoError(t, s.Update(ctx, update))
+	require.NotNil(t, update.Seen, "readonly columns are kept on update")
+	assert.True(t, seen.Equal(*update.Seen))
+
+	assert.ErrorIs(t, s.Modify(ctx, "missing", func(*testReadOnlyRecord) {}), sql.ErrNoRows)
+	assert.ErrorIs(t, s.Create(ctx, &testReadOnlyRecord{ID: item.ID}), ErrDuplicate)
+}
+
+func TestMemorySoftDeleteStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySoftDeleteStore[testRecord](NewMemory(), "test")
+
+	a, b := &testRecord{Name: "a"}, &testRecord{Name: "b"}
+	require.NoError(t, s.Create(ctx, a))
+	require.NoError(t, s.Create(ctx, b))
+	require.NoError(t, s.Delete(ctx, a.ID))
+
+	live, err := s.GetByIDs(ctx, []string{a.ID, b.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []testRecord{*b}, live)
+
+	trash, err := s.GetTrash(ctx)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.NotNil(t, trash[0].DeletedAt)
+
+	require.NoError(t, s.Restore(ctx, a.ID))
+	assert.ErrorIs(t, s.Restore(ctx, a.ID), sql.ErrNoRows)
+	_, err = s.GetByID(ctx, a.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Delete(ctx, a.ID))
+	n, err := s.Purge(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = s.Purge(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.ErrorIs(t, s.Restore(ctx, a.ID), sql.ErrNoRows)
+
+	assert.Panics(t, func() { NewMemorySoftDeleteStore[testPlainRecord](NewMemory(), "test") })
+}
+
+func TestMemorySoftDeleteStore_Where(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	s := NewMemorySoftDeleteStore[testRecord](mem, "test")
+	shared := NewMemorySoftDeleteStore[testRecord](mem, "test")
+
+	a, b, c := &testRecord{Name: "a"}, &testRecord{Name: "b"}, &testRecord{Name: "c"}
+	for _, r := range []*testRecord{a, b, c} {
+		require.NoError(t, s.Create(ctx, r))
+	}
+	_, err := shared.GetByID(ctx, a.ID)
+	require.NoError(t, err, "stores of a table share its records")
+	assert.Panics(t, func() { NewMemoryStore[testPlainRecord](mem, "test") })
+
+	require.NoError(t, s.Delete(ctx, c.ID))
+	at := time.Now()
+	require.NoError(t, s.TrashWhere(ctx, at, func(r testRecord) bool { return r.Name != "b" }))
+	assert.True(t, mem.InTrash("test", a.ID))
+	assert.False(t, mem.InTrash("test", b.ID))
+
+	require.NoError(t, s.RestoreWhere(ctx, at, func(testRecord) bool { return true }))
+	assert.False(t, mem.InTrash("test", a.ID))
+	assert.True(t, mem.InTrash("test", c.ID), "records deleted on their own stay in the trash")
+
+	n, err := s.PurgeWhere(ctx, time.Now().Add(time.Second), func(r testRecord) bool { return r.ID != c.ID })
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestMemory_InTx(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	s := NewMemoryStore[testPlainRecord](mem, "test")
+	kept := &testPlainRecord{Name: "kept"}
+	require.NoError(t, s.Create(ctx, kept))
+
+	t.Run("commit", func(t *testing.T) {
+		err := mem.InTx(ctx, func(ctx context.Context) error {
+			return s.Create(ctx, &testPlainRecord{Name: "committed"})
+		})
+		require.NoError(t, err)
+		all, _ := s.GetAll(ctx)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		failed := errors.New("failed")
+		err := mem.InTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, s.Create(ctx, &testPlainRecord{Name: "rolled back"}))
+			require.NoError(t, s.Delete(ctx, kept.ID))
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		all, _ := s.GetAll(ctx)
+		assert.Len(t, all, 2)
+		_, err = s.GetByID(ctx, kept.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("nested rollback keeps the outer unit", func(t *testing.T) {
+		err := mem.InTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, s.Create(ctx, &testPlainRecord{Name: "outer"}))
+			_ = mem.InTx(ctx, func(ctx context.Context) error {
+				require.NoError(t, s.Create(ctx, &testPlainRecord{Name: "inner"}))
+				return errors.New("failed")
+			})
+			return nil
+		})
+		require.NoError(t, err)
+		all, _ := s.GetAll(ctx)
+		assert.Len(t, all, 3)
+		assert.Equal(t, "outer", all[2].Name)
+	})
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	s := NewMemoryStore[testPlainRecord](mem, "test")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = mem.InTx(ctx, func(ctx context.Context) error {
+				return s.Create(ctx, &testPlainRecord{Name: "a"})
+			})
+			_, _ = s.GetAll(ctx)
+		}()
+	}
+	wg.Wait()
+
+	all, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 20)
+}
+
+func TestMemoryStore_NullTimestamps(t *testing.T) {
+	type nullTimeRecord struct {
+		ID        string       `db:"id"`
+		CreatedAt sql.NullTime `db:"created_at"`
+		UpdatedAt sql.NullTime `db:"updated_at"`
+		DeletedAt sql.NullTime `db:"deleted_at"`
+	}
+	ctx := context.Background()
+	s := NewMemorySoftDeleteStore[nullTimeRecord](NewMemory(), "test")
+
+	item := &nullTimeRecord{}
+	require.NoError(t, s.Create(ctx, item))
+	assert.True(t, item.CreatedAt.Valid)
+	assert.Equal(t, item.CreatedAt, item.UpdatedAt)
+
+	require.NoError(t, s.Delete(ctx, item.ID))
+	_, err := s.GetByID(ctx, item.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	trash, err := s.GetTrash(ctx)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.True(t, trash[0].DeletedAt.Valid)
+}
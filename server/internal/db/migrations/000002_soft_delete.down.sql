@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS mendel_core.plant_pollen_id_idx;
+DROP INDEX IF EXISTS mendel_core.plant_seed_id_idx;
+DROP INDEX IF EXISTS mendel_core.plant_cultivar_id_idx;
+DROP INDEX IF EXISTS mendel_core.plant_species_id_idx;
+DROP INDEX IF EXISTS mendel_core.plant_cultivar_species_id_idx;
+
+DROP INDEX IF EXISTS mendel_core.plant_deleted_at_idx;
+DROP INDEX IF EXISTS mendel_core.plant_cultivar_deleted_at_idx;
+DROP INDEX IF EXISTS mendel_core.plant_species_deleted_at_idx;
+
+-- Rows still in the trash become live again rather than being destroyed
+
+ALTER TABLE mendel_core.plant DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE mendel_core.plant_cultivar DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE mendel_core.plant_species DROP COLUMN IF EXISTS deleted_at;
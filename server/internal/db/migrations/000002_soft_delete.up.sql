@@ -0,0 +1,13 @@
+ALTER TABLE mendel_core.plant_species ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE mendel_core.plant_cultivar ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE mendel_core.plant ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS plant_species_deleted_at_idx ON mendel_core.plant_species (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS plant_cultivar_deleted_at_idx ON mendel_core.plant_cultivar (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS plant_deleted_at_idx ON mendel_core.plant (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS plant_cultivar_species_id_idx ON mendel_core.plant_cultivar (species_id);
+CREATE INDEX IF NOT EXISTS plant_species_id_idx ON mendel_core.plant (species_id);
+CREATE INDEX IF NOT EXISTS plant_cultivar_id_idx ON mendel_core.plant (cultivar_id);
+CREATE INDEX IF NOT EXISTS plant_seed_id_idx ON mendel_core.plant (seed_id);
+CREATE INDEX IF NOT EXISTS plant_pollen_id_idx ON mendel_core.plant (pollen_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS mendel_core.users_username_idx;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS mendel_core.api_tokens;
+DROP TABLE IF EXISTS mendel_core.sessions;
+DROP TABLE IF EXISTS mendel_core.credentials;
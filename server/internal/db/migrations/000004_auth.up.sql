@@ -0,0 +1,33 @@
+-- Passwords live apart from users so user records never carry a hash.
+-- A credential's id is the id of the user it belongs to.
+CREATE TABLE IF NOT EXISTS mendel_core.credentials (
+    id UUID PRIMARY KEY REFERENCES mendel_core.users (id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Sessions and API tokens store a SHA-256 hash of their secret, never the secret itself
+CREATE TABLE IF NOT EXISTS mendel_core.sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES mendel_core.users (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON mendel_core.sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_expires_at_idx ON mendel_core.sessions (expires_at);
+
+CREATE TABLE IF NOT EXISTS mendel_core.api_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES mendel_core.users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes JSONB NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_user_id_idx ON mendel_core.api_tokens (user_id);
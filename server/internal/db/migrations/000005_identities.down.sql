@@ -0,0 +1 @@
+DROP TABLE IF EXISTS mendel_core.identities;
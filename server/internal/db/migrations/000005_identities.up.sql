@@ -0,0 +1,11 @@
+-- An identity links a user to the subject an OpenID Connect provider signs them in as
+CREATE TABLE IF NOT EXISTS mendel_core.identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES mendel_core.users (id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS identities_user_id_idx ON mendel_core.identities (user_id);
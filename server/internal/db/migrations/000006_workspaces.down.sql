@@ -0,0 +1,22 @@
+ALTER TABLE mendel_core.plant DROP CONSTRAINT IF EXISTS plant_pollen_workspace_fkey;
+ALTER TABLE mendel_core.plant DROP CONSTRAINT IF EXISTS plant_seed_workspace_fkey;
+ALTER TABLE mendel_core.plant DROP CONSTRAINT IF EXISTS plant_species_workspace_fkey;
+ALTER TABLE mendel_core.plant DROP CONSTRAINT IF EXISTS plant_cultivar_workspace_fkey;
+ALTER TABLE mendel_core.plant_cultivar DROP CONSTRAINT IF EXISTS plant_cultivar_species_workspace_fkey;
+
+ALTER TABLE mendel_core.plant DROP CONSTRAINT IF EXISTS plant_id_workspace_id_key;
+ALTER TABLE mendel_core.plant_cultivar DROP CONSTRAINT IF EXISTS plant_cultivar_id_workspace_id_key;
+ALTER TABLE mendel_core.plant_species DROP CONSTRAINT IF EXISTS plant_species_id_workspace_id_key;
+
+DROP INDEX IF EXISTS mendel_core.plant_workspace_id_idx;
+DROP INDEX IF EXISTS mendel_core.plant_cultivar_workspace_id_idx;
+DROP INDEX IF EXISTS mendel_core.plant_species_workspace_id_idx;
+
+-- Records of every workspace are kept and become visible to everyone again
+
+ALTER TABLE mendel_core.plant DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE mendel_core.plant_cultivar DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE mendel_core.plant_species DROP COLUMN IF EXISTS workspace_id;
+
+DROP TABLE IF EXISTS mendel_core.workspace_members;
+DROP TABLE IF EXISTS mendel_core.workspaces;
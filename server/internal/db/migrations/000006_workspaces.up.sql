@@ -0,0 +1,59 @@
+-- A workspace holds one team's species, cultivars and plants. Users only see the records of the
+-- workspaces they are members of.
+CREATE TABLE IF NOT EXISTS mendel_core.workspaces (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS mendel_core.workspace_members (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES mendel_core.workspaces (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES mendel_core.users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (workspace_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS workspace_members_user_id_idx ON mendel_core.workspace_members (user_id);
+
+-- Existing records and users move to the default workspace
+INSERT INTO mendel_core.workspaces (id, name)
+VALUES ('00000000-0000-0000-0000-000000000001', 'Default')
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO mendel_core.workspace_members (workspace_id, user_id)
+SELECT '00000000-0000-0000-0000-000000000001', id FROM mendel_core.users
+ON CONFLICT (workspace_id, user_id) DO NOTHING;
+
+ALTER TABLE mendel_core.plant_species ADD COLUMN IF NOT EXISTS workspace_id UUID REFERENCES mendel_core.workspaces (id) ON DELETE CASCADE;
+ALTER TABLE mendel_core.plant_cultivar ADD COLUMN IF NOT EXISTS workspace_id UUID REFERENCES mendel_core.workspaces (id) ON DELETE CASCADE;
+ALTER TABLE mendel_core.plant ADD COLUMN IF NOT EXISTS workspace_id UUID REFERENCES mendel_core.workspaces (id) ON DELETE CASCADE;
+
+UPDATE mendel_core.plant_species SET workspace_id = '00000000-0000-0000-0000-000000000001' WHERE workspace_id IS NULL;
+UPDATE mendel_core.plant_cultivar SET workspace_id = '00000000-0000-0000-0000-000000000001' WHERE workspace_id IS NULL;
+UPDATE mendel_core.plant SET workspace_id = '00000000-0000-0000-0000-000000000001' WHERE workspace_id IS NULL;
+
+ALTER TABLE mendel_core.plant_species ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE mendel_core.plant_cultivar ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE mendel_core.plant ALTER COLUMN workspace_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS plant_species_workspace_id_idx ON mendel_core.plant_species (workspace_id);
+CREATE INDEX IF NOT EXISTS plant_cultivar_workspace_id_idx ON mendel_core.plant_cultivar (workspace_id);
+CREATE INDEX IF NOT EXISTS plant_workspace_id_idx ON mendel_core.plant (workspace_id);
+
+-- References never cross workspaces: every foreign key between records also matches workspace_id
+ALTER TABLE mendel_core.plant_species ADD CONSTRAINT plant_species_id_workspace_id_key UNIQUE (id, workspace_id);
+ALTER TABLE mendel_core.plant_cultivar ADD CONSTRAINT plant_cultivar_id_workspace_id_key UNIQUE (id, workspace_id);
+ALTER TABLE mendel_core.plant ADD CONSTRAINT plant_id_workspace_id_key UNIQUE (id, workspace_id);
+
+ALTER TABLE mendel_core.plant_cultivar ADD CONSTRAINT plant_cultivar_species_workspace_fkey
+    FOREIGN KEY (species_id, workspace_id) REFERENCES mendel_core.plant_species (id, workspace_id) ON DELETE CASCADE;
+ALTER TABLE mendel_core.plant ADD CONSTRAINT plant_cultivar_workspace_fkey
+    FOREIGN KEY (cultivar_id, workspace_id) REFERENCES mendel_core.plant_cultivar (id, workspace_id) ON DELETE CASCADE;
+ALTER TABLE mendel_core.plant ADD CONSTRAINT plant_species_workspace_fkey
+    FOREIGN KEY (species_id, workspace_id) REFERENCES mendel_core.plant_species (id, workspace_id) ON DELETE CASCADE;
+ALTER TABLE mendel_core.plant ADD CONSTRAINT plant_seed_workspace_fkey
+    FOREIGN KEY (seed_id, workspace_id) REFERENCES mendel_core.plant (id, workspace_id);
+ALTER TABLE mendel_core.plant ADD CONSTRAINT plant_pollen_workspace_fkey
+    FOREIGN KEY (pollen_id, workspace_id) REFERENCES mendel_core.plant (id, workspace_id);
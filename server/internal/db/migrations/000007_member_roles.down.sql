@@ -0,0 +1 @@
+ALTER TABLE mendel_core.workspace_members DROP COLUMN IF EXISTS role;
@@ -0,0 +1,7 @@
+-- A member's role decides what they may do in their workspace.
+-- Members from before roles existed could do anything, so they become owners.
+ALTER TABLE mendel_core.workspace_members
+    ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'owner'
+    CONSTRAINT workspace_members_role_check CHECK (role IN ('owner', 'breeder', 'observer', 'guest'));
+
+ALTER TABLE mendel_core.workspace_members ALTER COLUMN role DROP DEFAULT;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS audit_log_immutable ON mendel_core.audit_log;
+DROP FUNCTION IF EXISTS mendel_core.audit_log_immutable();
+DROP TABLE IF EXISTS mendel_core.audit_log;
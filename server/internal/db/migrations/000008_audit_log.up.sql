@@ -0,0 +1,31 @@
+-- The audit log records every write made to a record through the API, in the record's workspace.
+-- actor_id has no foreign key so entries outlive the users who made them.
+CREATE TABLE IF NOT EXISTS mendel_core.audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES mendel_core.workspaces (id) ON DELETE CASCADE,
+    actor_id UUID,
+    component TEXT NOT NULL,
+    record_id UUID NOT NULL,
+    action TEXT NOT NULL CONSTRAINT audit_log_action_check CHECK (action IN ('create', 'update', 'delete', 'restore')),
+    request_id TEXT NOT NULL DEFAULT '',
+    changes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_record_idx ON mendel_core.audit_log (workspace_id, component, record_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON mendel_core.audit_log (workspace_id, created_at);
+
+-- entries are never changed once written
+CREATE OR REPLACE FUNCTION mendel_core.audit_log_immutable()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log entries cannot be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_immutable ON mendel_core.audit_log;
+
+CREATE TRIGGER audit_log_immutable
+BEFORE UPDATE ON mendel_core.audit_log
+FOR EACH ROW
+EXECUTE FUNCTION mendel_core.audit_log_immutable();
@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS plant_versioning ON mendel_core.plant;
+DROP TRIGGER IF EXISTS plant_cultivar_versioning ON mendel_core.plant_cultivar;
+DROP TRIGGER IF EXISTS plant_species_versioning ON mendel_core.plant_species;
+
+DROP TABLE IF EXISTS mendel_core.plant_history;
+DROP TABLE IF EXISTS mendel_core.plant_cultivar_history;
+DROP TABLE IF EXISTS mendel_core.plant_species_history;
+
+DROP FUNCTION IF EXISTS mendel_core.trigger_versioning();
+
+ALTER TABLE mendel_core.plant DROP COLUMN IF EXISTS valid_from;
+ALTER TABLE mendel_core.plant_cultivar DROP COLUMN IF EXISTS valid_from;
+ALTER TABLE mendel_core.plant_species DROP COLUMN IF EXISTS valid_from;
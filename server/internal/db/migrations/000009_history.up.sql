@@ -0,0 +1,73 @@
+-- Species, cultivars and plants are system-versioned so reads can ask how records were at a past
+-- time. valid_from is when the current version of a record was written. As a record is changed or
+-- deleted, a trigger copies the version it replaces to the table's history table, adding valid_to,
+-- when it stopped being current. History tables have no keys or foreign keys, so past versions
+-- outlive what they referred to, and must gain every column their table does, in the same order.
+CREATE OR REPLACE FUNCTION mendel_core.trigger_versioning()
+RETURNS TRIGGER AS $$
+BEGIN
+    EXECUTE format('INSERT INTO %I.%I SELECT ($1).*, $2', TG_TABLE_SCHEMA, TG_TABLE_NAME || '_history')
+    USING OLD, NOW();
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    NEW.valid_from = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- existing records are taken to have been current since they were last written; the update
+-- timestamp trigger is disabled so backfilling leaves updated_at alone
+ALTER TABLE mendel_core.plant_species ADD COLUMN IF NOT EXISTS valid_from TIMESTAMP WITH TIME ZONE;
+ALTER TABLE mendel_core.plant_species DISABLE TRIGGER USER;
+UPDATE mendel_core.plant_species SET valid_from = COALESCE(GREATEST(created_at, updated_at, deleted_at), NOW()) WHERE valid_from IS NULL;
+ALTER TABLE mendel_core.plant_species ENABLE TRIGGER USER;
+ALTER TABLE mendel_core.plant_species ALTER COLUMN valid_from SET DEFAULT NOW(), ALTER COLUMN valid_from SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS mendel_core.plant_species_history (LIKE mendel_core.plant_species);
+ALTER TABLE mendel_core.plant_species_history ADD COLUMN IF NOT EXISTS valid_to TIMESTAMP WITH TIME ZONE NOT NULL;
+CREATE INDEX IF NOT EXISTS plant_species_history_id_idx ON mendel_core.plant_species_history (id, valid_from);
+CREATE INDEX IF NOT EXISTS plant_species_history_workspace_id_idx ON mendel_core.plant_species_history (workspace_id, valid_from);
+
+DROP TRIGGER IF EXISTS plant_species_versioning ON mendel_core.plant_species;
+
+CREATE TRIGGER plant_species_versioning
+BEFORE UPDATE OR DELETE ON mendel_core.plant_species
+FOR EACH ROW
+EXECUTE FUNCTION mendel_core.trigger_versioning();
+
+ALTER TABLE mendel_core.plant_cultivar ADD COLUMN IF NOT EXISTS valid_from TIMESTAMP WITH TIME ZONE;
+ALTER TABLE mendel_core.plant_cultivar DISABLE TRIGGER USER;
+UPDATE mendel_core.plant_cultivar SET valid_from = COALESCE(GREATEST(created_at, updated_at, deleted_at), NOW()) WHERE valid_from IS NULL;
+ALTER TABLE mendel_core.plant_cultivar ENABLE TRIGGER USER;
+ALTER TABLE mendel_core.plant_cultivar ALTER COLUMN valid_from SET DEFAULT NOW(), ALTER COLUMN valid_from SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS mendel_core.plant_cultivar_history (LIKE mendel_core.plant_cultivar);
+ALTER TABLE mendel_core.plant_cultivar_history ADD COLUMN IF NOT EXISTS valid_to TIMESTAMP WITH TIME ZONE NOT NULL;
+CREATE INDEX IF NOT EXISTS plant_cultivar_history_id_idx ON mendel_core.plant_cultivar_history (id, valid_from);
+CREATE INDEX IF NOT EXISTS plant_cultivar_history_workspace_id_idx ON mendel_core.plant_cultivar_history (workspace_id, valid_from);
+
+DROP TRIGGER IF EXISTS plant_cultivar_versioning ON mendel_core.plant_cultivar;
+
+CREATE TRIGGER plant_cultivar_versioning
+BEFORE UPDATE OR DELETE ON mendel_core.plant_cultivar
+FOR EACH ROW
+EXECUTE FUNCTION mendel_core.trigger_versioning();
+
+ALTER TABLE mendel_core.plant ADD COLUMN IF NOT EXISTS valid_from TIMESTAMP WITH TIME ZONE;
+ALTER TABLE mendel_core.plant DISABLE TRIGGER USER;
+UPDATE mendel_core.plant SET valid_from = COALESCE(GREATEST(created_at, updated_at, deleted_at), NOW()) WHERE valid_from IS NULL;
+ALTER TABLE mendel_core.plant ENABLE TRIGGER USER;
+ALTER TABLE mendel_core.plant ALTER COLUMN valid_from SET DEFAULT NOW(), ALTER COLUMN valid_from SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS mendel_core.plant_history (LIKE mendel_core.plant);
+ALTER TABLE mendel_core.plant_history ADD COLUMN IF NOT EXISTS valid_to TIMESTAMP WITH TIME ZONE NOT NULL;
+CREATE INDEX IF NOT EXISTS plant_history_id_idx ON mendel_core.plant_history (id, valid_from);
+CREATE INDEX IF NOT EXISTS plant_history_workspace_id_idx ON mendel_core.plant_history (workspace_id, valid_from);
+
+DROP TRIGGER IF EXISTS plant_versioning ON mendel_core.plant;
+
+CREATE TRIGGER plant_versioning
+BEFORE UPDATE OR DELETE ON mendel_core.plant
+FOR EACH ROW
+EXECUTE FUNCTION mendel_core.trigger_versioning();
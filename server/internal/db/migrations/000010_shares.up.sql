@@ -0,0 +1,16 @@
+-- Shares are read-only links to a record, or a plant and its ancestors, for anyone holding their
+-- token. Tokens are signed rather than stored, so revoking a share marks it rather than deleting it.
+CREATE TABLE IF NOT EXISTS mendel_core.shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace_id UUID NOT NULL REFERENCES mendel_core.workspaces (id) ON DELETE CASCADE,
+    created_by UUID REFERENCES mendel_core.users (id) ON DELETE SET NULL,
+    component TEXT NOT NULL,
+    record_id UUID NOT NULL,
+    lineage BOOLEAN NOT NULL DEFAULT FALSE,
+    fields JSONB NOT NULL DEFAULT '[]',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS shares_workspace_id_idx ON mendel_core.shares (workspace_id, created_at);
@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	"github.com/kylep342/mendel/internal/constants"
+)
+
+// LockID is the Postgres advisory lock held while the server migrates at startup, so replicas
+// starting together migrate one at a time
+const LockID int64 = 0x6d656e64656c // "mendel"
+
+// files are the migrations of every dialect, built into the binary
+//
+//go:embed *.sql sqlite/*.sql
+var files embed.FS
+
+// Open opens the migrations of env's dialect: those in Database.MigrationsFolder when it is set,
+// otherwise those embedded in the binary
+func Open(env *constants.EnvConfig) (source.Driver, error) {
+	if folder := env.MigrationsPath(); folder != "" {
+		return source.Open("file://" + folder)
+	}
+	return embedded(env.Database.Dialect)
+}
+
+// Files returns the migrations of every dialect, laid out as this folder is: those in
+// Database.MigrationsFolder when it is set, otherwise those embedded in the binary
+func Files(env *constants.EnvConfig) fs.FS {
+	if env.Database.MigrationsFolder != "" {
+		return os.DirFS(env.Database.MigrationsFolder)
+	}
+	return files
+}
+
+// embedded opens the migrations of dialect built into the binary
+func embedded(dialect string) (source.Driver, error) {
+	dir := "."
+	if dialect == constants.DialectSQLite {
+		dir = constants.DialectSQLite
+	}
+	return iofs.New(files, dir)
+}
+
+// Version returns the version of the newest migration of dialect built into the binary, which is
+// the schema version its stores are written against
+func Version(dialect string) (int, error) {
+	src, err := embedded(dialect)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return Last(src)
+}
+
+// New creates the migrate instance of env's database and migrations
+func New(env *constants.EnvConfig) (*migrate.Migrate, error) {
+	src, err := Open(env)
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrate.NewWithSourceInstance("migrations", src, env.DBUrl())
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Up applies every pending migration through m and returns the version of the database
+func Up(m *migrate.Migrate) (uint, error) {
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return 0, err
+	}
+	version, _, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, nil
+	}
+	return version, err
+}
@@ -0,0 +1,422 @@
+package migrations
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kylep342/mendel/internal/constants"
+)
+
+// Severities of a Finding
+const (
+	// SeverityError marks statements that lose data or migrations that cannot run both ways
+	SeverityError = "error"
+	// SeverityWarning marks statements that lock a table in use for as long as they take
+	SeverityWarning = "warning"
+)
+
+// IgnoreDirective starts a comment silencing rules for the statement it precedes, is part of or
+// ends the line of, such as "-- lint:ignore drop-column the column was never written"
+const IgnoreDirective = "lint:ignore"
+
+// Finding is a problem Lint found in a migration
+type Finding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s %s: %s", f.File, f.Severity, f.Rule, f.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s %s: %s", f.File, f.Line, f.Severity, f.Rule, f.Message)
+}
+
+// fileName matches the names of migration files, capturing their version, identifier and direction
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Lint checks the migrations of every dialect in fsys, laid out as this folder is, and returns what
+// it finds ordered by file and line. Up migrations are checked for statements that drop data and,
+// on Postgres, that lock tables created by earlier migrations; every migration is checked for a
+// matching down or up file and a counterpart in the other dialect; and triggers are checked for
+// being created twice or named after another table.
+func Lint(fsys fs.FS) ([]Finding, error) {
+	var findings []Finding
+	// versions holds the up or down file of each version of each dialect
+	versions := map[string]map[string]string{}
+	for _, dialect := range []string{constants.DialectPostgres, constants.DialectSQLite} {
+		dir := "."
+		if dialect == constants.DialectSQLite {
+			dir = constants.DialectSQLite
+		}
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		files := map[string]map[string]string{}
+		for _, entry := range entries {
+			if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+				continue
+			}
+			file := path.Join(dir, entry.Name())
+			m := fileName.FindStringSubmatch(entry.Name())
+			if m == nil {
+				findings = append(findings, Finding{File: file, Severity: SeverityError, Rule: "file-name",
+					Message: "migration files are named VERSION_NAME.up.sql or VERSION_NAME.down.sql"})
+				continue
+			}
+			version, direction := strings.TrimLeft(m[1], "0"), m[3]
+			if files[version] == nil {
+				files[version] = map[string]string{}
+			}
+			if other, ok := files[version][direction]; ok {
+				findings = append(findings, Finding{File: file, Severity: SeverityError, Rule: "duplicate-version",
+					Message: fmt.Sprintf("%s has the same version", other)})
+				continue
+			}
+			files[version][direction] = file
+
+			if direction == "up" {
+				b, err := fs.ReadFile(fsys, file)
+				if err != nil {
+					return nil, err
+				}
+				findings = append(findings, lintSQL(file, dialect, string(b))...)
+			}
+		}
+
+		versions[dialect] = map[string]string{}
+		for version, pair := range files {
+			up, hasUp := pair["up"]
+			down, hasDown := pair["down"]
+			versions[dialect][version] = up
+			if !hasUp {
+				versions[dialect][version] = down
+			}
+			if !hasDown {
+				findings = append(findings, Finding{File: up, Severity: SeverityError, Rule: "missing-down",
+					Message: "the migration has no down file to revert it"})
+			}
+			if !hasUp {
+				findings = append(findings, Finding{File: down, Severity: SeverityError, Rule: "missing-up",
+					Message: "the migration has no up file"})
+			}
+		}
+	}
+
+	for dialect, other := range map[string]string{constants.DialectPostgres: constants.DialectSQLite, constants.DialectSQLite: constants.DialectPostgres} {
+		for version, file := range versions[dialect] {
+			if _, ok := versions[other][version]; !ok {
+				findings = append(findings, Finding{File: file, Severity: SeverityError, Rule: "missing-dialect",
+					Message: fmt.Sprintf("version %s has no %s migration", version, other)})
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Message < findings[j].Message
+	})
+	return findings, nil
+}
+
+// statement is one SQL statement of a migration, with comments removed, quoted text blanked and
+// whitespace collapsed
+type statement struct {
+	line   int
+	text   string
+	ignore map[string]bool
+}
+
+// Patterns of the statements Lint looks at, matched against statement.text
+var (
+	createTable   = regexp.MustCompile(`(?i)^CREATE (?:(?:TEMP|TEMPORARY|UNLOGGED) )?TABLE (?:IF NOT EXISTS )?([^\s(]+)`)
+	alterTable    = regexp.MustCompile(`(?i)^ALTER TABLE (?:IF EXISTS )?(?:ONLY )?([^\s(]+) (.*)$`)
+	renameTable   = regexp.MustCompile(`(?i)^RENAME TO (\S+)$`)
+	dropSchema    = regexp.MustCompile(`(?i)^DROP SCHEMA `)
+	dropTable     = regexp.MustCompile(`(?i)^DROP TABLE (?:IF EXISTS )?(.+?)(?: CASCADE| RESTRICT)?$`)
+	truncate      = regexp.MustCompile(`(?i)^TRUNCATE `)
+	deleteAll     = regexp.MustCompile(`(?i)^DELETE FROM (?:ONLY )?(\S+)$`)
+	createIndex   = regexp.MustCompile(`(?i)^CREATE (?:UNIQUE )?INDEX (CONCURRENTLY )?.*? ON (?:ONLY )?([^\s(]+)`)
+	fullRewrite   = regexp.MustCompile(`(?i)^(?:VACUUM (?:\(.*FULL.*\)|FULL)|CLUSTER)\b`)
+	createTrigger = regexp.MustCompile(`(?i)^CREATE (?:OR REPLACE )?(?:CONSTRAINT )?TRIGGER (?:IF NOT EXISTS )?(\S+) .*? ON (\S+)`)
+
+	alterType     = regexp.MustCompile(`(?i)^ALTER (?:COLUMN )?(\S+) (?:SET DATA )?TYPE `)
+	setNotNull    = regexp.MustCompile(`(?i)^ALTER (?:COLUMN )?(\S+) SET NOT NULL$`)
+	dropColumn    = regexp.MustCompile(`(?i)^DROP (?:COLUMN )?(?:IF EXISTS )?(\S+)`)
+	addColumn     = regexp.MustCompile(`(?i)^ADD (?:COLUMN )?(?:IF NOT EXISTS )?(\S+) `)
+	volatile      = regexp.MustCompile(`(?i)\bDEFAULT .*\b(?:gen_random_uuid|uuid_generate_v[14]|random|clock_timestamp|timeofday|nextval)\s*\(`)
+	addConstraint = regexp.MustCompile(`(?i)^ADD (?:CONSTRAINT \S+ )?(FOREIGN KEY|CHECK|UNIQUE|PRIMARY KEY|EXCLUDE)\b`)
+	notValid      = regexp.MustCompile(`(?i)\bNOT VALID$`)
+	usingIndex    = regexp.MustCompile(`(?i)\bUSING INDEX \S+$`)
+)
+
+// lintSQL checks the up migration file of dialect holding sql
+func lintSQL(file, dialect, sql string) []Finding {
+	var findings []Finding
+	report := func(s statement, severity, rule, format string, args ...any) {
+		if !s.ignore[rule] && !s.ignore["all"] {
+			findings = append(findings, Finding{File: file, Line: s.line, Severity: severity, Rule: rule, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+	postgres := dialect == constants.DialectPostgres
+
+	// tables created by this migration are empty and unused, so changing them is safe
+	created := map[string]bool{}
+	triggers := map[string]int{}
+	statements := splitStatements(sql)
+	// a table rebuilt as SQLite changes tables is dropped before its copy is renamed to replace it
+	rebuilt := map[string]bool{}
+	for _, s := range statements {
+		if m := alterTable.FindStringSubmatch(s.text); m != nil {
+			if m := renameTable.FindStringSubmatch(m[2]); m != nil {
+				rebuilt[tableName(m[1])] = true
+			}
+		}
+	}
+	for _, s := range statements {
+		if m := createTable.FindStringSubmatch(s.text); m != nil {
+			created[tableName(m[1])] = true
+			continue
+		}
+		if dropSchema.MatchString(s.text) {
+			report(s, SeverityError, "drop-schema", "drops a schema and every table in it")
+			continue
+		}
+		if m := dropTable.FindStringSubmatch(s.text); m != nil {
+			for _, table := range strings.Split(m[1], ",") {
+				if table = tableName(table); !created[table] && !rebuilt[table] {
+					report(s, SeverityError, "drop-table", "drops the table %s and its records", table)
+				}
+			}
+			continue
+		}
+		if truncate.MatchString(s.text) {
+			report(s, SeverityError, "truncate", "deletes every record of a table")
+			continue
+		}
+		if m := deleteAll.FindStringSubmatch(s.text); m != nil && !created[tableName(m[1])] {
+			report(s, SeverityError, "delete-all", "deletes every record of %s; add a WHERE clause", tableName(m[1]))
+			continue
+		}
+		if m := createTrigger.FindStringSubmatch(s.text); m != nil {
+			name, table := tableName(m[1]), tableName(m[2])
+			key := name + " ON " + table
+			if first, ok := triggers[key]; ok {
+				report(s, SeverityError, "duplicate-trigger", "trigger %s on %s is created again, after line %d; was it meant for another table?", name, table, first)
+			} else {
+				triggers[key] = s.line
+			}
+			if unqualified := table[strings.LastIndex(table, ".")+1:]; !strings.HasPrefix(name, unqualified+"_") {
+				report(s, SeverityWarning, "trigger-name", "trigger %s is on %s; name it %s_...", name, table, unqualified)
+			}
+			continue
+		}
+		if m := createIndex.FindStringSubmatch(s.text); m != nil {
+			if postgres && m[1] == "" && !created[tableName(m[2])] {
+				report(s, SeverityWarning, "create-index", "blocks writes to %s while the index builds; use CREATE INDEX CONCURRENTLY in a migration of its own", tableName(m[2]))
+			}
+			continue
+		}
+		if postgres && fullRewrite.MatchString(s.text) {
+			report(s, SeverityWarning, "rewrite", "rewrites a table under an exclusive lock")
+			continue
+		}
+		if m := alterTable.FindStringSubmatch(s.text); m != nil {
+			table := tableName(m[1])
+			for _, action := range splitTopLevel(m[2]) {
+				if m := renameTable.FindStringSubmatch(action); m != nil {
+					// a rebuilt table replaces the one it is renamed to
+					created[tableName(m[1])] = true
+					continue
+				}
+				if created[table] {
+					continue
+				}
+				if m := dropColumn.FindStringSubmatch(action); m != nil && !strings.EqualFold(m[1], "CONSTRAINT") {
+					report(s, SeverityError, "drop-column", "drops the column %s of %s and its values", strings.ToLower(m[1]), table)
+				}
+				if !postgres {
+					continue
+				}
+				if m := alterType.FindStringSubmatch(action); m != nil {
+					report(s, SeverityWarning, "alter-type", "rewrites %s under an exclusive lock to change the type of %s", table, strings.ToLower(m[1]))
+				}
+				if m := setNotNull.FindStringSubmatch(action); m != nil {
+					report(s, SeverityWarning, "set-not-null", "scans %s under an exclusive lock to check %s; add a CHECK (%s IS NOT NULL) NOT VALID constraint and validate it first", table, strings.ToLower(m[1]), strings.ToLower(m[1]))
+				}
+				if m := addColumn.FindStringSubmatch(action); m != nil && !addConstraint.MatchString(action) && volatile.MatchString(action) {
+					report(s, SeverityWarning, "volatile-default", "rewrites %s under an exclusive lock to fill %s with a volatile default", table, strings.ToLower(m[1]))
+				}
+				if m := addConstraint.FindStringSubmatch(action); m != nil && !notValid.MatchString(action) && !usingIndex.MatchString(action) {
+					switch kind := strings.ToUpper(m[1]); kind {
+					case "FOREIGN KEY", "CHECK":
+						report(s, SeverityWarning, "add-constraint", "checks every record of %s under a lock to add a %s constraint; add it NOT VALID and VALIDATE CONSTRAINT in a later migration", table, kind)
+					default:
+						report(s, SeverityWarning, "add-constraint", "builds an index on %s under an exclusive lock to add a %s constraint; build a unique index CONCURRENTLY and add the constraint USING INDEX", table, kind)
+					}
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// tableName returns name unquoted and lowercased, as Postgres and SQLite compare it
+func tableName(name string) string {
+	return strings.ToLower(strings.NewReplacer(`"`, "", "`", "", ";", "").Replace(strings.TrimSpace(name)))
+}
+
+// splitTopLevel splits the actions of an ALTER TABLE on the commas outside parentheses
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// dollarTag matches the opening of a dollar-quoted string, such as $$ or $body$
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// splitStatements splits sql into its statements. The bodies of SQLite triggers, between BEGIN and
+// END, stay in the statement creating them. Comments are dropped, but for ignore directives, and
+// quoted strings are blanked so their content cannot match a pattern.
+func splitStatements(sql string) []statement {
+	var statements []statement
+	var text strings.Builder
+	current := statement{ignore: map[string]bool{}}
+	line, depth := 1, 0
+	// endLine is the line the last statement ended on, whose ignore directives may follow it
+	endLine := 0
+	var word strings.Builder
+
+	endWord := func() {
+		w := strings.ToUpper(word.String())
+		word.Reset()
+		if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(text.String())), "CREATE") ||
+			!strings.Contains(strings.ToUpper(text.String()), "TRIGGER") {
+			return
+		}
+		switch w {
+		case "BEGIN", "CASE":
+			depth++
+		case "END":
+			depth--
+		}
+	}
+	write := func(s string) {
+		if current.line == 0 && strings.TrimSpace(s) != "" {
+			current.line = line
+		}
+		text.WriteString(s)
+	}
+	end := func() {
+		if t := strings.Join(strings.Fields(text.String()), " "); t != "" {
+			current.text = t
+			statements = append(statements, current)
+		}
+		text.Reset()
+		current = statement{ignore: map[string]bool{}}
+		depth, endLine = 0, line
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		isWord := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+		if isWord {
+			word.WriteByte(c)
+		} else if word.Len() > 0 {
+			endWord()
+		}
+
+		switch {
+		case c == '\n':
+			line++
+			text.WriteByte(' ')
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			n := strings.IndexByte(sql[i:], '\n')
+			if n < 0 {
+				n = len(sql) - i
+			}
+			comment := strings.TrimSpace(sql[i+2 : i+n])
+			if rules, ok := strings.CutPrefix(comment, IgnoreDirective); ok {
+				ignore := current.ignore
+				if strings.TrimSpace(text.String()) == "" && endLine == line && len(statements) > 0 {
+					ignore = statements[len(statements)-1].ignore
+				}
+				if fields := strings.Fields(rules); len(fields) > 0 {
+					for _, rule := range strings.Split(fields[0], ",") {
+						ignore[rule] = true
+					}
+				}
+			}
+			i += n - 1
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			n := strings.Index(sql[i+2:], "*/")
+			if n < 0 {
+				n = len(sql) - i - 2
+			}
+			line += strings.Count(sql[i:i+2+n], "\n")
+			text.WriteByte(' ')
+			i += n + 3
+		case c == '\'':
+			n := i + 1
+			for n < len(sql) {
+				if sql[n] == '\'' {
+					if n+1 < len(sql) && sql[n+1] == '\'' {
+						n += 2
+						continue
+					}
+					break
+				}
+				n++
+			}
+			line += strings.Count(sql[i:min(n+1, len(sql))], "\n")
+			write("''")
+			i = n
+		case c == '$' && dollarTag.MatchString(sql[i:]):
+			tag := dollarTag.FindString(sql[i:])
+			n := strings.Index(sql[i+len(tag):], tag)
+			if n < 0 {
+				n = len(sql) - i - len(tag)
+			}
+			line += strings.Count(sql[i:min(i+len(tag)+n+len(tag), len(sql))], "\n")
+			write("$$ $$")
+			i += len(tag) + n + len(tag) - 1
+		case c == ';' && depth <= 0:
+			end()
+		default:
+			write(string(c))
+		}
+	}
+	if word.Len() > 0 {
+		endWord()
+	}
+	end()
+	return statements
+}
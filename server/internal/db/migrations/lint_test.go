@@ -0,0 +1,140 @@
+package migrations
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rules returns the line and rule of each finding in file
+func rules(findings []Finding, file string) map[int][]string {
+	got := map[int][]string{}
+	for _, f := range findings {
+		if f.File == file {
+			got[f.Line] = append(got[f.Line], f.Rule)
+		}
+	}
+	return got
+}
+
+func TestLint(t *testing.T) {
+	up := `-- the new tables are empty, so nothing on them is reported
+CREATE TABLE seeds (id UUID, lot TEXT);
+CREATE INDEX seeds_lot_idx ON seeds (lot);
+ALTER TABLE seeds DROP COLUMN lot, ALTER COLUMN id SET NOT NULL;
+
+DROP SCHEMA IF EXISTS mendel_core CASCADE;
+DROP TABLE mendel_core.plant, seeds;
+ALTER TABLE mendel_core.plant DROP COLUMN labels, DROP CONSTRAINT plant_pkey;
+ALTER TABLE mendel_core.plant
+    ALTER COLUMN generation TYPE BIGINT,
+    ALTER COLUMN genetics SET NOT NULL,
+    ALTER COLUMN genetics DROP DEFAULT;
+ALTER TABLE mendel_core.plant ADD COLUMN tag UUID DEFAULT gen_random_uuid(), ADD COLUMN seen TIMESTAMP DEFAULT NOW();
+ALTER TABLE mendel_core.plant ADD CONSTRAINT plant_seed_fkey FOREIGN KEY (seed_id) REFERENCES mendel_core.plant (id);
+ALTER TABLE mendel_core.plant ADD CONSTRAINT plant_pollen_fkey FOREIGN KEY (pollen_id) REFERENCES mendel_core.plant (id) NOT VALID;
+ALTER TABLE mendel_core.plant ADD CONSTRAINT plant_tag_key UNIQUE (tag);
+CREATE INDEX plant_tag_idx ON mendel_core.plant (tag);
+CREATE INDEX CONCURRENTLY plant_seen_idx ON mendel_core.plant (seen);
+TRUNCATE mendel_core.plant;
+DELETE FROM mendel_core.plant;
+DELETE FROM mendel_core.plant WHERE generation > 10;
+VACUUM FULL mendel_core.plant;
+
+CREATE FUNCTION mendel_core.drop_nothing() RETURNS TRIGGER AS $body$
+BEGIN
+    DROP TABLE mendel_core.plant; -- in a function body, not run by the migration
+END;
+$body$ LANGUAGE plpgsql;
+INSERT INTO mendel_core.plant (labels) VALUES ('DROP TABLE x; DELETE FROM y');
+
+CREATE TRIGGER plant_update_timestamp BEFORE UPDATE ON mendel_core.plant FOR EACH ROW EXECUTE PROCEDURE trigger_update_timestamp();
+CREATE TRIGGER plant_update_timestamp BEFORE UPDATE ON mendel_core.plant FOR EACH ROW EXECUTE PROCEDURE trigger_update_timestamp();
+CREATE TRIGGER cultivar_update_timestamp BEFORE UPDATE ON mendel_core.plant FOR EACH ROW EXECUTE PROCEDURE trigger_update_timestamp();
+
+-- lint:ignore drop-column,alter-type the column was never written
+ALTER TABLE mendel_core.plant DROP COLUMN legacy, ALTER COLUMN generation TYPE INT;
+/* a block
+   comment */ DROP TABLE mendel_core.users; -- lint:ignore all
+`
+	sqliteUp := `CREATE TABLE plant_new (id TEXT);
+INSERT INTO plant_new SELECT id FROM plant;
+DROP TABLE plant;
+ALTER TABLE plant_new RENAME TO plant;
+CREATE INDEX plant_id_idx ON plant (id);
+ALTER TABLE plant_species DROP COLUMN taxon;
+
+CREATE TRIGGER IF NOT EXISTS plant_species_soft_delete
+AFTER UPDATE OF deleted_at ON plant_species
+BEGIN
+    UPDATE plant SET deleted_at = CASE WHEN NEW.deleted_at IS NULL THEN NULL ELSE NEW.deleted_at END;
+    DELETE FROM plant;
+END;
+ALTER TABLE plant_cultivar DROP COLUMN cultivar;
+`
+	fsys := fstest.MapFS{
+		"000001_init.up.sql":             {Data: []byte(up)},
+		"000001_init.down.sql":           {Data: []byte("DROP TABLE seeds;")},
+		"000002_only_up.up.sql":          {Data: []byte("SELECT 1;")},
+		"000003_only_down.down.sql":      {Data: []byte("SELECT 1;")},
+		"notes.sql":                      {Data: []byte("SELECT 1;")},
+		"sqlite/000001_init.up.sql":      {Data: []byte(sqliteUp)},
+		"sqlite/000001_init.down.sql":    {Data: []byte("SELECT 1;")},
+		"sqlite/000002_only_up.up.sql":   {Data: []byte("SELECT 1;")},
+		"sqlite/000002_only_up.down.sql": {Data: []byte("SELECT 1;")},
+		"sqlite/000004_extra.up.sql":     {Data: []byte("SELECT 1;")},
+		"sqlite/000004_extra.down.sql":   {Data: []byte("SELECT 1;")},
+	}
+	findings, err := Lint(fsys)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[int][]string{
+		6:  {"drop-schema"},
+		7:  {"drop-table"},
+		8:  {"drop-column"},
+		9:  {"alter-type", "set-not-null"},
+		13: {"volatile-default"},
+		14: {"add-constraint"},
+		16: {"add-constraint"},
+		17: {"create-index"},
+		19: {"truncate"},
+		20: {"delete-all"},
+		22: {"rewrite"},
+		32: {"duplicate-trigger"},
+		33: {"trigger-name"},
+	}, rules(findings, "000001_init.up.sql"))
+	assert.Equal(t, map[int][]string{
+		6:  {"drop-column"},
+		14: {"drop-column"},
+	}, rules(findings, "sqlite/000001_init.up.sql"), "rebuilt tables may be dropped and trigger bodies are part of their trigger")
+
+	assert.Equal(t, map[int][]string{0: {"missing-down"}}, rules(findings, "000002_only_up.up.sql"))
+	assert.Equal(t, map[int][]string{0: {"missing-up", "missing-dialect"}}, rules(findings, "000003_only_down.down.sql"))
+	assert.Equal(t, map[int][]string{0: {"missing-dialect"}}, rules(findings, "sqlite/000004_extra.up.sql"))
+	assert.Equal(t, map[int][]string{0: {"file-name"}}, rules(findings, "notes.sql"))
+
+	for _, f := range findings {
+		assert.NotEmpty(t, f.Message)
+		assert.Contains(t, f.String(), f.File)
+	}
+}
+
+func TestLint_Migrations(t *testing.T) {
+	findings, err := Lint(os.DirFS("."))
+	require.NoError(t, err)
+
+	var errs []string
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errs = append(errs, f.String())
+		}
+	}
+	// these were applied before the linter existed; new migrations must not add errors
+	assert.Equal(t, []string{
+		"000001_init_db.up.sql:1: error drop-schema: drops a schema and every table in it",
+		"000001_init_db.up.sql:90: error duplicate-trigger: trigger plant_cultivar_update_timestamp on mendel_core.plant_cultivar is created again, after line 66; was it meant for another table?",
+	}, errs)
+}
@@ -0,0 +1,184 @@
+// Package migrations holds the SQL migrations of every dialect, in this folder for Postgres and in
+// its sqlite subfolder for SQLite, embeds them in the binary and runs them, and helps db-migrate
+// preview and scaffold them.
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/source"
+
+	"github.com/kylep342/mendel/internal/constants"
+)
+
+// NilVersion is the version of a database no migration has been applied to, as migrate.Force takes it
+const NilVersion = -1
+
+// TimestampFormat is the format of the versions Scaffold gives new migrations
+const TimestampFormat = "20060102150405"
+
+// Step is one migration file that moving a database between versions runs
+type Step struct {
+	// Version is the version the file belongs to
+	Version uint
+	// Identifier is the name of the migration, such as soft_delete
+	Identifier string
+	// Up is false for down migrations
+	Up bool
+	// SQL is the content of the file; it is empty when the migration has no down file
+	SQL string
+}
+
+// Name returns the file name of s, such as 000002_soft_delete.up.sql
+func (s Step) Name() string {
+	direction := "down"
+	if s.Up {
+		direction = "up"
+	}
+	return fmt.Sprintf("%06d_%s.%s.sql", s.Version, s.Identifier, direction)
+}
+
+// Walk returns the version reached by applying n migrations of src to a database at version from,
+// up when n is positive and down when it is negative. It stops at the first or last migration when
+// fewer than n remain, as migrate.Steps does.
+func Walk(src source.Driver, from int, n int) (int, error) {
+	at := from
+	for ; n > 0; n-- {
+		next, err := nextVersion(src, at)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		at = int(next)
+	}
+	for ; n < 0 && at != NilVersion; n++ {
+		prev, err := src.Prev(uint(at))
+		if errors.Is(err, os.ErrNotExist) {
+			at = NilVersion
+		} else if err != nil {
+			return 0, err
+		} else {
+			at = int(prev)
+		}
+	}
+	return at, nil
+}
+
+// Last returns the version of the newest migration of src, or NilVersion if it has none
+func Last(src source.Driver) (int, error) {
+	return Walk(src, NilVersion, int(^uint(0)>>1))
+}
+
+// Plan returns the migrations of src that moving a database from version from to version to runs,
+// in the order they run
+func Plan(src source.Driver, from, to int) ([]Step, error) {
+	if to != NilVersion {
+		r, _, err := src.ReadUp(uint(to))
+		if err != nil {
+			return nil, fmt.Errorf("no migration has version %d: %w", to, err)
+		}
+		r.Close()
+	}
+	var steps []Step
+	for at := from; at < to; {
+		next, err := nextVersion(src, at)
+		if err != nil {
+			return nil, fmt.Errorf("no migration leads from version %d to %d: %w", at, to, err)
+		}
+		step, err := read(src, next, true)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+		at = int(next)
+	}
+	for at := from; at > to; {
+		step, err := read(src, uint(at), false)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+		if at, err = Walk(src, at, -1); err != nil {
+			return nil, err
+		}
+	}
+	return steps, nil
+}
+
+// nextVersion returns the version of the migration after version at of src
+func nextVersion(src source.Driver, at int) (uint, error) {
+	if at == NilVersion {
+		return src.First()
+	}
+	return src.Next(uint(at))
+}
+
+// read returns the step of the up or down migration of src at version
+func read(src source.Driver, version uint, up bool) (Step, error) {
+	readFile := src.ReadDown
+	if up {
+		readFile = src.ReadUp
+	}
+	r, identifier, err := readFile(version)
+	if !up && errors.Is(err, os.ErrNotExist) {
+		// migrate skips missing down files, so only the identifier of the up file is needed
+		if r, identifier, err = src.ReadUp(version); err == nil {
+			r.Close()
+			return Step{Version: version, Identifier: identifier}, nil
+		}
+	}
+	if err != nil {
+		return Step{}, fmt.Errorf("failed to read migration %d: %w", version, err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return Step{}, fmt.Errorf("failed to read migration %d: %w", version, err)
+	}
+	return Step{Version: version, Identifier: identifier, Up: up, SQL: string(b)}, nil
+}
+
+// validName matches the names Scaffold accepts, which become part of file names
+var validName = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// Scaffold returns the up and down files of a new migration called name in folder, for Postgres in
+// folder and for SQLite in its sqlite subfolder, versioned by the time at
+func Scaffold(folder, name string, at time.Time) ([]string, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q: use lowercase letters, digits and underscores", name)
+	}
+	version := at.UTC().Format(TimestampFormat)
+	var files []string
+	for _, dir := range []string{folder, filepath.Join(folder, constants.DialectSQLite)} {
+		for _, direction := range []string{"up", "down"} {
+			files = append(files, filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction)))
+		}
+	}
+	return files, nil
+}
+
+// Create creates the empty files Scaffold returned, failing without creating any if one exists
+func Create(files []string) error {
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			return fmt.Errorf("migration %s already exists", file)
+		}
+	}
+	for _, file := range files {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openFolder opens the migrations in folder, after writing files to it
+func openFolder(t *testing.T, folder string, files map[string]string) source.Driver {
+	t.Helper()
+	for name, sql := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(folder, name), []byte(sql), 0o644))
+	}
+	src, err := source.Open("file://" + folder)
+	require.NoError(t, err)
+	t.Cleanup(func() { src.Close() })
+	return src
+}
+
+func TestPlan(t *testing.T) {
+	src := openFolder(t, t.TempDir(), map[string]string{
+		"000001_init.up.sql":      "CREATE TABLE a (id INT);",
+		"000001_init.down.sql":    "DROP TABLE a;",
+		"000002_b.up.sql":         "CREATE TABLE b (id INT);",
+		"000002_b.down.sql":       "DROP TABLE b;",
+		"000005_index.up.sql":     "CREATE INDEX b_id ON b (id);",
+		"20260101000000_c.up.sql": "CREATE TABLE c (id INT);",
+	})
+
+	last, err := Last(src)
+	require.NoError(t, err)
+	assert.Equal(t, 20260101000000, last)
+
+	for _, tc := range []struct {
+		from, n, want int
+	}{
+		{NilVersion, 1, 1},
+		{NilVersion, 3, 5},
+		{2, 10, 20260101000000},
+		{5, -1, 2},
+		{2, -5, NilVersion},
+		{NilVersion, -1, NilVersion},
+	} {
+		got, err := Walk(src, tc.from, tc.n)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got, "%d steps from %d", tc.n, tc.from)
+	}
+
+	steps, err := Plan(src, NilVersion, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Step{
+		{Version: 1, Identifier: "init", Up: true, SQL: "CREATE TABLE a (id INT);"},
+		{Version: 2, Identifier: "b", Up: true, SQL: "CREATE TABLE b (id INT);"},
+	}, steps)
+	assert.Equal(t, "000001_init.up.sql", steps[0].Name())
+
+	steps, err = Plan(src, 5, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Step{
+		{Version: 5, Identifier: "index"},
+		{Version: 2, Identifier: "b", SQL: "DROP TABLE b;"},
+	}, steps, "migrations without a down file are listed without SQL")
+	assert.Equal(t, "000005_index.down.sql", steps[0].Name())
+
+	steps, err = Plan(src, 2, NilVersion)
+	require.NoError(t, err)
+	assert.Len(t, steps, 2)
+
+	steps, err = Plan(src, 5, 5)
+	require.NoError(t, err)
+	assert.Empty(t, steps)
+
+	_, err = Plan(src, 1, 3)
+	assert.Error(t, err, "version 3 does not exist")
+}
+
+func TestScaffold(t *testing.T) {
+	folder := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(folder, "sqlite"), 0o755))
+	at := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+
+	files, err := Scaffold(folder, "add_seeds", at)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(folder, "20261019083000_add_seeds.up.sql"),
+		filepath.Join(folder, "20261019083000_add_seeds.down.sql"),
+		filepath.Join(folder, "sqlite", "20261019083000_add_seeds.up.sql"),
+		filepath.Join(folder, "sqlite", "20261019083000_add_seeds.down.sql"),
+	}, files)
+
+	require.NoError(t, Create(files))
+	for _, file := range files {
+		assert.FileExists(t, file)
+	}
+	assert.Error(t, Create(files), "existing migrations are not overwritten")
+
+	for _, name := range []string{"", "Add seeds", "../seeds", "seeds_"} {
+		_, err := Scaffold(folder, name, at)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, dialect := range []string{constants.DialectPostgres, constants.DialectSQLite} {
+		env := &constants.EnvConfig{}
+		env.Database.Dialect = dialect
+		embedded, err := Open(env)
+		require.NoError(t, err)
+		defer embedded.Close()
+		env.Database.MigrationsFolder = "."
+		folder, err := Open(env)
+		require.NoError(t, err)
+		defer folder.Close()
+
+		last, err := Last(folder)
+		require.NoError(t, err)
+		want, err := Plan(folder, NilVersion, last)
+		require.NoError(t, err)
+		assert.NotEmpty(t, want)
+		got, err := Plan(embedded, NilVersion, last)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "%s: the binary embeds every migration", dialect)
+		version, err := Version(dialect)
+		require.NoError(t, err)
+		assert.Equal(t, last, version)
+
+		down, err := Plan(embedded, last, NilVersion)
+		require.NoError(t, err)
+		assert.Len(t, down, len(want), dialect)
+	}
+}
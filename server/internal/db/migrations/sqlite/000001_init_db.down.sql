@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS plant;
+DROP TABLE IF EXISTS plant_cultivar;
+DROP TABLE IF EXISTS plant_species;
+DROP TABLE IF EXISTS users;
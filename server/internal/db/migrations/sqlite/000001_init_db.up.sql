@@ -0,0 +1,43 @@
+-- SQLite has no schemas, JSONB or UUID type: tables live in the main database, JSON is
+-- stored as text, UUIDs are generated by the server and timestamps are ISO 8601 text
+
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    username VARCHAR(49) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    web_settings TEXT NOT NULL CHECK (json_valid(web_settings)),
+    last_login TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS plant_species (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    taxon TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS plant_cultivar (
+    id TEXT PRIMARY KEY,
+    species_id TEXT NOT NULL REFERENCES plant_species (id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    name TEXT NOT NULL,
+    cultivar TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    genetics TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(genetics))
+);
+
+CREATE TABLE IF NOT EXISTS plant (
+    id TEXT PRIMARY KEY,
+    cultivar_id TEXT NOT NULL REFERENCES plant_cultivar (id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    species_id TEXT NOT NULL REFERENCES plant_species (id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    seed_id TEXT REFERENCES plant (id),
+    pollen_id TEXT REFERENCES plant (id),
+    generation INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP,
+    genetics TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(genetics)),
+    labels TEXT NOT NULL DEFAULT '{}' CHECK (json_valid(labels))
+);
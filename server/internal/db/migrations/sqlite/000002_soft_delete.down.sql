@@ -0,0 +1,20 @@
+DROP TRIGGER IF EXISTS plant_cultivar_restore;
+DROP TRIGGER IF EXISTS plant_cultivar_soft_delete;
+DROP TRIGGER IF EXISTS plant_species_restore;
+DROP TRIGGER IF EXISTS plant_species_soft_delete;
+
+DROP INDEX IF EXISTS plant_pollen_id_idx;
+DROP INDEX IF EXISTS plant_seed_id_idx;
+DROP INDEX IF EXISTS plant_cultivar_id_idx;
+DROP INDEX IF EXISTS plant_species_id_idx;
+DROP INDEX IF EXISTS plant_cultivar_species_id_idx;
+
+DROP INDEX IF EXISTS plant_deleted_at_idx;
+DROP INDEX IF EXISTS plant_cultivar_deleted_at_idx;
+DROP INDEX IF EXISTS plant_species_deleted_at_idx;
+
+-- Rows still in the trash become live again rather than being destroyed
+
+ALTER TABLE plant DROP COLUMN deleted_at;
+ALTER TABLE plant_cultivar DROP COLUMN deleted_at;
+ALTER TABLE plant_species DROP COLUMN deleted_at;
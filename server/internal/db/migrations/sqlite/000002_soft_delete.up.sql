@@ -0,0 +1,47 @@
+ALTER TABLE plant_species ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE plant_cultivar ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE plant ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS plant_species_deleted_at_idx ON plant_species (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS plant_cultivar_deleted_at_idx ON plant_cultivar (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS plant_deleted_at_idx ON plant (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS plant_cultivar_species_id_idx ON plant_cultivar (species_id);
+CREATE INDEX IF NOT EXISTS plant_species_id_idx ON plant (species_id);
+CREATE INDEX IF NOT EXISTS plant_cultivar_id_idx ON plant (cultivar_id);
+CREATE INDEX IF NOT EXISTS plant_seed_id_idx ON plant (seed_id);
+CREATE INDEX IF NOT EXISTS plant_pollen_id_idx ON plant (pollen_id);
+
+-- SQLite cannot update other tables from a CTE, so the cascades the Postgres stores run in
+-- their delete and restore queries are triggers here. Children share their parent's
+-- deleted_at so a restore brings back exactly the records deleted with it.
+
+CREATE TRIGGER IF NOT EXISTS plant_species_soft_delete
+AFTER UPDATE OF deleted_at ON plant_species
+WHEN OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL
+BEGIN
+    UPDATE plant_cultivar SET deleted_at = NEW.deleted_at WHERE species_id = NEW.id AND deleted_at IS NULL;
+    UPDATE plant SET deleted_at = NEW.deleted_at WHERE species_id = NEW.id AND deleted_at IS NULL;
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_species_restore
+AFTER UPDATE OF deleted_at ON plant_species
+WHEN OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL
+BEGIN
+    UPDATE plant_cultivar SET deleted_at = NULL WHERE species_id = NEW.id AND deleted_at = OLD.deleted_at;
+    UPDATE plant SET deleted_at = NULL WHERE species_id = NEW.id AND deleted_at = OLD.deleted_at;
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_cultivar_soft_delete
+AFTER UPDATE OF deleted_at ON plant_cultivar
+WHEN OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL
+BEGIN
+    UPDATE plant SET deleted_at = NEW.deleted_at WHERE cultivar_id = NEW.id AND deleted_at IS NULL;
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_cultivar_restore
+AFTER UPDATE OF deleted_at ON plant_cultivar
+WHEN OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL
+BEGIN
+    UPDATE plant SET deleted_at = NULL WHERE cultivar_id = NEW.id AND deleted_at = OLD.deleted_at;
+END;
@@ -0,0 +1,2 @@
+-- Usernames are unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_idx ON users (lower(username));
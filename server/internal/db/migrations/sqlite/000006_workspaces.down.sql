@@ -0,0 +1,19 @@
+DROP TRIGGER IF EXISTS plant_workspace_update;
+DROP TRIGGER IF EXISTS plant_workspace_insert;
+DROP TRIGGER IF EXISTS plant_cultivar_workspace_update;
+DROP TRIGGER IF EXISTS plant_cultivar_workspace_insert;
+DROP TRIGGER IF EXISTS plant_species_workspace_update;
+DROP TRIGGER IF EXISTS plant_species_workspace_insert;
+
+DROP INDEX IF EXISTS plant_workspace_id_idx;
+DROP INDEX IF EXISTS plant_cultivar_workspace_id_idx;
+DROP INDEX IF EXISTS plant_species_workspace_id_idx;
+
+-- Records of every workspace are kept and become visible to everyone again
+
+ALTER TABLE plant DROP COLUMN workspace_id;
+ALTER TABLE plant_cultivar DROP COLUMN workspace_id;
+ALTER TABLE plant_species DROP COLUMN workspace_id;
+
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
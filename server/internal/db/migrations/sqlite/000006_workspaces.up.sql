@@ -0,0 +1,93 @@
+-- A workspace holds one team's species, cultivars and plants. Users only see the records of the
+-- workspaces they are members of.
+CREATE TABLE IF NOT EXISTS workspaces (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (workspace_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS workspace_members_user_id_idx ON workspace_members (user_id);
+
+-- Existing records and users move to the default workspace
+INSERT OR IGNORE INTO workspaces (id, name) VALUES ('00000000-0000-0000-0000-000000000001', 'Default');
+
+INSERT OR IGNORE INTO workspace_members (id, workspace_id, user_id)
+SELECT lower(substr(h, 1, 8) || '-' || substr(h, 9, 4) || '-4' || substr(h, 14, 3) || '-' ||
+        substr('89ab', 1 + abs(random()) % 4, 1) || substr(h, 18, 3) || '-' || substr(h, 21, 12)),
+    '00000000-0000-0000-0000-000000000001', id
+FROM (SELECT hex(randomblob(16)) AS h, id FROM users);
+
+-- SQLite cannot add a NOT NULL column without a default, nor alter constraints, so the column is
+-- nullable and triggers refuse records without a workspace or referring to another workspace
+ALTER TABLE plant_species ADD COLUMN workspace_id TEXT REFERENCES workspaces (id) ON DELETE CASCADE;
+ALTER TABLE plant_cultivar ADD COLUMN workspace_id TEXT REFERENCES workspaces (id) ON DELETE CASCADE;
+ALTER TABLE plant ADD COLUMN workspace_id TEXT REFERENCES workspaces (id) ON DELETE CASCADE;
+
+UPDATE plant_species SET workspace_id = '00000000-0000-0000-0000-000000000001' WHERE workspace_id IS NULL;
+UPDATE plant_cultivar SET workspace_id = '00000000-0000-0000-0000-000000000001' WHERE workspace_id IS NULL;
+UPDATE plant SET workspace_id = '00000000-0000-0000-0000-000000000001' WHERE workspace_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS plant_species_workspace_id_idx ON plant_species (workspace_id);
+CREATE INDEX IF NOT EXISTS plant_cultivar_workspace_id_idx ON plant_cultivar (workspace_id);
+CREATE INDEX IF NOT EXISTS plant_workspace_id_idx ON plant (workspace_id);
+
+CREATE TRIGGER IF NOT EXISTS plant_species_workspace_insert
+BEFORE INSERT ON plant_species
+WHEN NEW.workspace_id IS NULL
+BEGIN
+    SELECT RAISE(ABORT, 'plant_species.workspace_id is required');
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_species_workspace_update
+BEFORE UPDATE OF workspace_id ON plant_species
+WHEN NEW.workspace_id IS NOT OLD.workspace_id
+BEGIN
+    SELECT RAISE(ABORT, 'plant_species.workspace_id cannot change');
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_cultivar_workspace_insert
+BEFORE INSERT ON plant_cultivar
+WHEN NEW.workspace_id IS NULL
+    OR NOT EXISTS (SELECT 1 FROM plant_species WHERE id = NEW.species_id AND workspace_id = NEW.workspace_id)
+BEGIN
+    SELECT RAISE(ABORT, 'plant_cultivar must belong to the workspace of its species');
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_cultivar_workspace_update
+BEFORE UPDATE OF workspace_id, species_id ON plant_cultivar
+WHEN NEW.workspace_id IS NOT OLD.workspace_id
+    OR NOT EXISTS (SELECT 1 FROM plant_species WHERE id = NEW.species_id AND workspace_id = NEW.workspace_id)
+BEGIN
+    SELECT RAISE(ABORT, 'plant_cultivar must belong to the workspace of its species');
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_workspace_insert
+BEFORE INSERT ON plant
+WHEN NEW.workspace_id IS NULL
+    OR NOT EXISTS (SELECT 1 FROM plant_cultivar WHERE id = NEW.cultivar_id AND workspace_id = NEW.workspace_id)
+    OR NOT EXISTS (SELECT 1 FROM plant_species WHERE id = NEW.species_id AND workspace_id = NEW.workspace_id)
+    OR NEW.seed_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM plant WHERE id = NEW.seed_id AND workspace_id = NEW.workspace_id)
+    OR NEW.pollen_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM plant WHERE id = NEW.pollen_id AND workspace_id = NEW.workspace_id)
+BEGIN
+    SELECT RAISE(ABORT, 'plant must belong to the workspace of its cultivar, species and parents');
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_workspace_update
+BEFORE UPDATE OF workspace_id, cultivar_id, species_id, seed_id, pollen_id ON plant
+WHEN NEW.workspace_id IS NOT OLD.workspace_id
+    OR NOT EXISTS (SELECT 1 FROM plant_cultivar WHERE id = NEW.cultivar_id AND workspace_id = NEW.workspace_id)
+    OR NOT EXISTS (SELECT 1 FROM plant_species WHERE id = NEW.species_id AND workspace_id = NEW.workspace_id)
+    OR NEW.seed_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM plant WHERE id = NEW.seed_id AND workspace_id = NEW.workspace_id)
+    OR NEW.pollen_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM plant WHERE id = NEW.pollen_id AND workspace_id = NEW.workspace_id)
+BEGIN
+    SELECT RAISE(ABORT, 'plant must belong to the workspace of its cultivar, species and parents');
+END;
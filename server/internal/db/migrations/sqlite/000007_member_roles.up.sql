@@ -0,0 +1,4 @@
+-- A member's role decides what they may do in their workspace.
+-- Members from before roles existed could do anything, so they become owners.
+ALTER TABLE workspace_members
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'owner' CHECK (role IN ('owner', 'breeder', 'observer', 'guest'));
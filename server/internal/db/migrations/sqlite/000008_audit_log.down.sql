@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS audit_log_immutable;
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS plant_version_delete;
+DROP TRIGGER IF EXISTS plant_version_update;
+DROP TRIGGER IF EXISTS plant_version_insert;
+DROP TRIGGER IF EXISTS plant_cultivar_version_delete;
+DROP TRIGGER IF EXISTS plant_cultivar_version_update;
+DROP TRIGGER IF EXISTS plant_cultivar_version_insert;
+DROP TRIGGER IF EXISTS plant_species_version_delete;
+DROP TRIGGER IF EXISTS plant_species_version_update;
+DROP TRIGGER IF EXISTS plant_species_version_insert;
+
+DROP TABLE IF EXISTS plant_history;
+DROP TABLE IF EXISTS plant_cultivar_history;
+DROP TABLE IF EXISTS plant_species_history;
+
+ALTER TABLE plant DROP COLUMN valid_from;
+ALTER TABLE plant_cultivar DROP COLUMN valid_from;
+ALTER TABLE plant_species DROP COLUMN valid_from;
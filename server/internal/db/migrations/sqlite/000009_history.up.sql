@@ -0,0 +1,132 @@
+-- Species, cultivars and plants are system-versioned, as in Postgres. SQLite cannot add a column
+-- with a non-constant default, so valid_from is set by a trigger after each insert, and triggers
+-- cannot copy OLD.*, so they name every column. Timestamps are written in the format of the
+-- stores so that comparing the text compares the times.
+
+ALTER TABLE plant_species ADD COLUMN valid_from TIMESTAMP;
+UPDATE plant_species SET valid_from = COALESCE(deleted_at, updated_at, created_at, strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z') WHERE valid_from IS NULL;
+
+CREATE TABLE IF NOT EXISTS plant_species_history (
+    id TEXT,
+    name TEXT,
+    taxon TEXT,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    workspace_id TEXT,
+    valid_from TIMESTAMP,
+    valid_to TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS plant_species_history_id_idx ON plant_species_history (id, valid_from);
+CREATE INDEX IF NOT EXISTS plant_species_history_workspace_id_idx ON plant_species_history (workspace_id, valid_from);
+
+CREATE TRIGGER IF NOT EXISTS plant_species_version_insert
+AFTER INSERT ON plant_species
+BEGIN
+    UPDATE plant_species SET valid_from = strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z' WHERE id = NEW.id;
+END;
+
+-- the update setting valid_from after an insert is not a new version
+CREATE TRIGGER IF NOT EXISTS plant_species_version_update
+AFTER UPDATE ON plant_species
+WHEN OLD.valid_from IS NOT NULL
+BEGIN
+    INSERT INTO plant_species_history (id, name, taxon, created_at, updated_at, deleted_at, workspace_id, valid_from, valid_to)
+    VALUES (OLD.id, OLD.name, OLD.taxon, OLD.created_at, OLD.updated_at, OLD.deleted_at, OLD.workspace_id, OLD.valid_from, strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z');
+    UPDATE plant_species SET valid_from = strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z' WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_species_version_delete
+BEFORE DELETE ON plant_species
+BEGIN
+    INSERT INTO plant_species_history (id, name, taxon, created_at, updated_at, deleted_at, workspace_id, valid_from, valid_to)
+    VALUES (OLD.id, OLD.name, OLD.taxon, OLD.created_at, OLD.updated_at, OLD.deleted_at, OLD.workspace_id, OLD.valid_from, strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z');
+END;
+
+ALTER TABLE plant_cultivar ADD COLUMN valid_from TIMESTAMP;
+UPDATE plant_cultivar SET valid_from = COALESCE(deleted_at, updated_at, created_at, strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z') WHERE valid_from IS NULL;
+
+CREATE TABLE IF NOT EXISTS plant_cultivar_history (
+    id TEXT,
+    species_id TEXT,
+    name TEXT,
+    cultivar TEXT,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    genetics TEXT,
+    deleted_at TIMESTAMP,
+    workspace_id TEXT,
+    valid_from TIMESTAMP,
+    valid_to TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS plant_cultivar_history_id_idx ON plant_cultivar_history (id, valid_from);
+CREATE INDEX IF NOT EXISTS plant_cultivar_history_workspace_id_idx ON plant_cultivar_history (workspace_id, valid_from);
+
+CREATE TRIGGER IF NOT EXISTS plant_cultivar_version_insert
+AFTER INSERT ON plant_cultivar
+BEGIN
+    UPDATE plant_cultivar SET valid_from = strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z' WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_cultivar_version_update
+AFTER UPDATE ON plant_cultivar
+WHEN OLD.valid_from IS NOT NULL
+BEGIN
+    INSERT INTO plant_cultivar_history (id, species_id, name, cultivar, created_at, updated_at, genetics, deleted_at, workspace_id, valid_from, valid_to)
+    VALUES (OLD.id, OLD.species_id, OLD.name, OLD.cultivar, OLD.created_at, OLD.updated_at, OLD.genetics, OLD.deleted_at, OLD.workspace_id, OLD.valid_from, strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z');
+    UPDATE plant_cultivar SET valid_from = strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z' WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_cultivar_version_delete
+BEFORE DELETE ON plant_cultivar
+BEGIN
+    INSERT INTO plant_cultivar_history (id, species_id, name, cultivar, created_at, updated_at, genetics, deleted_at, workspace_id, valid_from, valid_to)
+    VALUES (OLD.id, OLD.species_id, OLD.name, OLD.cultivar, OLD.created_at, OLD.updated_at, OLD.genetics, OLD.deleted_at, OLD.workspace_id, OLD.valid_from, strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z');
+END;
+
+ALTER TABLE plant ADD COLUMN valid_from TIMESTAMP;
+UPDATE plant SET valid_from = COALESCE(deleted_at, updated_at, created_at, strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z') WHERE valid_from IS NULL;
+
+CREATE TABLE IF NOT EXISTS plant_history (
+    id TEXT,
+    cultivar_id TEXT,
+    species_id TEXT,
+    seed_id TEXT,
+    pollen_id TEXT,
+    generation INT,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    genetics TEXT,
+    labels TEXT,
+    deleted_at TIMESTAMP,
+    workspace_id TEXT,
+    valid_from TIMESTAMP,
+    valid_to TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS plant_history_id_idx ON plant_history (id, valid_from);
+CREATE INDEX IF NOT EXISTS plant_history_workspace_id_idx ON plant_history (workspace_id, valid_from);
+
+CREATE TRIGGER IF NOT EXISTS plant_version_insert
+AFTER INSERT ON plant
+BEGIN
+    UPDATE plant SET valid_from = strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z' WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_version_update
+AFTER UPDATE ON plant
+WHEN OLD.valid_from IS NOT NULL
+BEGIN
+    INSERT INTO plant_history (id, cultivar_id, species_id, seed_id, pollen_id, generation, created_at, updated_at, genetics, labels, deleted_at, workspace_id, valid_from, valid_to)
+    VALUES (OLD.id, OLD.cultivar_id, OLD.species_id, OLD.seed_id, OLD.pollen_id, OLD.generation, OLD.created_at, OLD.updated_at, OLD.genetics, OLD.labels, OLD.deleted_at, OLD.workspace_id, OLD.valid_from, strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z');
+    UPDATE plant SET valid_from = strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z' WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS plant_version_delete
+BEFORE DELETE ON plant
+BEGIN
+    INSERT INTO plant_history (id, cultivar_id, species_id, seed_id, pollen_id, generation, created_at, updated_at, genetics, labels, deleted_at, workspace_id, valid_from, valid_to)
+    VALUES (OLD.id, OLD.cultivar_id, OLD.species_id, OLD.seed_id, OLD.pollen_id, OLD.generation, OLD.created_at, OLD.updated_at, OLD.genetics, OLD.labels, OLD.deleted_at, OLD.workspace_id, OLD.valid_from, strftime('%Y-%m-%dT%H:%M:%f', 'now') || '000000Z');
+END;
@@ -0,0 +1,16 @@
+-- Shares are read-only links to a record, or a plant and its ancestors, for anyone holding their
+-- token. Tokens are signed rather than stored, so revoking a share marks it rather than deleting it.
+CREATE TABLE IF NOT EXISTS shares (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    created_by TEXT REFERENCES users (id) ON DELETE SET NULL,
+    component TEXT NOT NULL,
+    record_id TEXT NOT NULL,
+    lineage BOOLEAN NOT NULL DEFAULT FALSE,
+    fields TEXT NOT NULL DEFAULT '[]' CHECK (json_valid(fields)),
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS shares_workspace_id_idx ON shares (workspace_id, created_at);
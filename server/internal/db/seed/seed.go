@@ -0,0 +1,267 @@
+// Package seed loads fixture sets of species, cultivars and plant pedigrees into a workspace.
+//
+// A fixture set names each record with a key that the other records of the set refer to it by:
+// cultivars name their species, and plants their cultivar and their seed and pollen parents. The
+// ID of every record is derived from its workspace, set and key, so seeding a set again finds the
+// records it wrote before and brings them back in line with the fixtures instead of adding more.
+package seed
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kylep342/mendel/internal/components"
+	"github.com/kylep342/mendel/internal/components/plants/plant"
+	"github.com/kylep342/mendel/internal/components/plants/plant_cultivar"
+	"github.com/kylep342/mendel/internal/components/plants/plant_species"
+	"github.com/kylep342/mendel/internal/constants"
+)
+
+// Namespace is the UUID namespace the IDs of seeded records are derived in
+var Namespace = uuid.MustParse("6d656e64-656c-5eed-8000-000000000000")
+
+// fixtures are the fixture sets built into the binary, one file per set
+//
+//go:embed fixtures/*.yaml
+var fixtures embed.FS
+
+// keyPattern is what a set name or record key looks like
+var keyPattern = regexp.MustCompile(`^[a-z0-9]+([-_][a-z0-9]+)*$`)
+
+// Set is a named fixture set. Records refer to the records they depend on by key, and those
+// must come earlier in the set.
+type Set struct {
+	Name      string     `json:"-" yaml:"-"`
+	Species   []Species  `json:"species" yaml:"species"`
+	Cultivars []Cultivar `json:"cultivars" yaml:"cultivars"`
+	Plants    []Plant    `json:"plants" yaml:"plants"`
+}
+
+// Species is the fixture of a plant species
+type Species struct {
+	Key   string `json:"key" yaml:"key"`
+	Name  string `json:"name" yaml:"name"`
+	Taxon string `json:"taxon" yaml:"taxon"`
+}
+
+// Cultivar is the fixture of a plant cultivar of the species keyed Species
+type Cultivar struct {
+	Key      string         `json:"key" yaml:"key"`
+	Species  string         `json:"species" yaml:"species"`
+	Name     string         `json:"name" yaml:"name"`
+	Cultivar string         `json:"cultivar" yaml:"cultivar"`
+	Genetics map[string]any `json:"genetics" yaml:"genetics"`
+}
+
+// Plant is the fixture of a plant of the cultivar keyed Cultivar, bred from the plants keyed Seed
+// and Pollen when set. Generation defaults to one more than the older parent's, or 0 without parents.
+type Plant struct {
+	Key        string         `json:"key" yaml:"key"`
+	Cultivar   string         `json:"cultivar" yaml:"cultivar"`
+	Seed       string         `json:"seed" yaml:"seed"`
+	Pollen     string         `json:"pollen" yaml:"pollen"`
+	Generation *uint32        `json:"generation" yaml:"generation"`
+	Genetics   map[string]any `json:"genetics" yaml:"genetics"`
+	Labels     map[string]any `json:"labels" yaml:"labels"`
+}
+
+// Names lists the fixture sets built into the binary
+func Names() []string {
+	entries, err := fs.Glob(fixtures, "fixtures/*.yaml")
+	if err != nil {
+		panic(err)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = strings.TrimSuffix(path.Base(entry), ".yaml")
+	}
+	return names
+}
+
+// Open returns the fixture set built into the binary named name
+func Open(name string) (*Set, error) {
+	if !slices.Contains(Names(), name) {
+		return nil, fmt.Errorf("no fixture set named %q; the sets are %s", name, strings.Join(Names(), ", "))
+	}
+	data, err := fixtures.ReadFile("fixtures/" + name + ".yaml")
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, data, false)
+}
+
+// Load reads the fixture set in the file at file, JSON if its extension is .json and YAML
+// otherwise. The set is named after the file, so a file loads the records of the built in set of
+// the same name.
+func Load(file string) (*Set, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(file)
+	return Parse(strings.TrimSuffix(filepath.Base(file), ext), data, strings.EqualFold(ext, ".json"))
+}
+
+// Parse decodes the fixture set named name from data, in JSON or YAML, rejecting unknown fields
+func Parse(name string, data []byte, isJSON bool) (*Set, error) {
+	if !keyPattern.MatchString(name) {
+		return nil, fmt.Errorf("fixture set name %q must be lowercase letters and digits separated by - or _", name)
+	}
+	set := &Set{Name: name}
+	var err error
+	if isJSON {
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(set)
+	} else {
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		err = dec.Decode(set)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fixture set %s: %w", name, err)
+	}
+	return set, nil
+}
+
+// Records are the records a Set resolves to in a workspace, in the order they are written
+type Records struct {
+	Species   []plant_species.PlantSpecies
+	Cultivars []plant_cultivar.PlantCultivar
+	Plants    []plant.Plant
+}
+
+// Resolve derives the ID of every record of the set in workspace and replaces the keys records
+// refer to each other by with those IDs. It reports every key that is missing, repeated, refers
+// to nothing or to a record coming later, and every record the server would reject.
+func (s *Set) Resolve(workspace string) (*Records, error) {
+	var errs []error
+	fail := func(table, key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s %s %q: %s", s.Name, table, key, fmt.Sprintf(format, args...)))
+	}
+	// ids maps the keys of each table to the IDs of their records
+	ids := map[string]map[string]string{}
+	id := func(table, key string) string {
+		if !keyPattern.MatchString(key) {
+			fail(table, key, "keys must be lowercase letters and digits separated by - or _")
+		} else if _, ok := ids[table][key]; ok {
+			fail(table, key, "the key is used twice")
+		}
+		if ids[table] == nil {
+			ids[table] = map[string]string{}
+		}
+		ids[table][key] = s.ID(workspace, table, key)
+		return ids[table][key]
+	}
+	ref := func(table, key, field, to string) string {
+		target, ok := ids[to][field]
+		if !ok {
+			fail(table, key, "%s %q is not defined before it", to, field)
+		}
+		return target
+	}
+	// valid checks model unless the record already failed, since then it is missing IDs
+	valid := func(table, key string, model any, failed int) {
+		if len(errs) > failed {
+			return
+		}
+		var invalid *components.ValidationError
+		if err := components.Validate(model); errors.As(err, &invalid) {
+			fail(table, key, "%s", strings.TrimPrefix(invalid.Error(), "validation failed: "))
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	records := &Records{}
+	for _, f := range s.Species {
+		failed := len(errs)
+		record := plant_species.PlantSpecies{ID: id(constants.TablePlantSpecies, f.Key), Name: f.Name, Taxon: f.Taxon}
+		valid(constants.TablePlantSpecies, f.Key, &record, failed)
+		records.Species = append(records.Species, record)
+	}
+
+	// cultivarSpecies maps the IDs of cultivars to the IDs of their species
+	cultivarSpecies := map[string]string{}
+	for _, f := range s.Cultivars {
+		failed := len(errs)
+		record := plant_cultivar.PlantCultivar{
+			ID:        id(constants.TablePlantCultivar, f.Key),
+			SpeciesID: ref(constants.TablePlantCultivar, f.Key, f.Species, constants.TablePlantSpecies),
+			Name:      f.Name,
+			Cultivar:  f.Cultivar,
+			Genetics:  object(f.Genetics),
+		}
+		valid(constants.TablePlantCultivar, f.Key, &record, failed)
+		cultivarSpecies[record.ID] = record.SpeciesID
+		records.Cultivars = append(records.Cultivars, record)
+	}
+
+	generations := map[string]uint32{}
+	for _, f := range s.Plants {
+		failed := len(errs)
+		record := plant.Plant{
+			ID:         id(constants.TablePlant, f.Key),
+			CultivarID: ref(constants.TablePlant, f.Key, f.Cultivar, constants.TablePlantCultivar),
+			Genetics:   object(f.Genetics),
+			Labels:     object(f.Labels),
+		}
+		record.SpeciesID = cultivarSpecies[record.CultivarID]
+		var parents []uint32
+		for _, parent := range []struct {
+			key string
+			id  **string
+		}{{f.Seed, &record.SeedID}, {f.Pollen, &record.PollenID}} {
+			if parent.key == "" {
+				continue
+			}
+			if parent.key == f.Key {
+				fail(constants.TablePlant, f.Key, "a plant cannot be its own parent")
+				continue
+			}
+			if parentID := ref(constants.TablePlant, f.Key, parent.key, constants.TablePlant); parentID != "" {
+				*parent.id = &parentID
+				parents = append(parents, generations[parentID])
+			}
+		}
+		switch {
+		case f.Generation != nil:
+			record.Generation = *f.Generation
+		case len(parents) > 0:
+			record.Generation = slices.Max(parents) + 1
+		}
+		generations[record.ID] = record.Generation
+		valid(constants.TablePlant, f.Key, &record, failed)
+		records.Plants = append(records.Plants, record)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ID returns the ID of the record of table keyed key when the set is seeded into workspace
+func (s *Set) ID(workspace, table, key string) string {
+	return uuid.NewSHA1(Namespace, []byte(workspace+"/"+s.Name+"/"+table+"/"+key)).String()
+}
+
+// object returns m, or an empty object when the fixture leaves it out
+func object(m map[string]any) map[string]any {
+	if m == nil {
+		return map[string]any{}
+	}
+	return m
+}
@@ -0,0 +1,221 @@
+package seed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/components/plants/plant"
+	"github.com/kylep342/mendel/internal/components/plants/plant_cultivar"
+	"github.com/kylep342/mendel/internal/components/plants/plant_species"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+const testSet = `
+species:
+  - key: tomato
+    name: Tomato
+    taxon: Solanum lycopersicum
+cultivars:
+  - key: red
+    species: tomato
+    name: Red
+    cultivar: Red
+plants:
+  - key: a
+    cultivar: red
+  - key: b
+    cultivar: red
+  - key: f1
+    cultivar: red
+    seed: a
+    pollen: b
+  - key: f2
+    cultivar: red
+    seed: f1
+    pollen: a
+    labels:
+      bed: 2
+`
+
+// newTestSeeder returns a Seeder writing to fresh in-memory stores
+func newTestSeeder() *Seeder {
+	mem := db.NewMemory()
+	return &Seeder{
+		Tx: mem,
+		Stores: Stores{
+			Species:   db.NewMemoryHistoryStore[plant_species.PlantSpecies](mem, constants.TablePlantSpecies),
+			Cultivars: db.NewMemoryHistoryStore[plant_cultivar.PlantCultivar](mem, constants.TablePlantCultivar),
+			Plants:    db.NewMemoryHistoryStore[plant.Plant](mem, constants.TablePlant),
+		},
+	}
+}
+
+func TestSets(t *testing.T) {
+	require.Equal(t, []string{"demo", "e2e"}, Names())
+	for _, name := range Names() {
+		set, err := Open(name)
+		require.NoError(t, err, name)
+		_, err = set.Resolve(workspace.DefaultID)
+		assert.NoError(t, err, name)
+	}
+
+	_, err := Open("missing")
+	assert.ErrorContains(t, err, "the sets are demo, e2e")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "small.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"species": [{"key": "tomato", "name": "Tomato", "taxon": "Solanum"}]}`), 0o644))
+	set, err := Load(file)
+	require.NoError(t, err)
+	assert.Equal(t, "small", set.Name)
+	assert.Equal(t, []Species{{Key: "tomato", Name: "Tomato", Taxon: "Solanum"}}, set.Species)
+
+	require.NoError(t, os.WriteFile(file, []byte(`{"species": [{"key": "tomato", "color": "red"}]}`), 0o644))
+	_, err = Load(file)
+	assert.ErrorContains(t, err, `unknown field "color"`)
+
+	_, err = Parse("small", []byte("plants:\n  - key: a\n    colour: red\n"), false)
+	assert.ErrorContains(t, err, "field colour not found")
+	_, err = Parse("Not A Name", []byte("{}"), true)
+	assert.Error(t, err)
+}
+
+func TestSet_Resolve(t *testing.T) {
+	set, err := Parse("test", []byte(testSet), false)
+	require.NoError(t, err)
+	records, err := set.Resolve(workspace.DefaultID)
+	require.NoError(t, err)
+
+	id := func(table, key string) string { return set.ID(workspace.DefaultID, table, key) }
+	species, red := id(constants.TablePlantSpecies, "tomato"), id(constants.TablePlantCultivar, "red")
+	a, b, f1 := id(constants.TablePlant, "a"), id(constants.TablePlant, "b"), id(constants.TablePlant, "f1")
+
+	assert.Equal(t, species, records.Species[0].ID)
+	assert.Equal(t, species, records.Cultivars[0].SpeciesID)
+	assert.Equal(t, map[string]any{}, records.Cultivars[0].Genetics)
+	require.Len(t, records.Plants, 4)
+	for i, want := range []struct {
+		seed, pollen *string
+		generation   uint32
+	}{{nil, nil, 0}, {nil, nil, 0}, {&a, &b, 1}, {&f1, &a, 2}} {
+		p := records.Plants[i]
+		assert.Equal(t, red, p.CultivarID)
+		assert.Equal(t, species, p.SpeciesID, "plants take the species of their cultivar")
+		assert.Equal(t, want.seed, p.SeedID)
+		assert.Equal(t, want.pollen, p.PollenID)
+		assert.Equal(t, want.generation, p.Generation)
+	}
+
+	other, err := set.Resolve("00000000-0000-0000-0000-000000000002")
+	require.NoError(t, err)
+	assert.NotEqual(t, species, other.Species[0].ID, "IDs differ between workspaces")
+}
+
+func TestSet_Resolve_Errors(t *testing.T) {
+	set, err := Parse("test", []byte(`
+species:
+  - key: tomato
+    name: Tomato
+    taxon: Solanum lycopersicum
+  - key: tomato
+    name: Tomato
+    taxon: Solanum lycopersicum
+  - key: Pepper
+    name: Pepper
+    taxon: Capsicum annuum
+  - key: bean
+    name: Bean
+cultivars:
+  - key: red
+    species: potato
+    name: Red
+    cultivar: Red
+  - key: yellow
+    species: tomato
+    name: Yellow
+    cultivar: Yellow
+plants:
+  - key: a
+    cultivar: yellow
+    seed: b
+  - key: b
+    cultivar: yellow
+    pollen: b
+`), false)
+	require.NoError(t, err)
+	_, err = set.Resolve(workspace.DefaultID)
+	require.Error(t, err)
+	for _, want := range []string{
+		`test plant_species "tomato": the key is used twice`,
+		`test plant_species "Pepper": keys must be lowercase`,
+		`test plant_species "bean": taxon:`,
+		`test plant_cultivar "red": plant_species "potato" is not defined before it`,
+		`test plant "a": plant "b" is not defined before it`,
+		`test plant "b": a plant cannot be its own parent`,
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+	assert.NotContains(t, err.Error(), `"yellow"`)
+}
+
+func TestSeeder_Seed(t *testing.T) {
+	ctx := db.WithWorkspace(context.Background(), workspace.DefaultID)
+	s := newTestSeeder()
+	set, err := Parse("test", []byte(testSet), false)
+	require.NoError(t, err)
+
+	result, err := s.Seed(context.Background(), set, workspace.DefaultID)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Species: Counts{Created: 1}, Cultivars: Counts{Created: 1}, Plants: Counts{Created: 4}}, result)
+
+	result, err = s.Seed(context.Background(), set, workspace.DefaultID)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Species: Counts{Unchanged: 1}, Cultivars: Counts{Unchanged: 1}, Plants: Counts{Unchanged: 4}}, result)
+	plants, err := s.Stores.Plants.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, plants, 4, "seeding again adds nothing")
+
+	// records deleted or changed since are brought back in line with the set
+	f2 := set.ID(workspace.DefaultID, constants.TablePlant, "f2")
+	require.NoError(t, s.Stores.Plants.Delete(ctx, f2))
+	set.Cultivars[0].Name = "Renamed"
+	result, err = s.Seed(context.Background(), set, workspace.DefaultID)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Species: Counts{Unchanged: 1}, Cultivars: Counts{Updated: 1}, Plants: Counts{Restored: 1, Unchanged: 3}}, result)
+
+	cultivar, err := s.Stores.Cultivars.GetByID(ctx, set.ID(workspace.DefaultID, constants.TablePlantCultivar, "red"))
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", cultivar.Name)
+	restored, err := s.Stores.Plants.GetByID(ctx, f2)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"bed": 2}, restored.Labels)
+
+	// records the set does not name are left alone
+	other := &plant.Plant{CultivarID: cultivar.ID, SpeciesID: cultivar.SpeciesID, Genetics: map[string]any{}, Labels: map[string]any{}}
+	require.NoError(t, s.Stores.Plants.Create(ctx, other))
+	_, err = s.Seed(context.Background(), set, workspace.DefaultID)
+	require.NoError(t, err)
+	_, err = s.Stores.Plants.GetByID(ctx, other.ID)
+	assert.NoError(t, err)
+}
+
+func TestSeeder_Seed_Invalid(t *testing.T) {
+	s := newTestSeeder()
+	set, err := Parse("test", []byte("plants:\n  - key: a\n    cultivar: missing\n"), false)
+	require.NoError(t, err)
+
+	_, err = s.Seed(context.Background(), set, workspace.DefaultID)
+	assert.ErrorContains(t, err, "is not defined before it")
+	plants, err := s.Stores.Plants.GetAll(db.WithWorkspace(context.Background(), workspace.DefaultID))
+	require.NoError(t, err)
+	assert.Empty(t, plants)
+}
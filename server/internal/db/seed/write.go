@@ -0,0 +1,147 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/kylep342/mendel/internal/components/plants/plant"
+	"github.com/kylep342/mendel/internal/components/plants/plant_cultivar"
+	"github.com/kylep342/mendel/internal/components/plants/plant_species"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+// Stores are the stores a Seeder writes records through
+type Stores struct {
+	Species   db.CRUDTable[plant_species.PlantSpecies]
+	Cultivars db.CRUDTable[plant_cultivar.PlantCultivar]
+	Plants    db.CRUDTable[plant.Plant]
+}
+
+// Seeder writes fixture sets through the stores the server reads records from
+type Seeder struct {
+	Tx     db.Transactor
+	Stores Stores
+}
+
+// Counts are how many records of a table seeding created, restored from the trash, updated to
+// match their fixture or found matching it already
+type Counts struct {
+	Created   int
+	Restored  int
+	Updated   int
+	Unchanged int
+}
+
+func (c Counts) String() string {
+	return fmt.Sprintf("%d created, %d restored, %d updated, %d unchanged", c.Created, c.Restored, c.Updated, c.Unchanged)
+}
+
+// Result is the Counts of each table a set was seeded into
+type Result struct {
+	Species   Counts
+	Cultivars Counts
+	Plants    Counts
+}
+
+// Seed writes the records of set into workspace in a single transaction. Records seeded before
+// are restored if they were deleted and updated if they no longer match their fixture; records
+// the set does not name, and fields the store manages, are left alone.
+func (s *Seeder) Seed(ctx context.Context, set *Set, workspace string) (Result, error) {
+	records, err := set.Resolve(workspace)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	err = db.InTx(db.WithWorkspace(ctx, workspace), s.Tx, func(ctx context.Context) error {
+		for i := range records.Species {
+			if err := upsert(ctx, s.Stores.Species, &records.Species[i], &result.Species, sameSpecies); err != nil {
+				return fmt.Errorf("%s %s %s: %w", set.Name, constants.TablePlantSpecies, set.Species[i].Key, err)
+			}
+		}
+		for i := range records.Cultivars {
+			if err := upsert(ctx, s.Stores.Cultivars, &records.Cultivars[i], &result.Cultivars, sameCultivar); err != nil {
+				return fmt.Errorf("%s %s %s: %w", set.Name, constants.TablePlantCultivar, set.Cultivars[i].Key, err)
+			}
+		}
+		for i := range records.Plants {
+			if err := upsert(ctx, s.Stores.Plants, &records.Plants[i], &result.Plants, samePlant); err != nil {
+				return fmt.Errorf("%s %s %s: %w", set.Name, constants.TablePlant, set.Plants[i].Key, err)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// upsert writes item, creating it, restoring it from the trash or updating it as needed, and
+// leaves it alone when the stored record already matches it by same
+func upsert[T any, PT interface {
+	*T
+	GetID() string
+}](ctx context.Context, table db.CRUDTable[T], item PT, counts *Counts, same func(stored, item PT) bool) error {
+	id := item.GetID()
+	stored, err := table.GetByID(ctx, id)
+	count := &counts.Unchanged
+	if errors.Is(err, sql.ErrNoRows) {
+		if trash, ok := table.(db.SoftDeleteTable[T]); ok {
+			err = trash.Restore(ctx, id)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			if err := table.Create(ctx, item); err != nil {
+				return err
+			}
+			counts.Created++
+			return nil
+		} else if err != nil {
+			return err
+		}
+		count = &counts.Restored
+		stored, err = table.GetByID(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !same(&stored, item) {
+		if err := table.Update(ctx, item); err != nil {
+			return err
+		}
+		if count == &counts.Unchanged {
+			count = &counts.Updated
+		}
+	} else {
+		*item = stored
+	}
+	*count++
+	return nil
+}
+
+func sameSpecies(stored, item *plant_species.PlantSpecies) bool {
+	return stored.Name == item.Name && stored.Taxon == item.Taxon
+}
+
+func sameCultivar(stored, item *plant_cultivar.PlantCultivar) bool {
+	return stored.SpeciesID == item.SpeciesID && stored.Name == item.Name && stored.Cultivar == item.Cultivar &&
+		sameJSON(stored.Genetics, item.Genetics)
+}
+
+func samePlant(stored, item *plant.Plant) bool {
+	return stored.CultivarID == item.CultivarID && stored.SpeciesID == item.SpeciesID &&
+		reflect.DeepEqual(stored.SeedID, item.SeedID) && reflect.DeepEqual(stored.PollenID, item.PollenID) &&
+		stored.Generation == item.Generation &&
+		sameJSON(stored.Genetics, item.Genetics) && sameJSON(stored.Labels, item.Labels)
+}
+
+// sameJSON reports whether a and b encode to the same JSON, which compares objects read back
+// from a store with those decoded from a fixture regardless of how either typed its numbers
+func sameJSON(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
@@ -0,0 +1,632 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLQuerier runs queries through database/sql; it is satisfied by *sql.DB, *sql.Conn and *sql.Tx
+type SQLQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// OpenSQLite opens the SQLite database at path with foreign keys enforced.
+// SQLite allows a single writer, so the returned pool holds one connection and
+// requests queue for it rather than failing with SQLITE_BUSY.
+func OpenSQLite(path string) (*sql.DB, error) {
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	conn, err := sql.Open("sqlite", "file:"+path+"?"+q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(1)
+	return conn, nil
+}
+
+// SQLiteStore implements CRUDTable[T] on SQLite for any struct whose fields carry `db` tags naming
+// their columns. It joins the transaction carried by the context of each call, see WithSQLTx.
+//
+// It manages the same columns as Store[T], but does so client-side: ids are generated UUIDs and
+// created_at, updated_at and deleted_at are set from the server's clock. Maps, slices, structs and
+// interfaces are stored as JSON text. Queries use ?NNN parameters and differ from Store[T]'s in that
+//
+//	Update($1 id, $2... writable columns in field order, then updated_at): returns every column
+//	Delete($1 id, $2 deleted_at), Restore($1 id), Purge($1 cutoff): report the records changed as rows affected
+//
+// and, likewise, scope every query but Purge to the workspace, its last parameter, for tables with
+// a workspace_id column.
+type SQLiteStore[T any] struct {
+	Conn    SQLQuerier
+	Table   string
+	Queries Queries
+
+	fields *Fields
+}
+
+// NewSQLiteStore creates a SQLiteStore[T] for table
+func NewSQLiteStore[T any](conn SQLQuerier, table string) *SQLiteStore[T] {
+	s := &SQLiteStore[T]{
+		Conn:   conn,
+		Table:  table,
+		fields: FieldsOf[T](),
+	}
+	s.Queries = s.generateQueries()
+	return s
+}
+
+// generateQueries builds the default Queries from T's columns
+func (s *SQLiteStore[T]) generateQueries() Queries {
+	cols := strings.Join(s.fields.Columns(), ", ")
+	var all []string
+	live := ""
+	if s.fields.Has(ColumnDeletedAt) {
+		all = append(all, ColumnDeletedAt+" IS NULL")
+		live = " AND " + ColumnDeletedAt + " IS NULL"
+	}
+	// ws scopes a query taking n other parameters to the workspace
+	ws := func(n int) string {
+		if !s.fields.Has(ColumnWorkspaceID) {
+			return ""
+		}
+		return fmt.Sprintf(" AND %s = ?%d", ColumnWorkspaceID, n+1)
+	}
+	if s.fields.Has(ColumnWorkspaceID) {
+		all = append(all, ColumnWorkspaceID+" = ?1")
+	}
+	where := ""
+	if len(all) > 0 {
+		where = " WHERE " + strings.Join(all, " AND ")
+	}
+
+	var sets []string
+	writable := s.fields.Writable()
+	for i, col := range writable {
+		sets = append(sets, fmt.Sprintf("%s = ?%d", col, i+2))
+	}
+	updateParams := len(writable) + 1
+	if s.fields.Has(ColumnUpdatedAt) {
+		updateParams++
+		sets = append(sets, fmt.Sprintf("%s = ?%d", ColumnUpdatedAt, updateParams))
+	}
+
+	q := Queries{
+		GetAll:   `SELECT ` + cols + ` FROM ` + s.Table + where,
+		GetByID:  `SELECT ` + cols + ` FROM ` + s.Table + ` WHERE id = ?1` + ws(1) + live,
+		GetByIDs: `SELECT ` + cols + ` FROM ` + s.Table + ` WHERE id IN (SELECT value FROM json_each(?1))` + ws(1) + live,
+		Update: `UPDATE ` + s.Table + ` SET ` + strings.Join(sets, ", ") +
+			` WHERE id = ?1` + ws(updateParams) + live + ` RETURNING ` + cols,
+		Delete: `DELETE FROM ` + s.Table + ` WHERE id = ?1` + ws(1),
+	}
+	if s.fields.Has(ColumnDeletedAt) {
+		q.Delete = `UPDATE ` + s.Table + ` SET deleted_at = ?2 WHERE id = ?1` + ws(2) + live
+		q.GetTrash = `SELECT ` + cols + ` FROM ` + s.Table + ` WHERE deleted_at IS NOT NULL` + ws(0)
+		q.Restore = `UPDATE ` + s.Table + ` SET deleted_at = NULL WHERE id = ?1` + ws(1) + ` AND deleted_at IS NOT NULL`
+		q.Purge = `DELETE FROM ` + s.Table + ` WHERE deleted_at < ?1`
+		q.IsTrashed = `SELECT EXISTS (SELECT 1 FROM ` + s.Table + ` WHERE id = ?1` + ws(1) + ` AND deleted_at IS NOT NULL)`
+	}
+	return q
+}
+
+// Scope returns args followed by the workspace ctx is scoped to, like Store.Scope
+func (s *SQLiteStore[T]) Scope(ctx context.Context, args ...any) ([]any, error) {
+	return scope(ctx, s.fields, args...)
+}
+
+// Querier returns the transaction carried by ctx, or Conn outside of one
+func (s *SQLiteStore[T]) Querier(ctx context.Context) SQLQuerier {
+	return SQLConn(ctx, s.Conn)
+}
+
+// Select runs query and scans every row into a T by column name
+func (s *SQLiteStore[T]) Select(ctx context.Context, query string, args ...any) ([]T, error) {
+	items := []T{}
+	err := s.Each(ctx, func(item T) error {
+		items = append(items, item)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Each runs query and calls fn with every row scanned into a T, one row at a time.
+// It stops at the first error fn returns.
+func (s *SQLiteStore[T]) Each(ctx context.Context, fn func(item T) error, query string, args ...any) error {
+	rows, err := s.Querier(ctx).QueryContext(ctx, query, sqliteArgs(args)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var item T
+		if err := s.scan(rows, cols, &item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// SelectOne runs query and scans its single row into a T by column name
+func (s *SQLiteStore[T]) SelectOne(ctx context.Context, query string, args ...any) (T, error) {
+	items, err := s.Select(ctx, query, args...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	switch len(items) {
+	case 0:
+		var zero T
+		return zero, sql.ErrNoRows
+	case 1:
+		return items[0], nil
+	default:
+		var zero T
+		return zero, fmt.Errorf("db: expected 1 row from %s, got %d", s.Table, len(items))
+	}
+}
+
+// scan reads the current row of rows, whose columns are cols, into item
+func (s *SQLiteStore[T]) scan(rows *sql.Rows, cols []string, item *T) error {
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(item).Elem()
+	for i, col := range cols {
+		if !s.fields.Has(col) {
+			return fmt.Errorf("db: %T has no field for column %s", *item, col)
+		}
+		if err := assignSQLite(s.fields.Field(v, col), values[i]); err != nil {
+			return fmt.Errorf("db: scanning %s.%s: %w", s.Table, col, err)
+		}
+	}
+	return nil
+}
+
+// Exec runs query, returning the number of records it changed
+func (s *SQLiteStore[T]) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	res, err := s.Querier(ctx).ExecContext(ctx, query, sqliteArgs(args)...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// changeOne runs query with args scoped to the workspace, expecting it to change at least one record
+func (s *SQLiteStore[T]) changeOne(ctx context.Context, query string, args ...any) error {
+	args, err := s.Scope(ctx, args...)
+	if err != nil {
+		return err
+	}
+	n, err := s.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAll retrieves all records from the table
+func (s *SQLiteStore[T]) GetAll(ctx context.Context) ([]T, error) {
+	args, err := s.Scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, s.Queries.GetAll, args...)
+}
+
+// sqliteStreamPage is how many records StreamAll reads at a time
+const sqliteStreamPage = 500
+
+// StreamAll calls fn with each record of the table, in id order. SQLite has a single connection,
+// so rather than holding it while fn runs, StreamAll reads the records a page at a time, each page
+// keyed on the last id of the one before. Records written meanwhile may or may not be seen.
+func (s *SQLiteStore[T]) StreamAll(ctx context.Context, fn func(item T) error) error {
+	return s.streamPages(ctx, fn, sqliteStreamPage)
+}
+
+// streamPages is StreamAll reading size records at a time
+func (s *SQLiteStore[T]) streamPages(ctx context.Context, fn func(item T) error, size int) error {
+	args, err := s.Scope(ctx)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`SELECT * FROM (%s) WHERE %s > ?%d ORDER BY %s LIMIT %d`,
+		s.Queries.GetAll, ColumnID, len(args)+1, ColumnID, size)
+	after := ""
+	for {
+		page, err := s.Select(ctx, query, append(args, after)...)
+		if err != nil {
+			return err
+		}
+		for _, item := range page {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if len(page) < size {
+			return nil
+		}
+		after = s.fields.Field(reflect.ValueOf(&page[len(page)-1]).Elem(), ColumnID).String()
+	}
+}
+
+// GetByID retrieves the record identified by `id`
+func (s *SQLiteStore[T]) GetByID(ctx context.Context, id string) (T, error) {
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return s.SelectOne(ctx, s.Queries.GetByID, args...)
+}
+
+// GetByIDs retrieves the records identified by `ids`, skipping any that do not exist
+func (s *SQLiteStore[T]) GetByIDs(ctx context.Context, ids []string) ([]T, error) {
+	args, err := s.Scope(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, s.Queries.GetByIDs, args...)
+}
+
+// Create inserts item, generating its id if empty, and scans the stored record back into it.
+// Columns whose value is nil are left to their column defaults.
+func (s *SQLiteStore[T]) Create(ctx context.Context, item *T) error {
+	v := reflect.ValueOf(item).Elem()
+	now := time.Now()
+
+	id, _ := s.fields.Value(v, ColumnID).(string)
+	if id == "" {
+		id = uuid.NewString()
+	}
+	cols := []string{ColumnID}
+	args := []any{id}
+	for _, col := range []string{ColumnCreatedAt, ColumnUpdatedAt} {
+		if s.fields.Has(col) {
+			cols = append(cols, col)
+			args = append(args, now)
+		}
+	}
+	if s.fields.Has(ColumnWorkspaceID) {
+		workspace, err := s.Scope(ctx)
+		if err != nil {
+			return err
+		}
+		cols = append(cols, ColumnWorkspaceID)
+		args = append(args, workspace...)
+	}
+	for _, col := range s.fields.Writable() {
+		val := s.fields.Value(v, col)
+		if isNil(val) {
+			continue
+		}
+		cols = append(cols, col)
+		args = append(args, val)
+	}
+
+	params := make([]string, len(cols))
+	for i := range cols {
+		params[i] = fmt.Sprintf("?%d", i+1)
+	}
+	query := `INSERT INTO ` + s.Table + ` (` + strings.Join(cols, ", ") + `) VALUES (` + strings.Join(params, ", ") +
+		`) RETURNING ` + strings.Join(s.fields.Columns(), ", ")
+
+	created, err := s.SelectOne(ctx, query, args...)
+	if err != nil {
+		return sqliteDuplicate(err)
+	}
+	*item = created
+	return nil
+}
+
+// Update writes every writable column of item, scanning the stored record back into it
+func (s *SQLiteStore[T]) Update(ctx context.Context, item *T) error {
+	v := reflect.ValueOf(item).Elem()
+
+	args := []any{s.fields.Value(v, ColumnID)}
+	for _, col := range s.fields.Writable() {
+		args = append(args, s.fields.Value(v, col))
+	}
+	if s.fields.Has(ColumnUpdatedAt) {
+		args = append(args, time.Now())
+	}
+	args, err := s.Scope(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := s.SelectOne(ctx, s.Queries.Update, args...)
+	if err != nil {
+		return sqliteDuplicate(err)
+	}
+	*item = updated
+	return nil
+}
+
+// Delete removes the record identified by `id`, or moves it to the trash if the table has deleted_at
+func (s *SQLiteStore[T]) Delete(ctx context.Context, id string) error {
+	if s.fields.Has(ColumnDeletedAt) {
+		return s.changeOne(ctx, s.Queries.Delete, id, time.Now())
+	}
+	return s.changeOne(ctx, s.Queries.Delete, id)
+}
+
+// sqliteDuplicate wraps a unique or primary key violation from SQLite in ErrDuplicate
+func sqliteDuplicate(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("%w: %s", ErrDuplicate, sqliteErr.Error())
+		}
+	}
+	return err
+}
+
+// SQLiteSoftDeleteStore is a SQLiteStore[T] for tables with a deleted_at column, adding the
+// SoftDeleteTable[T] methods
+type SQLiteSoftDeleteStore[T any] struct {
+	*SQLiteStore[T]
+}
+
+// NewSQLiteSoftDeleteStore creates a SQLiteSoftDeleteStore[T] for table. T must have a deleted_at column.
+func NewSQLiteSoftDeleteStore[T any](conn SQLQuerier, table string) *SQLiteSoftDeleteStore[T] {
+	s := NewSQLiteStore[T](conn, table)
+	if !s.fields.Has(ColumnDeletedAt) {
+		panic(fmt.Sprintf("db: %T has no %s column", *new(T), ColumnDeletedAt))
+	}
+	return &SQLiteSoftDeleteStore[T]{SQLiteStore: s}
+}
+
+// GetTrash retrieves all records in the trash
+func (s *SQLiteSoftDeleteStore[T]) GetTrash(ctx context.Context) ([]T, error) {
+	args, err := s.Scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, s.Queries.GetTrash, args...)
+}
+
+// Restore moves the record identified by `id` out of the trash.
+// It returns ErrParentDeleted if the record is in the trash but could not be restored.
+func (s *SQLiteSoftDeleteStore[T]) Restore(ctx context.Context, id string) error {
+	err := s.changeOne(ctx, s.Queries.Restore, id)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		return err
+	}
+	var trashed bool
+	if err := s.Querier(ctx).QueryRowContext(ctx, s.Queries.IsTrashed, sqliteArgs(args)...).Scan(&trashed); err != nil {
+		return err
+	}
+	if trashed {
+		return ErrParentDeleted
+	}
+	return sql.ErrNoRows
+}
+
+// Purge permanently removes records deleted before `before`, in every workspace.
+// Like SoftDeleteStore.Purge, it repeats until a pass removes nothing.
+func (s *SQLiteSoftDeleteStore[T]) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var total int64
+	for {
+		n, err := s.Exec(ctx, s.Queries.Purge, before)
+		if err != nil {
+			return total, err
+		}
+		if n == 0 {
+			return total, nil
+		}
+		total += n
+	}
+}
+
+// sqliteTime is how timestamps are stored: UTC with fixed width fractions, so that
+// comparing the text compares the times
+const sqliteTime = "2006-01-02T15:04:05.000000000Z"
+
+// sqliteTimeLayouts are the layouts timestamps written by SQLite itself or by older rows may have
+var sqliteTimeLayouts = []string{
+	sqliteTime,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+var (
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+	sqlTimeType  = reflect.TypeOf(time.Time{})
+)
+
+// sqliteArgs converts query arguments to values SQLite stores: timestamps become sqliteTime text,
+// pointers are dereferenced and JSON values are marshalled
+func sqliteArgs(args []any) []any {
+	converted := make([]any, len(args))
+	for i, arg := range args {
+		converted[i] = sqliteArg(arg)
+	}
+	return converted
+}
+
+func sqliteArg(arg any) any {
+	switch v := arg.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.UTC().Format(sqliteTime)
+	case sql.NullTime:
+		if !v.Valid {
+			return nil
+		}
+		return v.Time.UTC().Format(sqliteTime)
+	case []byte:
+		return v
+	}
+
+	rv := reflect.ValueOf(arg)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		return sqliteArg(rv.Elem().Interface())
+	}
+	if isJSONType(rv.Type()) {
+		if isNil(arg) {
+			return nil
+		}
+		b, err := json.Marshal(arg)
+		if err != nil {
+			// surfaced by SQLite rejecting the value
+			return arg
+		}
+		return string(b)
+	}
+	return arg
+}
+
+// isJSONType reports whether values of t are stored as JSON text
+func isJSONType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Map, reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Uint8
+	case reflect.Struct:
+		return t != sqlTimeType && t != nullTimeType
+	}
+	return false
+}
+
+// assignSQLite sets f to src, a value scanned from SQLite
+func assignSQLite(f reflect.Value, src any) error {
+	if src == nil {
+		f.SetZero()
+		return nil
+	}
+
+	switch f.Type() {
+	case sqlTimeType:
+		t, err := sqliteTimeOf(src)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	case nullTimeType:
+		t, err := sqliteTimeOf(src)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
+		return nil
+	}
+
+	if f.Kind() == reflect.Pointer {
+		v := reflect.New(f.Type().Elem())
+		if err := assignSQLite(v.Elem(), src); err != nil {
+			return err
+		}
+		f.Set(v)
+		return nil
+	}
+
+	if isJSONType(f.Type()) {
+		var b []byte
+		switch v := src.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			return fmt.Errorf("cannot decode JSON from %T", src)
+		}
+		return json.Unmarshal(b, f.Addr().Interface())
+	}
+
+	sv := reflect.ValueOf(src)
+	switch f.Kind() {
+	case reflect.String:
+		switch v := src.(type) {
+		case string:
+			f.SetString(v)
+		case []byte:
+			f.SetString(string(v))
+		default:
+			return fmt.Errorf("cannot assign %T to %s", src, f.Type())
+		}
+		return nil
+	case reflect.Bool:
+		switch v := src.(type) {
+		case bool:
+			f.SetBool(v)
+		case int64:
+			f.SetBool(v != 0)
+		default:
+			return fmt.Errorf("cannot assign %T to %s", src, f.Type())
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if sv.Kind() != reflect.Int64 && sv.Kind() != reflect.Float64 {
+			return fmt.Errorf("cannot assign %T to %s", src, f.Type())
+		}
+		f.Set(sv.Convert(f.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot assign %T to %s", src, f.Type())
+}
+
+func sqliteTimeOf(src any) (time.Time, error) {
+	switch v := src.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range sqliteTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as a timestamp", v)
+	}
+	return time.Time{}, fmt.Errorf("cannot assign %T to a timestamp", src)
+}
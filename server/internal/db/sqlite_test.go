@@ -0,0 +1,285 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSQLiteTable = `
+	CREATE TABLE test (
+		id TEXT PRIMARY KEY,
+		parent_id TEXT REFERENCES test (id),
+		name TEXT NOT NULL,
+		labels TEXT NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP,
+		deleted_at TIMESTAMP
+	)`
+
+func openTestSQLite(t *testing.T, schema ...string) *sql.DB {
+	t.Helper()
+	conn, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	for _, stmt := range schema {
+		_, err := conn.Exec(stmt)
+		require.NoError(t, err)
+	}
+	return conn
+}
+
+func TestSQLiteStore_Queries(t *testing.T) {
+	s := NewSQLiteSoftDeleteStore[testRecord](nil, "test")
+	cols := "id, parent_id, name, labels, created_at, updated_at, deleted_at"
+
+	assert.Equal(t, `SELECT `+cols+` FROM test WHERE id IN (SELECT value FROM json_each(?1)) AND deleted_at IS NULL`, s.Queries.GetByIDs)
+	assert.Equal(t, `UPDATE test SET parent_id = ?2, name = ?3, labels = ?4, updated_at = ?5 WHERE id = ?1 AND deleted_at IS NULL RETURNING `+cols, s.Queries.Update)
+	assert.Equal(t, `UPDATE test SET deleted_at = ?2 WHERE id = ?1 AND deleted_at IS NULL`, s.Queries.Delete)
+
+	plain := NewSQLiteStore[testPlainRecord](nil, "test")
+	assert.Equal(t, `DELETE FROM test WHERE id = ?1`, plain.Queries.Delete)
+	assert.Equal(t, `UPDATE test SET name = ?2 WHERE id = ?1 RETURNING id, name`, plain.Queries.Update)
+
+	scoped := NewSQLiteSoftDeleteStore[testScopedRecord](nil, "scoped")
+	assert.Equal(t, `UPDATE scoped SET name = ?2, updated_at = ?3 WHERE id = ?1 AND workspace_id = ?4 AND deleted_at IS NULL `+
+		`RETURNING id, workspace_id, name, created_at, updated_at, deleted_at`, scoped.Queries.Update)
+	assert.Equal(t, `UPDATE scoped SET deleted_at = ?2 WHERE id = ?1 AND workspace_id = ?3 AND deleted_at IS NULL`, scoped.Queries.Delete)
+}
+
+func TestSQLiteStore_CRUD(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLiteSoftDeleteStore[testRecord](openTestSQLite(t, testSQLiteTable), "test")
+
+	parent := &testRecord{Name: "parent", Labels: map[string]any{"color": "red"}}
+	require.NoError(t, s.Create(ctx, parent))
+	assert.Len(t, parent.ID, 36)
+	assert.False(t, parent.CreatedAt.IsZero())
+	assert.Equal(t, map[string]any{"color": "red"}, parent.Labels)
+
+	child := &testRecord{ParentID: &parent.ID, Name: "child"}
+	require.NoError(t, s.Create(ctx, child))
+	assert.Equal(t, map[string]any{}, child.Labels, "column default applies to nil values")
+
+	got, err := s.GetByID(ctx, child.ID)
+	require.NoError(t, err)
+	assert.Equal(t, parent.ID, *got.ParentID)
+	assert.Nil(t, got.DeletedAt)
+
+	got.Name = "renamed"
+	got.Labels = []any{"a"}
+	require.NoError(t, s.Update(ctx, &got))
+	assert.Equal(t, "renamed", got.Name)
+	assert.Equal(t, []any{"a"}, got.Labels)
+	assert.True(t, got.UpdatedAt.After(child.UpdatedAt))
+
+	both, err := s.GetByIDs(ctx, []string{parent.ID, child.ID, "missing"})
+	require.NoError(t, err)
+	assert.Len(t, both, 2)
+
+	_, err = s.GetByID(ctx, "missing")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.ErrorIs(t, s.Update(ctx, &testRecord{ID: "missing", Labels: map[string]any{}}), sql.ErrNoRows)
+}
+
+func TestSQLiteStore_Constraints(t *testing.T) {
+	ctx := context.Background()
+	conn := openTestSQLite(t, `CREATE TABLE test (id TEXT PRIMARY KEY, name TEXT NOT NULL UNIQUE, seen TIMESTAMP)`)
+	s := NewSQLiteStore[testReadOnlyRecord](conn, "test")
+
+	seen := time.Now()
+	a := &testReadOnlyRecord{Name: "a", Seen: &seen}
+	require.NoError(t, s.Create(ctx, a))
+	assert.Nil(t, a.Seen, "readonly columns are not written on create")
+
+	_, err := s.Exec(ctx, `UPDATE test SET seen = ?2 WHERE id = ?1`, a.ID, seen)
+	require.NoError(t, err)
+	a.Seen = nil
+	require.NoError(t, s.Update(ctx, a))
+	require.NotNil(t, a.Seen, "readonly columns are kept on update")
+
+	assert.ErrorIs(t, s.Create(ctx, &testReadOnlyRecord{Name: "a"}), ErrDuplicate)
+	assert.ErrorIs(t, s.Create(ctx, &testReadOnlyRecord{ID: a.ID, Name: "b"}), ErrDuplicate)
+	b := &testReadOnlyRecord{Name: "b"}
+	require.NoError(t, s.Create(ctx, b))
+	b.Name = "a"
+	assert.ErrorIs(t, s.Update(ctx, b), ErrDuplicate)
+}
+
+func TestSQLiteStore_StreamAll(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLiteSoftDeleteStore[testRecord](openTestSQLite(t, testSQLiteTable), "test")
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Create(ctx, &testRecord{Name: name}))
+	}
+
+	var names []string
+	require.NoError(t, s.StreamAll(ctx, func(item testRecord) error {
+		names = append(names, item.Name)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, names)
+
+	stop := errors.New("stop")
+	seen := 0
+	err := s.StreamAll(ctx, func(testRecord) error {
+		seen++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, seen)
+
+	t.Run("pages", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		require.NoError(t, s.Create(ctx, &testRecord{Name: "d"}))
+		deleted := &testRecord{Name: "e"}
+		require.NoError(t, s.Create(ctx, deleted))
+		require.NoError(t, s.Delete(ctx, deleted.ID))
+
+		var ids []string
+		require.NoError(t, s.streamPages(ctx, func(item testRecord) error {
+			ids = append(ids, item.ID)
+			_, err := s.GetByID(ctx, item.ID)
+			return err
+		}, 2), "the connection is free while fn runs")
+		assert.Len(t, ids, 4, "deleted records are left out")
+		assert.IsIncreasing(t, ids)
+	})
+}
+
+func TestSQLiteSoftDeleteStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLiteSoftDeleteStore[testRecord](openTestSQLite(t, testSQLiteTable), "test")
+
+	item := &testRecord{Name: "a"}
+	require.NoError(t, s.Create(ctx, item))
+	require.NoError(t, s.Delete(ctx, item.ID))
+	assert.ErrorIs(t, s.Delete(ctx, item.ID), sql.ErrNoRows)
+
+	all, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+	trash, err := s.GetTrash(ctx)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	require.NotNil(t, trash[0].DeletedAt)
+
+	require.NoError(t, s.Restore(ctx, item.ID))
+	assert.ErrorIs(t, s.Restore(ctx, item.ID), sql.ErrNoRows)
+
+	require.NoError(t, s.Delete(ctx, item.ID))
+	n, err := s.Purge(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = s.Purge(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestWithSQLTx(t *testing.T) {
+	ctx := context.Background()
+	conn := openTestSQLite(t, testSQLiteTable)
+	s := NewSQLiteStore[testRecord](conn, "test")
+	tx := SQLTransactor{Conn: conn}
+	failed := errors.New("failed")
+
+	err := tx.InTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, s.Create(ctx, &testRecord{Name: "rolled back"}))
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+
+	err = tx.InTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, s.Create(ctx, &testRecord{Name: "outer"}))
+		err := tx.InTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, s.Create(ctx, &testRecord{Name: "inner"}))
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		return nil
+	})
+	require.NoError(t, err)
+
+	all, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "outer", all[0].Name)
+}
+
+// TestSQLiteMigrations applies the sqlite migrations in order and checks that soft deletes
+// cascade like the Postgres stores' queries do, records stay within their workspace and their
+// past versions are kept
+func TestSQLiteMigrations(t *testing.T) {
+	const defaultWorkspace = "00000000-0000-0000-0000-000000000001"
+
+	ups, err := filepath.Glob("migrations/sqlite/*.up.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, ups)
+	sort.Strings(ups)
+
+	var schema []string
+	for _, up := range ups {
+		b, err := os.ReadFile(up)
+		require.NoError(t, err)
+		schema = append(schema, string(b))
+	}
+	conn := openTestSQLite(t, schema...)
+
+	ctx := context.Background()
+	exec := func(query string, args ...any) {
+		_, err := conn.ExecContext(ctx, query, sqliteArgs(args)...)
+		require.NoError(t, err)
+	}
+	deletedAt := func(table, id string) any {
+		var v any
+		require.NoError(t, conn.QueryRowContext(ctx, `SELECT deleted_at FROM `+table+` WHERE id = ?1`, id).Scan(&v))
+		return v
+	}
+
+	exec(`INSERT INTO workspaces (id, name) VALUES ('w', 'other')`)
+	exec(`INSERT INTO plant_species (id, name, taxon, workspace_id) VALUES ('s', 'species', 'taxon', ?1)`, defaultWorkspace)
+	exec(`INSERT INTO plant_cultivar (id, species_id, name, cultivar, workspace_id) VALUES ('c', 's', 'cultivar', 'c', ?1)`, defaultWorkspace)
+	exec(`INSERT INTO plant (id, cultivar_id, species_id, generation, workspace_id) VALUES ('p', 'c', 's', 0, ?1)`, defaultWorkspace)
+
+	refused := func(query string, args ...any) {
+		_, err := conn.ExecContext(ctx, query, sqliteArgs(args)...)
+		assert.ErrorContains(t, err, "workspace", query)
+	}
+	refused(`INSERT INTO plant_species (id, name, taxon) VALUES ('s2', 'species', 'taxon')`)
+	refused(`INSERT INTO plant_cultivar (id, species_id, name, cultivar, workspace_id) VALUES ('c2', 's', 'cultivar', 'c', 'w')`)
+	refused(`INSERT INTO plant (id, cultivar_id, species_id, generation, workspace_id) VALUES ('p2', 'c', 's', 0, 'w')`)
+	refused(`UPDATE plant_species SET workspace_id = 'w' WHERE id = 's'`)
+
+	exec(`UPDATE plant_species SET deleted_at = ?1 WHERE id = 's'`, time.Now())
+	assert.NotNil(t, deletedAt("plant_cultivar", "c"))
+	assert.Equal(t, deletedAt("plant_species", "s"), deletedAt("plant", "p"))
+
+	exec(`UPDATE plant_species SET deleted_at = NULL WHERE id = 's'`)
+	assert.Nil(t, deletedAt("plant_cultivar", "c"))
+	assert.Nil(t, deletedAt("plant", "p"))
+
+	var versions int
+	require.NoError(t, conn.QueryRowContext(ctx, `SELECT count(*) FROM plant_history WHERE id = 'p'`).Scan(&versions))
+	assert.Equal(t, 2, versions, "cascaded deletes and restores keep the versions they replace")
+
+	downs, err := filepath.Glob("migrations/sqlite/*.down.sql")
+	require.NoError(t, err)
+	assert.Len(t, downs, len(ups))
+	sort.Sort(sort.Reverse(sort.StringSlice(downs)))
+	for _, down := range downs {
+		b, err := os.ReadFile(down)
+		require.NoError(t, err)
+		_, err = conn.Exec(string(b))
+		require.NoError(t, err, strings.TrimPrefix(down, "migrations/sqlite/"))
+	}
+}
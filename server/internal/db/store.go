@@ -0,0 +1,405 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Columns managed by the database rather than written from a model
+const (
+	ColumnID        = "id"
+	ColumnCreatedAt = "created_at"
+	ColumnUpdatedAt = "updated_at"
+	ColumnDeletedAt = "deleted_at"
+)
+
+// pgUniqueViolation is the SQLSTATE of a write breaking a unique constraint
+const pgUniqueViolation = "23505"
+
+// Querier runs queries; it is satisfied by *pgxpool.Pool, *pgx.Conn and pgx.Tx
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Queries are the statements a Store[T] runs. Each is generated from T's `db` tags
+// and may be replaced after construction.
+//
+//	GetAll, GetByID($1 id), GetByIDs($1 ids): select every column
+//	Update($1 id, $2... writable columns in field order): returns every column
+//	Delete($1 id), Restore($1 id): return the number of records changed as a single row
+//	GetTrash: selects every column of deleted records
+//	Purge($1 cutoff): permanently deletes records deleted before the cutoff
+//	IsTrashed($1 id): returns whether the record is in the trash
+//	GetAllAsOf($1 time), GetByIDAsOf($1 id, $2 time): select every column of records as they were
+//	at the time, for HistoryStore[T]
+//
+// For tables with a workspace_id column every query but Purge takes the workspace as its last
+// parameter, see Scope.
+type Queries struct {
+	GetAll    string
+	GetByID   string
+	GetByIDs  string
+	Update    string
+	Delete    string
+	GetTrash  string
+	Restore   string
+	Purge     string
+	IsTrashed string
+
+	GetAllAsOf  string
+	GetByIDAsOf string
+}
+
+// Store implements CRUDTable[T] for any struct whose fields carry `db` tags naming their columns.
+// It joins the transaction carried by the context of each call, see WithTx.
+//
+// The id, created_at, updated_at and deleted_at columns are managed by the store: ids and
+// created_at come from column defaults, updated_at is set on every update, and tables with
+// deleted_at only ever see live records. Tables with workspace_id only ever see the records of
+// the workspace carried by the context, see WithWorkspace.
+type Store[T any] struct {
+	Conn    Querier
+	Table   string
+	Queries Queries
+
+	fields *Fields
+}
+
+// NewStore creates a Store[T] for table, a schema qualified table name
+func NewStore[T any](conn Querier, table string) *Store[T] {
+	s := &Store[T]{
+		Conn:   conn,
+		Table:  table,
+		fields: FieldsOf[T](),
+	}
+	s.Queries = s.generateQueries()
+	return s
+}
+
+// generateQueries builds the default Queries from T's columns
+func (s *Store[T]) generateQueries() Queries {
+	cols := strings.Join(s.fields.Columns(), ", ")
+	writable := s.fields.Writable()
+	var all []string
+	live := ""
+	if s.fields.Has(ColumnDeletedAt) {
+		all = append(all, ColumnDeletedAt+" IS NULL")
+		live = " AND " + ColumnDeletedAt + " IS NULL"
+	}
+	// ws scopes a query taking n other parameters to the workspace
+	ws := func(n int) string {
+		if !s.fields.Has(ColumnWorkspaceID) {
+			return ""
+		}
+		return fmt.Sprintf(" AND %s = $%d", ColumnWorkspaceID, n+1)
+	}
+	if s.fields.Has(ColumnWorkspaceID) {
+		all = append(all, ColumnWorkspaceID+" = $1")
+	}
+	where := ""
+	if len(all) > 0 {
+		where = " WHERE " + strings.Join(all, " AND ")
+	}
+
+	var sets []string
+	for i, col := range writable {
+		sets = append(sets, fmt.Sprintf("%s = $%d", col, i+2))
+	}
+	if s.fields.Has(ColumnUpdatedAt) {
+		sets = append(sets, ColumnUpdatedAt+" = NOW()")
+	}
+
+	q := Queries{
+		GetAll:   `SELECT ` + cols + ` FROM ` + s.Table + where,
+		GetByID:  `SELECT ` + cols + ` FROM ` + s.Table + ` WHERE id = $1` + ws(1) + live,
+		GetByIDs: `SELECT ` + cols + ` FROM ` + s.Table + ` WHERE id = ANY($1)` + ws(1) + live,
+		Update: `UPDATE ` + s.Table + ` SET ` + strings.Join(sets, ", ") +
+			` WHERE id = $1` + ws(len(writable)+1) + live + ` RETURNING ` + cols,
+		Delete: `WITH deleted AS (DELETE FROM ` + s.Table + ` WHERE id = $1` + ws(1) + ` RETURNING 1) SELECT count(*) FROM deleted`,
+	}
+	if s.fields.Has(ColumnDeletedAt) {
+		q.Delete = `WITH deleted AS (UPDATE ` + s.Table + ` SET deleted_at = NOW() WHERE id = $1` + ws(1) + live +
+			` RETURNING 1) SELECT count(*) FROM deleted`
+		q.GetTrash = `SELECT ` + cols + ` FROM ` + s.Table + ` WHERE deleted_at IS NOT NULL` + ws(0)
+		q.Restore = `WITH restored AS (UPDATE ` + s.Table + ` SET deleted_at = NULL WHERE id = $1` + ws(1) + ` AND deleted_at IS NOT NULL` +
+			` RETURNING 1) SELECT count(*) FROM restored`
+		q.Purge = `DELETE FROM ` + s.Table + ` WHERE deleted_at < $1`
+		q.IsTrashed = `SELECT EXISTS (SELECT 1 FROM ` + s.Table + ` WHERE id = $1` + ws(1) + ` AND deleted_at IS NOT NULL)`
+	}
+	return q
+}
+
+// Scope returns args followed by the workspace ctx is scoped to, for running Queries and queries
+// written for a table with a workspace_id column. It returns ErrNoWorkspace when ctx carries none,
+// and args unchanged for tables without the column.
+func (s *Store[T]) Scope(ctx context.Context, args ...any) ([]any, error) {
+	return scope(ctx, s.fields, args...)
+}
+
+// Querier returns the transaction carried by ctx, or Conn outside of one
+func (s *Store[T]) Querier(ctx context.Context) Querier {
+	return Conn(ctx, s.Conn)
+}
+
+// Select runs query and scans every row into a T by column name
+func (s *Store[T]) Select(ctx context.Context, query string, args ...any) ([]T, error) {
+	rows, err := s.Querier(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByName[T])
+}
+
+// SelectOne runs query and scans its single row into a T by column name
+func (s *Store[T]) SelectOne(ctx context.Context, query string, args ...any) (T, error) {
+	rows, err := s.Querier(ctx).Query(ctx, query, args...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[T])
+}
+
+// Each runs query and calls fn with every row scanned into a T, one row at a time.
+// It stops at the first error fn returns.
+func (s *Store[T]) Each(ctx context.Context, fn func(item T) error, query string, args ...any) error {
+	rows, err := s.Querier(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := pgx.RowToStructByName[T](rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetAll retrieves all records from the table
+func (s *Store[T]) GetAll(ctx context.Context) ([]T, error) {
+	args, err := s.Scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, s.Queries.GetAll, args...)
+}
+
+// StreamAll calls fn with each record of the table as it is read
+func (s *Store[T]) StreamAll(ctx context.Context, fn func(item T) error) error {
+	args, err := s.Scope(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Each(ctx, fn, s.Queries.GetAll, args...)
+}
+
+// GetByID retrieves the record identified by `id`
+func (s *Store[T]) GetByID(ctx context.Context, id string) (T, error) {
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return s.SelectOne(ctx, s.Queries.GetByID, args...)
+}
+
+// GetByIDs retrieves the records identified by `ids`, skipping any that do not exist
+func (s *Store[T]) GetByIDs(ctx context.Context, ids []string) ([]T, error) {
+	args, err := s.Scope(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, s.Queries.GetByIDs, args...)
+}
+
+// Create inserts item, scanning the stored record back into it.
+// Columns whose value is nil, and an empty id, are left to their column defaults.
+func (s *Store[T]) Create(ctx context.Context, item *T) error {
+	v := reflect.ValueOf(item).Elem()
+
+	var cols, params []string
+	var args []any
+	if id := s.fields.Value(v, ColumnID); id != nil && !isZero(id) {
+		cols = append(cols, ColumnID)
+		args = append(args, id)
+	}
+	if s.fields.Has(ColumnWorkspaceID) {
+		workspace, err := s.Scope(ctx)
+		if err != nil {
+			return err
+		}
+		cols = append(cols, ColumnWorkspaceID)
+		args = append(args, workspace...)
+	}
+	for _, col := range s.fields.Writable() {
+		val := s.fields.Value(v, col)
+		if isNil(val) {
+			continue
+		}
+		cols = append(cols, col)
+		args = append(args, val)
+	}
+	for i := range cols {
+		params = append(params, fmt.Sprintf("$%d", i+1))
+	}
+
+	query := `INSERT INTO ` + s.Table + ` DEFAULT VALUES RETURNING ` + strings.Join(s.fields.Columns(), ", ")
+	if len(cols) > 0 {
+		query = `INSERT INTO ` + s.Table + ` (` + strings.Join(cols, ", ") + `) VALUES (` + strings.Join(params, ", ") +
+			`) RETURNING ` + strings.Join(s.fields.Columns(), ", ")
+	}
+
+	created, err := s.SelectOne(ctx, query, args...)
+	if err != nil {
+		return pgDuplicate(err)
+	}
+	*item = created
+	return nil
+}
+
+// Update writes every writable column of item, scanning the stored record back into it
+func (s *Store[T]) Update(ctx context.Context, item *T) error {
+	v := reflect.ValueOf(item).Elem()
+
+	args := []any{s.fields.Value(v, ColumnID)}
+	for _, col := range s.fields.Writable() {
+		args = append(args, s.fields.Value(v, col))
+	}
+	args, err := s.Scope(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := s.SelectOne(ctx, s.Queries.Update, args...)
+	if err != nil {
+		return pgDuplicate(err)
+	}
+	*item = updated
+	return nil
+}
+
+// Delete removes the record identified by `id`, or moves it to the trash if the table has deleted_at
+func (s *Store[T]) Delete(ctx context.Context, id string) error {
+	return s.changeOne(ctx, s.Queries.Delete, id)
+}
+
+// changeOne runs a query for the record identified by id, scoped to the workspace, returning a
+// count of changed records, expecting at least one
+func (s *Store[T]) changeOne(ctx context.Context, query string, id string) error {
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		return err
+	}
+	var n int
+	if err := s.Querier(ctx).QueryRow(ctx, query, args...).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SoftDeleteStore is a Store[T] for tables with a deleted_at column, adding the
+// SoftDeleteTable[T] methods
+type SoftDeleteStore[T any] struct {
+	*Store[T]
+}
+
+// NewSoftDeleteStore creates a SoftDeleteStore[T] for table. T must have a deleted_at column.
+func NewSoftDeleteStore[T any](conn Querier, table string) *SoftDeleteStore[T] {
+	s := NewStore[T](conn, table)
+	if !s.fields.Has(ColumnDeletedAt) {
+		panic(fmt.Sprintf("db: %T has no %s column", *new(T), ColumnDeletedAt))
+	}
+	return &SoftDeleteStore[T]{Store: s}
+}
+
+// GetTrash retrieves all records in the trash
+func (s *SoftDeleteStore[T]) GetTrash(ctx context.Context) ([]T, error) {
+	args, err := s.Scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(ctx, s.Queries.GetTrash, args...)
+}
+
+// Restore moves the record identified by `id` out of the trash.
+// It returns ErrParentDeleted if the record is in the trash but could not be restored.
+func (s *SoftDeleteStore[T]) Restore(ctx context.Context, id string) error {
+	err := s.changeOne(ctx, s.Queries.Restore, id)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	args, err := s.Scope(ctx, id)
+	if err != nil {
+		return err
+	}
+	var trashed bool
+	if err := s.Querier(ctx).QueryRow(ctx, s.Queries.IsTrashed, args...).Scan(&trashed); err != nil {
+		return err
+	}
+	if trashed {
+		return ErrParentDeleted
+	}
+	return sql.ErrNoRows
+}
+
+// Purge permanently removes records deleted before `before`, in every workspace.
+// It repeats until a pass removes nothing, so Purge queries may leave records that
+// others still depend on for a later pass.
+func (s *SoftDeleteStore[T]) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var total int64
+	for {
+		tag, err := s.Querier(ctx).Exec(ctx, s.Queries.Purge, before)
+		if err != nil {
+			return total, err
+		}
+		if tag.RowsAffected() == 0 {
+			return total, nil
+		}
+		total += tag.RowsAffected()
+	}
+}
+
+// pgDuplicate wraps a unique violation from Postgres in ErrDuplicate
+func pgDuplicate(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return fmt.Errorf("%w: %s", ErrDuplicate, pgErr.Detail)
+	}
+	return err
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func isZero(v any) bool {
+	return isNil(v) || reflect.ValueOf(v).IsZero()
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	ID        string     `db:"id"`
+	ParentID  *string    `db:"parent_id"`
+	Name      string     `db:"name"`
+	Labels    any        `db:"labels"`
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
+	Note      string     `db:"-"`
+	Computed  string
+}
+
+type testPlainRecord struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+}
+
+// testReadOnlyRecord has a column only written by queries of its own
+type testReadOnlyRecord struct {
+	ID   string     `db:"id"`
+	Name string     `db:"name"`
+	Seen *time.Time `db:"seen,readonly"`
+}
+
+// testScopedRecord belongs to a workspace
+type testScopedRecord struct {
+	ID          string     `db:"id"`
+	WorkspaceID string     `db:"workspace_id"`
+	Name        string     `db:"name"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+	DeletedAt   *time.Time `db:"deleted_at"`
+}
+
+func TestFieldsOf(t *testing.T) {
+	f := FieldsOf[testRecord]()
+
+	assert.Equal(t, []string{"id", "parent_id", "name", "labels", "created_at", "updated_at", "deleted_at"}, f.Columns())
+	assert.Equal(t, []string{"parent_id", "name", "labels"}, f.Writable())
+	assert.True(t, f.Has("deleted_at"))
+	assert.False(t, f.Has("Computed"))
+	assert.Same(t, f, FieldsOf[testRecord]())
+
+	ro := FieldsOf[testReadOnlyRecord]()
+	assert.Equal(t, []string{"id", "name", "seen"}, ro.Columns())
+	assert.Equal(t, []string{"name"}, ro.Writable())
+	assert.Equal(t, []string{"seen"}, ro.ReadOnly())
+}
+
+func TestPGDuplicate(t *testing.T) {
+	err := pgDuplicate(&pgconn.PgError{Code: pgUniqueViolation, Detail: "Key (name)=(a) already exists."})
+	assert.ErrorIs(t, err, ErrDuplicate)
+	assert.Contains(t, err.Error(), "Key (name)=(a)")
+
+	other := &pgconn.PgError{Code: "23503"}
+	assert.Same(t, other, pgDuplicate(other))
+}
+
+func TestStore_Queries(t *testing.T) {
+	t.Run("soft delete", func(t *testing.T) {
+		s := NewSoftDeleteStore[testRecord](nil, "mendel_core.test")
+		cols := "id, parent_id, name, labels, created_at, updated_at, deleted_at"
+
+		assert.Equal(t, "SELECT "+cols+" FROM mendel_core.test WHERE deleted_at IS NULL", s.Queries.GetAll)
+		assert.Equal(t, "SELECT "+cols+" FROM mendel_core.test WHERE id = $1 AND deleted_at IS NULL", s.Queries.GetByID)
+		assert.Equal(t,
+			"UPDATE mendel_core.test SET parent_id = $2, name = $3, labels = $4, updated_at = NOW() "+
+				"WHERE id = $1 AND deleted_at IS NULL RETURNING "+cols,
+			s.Queries.Update,
+		)
+		assert.Contains(t, s.Queries.Delete, "SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL")
+		assert.Equal(t, "SELECT "+cols+" FROM mendel_core.test WHERE deleted_at IS NOT NULL", s.Queries.GetTrash)
+		assert.Equal(t, "DELETE FROM mendel_core.test WHERE deleted_at < $1", s.Queries.Purge)
+	})
+
+	t.Run("hard delete", func(t *testing.T) {
+		s := NewStore[testPlainRecord](nil, "mendel_core.plain")
+
+		assert.Equal(t, "SELECT id, name FROM mendel_core.plain", s.Queries.GetAll)
+		assert.Equal(t, "UPDATE mendel_core.plain SET name = $2 WHERE id = $1 RETURNING id, name", s.Queries.Update)
+		assert.Contains(t, s.Queries.Delete, "DELETE FROM mendel_core.plain WHERE id = $1")
+		assert.Empty(t, s.Queries.GetTrash)
+	})
+
+	t.Run("workspace", func(t *testing.T) {
+		s := NewSoftDeleteStore[testScopedRecord](nil, "mendel_core.scoped")
+		cols := "id, workspace_id, name, created_at, updated_at, deleted_at"
+
+		assert.Equal(t, "SELECT "+cols+" FROM mendel_core.scoped WHERE deleted_at IS NULL AND workspace_id = $1", s.Queries.GetAll)
+		assert.Equal(t, "SELECT "+cols+" FROM mendel_core.scoped WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NULL", s.Queries.GetByID)
+		assert.Equal(t,
+			"UPDATE mendel_core.scoped SET name = $2, updated_at = NOW() "+
+				"WHERE id = $1 AND workspace_id = $3 AND deleted_at IS NULL RETURNING "+cols,
+			s.Queries.Update,
+		)
+		assert.Contains(t, s.Queries.Delete, "SET deleted_at = NOW() WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NULL")
+		assert.Equal(t, "SELECT "+cols+" FROM mendel_core.scoped WHERE deleted_at IS NOT NULL AND workspace_id = $1", s.Queries.GetTrash)
+		assert.Contains(t, s.Queries.Restore, "WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NOT NULL")
+		assert.Equal(t, "DELETE FROM mendel_core.scoped WHERE deleted_at < $1", s.Queries.Purge, "purges reach every workspace")
+	})
+
+	t.Run("soft delete requires deleted_at", func(t *testing.T) {
+		assert.Panics(t, func() { NewSoftDeleteStore[testPlainRecord](nil, "mendel_core.plain") })
+	})
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Beginner starts transactions; it is satisfied by *pgxpool.Pool, *pgx.Conn and pgx.Tx
+type Beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Transactor runs a function as a single unit of work: everything the stores do with the
+// context handed to fn is committed together, or not at all if fn returns an error
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// WithTx runs fn in a transaction begun on conn, committing if fn returns nil and rolling back otherwise.
+// Stores join the transaction through the context passed to fn. When ctx already carries a transaction,
+// fn runs in a savepoint of it instead.
+func WithTx(ctx context.Context, conn Beginner, fn func(ctx context.Context) error) error {
+	var (
+		tx  pgx.Tx
+		err error
+	)
+	if outer, ok := TxFrom(ctx); ok {
+		tx, err = outer.Begin(ctx)
+	} else {
+		tx, err = conn.Begin(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// TxFrom returns the transaction carried by ctx, if any
+func TxFrom(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// Conn returns the transaction carried by ctx, or conn outside of one
+func Conn(ctx context.Context, conn Querier) Querier {
+	if tx, ok := TxFrom(ctx); ok {
+		return tx
+	}
+	return conn
+}
+
+// PoolTransactor is a Transactor running units of work in Postgres transactions
+type PoolTransactor struct {
+	Conn Beginner
+}
+
+func (t PoolTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return WithTx(ctx, t.Conn, fn)
+}
+
+// InTx runs fn through t, or directly if t is nil
+func InTx(ctx context.Context, t Transactor, fn func(ctx context.Context) error) error {
+	if t == nil {
+		return fn(ctx)
+	}
+	return t.InTx(ctx, fn)
+}
+
+type sqlTxKey struct{}
+
+// sqlTx is a database/sql transaction carried by a context, with the depth of the savepoint it is in
+type sqlTx struct {
+	tx    *sql.Tx
+	depth int
+}
+
+// WithSQLTx is WithTx for database/sql: it runs fn in a transaction begun on conn, or in a
+// savepoint of the transaction ctx already carries. Stores join it through SQLConn.
+func WithSQLTx(ctx context.Context, conn *sql.DB, fn func(ctx context.Context) error) error {
+	if outer, ok := ctx.Value(sqlTxKey{}).(*sqlTx); ok {
+		return withSavepoint(ctx, outer, fn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, sqlTxKey{}, &sqlTx{tx: tx})); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// withSavepoint runs fn in a savepoint of outer, rolling back to it if fn returns an error or panics
+func withSavepoint(ctx context.Context, outer *sqlTx, fn func(ctx context.Context) error) error {
+	inner := &sqlTx{tx: outer.tx, depth: outer.depth + 1}
+	name := fmt.Sprintf("sp_%d", inner.depth)
+	if _, err := inner.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	rollback := func() error {
+		if _, err := inner.tx.ExecContext(context.WithoutCancel(ctx), "ROLLBACK TO "+name); err != nil {
+			return err
+		}
+		_, err := inner.tx.ExecContext(context.WithoutCancel(ctx), "RELEASE "+name)
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, sqlTxKey{}, inner)); err != nil {
+		if rbErr := rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	_, err := inner.tx.ExecContext(ctx, "RELEASE "+name)
+	return err
+}
+
+// SQLConn returns the database/sql transaction carried by ctx, or conn outside of one
+func SQLConn(ctx context.Context, conn SQLQuerier) SQLQuerier {
+	if tx, ok := ctx.Value(sqlTxKey{}).(*sqlTx); ok {
+		return tx.tx
+	}
+	return conn
+}
+
+// SQLTransactor is a Transactor running units of work in database/sql transactions
+type SQLTransactor struct {
+	Conn *sql.DB
+}
+
+func (t SQLTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return WithSQLTx(ctx, t.Conn, fn)
+}
@@ -1,6 +1,35 @@
 package db
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrParentDeleted is returned when restoring a record whose parent is still in the trash
+var ErrParentDeleted = errors.New("parent record is deleted; restore it first")
+
+// ErrDuplicate is returned when a write would break a unique constraint
+var ErrDuplicate = errors.New("a record with the same unique values already exists")
+
+// ErrConflict is returned when loading a record whose ID is taken, under ConflictFail
+var ErrConflict = errors.New("a record with the same id already exists")
+
+// Conflict is what ArchiveTable.Load does with a record whose ID another record has
+type Conflict string
+
+// Conflict policies
+const (
+	// ConflictFail fails with ErrConflict
+	ConflictFail Conflict = "fail"
+	// ConflictSkip keeps the stored record
+	ConflictSkip Conflict = "skip"
+	// ConflictReplace overwrites the stored record
+	ConflictReplace Conflict = "replace"
+)
+
+// Conflicts lists every Conflict policy
+var Conflicts = []Conflict{ConflictFail, ConflictSkip, ConflictReplace}
 
 // CRUDTable is an interface for go_model-to-db_record mapping for a table as T
 //
@@ -12,3 +41,56 @@ type CRUDTable[T any] interface {
 	Update(ctx context.Context, item *T) error
 	Delete(ctx context.Context, id string) error
 }
+
+// BulkReader is implemented by a CRUDTable[T] that can fetch many records in one round trip
+//
+//	GetByIDs: the live records among ids, in no particular order
+type BulkReader[T any] interface {
+	GetByIDs(ctx context.Context, ids []string) ([]T, error)
+}
+
+// StreamTable is implemented by a CRUDTable[T] that can hand over records as they are read
+// instead of collecting them first
+//
+//	StreamAll: calls fn with each record GetAll would return, stopping at the first error fn returns
+type StreamTable[T any] interface {
+	StreamAll(ctx context.Context, fn func(item T) error) error
+}
+
+// Purger permanently removes records that were soft deleted before a cutoff
+type Purger interface {
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// SoftDeleteTable is implemented by a CRUDTable[T] whose Delete moves records to a trash
+//
+//	GetTrash: lists deleted records
+//	Restore: moves a record, and anything deleted along with it, out of the trash
+type SoftDeleteTable[T any] interface {
+	Purger
+	GetTrash(ctx context.Context) ([]T, error)
+	Restore(ctx context.Context, id string) error
+}
+
+// LineageTable is implemented by a CRUDTable[T] whose records descend from other records
+//
+//	GetLineage: the record identified by id and all of its ancestors, deleted or not
+type LineageTable[T any] interface {
+	GetLineage(ctx context.Context, id string) ([]T, error)
+}
+
+// ImpactTable is implemented by a CRUDTable[T] that can preview what its Delete would affect
+type ImpactTable interface {
+	GetImpact(ctx context.Context, id string) (Impact, error)
+}
+
+// ArchiveTable is implemented by a CRUDTable[T] whose records can be copied out and written back
+// whole, as logical backups do: the records of every workspace, in the trash or not, with every
+// column including those the store otherwise manages.
+//
+//	Dump: calls fn with every record, oldest first, stopping at the first error fn returns
+//	Load: inserts item as it is or, when its ID is taken, does as conflict says; it reports whether item was written
+type ArchiveTable[T any] interface {
+	Dump(ctx context.Context, fn func(item T) error) error
+	Load(ctx context.Context, item *T, conflict Conflict) (bool, error)
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// ColumnWorkspaceID scopes the records of a table to a workspace.
+// Stores of tables with it only read and write the records of the workspace carried by the
+// context of each call, see WithWorkspace, and set it on create; it is never written from the model.
+const ColumnWorkspaceID = "workspace_id"
+
+// ErrNoWorkspace is returned when a table scoped to workspaces is used with a context carrying none
+var ErrNoWorkspace = errors.New("db: no workspace in context")
+
+type workspaceKey struct{}
+
+// WithWorkspace returns a copy of ctx scoping every store call made with it to the workspace
+// identified by id
+func WithWorkspace(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, id)
+}
+
+// WorkspaceFrom returns the workspace ctx is scoped to, if any
+func WorkspaceFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(workspaceKey{}).(string)
+	return id, ok && id != ""
+}
+
+// scope returns args followed by the workspace ctx is scoped to when fields has a workspace_id
+// column, or ErrNoWorkspace when it carries none. Tables without the column get args unchanged.
+func scope(ctx context.Context, fields *Fields, args ...any) ([]any, error) {
+	if !fields.Has(ColumnWorkspaceID) {
+		return args, nil
+	}
+	id, ok := WorkspaceFrom(ctx)
+	if !ok {
+		return nil, ErrNoWorkspace
+	}
+	return append(args, id), nil
+}
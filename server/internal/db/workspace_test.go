@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSQLiteScopedTable = `
+	CREATE TABLE scoped (
+		id TEXT PRIMARY KEY,
+		workspace_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP,
+		deleted_at TIMESTAMP
+	)`
+
+func TestWithWorkspace(t *testing.T) {
+	_, ok := WorkspaceFrom(context.Background())
+	assert.False(t, ok)
+	_, ok = WorkspaceFrom(WithWorkspace(context.Background(), ""))
+	assert.False(t, ok, "an empty workspace is none")
+
+	id, ok := WorkspaceFrom(WithWorkspace(context.Background(), "w"))
+	assert.True(t, ok)
+	assert.Equal(t, "w", id)
+}
+
+// TestStores_Workspace checks stores of tables with a workspace_id column only ever see the
+// records of the context's workspace
+func TestStores_Workspace(t *testing.T) {
+	type scopedTable interface {
+		CRUDTable[testScopedRecord]
+		BulkReader[testScopedRecord]
+		SoftDeleteTable[testScopedRecord]
+	}
+	stores := map[string]func(t *testing.T) scopedTable{
+		"sqlite": func(t *testing.T) scopedTable {
+			return NewSQLiteSoftDeleteStore[testScopedRecord](openTestSQLite(t, testSQLiteScopedTable), "scoped")
+		},
+		"memory": func(t *testing.T) scopedTable {
+			return NewMemorySoftDeleteStore[testScopedRecord](NewMemory(), "scoped")
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			ours := WithWorkspace(context.Background(), "ours")
+			theirs := WithWorkspace(context.Background(), "theirs")
+
+			mine := &testScopedRecord{Name: "mine", WorkspaceID: "theirs"}
+			require.NoError(t, s.Create(ours, mine))
+			assert.Equal(t, "ours", mine.WorkspaceID, "the workspace comes from the context, never the model")
+			other := &testScopedRecord{Name: "other"}
+			require.NoError(t, s.Create(theirs, other))
+
+			all, err := s.GetAll(ours)
+			require.NoError(t, err)
+			require.Len(t, all, 1)
+			assert.Equal(t, mine.ID, all[0].ID)
+			some, err := s.GetByIDs(ours, []string{mine.ID, other.ID})
+			require.NoError(t, err)
+			assert.Len(t, some, 1)
+
+			_, err = s.GetByID(ours, other.ID)
+			assert.ErrorIs(t, err, sql.ErrNoRows)
+			stolen := *other
+			stolen.Name = "stolen"
+			assert.ErrorIs(t, s.Update(ours, &stolen), sql.ErrNoRows)
+			assert.ErrorIs(t, s.Delete(ours, other.ID), sql.ErrNoRows)
+
+			moved := *mine
+			moved.WorkspaceID = "theirs"
+			require.NoError(t, s.Update(ours, &moved))
+			assert.Equal(t, "ours", moved.WorkspaceID, "updates keep the workspace")
+
+			require.NoError(t, s.Delete(theirs, other.ID))
+			trash, err := s.GetTrash(ours)
+			require.NoError(t, err)
+			assert.Empty(t, trash)
+			assert.ErrorIs(t, s.Restore(ours, other.ID), sql.ErrNoRows)
+			require.NoError(t, s.Restore(theirs, other.ID))
+
+			ctx := context.Background()
+			_, err = s.GetAll(ctx)
+			assert.ErrorIs(t, err, ErrNoWorkspace)
+			_, err = s.GetByID(ctx, mine.ID)
+			assert.ErrorIs(t, err, ErrNoWorkspace)
+			assert.ErrorIs(t, s.Create(ctx, &testScopedRecord{Name: "nowhere"}), ErrNoWorkspace)
+			assert.ErrorIs(t, s.Delete(ctx, mine.ID), ErrNoWorkspace)
+
+			require.NoError(t, s.Delete(ours, mine.ID))
+			require.NoError(t, s.Delete(theirs, other.ID))
+			n, err := s.Purge(ctx, time.Now().Add(time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), n, "purges need no workspace and reach every one")
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+// ErrForbidden wraps every reason an Authorizer refuses a request
+var ErrForbidden = errors.New("forbidden")
+
+// Authorizer decides what requests may do to the records of components
+type Authorizer interface {
+	// Authorize returns an error wrapping ErrForbidden if the request may not take action on
+	// the records of component, or nil if it may
+	Authorize(c *gin.Context, component string, action workspace.Action) error
+}
+
+// authorized reports whether access allows the request to take action on the records of
+// component, responding if not. Every request is allowed when access is nil.
+//
+//	403: access refused the request
+func authorized(c *gin.Context, access Authorizer, component string, action workspace.Action) bool {
+	if access == nil {
+		return true
+	}
+	err := access.Authorize(c, component, action)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrForbidden):
+		responses.RespondError(c, err.Error(), http.StatusForbidden)
+	default:
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
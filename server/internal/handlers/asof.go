@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/openapi"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+// queryAsOf asks for records as they were at a past time
+const queryAsOf = "as_of"
+
+// asOf reads ?as_of= from a request for records of a db.HistoryTable[T], responding on failure.
+// It returns the zero time when the request asks for the records as they are now.
+//
+//	400: as_of is not an RFC 3339 time, or Table keeps no history
+func (h *CRUDHandler[T, PT]) asOf(c *gin.Context) (time.Time, bool) {
+	v := c.Query(queryAsOf)
+	if v == "" {
+		return time.Time{}, true
+	}
+	if _, ok := h.Table.(db.HistoryTable[T]); !ok {
+		responses.RespondError(c, fmt.Sprintf("%s is not supported: past versions are not kept", queryAsOf), http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		responses.RespondError(c, fmt.Sprintf("%s must be an RFC 3339 time", queryAsOf), http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// asOfParam documents ?as_of= for reads of what
+func asOfParam(what string) openapi.Parameter {
+	return openapi.QueryParam(queryAsOf, what+" as it was at this time", &openapi.Schema{Type: "string", Format: "date-time"})
+}
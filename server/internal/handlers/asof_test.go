@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRUDHandler_AsOf(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+	// tick returns a time between the writes before and after it
+	tick := func() string {
+		time.Sleep(5 * time.Millisecond)
+		at := time.Now()
+		time.Sleep(5 * time.Millisecond)
+		return url.QueryEscape(at.Format(time.RFC3339Nano))
+	}
+
+	before := tick()
+	var note testNote
+	w.data(w.do(http.MethodPost, "/notes/", `{"name": "first"}`, admin), &note)
+	created := tick()
+	w.data(w.do(http.MethodPut, "/notes/"+note.ID, `{"name": "second"}`, admin), &note)
+	updated := tick()
+	w.data(w.do(http.MethodDelete, "/notes/"+note.ID, "", admin), new(string))
+
+	var got testNote
+	w.data(w.do(http.MethodGet, "/notes/"+note.ID+"?as_of="+created, "", admin), &got)
+	assert.Equal(t, "first", got.Name)
+	w.data(w.do(http.MethodGet, "/notes/"+note.ID+"?as_of="+updated, "", admin), &got)
+	assert.Equal(t, "second", got.Name)
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodGet, "/notes/"+note.ID, "", admin).Code)
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodGet, "/notes/"+note.ID+"?as_of="+before, "", admin).Code)
+
+	var notes []testNote
+	w.data(w.do(http.MethodGet, "/notes/?as_of="+created, "", admin), &notes)
+	require.Len(t, notes, 1)
+	assert.Equal(t, "first", notes[0].Name)
+	w.data(w.do(http.MethodGet, "/notes/", "", admin), &notes)
+	assert.Empty(t, notes)
+
+	res := w.do(http.MethodGet, "/notes/?format=csv&as_of="+updated, "", admin)
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Contains(t, res.Body.String(), "second")
+
+	assert.Equal(t, http.StatusBadRequest, w.do(http.MethodGet, "/notes/?as_of=yesterday", "", admin).Code)
+	assert.Equal(t, http.StatusBadRequest, w.do(http.MethodGet, "/notes/"+note.ID+"?as_of=2024-01-01", "", admin).Code)
+}
+
+func TestCRUDHandler_AsOf_NoHistory(t *testing.T) {
+	w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/?as_of="+url.QueryEscape(time.Now().Format(time.RFC3339)), nil)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "123"}}
+
+	handler.GetByID(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "past versions are not kept")
+	mockTable.AssertExpectations(t)
+}
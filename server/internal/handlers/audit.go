@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kylep342/mendel/internal/components/app/audit"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/openapi"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+const (
+	queryComponent = "component"
+	queryRecordID  = "record_id"
+	queryActorID   = "actor_id"
+	queryRequestID = "request_id"
+	queryAction    = "action"
+	querySince     = "since"
+	queryUntil     = "until"
+	queryLimit     = "limit"
+)
+
+const (
+	// auditDefaultLimit is how many entries a query of the audit log returns without ?limit=
+	auditDefaultLimit = 100
+	// auditMaxLimit is the most entries a query of the audit log may ask for
+	auditMaxLimit = 1000
+)
+
+// audit records the request's action on the record id in the audit log, with the fields that
+// differ between before and after. It records nothing when Audit is nil.
+func (h *CRUDHandler[T, PT]) audit(ctx context.Context, c *gin.Context, action, id string, before, after PT) error {
+	if h.Audit == nil {
+		return nil
+	}
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		return fmt.Errorf("audit %s of %s: %w", action, id, err)
+	}
+	entry := &audit.Entry{
+		Component: h.Component,
+		RecordID:  id,
+		Action:    action,
+		RequestID: RequestIDFrom(c),
+		Changes:   changes,
+	}
+	if p, ok := PrincipalFrom(c); ok {
+		entry.ActorID = &p.User.ID
+	}
+	return h.Audit.Create(auditContext(ctx), entry)
+}
+
+// auditContext returns ctx in the default workspace if it is not scoped to one, as the writes to
+// global components such as users are audited there
+func auditContext(ctx context.Context) context.Context {
+	if _, ok := db.WorkspaceFrom(ctx); ok {
+		return ctx
+	}
+	return db.WithWorkspace(ctx, workspace.DefaultID)
+}
+
+// GetHistory responds to a request with the audit entries of the requested record, newest first.
+// It takes the query parameters of AuditHandler.GetAll but component and record_id.
+//
+//	400: an invalid query parameter
+//	403: Access does not allow reading the audit log
+func (h *CRUDHandler[T, PT]) GetHistory(c *gin.Context) {
+	if !authorized(c, h.Access, constants.TableAudit, workspace.ActionRead) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	id := c.Param("id")
+	if uuid.Validate(id) != nil {
+		responses.RespondError(c, "id must be a UUID", http.StatusBadRequest)
+		return
+	}
+	f, err := auditFilter(c)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.Component, f.RecordID = h.Component, id
+
+	entries, err := h.Audit.Find(auditContext(ctx), f)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	responses.RespondData(c, entries, http.StatusOK)
+}
+
+// AuditHandler exposes the audit log of the request's workspace over HTTP
+//
+//	Env: for config values
+//	Table: the audit log
+//	Access: decides who may read the audit log; anyone may when nil
+type AuditHandler struct {
+	Env    *constants.EnvConfig
+	Table  audit.Table
+	Access Authorizer
+}
+
+// NewAuditHandler is the constructor for AuditHandler
+func NewAuditHandler(env *constants.EnvConfig, table audit.Table) *AuditHandler {
+	return &AuditHandler{
+		Env:   env,
+		Table: table,
+	}
+}
+
+// RegisterRoutes connects the handlers to an HTTP server.
+// Its routes must be behind WorkspaceHandler.Scope.
+func (h *AuditHandler) RegisterRoutes(g gin.IRouter, basePath string) {
+	rg := g.Group(basePath)
+	rg.GET("/", h.GetAll)
+}
+
+// GetAll responds to a request with the audit entries of the request's workspace that match its
+// query parameters, newest first:
+//
+//	component, record_id, actor_id, request_id, action: entries with the value given
+//	since, until: entries written at or after, or at or before, an RFC 3339 time
+//	limit: at most this many entries, 100 by default and at most 1000
+//
+//	400: an invalid query parameter
+//	403: Access does not allow reading the audit log
+func (h *AuditHandler) GetAll(c *gin.Context) {
+	if !authorized(c, h.Access, constants.TableAudit, workspace.ActionRead) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	f, err := auditFilter(c)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := h.Table.Find(ctx, f)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	responses.RespondData(c, entries, http.StatusOK)
+}
+
+// auditFilter reads the query parameters of a request for audit entries
+func auditFilter(c *gin.Context) (audit.Filter, error) {
+	f := audit.Filter{
+		Component: c.Query(queryComponent),
+		RecordID:  c.Query(queryRecordID),
+		ActorID:   c.Query(queryActorID),
+		RequestID: c.Query(queryRequestID),
+		Action:    c.Query(queryAction),
+		Limit:     auditDefaultLimit,
+	}
+	for _, param := range []struct{ name, value string }{
+		{queryRecordID, f.RecordID},
+		{queryActorID, f.ActorID},
+	} {
+		if param.value != "" && uuid.Validate(param.value) != nil {
+			return f, fmt.Errorf("%s must be a UUID", param.name)
+		}
+	}
+	if f.Action != "" && !slices.Contains(audit.Actions, f.Action) {
+		return f, fmt.Errorf("%s must be one of %s", queryAction, strings.Join(audit.Actions, ", "))
+	}
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{querySince, &f.Since},
+		{queryUntil, &f.Until},
+	} {
+		v := c.Query(param.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return f, fmt.Errorf("%s must be an RFC 3339 time", param.name)
+		}
+		*param.value = t
+	}
+	if v := c.Query(queryLimit); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > auditMaxLimit {
+			return f, fmt.Errorf("%s must be a number from 1 to %d", queryLimit, auditMaxLimit)
+		}
+		f.Limit = limit
+	}
+	return f, nil
+}
+
+// auditParams documents the query parameters of auditFilter, but those named in skip
+func auditParams(skip ...string) []openapi.Parameter {
+	uuidSchema := &openapi.Schema{Type: "string", Format: "uuid"}
+	timeSchema := &openapi.Schema{Type: "string", Format: "date-time"}
+	params := []openapi.Parameter{
+		openapi.QueryParam(queryComponent, "entries of records of this component, named by its table", &openapi.Schema{Type: "string"}),
+		openapi.QueryParam(queryRecordID, "entries of this record", uuidSchema),
+		openapi.QueryParam(queryActorID, "entries of writes by this user", uuidSchema),
+		openapi.QueryParam(queryRequestID, "entries of writes made by this request", &openapi.Schema{Type: "string"}),
+		openapi.QueryParam(queryAction, "entries of this action", &openapi.Schema{Type: "string", Enum: audit.Actions}),
+		openapi.QueryParam(querySince, "entries written at or after this time", timeSchema),
+		openapi.QueryParam(queryUntil, "entries written at or before this time", timeSchema),
+		openapi.QueryParam(queryLimit, fmt.Sprintf("the most entries to return, from 1 to %d and %d by default", auditMaxLimit, auditDefaultLimit),
+			&openapi.Schema{Type: "integer"}),
+	}
+	return slices.DeleteFunc(params, func(p openapi.Parameter) bool { return slices.Contains(skip, p.Name) })
+}
+
+// Describe documents the routes RegisterRoutes serves at basePath
+func (h *AuditHandler) Describe(doc *openapi.Document, basePath string) {
+	tag := strings.TrimPrefix(basePath, "/")
+	getAll := openapi.Operation{
+		OperationID: tag + ".getAll",
+		Summary:     "Query the audit log of the workspace, newest first",
+		Tags:        []string{tag},
+		Parameters:  auditParams(),
+		Responses: map[string]openapi.Response{
+			"200": openapi.DataResponse("the matching entries", &openapi.Schema{Type: "array", Items: doc.SchemaFor(&audit.Entry{})}),
+			"400": openapi.ErrorResponse("an invalid query parameter"),
+			"403": openapi.ErrorResponse("your role does not allow reading the audit log"),
+			"500": openapi.ErrorResponse("unexpected error"),
+		},
+	}
+	doc.AddOperation(http.MethodGet, basePath+"/", getAll)
+}
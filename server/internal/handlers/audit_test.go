@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kylep342/mendel/internal/components/app/audit"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRUDHandler_Audit(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+	adminUser, err := w.users.GetByUsername(context.Background(), "admin")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/notes/", strings.NewReader(`{"name": "first"}`))
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: admin})
+	req.Header.Set(RequestIDHeader, "req-1")
+	w.router.ServeHTTP(rec, req)
+	assert.Equal(t, "req-1", rec.Header().Get(RequestIDHeader))
+	var note testNote
+	w.data(rec, &note)
+
+	w.data(w.do(http.MethodPut, "/notes/"+note.ID, `{"name": "second"}`, admin), &note)
+	w.data(w.do(http.MethodDelete, "/notes/"+note.ID, "", admin), new(string))
+	w.data(w.do(http.MethodPost, "/notes/"+note.ID+"/restore", "", admin), new(string))
+
+	var entries []audit.Entry
+	w.data(w.do(http.MethodGet, "/notes/"+note.ID+"/history", "", admin), &entries)
+	require.Len(t, entries, 4)
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+		assert.Equal(t, workspace.DefaultID, e.WorkspaceID)
+		assert.Equal(t, constants.TablePlant, e.Component)
+		assert.Equal(t, note.ID, e.RecordID)
+		require.NotNil(t, e.ActorID)
+		assert.Equal(t, adminUser.ID, *e.ActorID)
+		assert.NotEmpty(t, e.RequestID)
+	}
+	assert.Equal(t, []string{audit.ActionRestore, audit.ActionDelete, audit.ActionUpdate, audit.ActionCreate}, actions, "newest first")
+
+	create, update, del := entries[3], entries[2], entries[1]
+	assert.Equal(t, "req-1", create.RequestID)
+	assert.JSONEq(t, `"first"`, string(create.Changes["name"].After))
+	assert.Nil(t, create.Changes["name"].Before)
+	assert.JSONEq(t, `"first"`, string(update.Changes["name"].Before))
+	assert.JSONEq(t, `"second"`, string(update.Changes["name"].After))
+	assert.NotContains(t, update.Changes, "id", "unchanged fields are left out")
+	assert.JSONEq(t, `"second"`, string(del.Changes["name"].Before))
+	assert.Nil(t, del.Changes["name"].After)
+
+	w.data(w.do(http.MethodGet, "/notes/"+note.ID+"/history?action=update", "", admin), &entries)
+	require.Len(t, entries, 1)
+	assert.Equal(t, update.ID, entries[0].ID)
+
+	res := w.do(http.MethodPost, "/notes/batch", `{"operations": [{"op": "create", "data": {"name": "x"}}, {"op": "create", "data": {"name": "y"}}]}`, admin)
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	w.data(w.do(http.MethodGet, "/audit/?request_id="+res.Header().Get(RequestIDHeader), "", admin), &entries)
+	assert.Len(t, entries, 2, "each operation of a batch is audited")
+
+	res = w.do(http.MethodPost, "/notes/batch", `{"operations": [{"op": "create", "data": {"name": "z"}}, {"op": "delete", "id": "`+uuid.NewString()+`"}]}`, admin)
+	require.Equal(t, http.StatusNotFound, res.Code, res.Body.String())
+	w.data(w.do(http.MethodGet, "/audit/?request_id="+res.Header().Get(RequestIDHeader), "", admin), &entries)
+	assert.Empty(t, entries, "entries of writes that were rolled back are rolled back too")
+
+	w.data(w.do(http.MethodGet, "/audit/?component="+constants.TablePlant+"&actor_id="+adminUser.ID, "", admin), &entries)
+	assert.Len(t, entries, 6)
+	w.data(w.do(http.MethodGet, "/audit/?limit=1", "", admin), &entries)
+	assert.Len(t, entries, 1)
+	w.data(w.do(http.MethodGet, "/audit/?since="+create.CreatedAt.Format(time.RFC3339Nano)+"&until="+update.CreatedAt.Format(time.RFC3339Nano), "", admin), &entries)
+	assert.Len(t, entries, 2)
+
+	for _, query := range []string{"record_id=1", "actor_id=bob", "action=purge", "since=yesterday", "limit=0", "limit=1001"} {
+		res := w.do(http.MethodGet, "/audit/?"+query, "", admin)
+		assert.Equal(t, http.StatusBadRequest, res.Code, "%s: %s", query, res.Body.String())
+	}
+	assert.Equal(t, http.StatusBadRequest, w.do(http.MethodGet, "/notes/1/history", "", admin).Code)
+}
+
+func TestCRUDHandler_AuditAccess(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+	adminUser, err := w.users.GetByUsername(context.Background(), "admin")
+	require.NoError(t, err)
+	_, bob := w.newUser(admin, "bob")
+
+	var lab workspace.Workspace
+	w.data(w.do(http.MethodPost, "/workspaces/", `{"name": "lab"}`, bob), &lab)
+	var note testNote
+	w.data(w.in(lab.ID, http.MethodPost, "/notes/", `{"name": "bob's"}`, bob), &note)
+	members := "/workspaces/" + lab.ID + "/members/"
+	w.data(w.do(http.MethodPost, members, `{"user_id": "`+adminUser.ID+`", "role": "guest"}`, bob), &workspace.Member{})
+
+	var entries []audit.Entry
+	w.data(w.in(workspace.DefaultID, http.MethodGet, "/audit/", "", admin), &entries)
+	assert.Empty(t, entries, "entries of other workspaces are never seen")
+
+	assert.Equal(t, http.StatusOK, w.in(lab.ID, http.MethodGet, "/notes/"+note.ID, "", admin).Code)
+	assert.Equal(t, http.StatusForbidden, w.in(lab.ID, http.MethodGet, "/notes/"+note.ID+"/history", "", admin).Code, "guests cannot read the audit log")
+	assert.Equal(t, http.StatusForbidden, w.in(lab.ID, http.MethodGet, "/audit/", "", admin).Code)
+
+	w.data(w.do(http.MethodPut, members+adminUser.ID, `{"role": "observer"}`, bob), &workspace.Member{})
+	w.data(w.in(lab.ID, http.MethodGet, "/notes/"+note.ID+"/history", "", admin), &entries)
+	require.Len(t, entries, 1)
+	assert.Equal(t, lab.ID, entries[0].WorkspaceID)
+	w.data(w.in(lab.ID, http.MethodGet, "/audit/", "", admin), &entries)
+	assert.Len(t, entries, 1)
+}
+
+func TestRequestID(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+
+	for header, kept := range map[string]bool{
+		"abc-123:x.y_z":          true,
+		"":                       false,
+		"has spaces":             false,
+		strings.Repeat("a", 129): false,
+	} {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/notes/", nil)
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: admin})
+		req.Header.Set(RequestIDHeader, header)
+		w.router.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if kept {
+			assert.Equal(t, header, id)
+		} else {
+			assert.NoError(t, uuid.Validate(id), "%q is replaced by a new ID", header)
+		}
+	}
+}
@@ -0,0 +1,570 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/components"
+	"github.com/kylep342/mendel/internal/components/app/auth"
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/openapi"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+const (
+	// SessionCookie names the cookie holding the secret of a web app session
+	SessionCookie = "mendel_session"
+
+	// passwordMaxLength bounds the work a single login can ask of argon2id
+	passwordMaxLength = 1024
+)
+
+// errBadCredentials is the single answer to a failed login, whichever part of it was wrong
+var errBadCredentials = errors.New("invalid username or password")
+
+// AuthHandler logs users in and out of cookie sessions and manages their personal API tokens.
+// Its Authenticate middleware guards the routes of other handlers.
+//
+//	Env: for config values
+//	Users: the users who may log in
+//	Stores: passwords, sessions and API tokens
+//	Tx: runs each write request as a single unit of work; writes are not grouped when nil
+//	OIDC: signs users in through an identity provider; nil unless OIDC.Issuer is set
+//	Access: decides who may set the passwords of other users; anyone may when nil
+//	ReadOnly: true while the database schema is read-only; sessions still start and end, but
+//	logins are not recorded and single sign-on creates no users
+type AuthHandler struct {
+	Env      *constants.EnvConfig
+	Users    user.Table
+	Stores   auth.Stores
+	Tx       db.Transactor
+	OIDC     *auth.OIDC
+	Access   Authorizer
+	ReadOnly bool
+
+	// dummyHash is checked when a login names no user, so it takes as long as a wrong password
+	dummyHash string
+}
+
+// NewAuthHandler is the constructor for AuthHandler
+func NewAuthHandler(tx db.Transactor, env *constants.EnvConfig, users user.Table, stores auth.Stores) *AuthHandler {
+	dummyHash, err := auth.HashPassword("")
+	if err != nil {
+		panic("handlers: cannot read random bytes: " + err.Error())
+	}
+	h := &AuthHandler{
+		Env:       env,
+		Users:     users,
+		Stores:    stores,
+		Tx:        tx,
+		dummyHash: dummyHash,
+	}
+	if env.OIDC.Issuer != "" {
+		h.OIDC = auth.NewOIDC(env.OIDC.Issuer, env.OIDC.ClientID, env.OIDC.ClientSecret, env.OIDC.RedirectURL, env.OIDC.Scopes)
+	}
+	return h
+}
+
+// loginRequest is the body of a login
+type loginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// passwordRequest is the body of a password change.
+// CurrentPassword is required when users change their own password.
+type passwordRequest struct {
+	CurrentPassword string `json:"current_password,omitempty"`
+	Password        string `json:"password" validate:"required"`
+}
+
+// tokenRequest is the body of a request for a new API token
+type tokenRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=read write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// newToken is an API token as it is created: the only time its secret is shown
+type newToken struct {
+	auth.APIToken
+	Token string `json:"token"`
+}
+
+// RegisterRoutes connects the handlers to an HTTP server.
+// Only logging in is open; every other route needs a session or API token, and those that
+// manage credentials need a session. Single sign-on is served when OIDC is set.
+func (h *AuthHandler) RegisterRoutes(g gin.IRouter, basePath string) {
+	rg := g.Group(basePath)
+	rg.POST("/login", h.Login)
+	if h.OIDC != nil {
+		rg.GET("/oidc/login", h.OIDCLogin)
+		rg.GET("/oidc/callback", h.OIDCCallback)
+	}
+
+	authed := rg.Group("", h.Authenticate)
+	authed.GET("/me", h.Me)
+
+	session := authed.Group("", h.RequireSession)
+	session.POST("/logout", h.Logout)
+	session.PUT("/password", h.ChangePassword)
+	session.PUT("/users/:id/password", h.SetPassword)
+	session.GET("/tokens", h.GetTokens)
+	session.POST("/tokens", h.CreateToken)
+	session.DELETE("/tokens/:id", h.RevokeToken)
+}
+
+// Security schemes Describe registers, for Document.Secure
+const (
+	SecuritySession = "session"
+	SecurityToken   = "apiToken"
+)
+
+// Describe documents the routes RegisterRoutes serves at basePath, and the security schemes
+// Authenticate accepts
+func (h *AuthHandler) Describe(doc *openapi.Document, basePath string) {
+	doc.AddSecurityScheme(SecuritySession, &openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "cookie",
+		Name:        SessionCookie,
+		Description: "set by logging in",
+	})
+	doc.AddSecurityScheme(SecurityToken, &openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "a personal API token, limited to its scopes: read for GET requests, write for the rest",
+	})
+
+	tag := strings.TrimPrefix(basePath, "/")
+	sessionOnly := []openapi.SecurityRequirement{{SecuritySession: {}}}
+	op := func(name, summary string, security []openapi.SecurityRequirement) openapi.Operation {
+		o := openapi.Operation{
+			OperationID: tag + "." + name,
+			Summary:     summary,
+			Tags:        []string{tag},
+			Security:    security,
+			Responses:   map[string]openapi.Response{"500": openapi.ErrorResponse("unexpected error")},
+		}
+		if security != nil {
+			o.Responses["401"] = openapi.ErrorResponse("not logged in, or the API token is unknown, expired or revoked")
+			o.Responses["403"] = openapi.ErrorResponse("the request was made with an API token")
+		}
+		return o
+	}
+	invalid := func(o openapi.Operation) openapi.Operation {
+		o.Responses["400"] = openapi.ErrorResponse("malformed body or unknown fields")
+		o.Responses["422"] = openapi.ErrorResponse("one or more fields are invalid")
+		return o
+	}
+	principal := doc.SchemaFor(&Principal{})
+	id := openapi.DataResponse("the id of the changed record", &openapi.Schema{Type: "string"})
+	password := openapi.JSONBody(doc.NamedSchemaFor("PasswordChange", &passwordRequest{}))
+
+	login := invalid(op("login", "Log in, starting a session set as the "+SessionCookie+" cookie", nil))
+	login.RequestBody = openapi.JSONBody(doc.NamedSchemaFor("Login", &loginRequest{}))
+	login.Responses["200"] = openapi.DataResponse("who logged in, and their session", principal)
+	login.Responses["401"] = openapi.ErrorResponse("invalid username or password")
+	doc.AddOperation(http.MethodPost, basePath+"/login", login)
+
+	if h.OIDC != nil {
+		redirect := func(description string) openapi.Response { return openapi.Response{Description: description} }
+		str := &openapi.Schema{Type: "string"}
+
+		sso := op("oidcLogin", "Sign in through the identity provider, which returns to oidc/callback", nil)
+		sso.Parameters = []openapi.Parameter{
+			openapi.QueryParam("redirect", "path of the web app to return to once signed in", str),
+			openapi.QueryParam("login_hint", "who to sign in as, passed on to the identity provider", str),
+		}
+		sso.Responses["302"] = redirect("to the identity provider")
+		sso.Responses["502"] = openapi.ErrorResponse("the identity provider cannot be discovered")
+		doc.AddOperation(http.MethodGet, basePath+"/oidc/login", sso)
+
+		callback := op("oidcCallback", "Finish signing in through the identity provider, starting a session set as the "+SessionCookie+
+			" cookie; users are created on their first sign in", nil)
+		callback.Parameters = []openapi.Parameter{
+			openapi.QueryParam("code", "the authorization code", str),
+			openapi.QueryParam("state", "the state of the sign in", str),
+			openapi.QueryParam("error", "why the identity provider refused the sign in", str),
+		}
+		callback.Responses["302"] = redirect("to the web app, logged in")
+		callback.Responses["401"] = openapi.ErrorResponse("the sign in was refused or expired, or the user is disabled")
+		callback.Responses["502"] = openapi.ErrorResponse("the identity provider cannot be reached")
+		doc.AddOperation(http.MethodGet, basePath+"/oidc/callback", callback)
+	}
+
+	me := op("me", "Get who the request is authenticated as", []openapi.SecurityRequirement{{SecuritySession: {}}, {SecurityToken: {}}})
+	me.Responses["200"] = openapi.DataResponse("the user, and the session or API token they used", principal)
+	doc.AddOperation(http.MethodGet, basePath+"/me", me)
+
+	logout := op("logout", "End the session and clear its cookie", sessionOnly)
+	logout.Responses["200"] = openapi.DataResponse("logged out", &openapi.Schema{Type: "string"})
+	doc.AddOperation(http.MethodPost, basePath+"/logout", logout)
+
+	change := invalid(op("changePassword", "Change your password, ending your other sessions", sessionOnly))
+	change.RequestBody = password
+	change.Responses["200"] = id
+	doc.AddOperation(http.MethodPut, basePath+"/password", change)
+
+	set := invalid(op("setPassword", "Set a user's password, ending their sessions; current_password is not needed", sessionOnly))
+	set.Parameters = []openapi.Parameter{openapi.PathParam("id", "ID of the user")}
+	set.RequestBody = password
+	set.Responses["200"] = id
+	set.Responses["403"] = openapi.ErrorResponse("the request was made with an API token, or your role does not allow managing users")
+	set.Responses["404"] = openapi.ErrorResponse("not found")
+	doc.AddOperation(http.MethodPut, basePath+"/users/:id/password", set)
+
+	tokens := op("getTokens", "List your API tokens", sessionOnly)
+	tokens.Responses["200"] = openapi.DataResponse("your API tokens", &openapi.Schema{Type: "array", Items: doc.SchemaFor(&auth.APIToken{})})
+	doc.AddOperation(http.MethodGet, basePath+"/tokens", tokens)
+
+	create := invalid(op("createToken", "Create an API token; its secret is only ever shown in this response", sessionOnly))
+	create.RequestBody = openapi.JSONBody(doc.NamedSchemaFor("APITokenRequest", &tokenRequest{}))
+	create.Responses["200"] = openapi.DataResponse("the API token and its secret", doc.NamedSchemaFor("NewAPIToken", &newToken{}))
+	doc.AddOperation(http.MethodPost, basePath+"/tokens", create)
+
+	revoke := op("revokeToken", "Revoke one of your API tokens", sessionOnly)
+	revoke.Parameters = []openapi.Parameter{openapi.PathParam("id", "ID of the API token")}
+	revoke.Responses["200"] = id
+	revoke.Responses["404"] = openapi.ErrorResponse("not found")
+	doc.AddOperation(http.MethodDelete, basePath+"/tokens/:id", revoke)
+}
+
+// Login responds to a username and password by starting a session, set as a cookie
+func (h *AuthHandler) Login(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	var req loginRequest
+	if !bindRequest(c, h.Env, &req) {
+		return
+	}
+
+	u, err := h.checkPassword(ctx, req.Username, req.Password)
+	if errors.Is(err, errBadCredentials) {
+		responses.RespondError(c, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p, err := h.startSession(ctx, c, u.ID)
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, p, http.StatusOK)
+}
+
+// Logout responds by ending the session the request carries and clearing its cookie
+func (h *AuthHandler) Logout(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	p := MustPrincipal(c)
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		return h.Stores.Sessions.Delete(ctx, p.Session.ID)
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondTableError(c, err)
+		return
+	}
+	h.setSessionCookie(c, "", -1)
+	responses.RespondData(c, "logged out", http.StatusOK)
+}
+
+// Me responds with who the request is authenticated as
+func (h *AuthHandler) Me(c *gin.Context) {
+	responses.RespondData(c, MustPrincipal(c), http.StatusOK)
+}
+
+// ChangePassword responds to a request to change the password of the logged in user.
+// Their other sessions are ended.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	var req passwordRequest
+	if !bindRequest(c, h.Env, &req) {
+		return
+	}
+	p := MustPrincipal(c)
+	if _, err := h.checkPassword(ctx, p.User.Username, req.CurrentPassword); err != nil {
+		if errors.Is(err, errBadCredentials) {
+			responses.RespondError(c, &components.ValidationError{Fields: []components.FieldError{
+				{Field: "current_password", Message: "is incorrect"},
+			}}, http.StatusUnprocessableEntity)
+			return
+		}
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.setPassword(ctx, c, p.User.ID, req.Password, p.Session.ID)
+}
+
+// SetPassword responds to a request to set the password of the requested user, ending their sessions.
+// Setting another user's password needs Access to allow updating users.
+func (h *AuthHandler) SetPassword(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	var req passwordRequest
+	if !bindRequest(c, h.Env, &req) {
+		return
+	}
+	keep := ""
+	if p := MustPrincipal(c); p.User.ID == c.Param("id") {
+		keep = p.Session.ID
+	} else if !authorized(c, h.Access, constants.TableUser, workspace.ActionUpdate) {
+		return
+	}
+	h.setPassword(ctx, c, c.Param("id"), req.Password, keep)
+}
+
+// GetTokens responds with the API tokens of the logged in user
+func (h *AuthHandler) GetTokens(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	tokens, err := h.Stores.Tokens.GetByUser(ctx, MustPrincipal(c).User.ID)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	responses.RespondData(c, tokens, http.StatusOK)
+}
+
+// CreateToken responds to a request for a new API token for the logged in user.
+// The response holds the token's secret, which cannot be retrieved again.
+func (h *AuthHandler) CreateToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	var req tokenRequest
+	if !bindRequest(c, h.Env, &req) {
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		responses.RespondError(c, &components.ValidationError{Fields: []components.FieldError{
+			{Field: "expires_at", Message: "must be in the future"},
+		}}, http.StatusUnprocessableEntity)
+		return
+	}
+
+	secret, hash, err := auth.NewToken(auth.TokenPrefix)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := auth.APIToken{
+		UserID:    MustPrincipal(c).User.ID,
+		Name:      req.Name,
+		TokenHash: hash,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	err = db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		return h.Stores.Tokens.Create(ctx, &token)
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, newToken{APIToken: token, Token: secret}, http.StatusOK)
+}
+
+// RevokeToken responds to a request to revoke one of the logged in user's API tokens
+func (h *AuthHandler) RevokeToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	id := c.Param("id")
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		token, err := h.Stores.Tokens.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if token.UserID != MustPrincipal(c).User.ID {
+			return sql.ErrNoRows
+		}
+		return h.Stores.Tokens.Delete(ctx, id)
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, id, http.StatusOK)
+}
+
+// Bootstrap creates the user Auth.BootstrapUsername names with Auth.BootstrapPassword, so a new
+// install has someone to log in as. It does nothing if no name is set or the user exists.
+// It reports whether it created the user.
+func (h *AuthHandler) Bootstrap(ctx context.Context) (bool, error) {
+	name, password := h.Env.Auth.BootstrapUsername, h.Env.Auth.BootstrapPassword
+	if name == "" {
+		return false, nil
+	}
+	if _, err := h.Users.GetByUsername(ctx, name); !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if len(password) < h.Env.Auth.PasswordMinLength {
+		return false, fmt.Errorf("AUTH_BOOTSTRAP_PASSWORD must be at least %d characters", h.Env.Auth.PasswordMinLength)
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return false, err
+	}
+
+	u := user.User{Username: name, Enabled: true}
+	if err := components.Validate(&u); err != nil {
+		return false, fmt.Errorf("AUTH_BOOTSTRAP_USERNAME: %w", err)
+	}
+	err = db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		if err := h.Users.Create(ctx, &u); err != nil {
+			return err
+		}
+		return h.Stores.Credentials.Create(ctx, &auth.Credential{ID: u.ID, PasswordHash: hash})
+	})
+	return err == nil, err
+}
+
+// checkPassword returns the enabled user named username if password is theirs, or errBadCredentials
+func (h *AuthHandler) checkPassword(ctx context.Context, username, password string) (user.User, error) {
+	hash := h.dummyHash
+	u, err := h.Users.GetByUsername(ctx, username)
+	switch {
+	case err == nil:
+		cred, err := h.Stores.Credentials.GetByID(ctx, u.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, err
+		}
+		if err == nil {
+			hash = cred.PasswordHash
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return user.User{}, err
+	}
+	if len(password) > passwordMaxLength {
+		return user.User{}, errBadCredentials
+	}
+
+	ok, err := auth.VerifyPassword(hash, password)
+	if err != nil {
+		return user.User{}, err
+	}
+	if !ok || hash == h.dummyHash || !u.Enabled {
+		return user.User{}, errBadCredentials
+	}
+	return u, nil
+}
+
+// startSession starts a session for the user identified by userID, set as the session cookie,
+// and records their login unless ReadOnly
+func (h *AuthHandler) startSession(ctx context.Context, c *gin.Context, userID string) (*Principal, error) {
+	secret, hash, err := auth.NewToken("")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := auth.Session{UserID: userID, TokenHash: hash, ExpiresAt: now.Add(h.Env.Auth.SessionTTL)}
+	err = db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		if h.ReadOnly {
+			return h.Stores.Sessions.Create(ctx, &session)
+		}
+		if _, err := h.Stores.Sessions.DeleteExpired(ctx, now); err != nil {
+			return err
+		}
+		if err := h.Stores.Sessions.Create(ctx, &session); err != nil {
+			return err
+		}
+		return h.Users.RecordLogin(ctx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.setSessionCookie(c, secret, int(h.Env.Auth.SessionTTL.Seconds()))
+	u, err := h.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{User: u, Session: &session}, nil
+}
+
+// setPassword sets the password of the user identified by id and ends their sessions but keep,
+// responding with the outcome
+func (h *AuthHandler) setPassword(ctx context.Context, c *gin.Context, id, password, keep string) {
+	if n := len(password); n < h.Env.Auth.PasswordMinLength || n > passwordMaxLength {
+		responses.RespondError(c, &components.ValidationError{Fields: []components.FieldError{
+			{Field: "password", Message: fmt.Sprintf("must be %d to %d characters", h.Env.Auth.PasswordMinLength, passwordMaxLength)},
+		}}, http.StatusUnprocessableEntity)
+		return
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		if _, err := h.Users.GetByID(ctx, id); err != nil {
+			return err
+		}
+		cred, err := h.Stores.Credentials.GetByID(ctx, id)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			err = h.Stores.Credentials.Create(ctx, &auth.Credential{ID: id, PasswordHash: hash})
+		case err == nil:
+			cred.PasswordHash = hash
+			err = h.Stores.Credentials.Update(ctx, &cred)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = h.Stores.Sessions.DeleteByUser(ctx, id, keep)
+		return err
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, id, http.StatusOK)
+}
+
+// setSessionCookie sets the session cookie to secret for maxAge seconds, or clears it when maxAge is negative
+func (h *AuthHandler) setSessionCookie(c *gin.Context, secret string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookie, secret, maxAge, "/", "", h.Env.Auth.CookieSecure, true)
+}
+
+// bindRequest decodes the request body, capped at Server.MaxBodyBytes, into req and validates it,
+// responding on failure as CRUDHandler.bind does
+func bindRequest(c *gin.Context, env *constants.EnvConfig, req any) bool {
+	if c.Request.Body == nil {
+		responses.RespondError(c, "request body is required", http.StatusBadRequest)
+		return false
+	}
+	body := c.Request.Body
+	if env.Server.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(c.Writer, body, env.Server.MaxBodyBytes)
+	}
+	if err := decodeJSON(body, req); err != nil {
+		respondBindError(c, err)
+		return false
+	}
+	if err := components.Validate(req); err != nil {
+		respondBindError(c, err)
+		return false
+	}
+	return true
+}
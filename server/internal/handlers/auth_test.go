@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/components/app/auth"
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassword = "correct horse battery"
+
+// authTest serves an AuthHandler at /auth and a CRUDHandler behind its middleware at /items
+type authTest struct {
+	t       *testing.T
+	handler *AuthHandler
+	users   *user.MemoryStore
+	router  *gin.Engine
+}
+
+func newAuthTest(t *testing.T, configure ...func(env *constants.EnvConfig)) *authTest {
+	gin.SetMode(gin.TestMode)
+	env := &constants.EnvConfig{}
+	env.Server.ReadTimeout = 5 * time.Second
+	env.Server.WriteTimeout = 5 * time.Second
+	env.Auth.SessionTTL = time.Hour
+	env.Auth.PasswordMinLength = 12
+	env.Auth.BootstrapUsername = "admin"
+	env.Auth.BootstrapPassword = testPassword
+	for _, f := range configure {
+		f(env)
+	}
+
+	mem := db.NewMemory()
+	users := user.NewMemoryStore(mem)
+	handler := NewAuthHandler(mem, env, users, auth.NewMemoryStores(mem))
+	created, err := handler.Bootstrap(context.Background())
+	require.NoError(t, err)
+	require.True(t, created)
+
+	router := gin.New()
+	handler.RegisterRoutes(router, "/auth")
+	items := NewCRUDHandler(mem, env, func() *testTraitModel { return &testTraitModel{} },
+		db.CRUDTable[testTraitModel](db.NewMemoryStore[testTraitModel](mem, "test")))
+	items.RegisterRoutes(router.Group("", handler.Authenticate), "/items")
+
+	return &authTest{t: t, handler: handler, users: users, router: router}
+}
+
+// do sends a request with body, authenticated by the session cookie or API token in credential
+func (a *authTest) do(method, path, body, credential string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	switch {
+	case strings.HasPrefix(credential, auth.TokenPrefix):
+		req.Header.Set("Authorization", "Bearer "+credential)
+	case credential != "":
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: credential})
+	}
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// login logs in as username and returns the session cookie's secret
+func (a *authTest) login(username, password string) string {
+	w := a.do(http.MethodPost, "/auth/login", `{"username": "`+username+`", "password": "`+password+`"}`, "")
+	require.Equal(a.t, http.StatusOK, w.Code, w.Body.String())
+	return a.cookie(w, SessionCookie).Value
+}
+
+// readOnly serves the auth routes as a server whose database schema is read-only does
+func (a *authTest) readOnly() {
+	internal := NewInternalHandler(nil, a.handler.Env)
+	internal.Schema = &Schema{Version: 9, Expected: 10, ReadOnly: true}
+	a.handler.ReadOnly = true
+	a.router = gin.New()
+	a.router.Use(internal.ReadOnly)
+	a.handler.RegisterRoutes(a.router, constants.RouteAuth)
+}
+
+// cookie returns the cookie named name the response sets
+func (a *authTest) cookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			assert.True(a.t, cookie.HttpOnly)
+			return cookie
+		}
+	}
+	a.t.Fatalf("response set no %s cookie", name)
+	return nil
+}
+
+// data decodes the data of a successful response into v
+func (a *authTest) data(w *httptest.ResponseRecorder, v any) {
+	require.Equal(a.t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(a.t, json.Unmarshal(w.Body.Bytes(), &struct {
+		Data any `json:"data"`
+	}{v}))
+}
+
+func TestAuthHandler_Bootstrap(t *testing.T) {
+	a := newAuthTest(t)
+
+	created, err := a.handler.Bootstrap(context.Background())
+	require.NoError(t, err)
+	assert.False(t, created, "an existing user is left alone")
+
+	a.handler.Env.Auth.BootstrapUsername = "other"
+	a.handler.Env.Auth.BootstrapPassword = "short"
+	_, err = a.handler.Bootstrap(context.Background())
+	assert.Error(t, err)
+}
+
+func TestAuthHandler_Login(t *testing.T) {
+	a := newAuthTest(t)
+
+	w := a.do(http.MethodGet, "/items/", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+	for _, body := range []string{
+		`{"username": "admin", "password": "wrong password"}`,
+		`{"username": "nobody", "password": "` + testPassword + `"}`,
+	} {
+		assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/auth/login", body, "").Code, body)
+	}
+	assert.Equal(t, http.StatusUnprocessableEntity, a.do(http.MethodPost, "/auth/login", `{"username": "admin"}`, "").Code)
+
+	session := a.login("ADMIN", testPassword)
+	var me Principal
+	a.data(a.do(http.MethodGet, "/auth/me", "", session), &me)
+	assert.Equal(t, "admin", me.User.Username)
+	assert.NotNil(t, me.User.LastLogin)
+	require.NotNil(t, me.Session)
+	assert.Nil(t, me.Token)
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/items/", `{"name": "a"}`, session).Code)
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/items/", "", session).Code)
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/items/", "", "not a session").Code)
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/auth/logout", "", session).Code)
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/items/", "", session).Code)
+}
+
+func TestAuthHandler_CrossOrigin(t *testing.T) {
+	a := newAuthTest(t, func(env *constants.EnvConfig) { env.App.WebHost = "https://mendel.example" })
+	session := a.login("admin", testPassword)
+	var writer newToken
+	a.data(a.do(http.MethodPost, "/auth/tokens", `{"name": "writer", "scopes": ["read", "write"]}`, session), &writer)
+	token := writer.Token
+
+	for _, tc := range []struct {
+		method, credential string
+		headers            map[string]string
+		want               int
+	}{
+		{http.MethodPost, session, map[string]string{"Origin": "https://mendel.example"}, http.StatusOK},
+		{http.MethodPost, session, map[string]string{"Origin": "http://example.com"}, http.StatusOK}, // the API's own host
+		{http.MethodPost, session, nil, http.StatusOK},
+		{http.MethodPost, session, map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{http.MethodPost, session, map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{http.MethodPost, session, map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{http.MethodGet, session, map[string]string{"Origin": "https://evil.example"}, http.StatusOK},
+		{http.MethodPost, token, map[string]string{"Origin": "https://evil.example"}, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, "/items/", strings.NewReader(`{"name": "a"}`))
+		if strings.HasPrefix(tc.credential, auth.TokenPrefix) {
+			req.Header.Set("Authorization", "Bearer "+tc.credential)
+		} else {
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: tc.credential})
+		}
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		a.router.ServeHTTP(w, req)
+		assert.Equal(t, tc.want, w.Code, "%s %v", tc.method, tc.headers)
+	}
+}
+
+func TestAuthHandler_ReadOnly(t *testing.T) {
+	a := newAuthTest(t)
+	a.readOnly()
+
+	session := a.login("admin", testPassword)
+	admin, err := a.users.GetByUsername(context.Background(), "admin")
+	require.NoError(t, err)
+	assert.Nil(t, admin.LastLogin, "logins are not recorded")
+
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodPost, "/auth/tokens", `{"name": "writer", "scopes": ["read"]}`},
+		{http.MethodPut, "/auth/password", `{"current_password": "` + testPassword + `", "password": "another long password"}`},
+		{http.MethodPut, "/auth/users/" + admin.ID + "/password", `{"password": "another long password"}`},
+	} {
+		w := a.do(tc.method, tc.path, tc.body, session)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, "%s %s", tc.method, tc.path)
+	}
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/auth/logout", "", session).Code)
+}
+
+func TestAuthHandler_DisabledUser(t *testing.T) {
+	a := newAuthTest(t)
+	session := a.login("admin", testPassword)
+
+	admin, err := a.users.GetByUsername(context.Background(), "admin")
+	require.NoError(t, err)
+	admin.Enabled = false
+	require.NoError(t, a.users.Update(context.Background(), &admin))
+
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/items/", "", session).Code)
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/auth/login",
+		`{"username": "admin", "password": "`+testPassword+`"}`, "").Code)
+}
+
+func TestAuthHandler_Tokens(t *testing.T) {
+	a := newAuthTest(t)
+	session := a.login("admin", testPassword)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, a.do(http.MethodPost, "/auth/tokens", `{"name": "script", "scopes": ["admin"]}`, session).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, a.do(http.MethodPost, "/auth/tokens", `{"name": "script", "scopes": []}`, session).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, a.do(http.MethodPost, "/auth/tokens",
+		`{"name": "script", "scopes": ["read"], "expires_at": "2000-01-01T00:00:00Z"}`, session).Code)
+
+	var read, write newToken
+	a.data(a.do(http.MethodPost, "/auth/tokens", `{"name": "reader", "scopes": ["read"]}`, session), &read)
+	a.data(a.do(http.MethodPost, "/auth/tokens", `{"name": "writer", "scopes": ["read", "write"]}`, session), &write)
+	assert.True(t, strings.HasPrefix(read.Token, auth.TokenPrefix))
+
+	var listed []map[string]any
+	a.data(a.do(http.MethodGet, "/auth/tokens", "", session), &listed)
+	require.Len(t, listed, 2)
+	assert.NotContains(t, listed[0], "token", "secrets are only shown once")
+	assert.NotContains(t, listed[0], "token_hash")
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/items/", "", read.Token).Code)
+	assert.Equal(t, http.StatusForbidden, a.do(http.MethodPost, "/items/", `{"name": "a"}`, read.Token).Code)
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/items/", `{"name": "a"}`, write.Token).Code)
+	assert.Equal(t, http.StatusForbidden, a.do(http.MethodGet, "/auth/tokens", "", write.Token).Code, "tokens cannot manage credentials")
+
+	var me Principal
+	a.data(a.do(http.MethodGet, "/auth/me", "", read.Token), &me)
+	require.NotNil(t, me.Token)
+	assert.Equal(t, read.ID, me.Token.ID)
+	assert.NotNil(t, me.Token.LastUsed)
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodDelete, "/auth/tokens/"+read.ID, "", session).Code)
+	assert.Equal(t, http.StatusNotFound, a.do(http.MethodDelete, "/auth/tokens/"+read.ID, "", session).Code)
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/items/", "", read.Token).Code)
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/items/", "", auth.TokenPrefix+"unknown").Code)
+}
+
+func TestAuthHandler_ChangePassword(t *testing.T) {
+	a := newAuthTest(t)
+	session := a.login("admin", testPassword)
+	other := a.login("admin", testPassword)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, a.do(http.MethodPut, "/auth/password",
+		`{"current_password": "wrong password", "password": "a new password"}`, session).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, a.do(http.MethodPut, "/auth/password",
+		`{"current_password": "`+testPassword+`", "password": "short"}`, session).Code)
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPut, "/auth/password",
+		`{"current_password": "`+testPassword+`", "password": "a new password"}`, session).Code)
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/items/", "", session).Code, "the changing session is kept")
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/items/", "", other).Code, "other sessions end")
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/auth/login",
+		`{"username": "admin", "password": "`+testPassword+`"}`, "").Code)
+	a.login("admin", "a new password")
+}
+
+func TestAuthHandler_SetPassword(t *testing.T) {
+	a := newAuthTest(t)
+	session := a.login("admin", testPassword)
+
+	bob := &user.User{Username: "bob", Enabled: true}
+	require.NoError(t, a.users.Create(context.Background(), bob))
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/auth/login", `{"username": "bob", "password": "`+testPassword+`"}`, "").Code,
+		"users without a password cannot log in")
+
+	assert.Equal(t, http.StatusNotFound, a.do(http.MethodPut, "/auth/users/missing/password", `{"password": "bob's password"}`, session).Code)
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPut, "/auth/users/"+bob.ID+"/password", `{"password": "bob's password"}`, session).Code)
+	bobSession := a.login("bob", "bob's password")
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPut, "/auth/users/"+bob.ID+"/password", `{"password": "bob's new password"}`, session).Code)
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/items/", "", bobSession).Code)
+	a.login("bob", "bob's new password")
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/components/app/auth"
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+// principalKey is the gin context key Authenticate stores the request's Principal under
+const principalKey = "principal"
+
+// tokenUseEvery is how often the last use of an API token is recorded, at most
+const tokenUseEvery = time.Minute
+
+// errUnauthenticated wraps every reason a request could not be authenticated
+var errUnauthenticated = errors.New("unauthenticated")
+
+// Principal is who a request is authenticated as
+//
+//	User: the user the request acts as
+//	Session: the web app session the request carries, or nil
+//	Token: the API token the request carries, or nil; the request is limited to its scopes
+type Principal struct {
+	User    user.User      `json:"user"`
+	Session *auth.Session  `json:"session,omitempty"`
+	Token   *auth.APIToken `json:"token,omitempty"`
+}
+
+// HasScope reports whether the principal may act within scope. Sessions may do anything.
+func (p *Principal) HasScope(scope string) bool {
+	return p.Token == nil || p.Token.HasScope(scope)
+}
+
+// PrincipalFrom returns who Authenticate found the request was made by
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	p, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := p.(*Principal)
+	return principal, ok
+}
+
+// MustPrincipal returns the Principal of a request on a route behind Authenticate
+func MustPrincipal(c *gin.Context) *Principal {
+	p, ok := PrincipalFrom(c)
+	if !ok {
+		panic("handlers: route is not behind Authenticate")
+	}
+	return p
+}
+
+// Authenticate is middleware admitting requests that carry a live session cookie, or an API token
+// as `Authorization: Bearer <token>`, from an enabled user. API tokens need the write scope for
+// anything but reads. CORS does not stop a page of another origin from sending a form, such as a
+// multipart import, along with the session cookie, so requests authenticated by the cookie may
+// only change anything when they come from the web app or the API itself, see sameOrigin.
+//
+//	401: no credentials, or they are unknown, expired or revoked
+//	403: the API token lacks the scope, or a session changes something from another origin
+func (h *AuthHandler) Authenticate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	p, err := h.authenticate(ctx, c)
+	cancel()
+	if errors.Is(err, errUnauthenticated) {
+		c.Header("WWW-Authenticate", `Bearer realm="mendel"`)
+		responses.RespondError(c, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	scope := auth.ScopeWrite
+	if isReadOnlyMethod(c.Request.Method) {
+		scope = auth.ScopeRead
+	}
+	if p.Session != nil && scope == auth.ScopeWrite && !h.sameOrigin(c) {
+		responses.RespondError(c, "cross-origin requests cannot use the session cookie to make changes", http.StatusForbidden)
+		return
+	}
+	if !p.HasScope(scope) {
+		responses.RespondError(c, "API token lacks the "+scope+" scope", http.StatusForbidden)
+		return
+	}
+
+	c.Set(principalKey, p)
+	c.Next()
+}
+
+// sameOrigin reports whether a request comes from the web app or from a page of the API itself.
+// Browsers send Origin with every request that can change anything; without it, the request is
+// trusted unless Sec-Fetch-Site says a browser sent it from another site, since clients other
+// than browsers are not open to cross-site request forgery.
+func (h *AuthHandler) sameOrigin(c *gin.Context) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		site := c.GetHeader("Sec-Fetch-Site")
+		return site == "" || site == "same-origin" || site == "none"
+	}
+	if h.Env.App.WebHost != "" && origin == strings.TrimSuffix(h.Env.App.WebHost, "/") {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && u.Host == c.Request.Host
+}
+
+// RequireSession is middleware, behind Authenticate, refusing requests made with an API token
+func (h *AuthHandler) RequireSession(c *gin.Context) {
+	if MustPrincipal(c).Session == nil {
+		responses.RespondError(c, "API tokens cannot manage credentials; log in instead", http.StatusForbidden)
+		return
+	}
+	c.Next()
+}
+
+// authenticate finds who the request was made by from its API token or, without one, its session cookie
+func (h *AuthHandler) authenticate(ctx context.Context, c *gin.Context) (*Principal, error) {
+	now := time.Now()
+	if header := c.GetHeader("Authorization"); header != "" {
+		secret, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || !strings.HasPrefix(secret, auth.TokenPrefix) {
+			return nil, fmt.Errorf("%w: Authorization must be Bearer <API token>", errUnauthenticated)
+		}
+		token, err := h.Stores.Tokens.GetByTokenHash(ctx, auth.HashToken(secret))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: unknown or revoked API token", errUnauthenticated)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if token.Expired(now) {
+			return nil, fmt.Errorf("%w: API token has expired", errUnauthenticated)
+		}
+		u, err := h.enabledUser(ctx, token.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if token.LastUsed == nil || now.Sub(*token.LastUsed) > tokenUseEvery {
+			if err := h.Stores.Tokens.RecordUse(ctx, token.ID); err != nil {
+				return nil, err
+			}
+		}
+		return &Principal{User: u, Token: &token}, nil
+	}
+
+	secret, err := c.Cookie(SessionCookie)
+	if err != nil || secret == "" {
+		return nil, fmt.Errorf("%w: log in or send an API token", errUnauthenticated)
+	}
+	session, err := h.Stores.Sessions.GetByTokenHash(ctx, auth.HashToken(secret))
+	if errors.Is(err, sql.ErrNoRows) || err == nil && !now.Before(session.ExpiresAt) {
+		return nil, fmt.Errorf("%w: session has ended; log in again", errUnauthenticated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	u, err := h.enabledUser(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{User: u, Session: &session}, nil
+}
+
+// enabledUser returns the user identified by id, failing authentication if they are gone or disabled
+func (h *AuthHandler) enabledUser(ctx context.Context, id string) (user.User, error) {
+	u, err := h.Users.GetByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && !u.Enabled {
+		return user.User{}, fmt.Errorf("%w: user is disabled", errUnauthenticated)
+	}
+	return u, err
+}
+
+// isReadOnlyMethod reports whether requests with method only read
+func isReadOnlyMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
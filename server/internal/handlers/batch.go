@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kylep342/mendel/internal/components"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+const (
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+
+	queryIDs = "ids"
+)
+
+// batchRequest is a list of writes applied all together or not at all
+type batchRequest struct {
+	Operations []batchOperation `json:"operations" validate:"required"`
+}
+
+// batchOperation is a single write of a batchRequest
+//
+//	Op: create, update or delete
+//	ID: the record to update or delete
+//	Data: the record to create, or the new values of the record to update
+//	Confirm: the confirmation token of a delete that requires one
+type batchOperation struct {
+	Op      string          `json:"op" validate:"required,oneof=create update delete"`
+	ID      string          `json:"id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Confirm string          `json:"confirm,omitempty"`
+}
+
+// batchResult is the outcome of an applied batchOperation
+type batchResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	ID    string `json:"id"`
+	Data  any    `json:"data,omitempty"`
+}
+
+// batchItemError explains why a batchOperation could not be applied
+type batchItemError struct {
+	Index  int                     `json:"index"`
+	Op     string                  `json:"op"`
+	Error  string                  `json:"error"`
+	Fields []components.FieldError `json:"fields,omitempty"`
+}
+
+// batchError is the response to a batch that was rolled back
+type batchError struct {
+	Message string           `json:"message"`
+	Items   []batchItemError `json:"items"`
+}
+
+// bulkRead is the response to a request for many records by ID
+type bulkRead[T any] struct {
+	Items   []T      `json:"items"`
+	Missing []string `json:"missing"`
+}
+
+// Batch responds to a request to apply many creates, updates and deletes in a single unit of work.
+// Every operation is decoded and validated before any is applied; if any fails, nothing is written
+// and the response lists the failed operations by index.
+func (h *CRUDHandler[T, PT]) Batch(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	if c.Request.Body == nil {
+		responses.RespondError(c, "request body is required", http.StatusBadRequest)
+		return
+	}
+	var req batchRequest
+	if err := decodeJSON(h.limitBody(c), &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if len(req.Operations) == 0 {
+		responses.RespondError(c, "operations must not be empty", http.StatusBadRequest)
+		return
+	}
+	if max := h.Env.App.BatchMaxItems; max > 0 && len(req.Operations) > max {
+		responses.RespondError(c, fmt.Sprintf("a batch may hold at most %d operations", max), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	items, invalid := h.decodeBatch(req.Operations)
+	if len(invalid) > 0 {
+		responses.RespondError(c, batchError{Message: "batch is invalid; nothing was applied", Items: invalid}, http.StatusUnprocessableEntity)
+		return
+	}
+	checked := map[string]bool{}
+	for _, op := range req.Operations {
+		if checked[op.Op] {
+			continue
+		}
+		if !authorized(c, h.Access, h.Component, workspace.Action(op.Op)) {
+			return
+		}
+		checked[op.Op] = true
+	}
+
+	results := make([]batchResult, len(req.Operations))
+	var failed *batchItemError
+	var failedCode int
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		for i, op := range req.Operations {
+			result, err := h.applyBatchOperation(ctx, c, i, op, items[i])
+			if err != nil {
+				failedCode, failed = batchFailure(i, op, err)
+				return err
+			}
+			results[i] = result
+		}
+		return nil
+	})
+	if err != nil {
+		if failed == nil {
+			responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		responses.RespondError(c, batchError{Message: "batch was rolled back; nothing was applied", Items: []batchItemError{*failed}}, failedCode)
+		return
+	}
+	responses.RespondData(c, results, http.StatusOK)
+}
+
+// decodeBatch decodes and validates the data of every operation, returning the decoded
+// records by index and an error for each invalid operation
+func (h *CRUDHandler[T, PT]) decodeBatch(ops []batchOperation) ([]PT, []batchItemError) {
+	items := make([]PT, len(ops))
+	var invalid []batchItemError
+	for i, op := range ops {
+		fail := func(err error) {
+			itemErr := batchItemError{Index: i, Op: op.Op, Error: err.Error()}
+			var fields *components.ValidationError
+			if errors.As(err, &fields) {
+				itemErr.Fields = fields.Fields
+			}
+			invalid = append(invalid, itemErr)
+		}
+
+		if err := components.Validate(&op); err != nil {
+			fail(err)
+			continue
+		}
+		if op.Op != batchCreate && op.ID == "" {
+			fail(errors.New("id is required"))
+			continue
+		}
+		if op.Op == batchDelete {
+			continue
+		}
+		if len(op.Data) == 0 {
+			fail(errors.New("data is required"))
+			continue
+		}
+
+		item := h.New()
+		if err := decodeJSON(bytes.NewReader(op.Data), item); err != nil {
+			fail(err)
+			continue
+		}
+		if op.Op == batchUpdate {
+			item.SetID(op.ID)
+		}
+		if err := components.Validate(item); err != nil {
+			fail(err)
+			continue
+		}
+		items[i] = item
+	}
+	return items, invalid
+}
+
+func (h *CRUDHandler[T, PT]) applyBatchOperation(ctx context.Context, c *gin.Context, i int, op batchOperation, item PT) (batchResult, error) {
+	result := batchResult{Index: i, Op: op.Op, ID: op.ID}
+	switch op.Op {
+	case batchCreate:
+		if err := h.create(ctx, c, item); err != nil {
+			return result, err
+		}
+		result.ID, result.Data = item.GetID(), item
+	case batchUpdate:
+		if err := h.update(ctx, c, item); err != nil {
+			return result, err
+		}
+		result.Data = item
+	case batchDelete:
+		if err := h.confirmDelete(ctx, op.ID, op.Confirm); err != nil {
+			return result, err
+		}
+		if err := h.delete(ctx, c, op.ID); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// batchFailure describes why operation i failed and the status to respond with
+func batchFailure(i int, op batchOperation, err error) (int, *batchItemError) {
+	itemErr := &batchItemError{Index: i, Op: op.Op}
+	var required *confirmationRequiredError
+	if errors.As(err, &required) {
+		itemErr.Error = err.Error()
+		return http.StatusPreconditionRequired, itemErr
+	}
+	code, msg := tableErrorStatus(err)
+	itemErr.Error = msg
+	return code, itemErr
+}
+
+// GetBatch responds to a request for the records whose IDs are listed in ?ids=, comma separated.
+// Records are returned in the order requested, with unknown IDs listed as missing.
+func (h *CRUDHandler[T, PT]) GetBatch(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	var ids []string
+	for _, param := range c.QueryArray(queryIDs) {
+		for _, id := range strings.Split(param, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		responses.RespondError(c, "ids is required", http.StatusBadRequest)
+		return
+	}
+	if max := h.Env.App.BatchMaxItems; max > 0 && len(ids) > max {
+		responses.RespondError(c, fmt.Sprintf("at most %d ids may be requested at once", max), http.StatusBadRequest)
+		return
+	}
+	for _, id := range ids {
+		if uuid.Validate(id) != nil {
+			responses.RespondError(c, fmt.Sprintf("%q is not a valid UUID", id), http.StatusBadRequest)
+			return
+		}
+	}
+
+	found, err := h.getByIDs(ctx, ids)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	byID := make(map[string]T, len(found))
+	for i := range found {
+		byID[PT(&found[i]).GetID()] = found[i]
+	}
+	resp := bulkRead[T]{Items: []T{}, Missing: []string{}}
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			resp.Items = append(resp.Items, item)
+		} else {
+			resp.Missing = append(resp.Missing, id)
+		}
+	}
+	responses.RespondData(c, resp, http.StatusOK)
+}
+
+// getByIDs fetches ids in one call when the table supports it, or one at a time otherwise
+func (h *CRUDHandler[T, PT]) getByIDs(ctx context.Context, ids []string) ([]T, error) {
+	if bulk, ok := h.Table.(db.BulkReader[T]); ok {
+		return bulk.GetByIDs(ctx, ids)
+	}
+
+	var items []T
+	for _, id := range ids {
+		item, err := h.Table.GetByID(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	batchID1 = "0b4f2a4e-8f5c-4d51-9d0e-4a0f1c2b3d4e"
+	batchID2 = "5c1d6e7f-0a1b-4c2d-8e3f-4a5b6c7d8e9f"
+)
+
+// MockBulkTable extends MockCRUDTable with the db.BulkReader method.
+type MockBulkTable[T any] struct {
+	MockCRUDTable[T]
+}
+
+func (m *MockBulkTable[T]) GetByIDs(ctx context.Context, ids []string) ([]T, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]T), args.Error(1)
+}
+
+type batchErrorResponse struct {
+	Error batchError `json:"error"`
+}
+
+func TestCRUDHandler_Batch(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+		tx := &recordingTransactor{}
+		handler.Tx = tx
+		c.Request, _ = http.NewRequest(http.MethodPost, "/items/batch", strings.NewReader(`{"operations": [
+			{"op": "create", "data": {"name": "New Plant"}},
+			{"op": "update", "id": "123", "data": {"name": "Renamed"}},
+			{"op": "delete", "id": "456"}
+		]}`))
+
+		mockTable.On("Create", mock.Anything, &testModel{Name: "New Plant"}).Run(func(args mock.Arguments) {
+			args.Get(1).(*testModel).ID = "789"
+		}).Return(nil).Once()
+		mockTable.On("Update", mock.Anything, &testModel{ID: "123", Name: "Renamed"}).Return(nil).Once()
+		mockTable.On("Delete", mock.Anything, "456").Return(nil).Once()
+		handler.Batch(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data []batchResult `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Data, 3)
+		assert.Equal(t, "789", response.Data[0].ID)
+		assert.Equal(t, "123", response.Data[1].ID)
+		assert.Equal(t, batchResult{Index: 2, Op: batchDelete, ID: "456"}, response.Data[2])
+		assert.Equal(t, []error{nil}, tx.results)
+		mockTable.AssertExpectations(t)
+	})
+
+	t.Run("invalid operations are reported by index before anything is applied", func(t *testing.T) {
+		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/items/batch", strings.NewReader(`{"operations": [
+			{"op": "create", "data": {"name": "New Plant"}},
+			{"op": "create", "data": {"name": "a name far longer than allowed"}},
+			{"op": "update", "data": {"name": "No ID"}},
+			{"op": "rename", "id": "123"}
+		]}`))
+
+		handler.Batch(c)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		var response batchErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Error.Items, 3)
+		assert.Equal(t, 1, response.Error.Items[0].Index)
+		assert.Equal(t, "name", response.Error.Items[0].Fields[0].Field)
+		assert.Equal(t, 2, response.Error.Items[1].Index)
+		assert.Equal(t, 3, response.Error.Items[2].Index)
+		mockTable.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("failed operation rolls back the batch", func(t *testing.T) {
+		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+		tx := &recordingTransactor{}
+		handler.Tx = tx
+		c.Request, _ = http.NewRequest(http.MethodPost, "/items/batch", strings.NewReader(`{"operations": [
+			{"op": "create", "data": {"name": "New Plant"}},
+			{"op": "delete", "id": "456"},
+			{"op": "delete", "id": "789"}
+		]}`))
+
+		mockTable.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+		mockTable.On("Delete", mock.Anything, "456").Return(sql.ErrNoRows).Once()
+		handler.Batch(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		var response batchErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, []batchItemError{{Index: 1, Op: batchDelete, Error: "not found"}}, response.Error.Items)
+		assert.Equal(t, []error{sql.ErrNoRows}, tx.results)
+		mockTable.AssertNotCalled(t, "Delete", mock.Anything, "789")
+	})
+
+	t.Run("too many operations", func(t *testing.T) {
+		w, c, _, handler := setupTest[testModel, *testModel](t)
+		handler.Env.App.BatchMaxItems = 1
+		c.Request, _ = http.NewRequest(http.MethodPost, "/items/batch", strings.NewReader(`{"operations": [
+			{"op": "delete", "id": "123"},
+			{"op": "delete", "id": "456"}
+		]}`))
+
+		handler.Batch(c)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
+
+func TestCRUDHandler_GetBatch(t *testing.T) {
+	found := []testModel{{ID: batchID1, Name: "Plant A"}}
+
+	t.Run("bulk read", func(t *testing.T) {
+		w, c, _, handler := setupTest[testModel, *testModel](t)
+		mockTable := new(MockBulkTable[testModel])
+		handler.Table = mockTable
+		c.Request, _ = http.NewRequest(http.MethodGet, "/items/batch?ids="+batchID2+","+batchID1, nil)
+
+		mockTable.On("GetByIDs", mock.Anything, []string{batchID2, batchID1}).Return(found, nil).Once()
+		handler.GetBatch(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data bulkRead[testModel] `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, found, response.Data.Items)
+		assert.Equal(t, []string{batchID2}, response.Data.Missing)
+		mockTable.AssertExpectations(t)
+	})
+
+	t.Run("falls back to reading one at a time", func(t *testing.T) {
+		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/items/batch?ids="+batchID1+"&ids="+batchID2, nil)
+
+		mockTable.On("GetByID", mock.Anything, batchID1).Return(found[0], nil).Once()
+		mockTable.On("GetByID", mock.Anything, batchID2).Return(nil, sql.ErrNoRows).Once()
+		handler.GetBatch(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockTable.AssertExpectations(t)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/items/batch?ids="+batchID1+",nope", nil)
+
+		handler.GetBatch(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockTable.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+}
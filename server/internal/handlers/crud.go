@@ -5,11 +5,14 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kylep342/mendel/internal/components"
+	"github.com/kylep342/mendel/internal/components/app/audit"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
 	"github.com/kylep342/mendel/internal/constants"
 	"github.com/kylep342/mendel/internal/db"
 	"github.com/kylep342/mendel/pkg/responses"
@@ -20,13 +23,21 @@ import (
 //	Env: for config values
 //	Table: the table to CRUD
 //	New: Constructor CRUDTable[T]
+//	Tx: runs each write request as a single unit of work; writes are not grouped when nil
+//	Access: decides what each request may do to the records; every request may do anything when nil
+//	Component: names the records to Access and in the audit log
+//	Audit: records every write in the audit log; writes are not recorded when nil
 type CRUDHandler[T any, PT interface {
 	~*T
 	components.Model
 }] struct {
-	Env   *constants.EnvConfig
-	Table db.CRUDTable[T]
-	New   func() PT
+	Env       *constants.EnvConfig
+	Table     db.CRUDTable[T]
+	New       func() PT
+	Tx        db.Transactor
+	Access    Authorizer
+	Component string
+	Audit     audit.Table
 }
 
 // NewCRUDHandler is the constructor for CRUDHandler
@@ -34,38 +45,103 @@ func NewCRUDHandler[T any, PT interface {
 	~*T
 	components.Model
 }](
-	db *pgxpool.Pool,
+	tx db.Transactor,
 	env *constants.EnvConfig,
 	newModelFunc func() PT,
-	tableCreator func(d *pgxpool.Pool) db.CRUDTable[T],
+	table db.CRUDTable[T],
 ) *CRUDHandler[T, PT] {
 	return &CRUDHandler[T, PT]{
-		Table: tableCreator(db),
+		Table: table,
 		New:   newModelFunc,
 		Env:   env,
+		Tx:    tx,
 	}
 }
 
-// RegisterRoutes connects the handlers to an HTTP server
-func (h *CRUDHandler[T, PT]) RegisterRoutes(g *gin.Engine, basePath string) {
+// RegisterRoutes connects the handlers to an HTTP server.
+// Each route first asks Access whether the request may take the action it does; a batch is
+// checked for the action of each of its operations, and restoring counts as deleting.
+// Reading the history of a record also needs Access to allow reading the audit log.
+func (h *CRUDHandler[T, PT]) RegisterRoutes(g gin.IRouter, basePath string) {
+	create := h.authorize(workspace.ActionCreate)
+	read := h.authorize(workspace.ActionRead)
+	update := h.authorize(workspace.ActionUpdate)
+	remove := h.authorize(workspace.ActionDelete)
+
 	rg := g.Group(basePath)
-	rg.GET("/", h.GetAll)
-	rg.GET("/:id", h.GetByID)
-	rg.POST("/", h.Create)
-	rg.PUT("/:id", h.Update)
-	rg.DELETE("/:id", h.Delete)
+	rg.GET("/", read, h.GetAll)
+	rg.GET("/:id", read, h.GetByID)
+	rg.POST("/", create, h.Create)
+	rg.PUT("/:id", update, h.Update)
+	rg.DELETE("/:id", remove, h.Delete)
+	rg.GET("/batch", read, h.GetBatch)
+	rg.POST("/batch", h.Batch)
+	rg.POST("/import", create, h.Import)
+
+	if _, ok := h.Table.(db.SoftDeleteTable[T]); ok {
+		rg.GET("/trash", read, h.GetTrash)
+		rg.POST("/:id/restore", remove, h.Restore)
+	}
+	if _, ok := h.Table.(db.LineageTable[T]); ok {
+		rg.GET("/:id/lineage", read, h.GetLineage)
+	}
+	if _, ok := h.Table.(db.ImpactTable); ok {
+		rg.GET("/:id/impact", read, h.GetImpact)
+	}
+	if h.Audit != nil {
+		rg.GET("/:id/history", read, h.GetHistory)
+	}
 }
 
-// GetAll responds to a request with all records from CRUDTable[T]
+// authorize is middleware refusing requests Access does not allow to take action
+func (h *CRUDHandler[T, PT]) authorize(action workspace.Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authorized(c, h.Access, h.Component, action) {
+			c.Next()
+		}
+	}
+}
+
+// GetAll responds to a request with all records from CRUDTable[T], in the format ?format= or
+// the Accept header asks for:
+//
+//	json: the records in a {"data": [...]} envelope, the default
+//	ndjson: the records streamed one per line when the table supports it, or json otherwise
+//	csv, xlsx: a spreadsheet with a row per record
+//
+// With ?as_of=, it responds with the records as they were at that time, from a db.HistoryTable[T].
 func (h *CRUDHandler[T, PT]) GetAll(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
 	defer cancel()
 
-	items, err := h.Table.GetAll(ctx)
+	format, err := listFormat(c)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	at, ok := h.asOf(c)
+	if !ok {
+		return
+	}
+	if stream, ok := h.Table.(db.StreamTable[T]); ok && format == formatNDJSON && at.IsZero() {
+		h.streamAll(c, stream)
+		return
+	}
+
+	var items []T
+	if at.IsZero() {
+		items, err = h.Table.GetAll(ctx)
+	} else {
+		items, err = h.Table.(db.HistoryTable[T]).GetAllAsOf(ctx, at)
+	}
 	if err != nil {
 		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if format == formatCSV || format == formatXLSX {
+		h.export(c, format, items)
+		return
+	}
 	responses.RespondData(c, items, http.StatusOK)
 }
 
@@ -75,30 +151,41 @@ func (h *CRUDHandler[T, PT]) Create(c *gin.Context) {
 	defer cancel()
 
 	item := h.New()
-	if err := json.NewDecoder(c.Request.Body).Decode(item); err != nil {
-		responses.RespondError(c, err.Error(), http.StatusBadRequest)
+	if !h.bind(c, item, "") {
 		return
 	}
-	if err := h.Table.Create(ctx, item); err != nil {
-		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		return h.create(ctx, c, item)
+	})
+	if err != nil {
+		respondTableError(c, err)
 		return
 	}
 	responses.RespondData(c, item, http.StatusOK)
 }
 
-// GetByID responds to a request with the requested record from CRUDTable[T]
+// GetByID responds to a request with the requested record from CRUDTable[T], as it was at
+// ?as_of= if given
 func (h *CRUDHandler[T, PT]) GetByID(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
 	defer cancel()
 
-	id := c.Param("id")
-	item, err := h.Table.GetByID(ctx, id)
+	at, ok := h.asOf(c)
+	if !ok {
+		return
+	}
+	var (
+		id   = c.Param("id")
+		item T
+		err  error
+	)
+	if at.IsZero() {
+		item, err = h.Table.GetByID(ctx, id)
+	} else {
+		item, err = h.Table.(db.HistoryTable[T]).GetByIDAsOf(ctx, id, at)
+	}
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			responses.RespondError(c, "not found", http.StatusNotFound)
-		} else {
-			responses.RespondError(c, err.Error(), http.StatusInternalServerError)
-		}
+		respondTableError(c, err)
 		return
 	}
 	responses.RespondData(c, item, http.StatusOK)
@@ -111,34 +198,252 @@ func (h *CRUDHandler[T, PT]) Update(c *gin.Context) {
 
 	id := c.Param("id")
 	item := h.New()
-	if model, ok := any(item).(components.Model); ok {
-		model.SetID(id)
-	} else {
-		responses.RespondError(c, "failed to set ID on model", http.StatusInternalServerError)
+	if !h.bind(c, item, id) {
 		return
 	}
 
-	if err := json.NewDecoder(c.Request.Body).Decode(item); err != nil {
-		responses.RespondError(c, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	if err := h.Table.Update(ctx, item); err != nil {
-		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		return h.update(ctx, c, item)
+	})
+	if err != nil {
+		respondTableError(c, err)
 		return
 	}
 	responses.RespondData(c, item, http.StatusOK)
 }
 
 // Delete responds to a request to remove the requested record from CRUDTable[T]
+//
+//	?dry_run=true: respond with what the delete would affect instead
+//	?confirm=<token>: required when the delete affects more than App.DeleteConfirmThreshold records
 func (h *CRUDHandler[T, PT]) Delete(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
 	defer cancel()
 
 	id := c.Param("id")
-	if err := h.Table.Delete(ctx, id); err != nil {
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		if !h.checkDelete(ctx, c, id) {
+			return errResponded
+		}
+		return h.delete(ctx, c, id)
+	})
+	if errors.Is(err, errResponded) {
+		return
+	}
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, id, http.StatusOK)
+}
+
+// GetTrash responds to a request with all deleted records from a db.SoftDeleteTable[T]
+func (h *CRUDHandler[T, PT]) GetTrash(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	items, err := h.Table.(db.SoftDeleteTable[T]).GetTrash(ctx)
+	if err != nil {
 		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	responses.RespondData(c, items, http.StatusOK)
+}
+
+// Restore responds to a request to move the requested record out of the trash of a db.SoftDeleteTable[T]
+func (h *CRUDHandler[T, PT]) Restore(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	id := c.Param("id")
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		return h.restore(ctx, c, id)
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
 	responses.RespondData(c, id, http.StatusOK)
 }
+
+// GetLineage responds to a request with the requested record and its ancestors from a db.LineageTable[T],
+// as they were at ?as_of= if given and the table is a db.LineageHistoryTable[T]
+func (h *CRUDHandler[T, PT]) GetLineage(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	at, ok := h.asOf(c)
+	if !ok {
+		return
+	}
+	var (
+		items []T
+		err   error
+	)
+	if at.IsZero() {
+		items, err = h.Table.(db.LineageTable[T]).GetLineage(ctx, c.Param("id"))
+	} else if lineage, ok := h.Table.(db.LineageHistoryTable[T]); ok {
+		items, err = lineage.GetLineageAsOf(ctx, c.Param("id"), at)
+	} else {
+		responses.RespondError(c, fmt.Sprintf("%s is not supported: past lineages are not kept", queryAsOf), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, items, http.StatusOK)
+}
+
+// errResponded rolls back a unit of work whose handler has already responded
+var errResponded = errors.New("handler responded")
+
+// respondTableError maps errors from a CRUDTable[T] to an HTTP status
+func respondTableError(c *gin.Context, err error) {
+	code, msg := tableErrorStatus(err)
+	responses.RespondError(c, msg, code)
+}
+
+func tableErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound, "not found"
+	case errors.Is(err, db.ErrParentDeleted), errors.Is(err, db.ErrDuplicate):
+		return http.StatusConflict, err.Error()
+	default:
+		return http.StatusInternalServerError, err.Error()
+	}
+}
+
+// bind decodes the request body into item and validates it, responding on failure
+//
+//	400: malformed JSON or unknown fields
+//	413: body larger than Server.MaxBodyBytes
+//	422: one or more fields failed validation
+//
+// A non-empty id is set on item after decoding so the body cannot override it.
+func (h *CRUDHandler[T, PT]) bind(c *gin.Context, item PT, id string) bool {
+	if c.Request.Body == nil {
+		responses.RespondError(c, "request body is required", http.StatusBadRequest)
+		return false
+	}
+
+	if err := decodeJSON(h.limitBody(c), item); err != nil {
+		respondBindError(c, err)
+		return false
+	}
+
+	if id != "" {
+		item.SetID(id)
+	}
+
+	if err := components.Validate(item); err != nil {
+		respondBindError(c, err)
+		return false
+	}
+	return true
+}
+
+// create adds item to Table with an ID the store generates, recording it in the audit log.
+// Clients never choose IDs: they are unique across workspaces, so a conflict on a chosen ID
+// would reveal a record of another workspace.
+func (h *CRUDHandler[T, PT]) create(ctx context.Context, c *gin.Context, item PT) error {
+	item.SetID("")
+	if err := h.Table.Create(ctx, item); err != nil {
+		return err
+	}
+	return h.audit(ctx, c, audit.ActionCreate, item.GetID(), nil, item)
+}
+
+// update changes item in Table, recording what changed in the audit log
+func (h *CRUDHandler[T, PT]) update(ctx context.Context, c *gin.Context, item PT) error {
+	var before PT
+	if h.Audit != nil {
+		existing, err := h.Table.GetByID(ctx, item.GetID())
+		if err != nil {
+			return err
+		}
+		before = &existing
+	}
+	if err := h.Table.Update(ctx, item); err != nil {
+		return err
+	}
+	return h.audit(ctx, c, audit.ActionUpdate, item.GetID(), before, item)
+}
+
+// delete removes the record id from Table, recording what it was in the audit log
+func (h *CRUDHandler[T, PT]) delete(ctx context.Context, c *gin.Context, id string) error {
+	var before PT
+	if h.Audit != nil {
+		existing, err := h.Table.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		before = &existing
+	}
+	if err := h.Table.Delete(ctx, id); err != nil {
+		return err
+	}
+	return h.audit(ctx, c, audit.ActionDelete, id, before, nil)
+}
+
+// restore moves the record id out of the trash of a db.SoftDeleteTable[T], recording it as
+// restored in the audit log
+func (h *CRUDHandler[T, PT]) restore(ctx context.Context, c *gin.Context, id string) error {
+	if err := h.Table.(db.SoftDeleteTable[T]).Restore(ctx, id); err != nil {
+		return err
+	}
+	if h.Audit == nil {
+		return nil
+	}
+	restored, err := h.Table.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return h.audit(ctx, c, audit.ActionRestore, id, nil, &restored)
+}
+
+// limitBody caps the request body at Server.MaxBodyBytes
+func (h *CRUDHandler[T, PT]) limitBody(c *gin.Context) io.Reader {
+	if h.Env.Server.MaxBodyBytes > 0 {
+		return http.MaxBytesReader(c.Writer, c.Request.Body, h.Env.Server.MaxBodyBytes)
+	}
+	return c.Request.Body
+}
+
+// errTrailingData is returned by decodeJSON when a value is followed by anything but whitespace
+var errTrailingData = errors.New("request body must hold a single JSON value")
+
+// decodeJSON decodes a single JSON value from r into v, rejecting unknown fields and anything
+// after the value
+func decodeJSON(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	var tooLarge *http.MaxBytesError
+	if _, err := dec.Token(); err != io.EOF {
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return errTrailingData
+	}
+	return nil
+}
+
+// respondBindError responds to an error from decodeJSON or components.Validate
+func respondBindError(c *gin.Context, err error) {
+	var (
+		tooLarge *http.MaxBytesError
+		invalid  *components.ValidationError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		responses.RespondError(c, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &invalid):
+		responses.RespondError(c, invalid, http.StatusUnprocessableEntity)
+	default:
+		responses.RespondError(c, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -15,14 +15,16 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/kylep342/mendel/internal/components"
 	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // testModel is a concrete struct that satisfies the models.Model interface for our tests.
 type testModel struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID   string `db:"id" json:"id"`
+	Name string `db:"name" json:"name" validate:"max=20"`
 }
 
 // SetID satisfies the models.Model interface.
@@ -87,6 +89,29 @@ func (m *MockCRUDTable[T]) Delete(ctx context.Context, id string) error {
 	return args.Error(0)
 }
 
+// MockSoftDeleteTable extends MockCRUDTable with the db.SoftDeleteTable methods.
+type MockSoftDeleteTable[T any] struct {
+	MockCRUDTable[T]
+}
+
+func (m *MockSoftDeleteTable[T]) GetTrash(ctx context.Context) ([]T, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]T), args.Error(1)
+}
+
+func (m *MockSoftDeleteTable[T]) Restore(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockSoftDeleteTable[T]) Purge(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 // setupTest is a helper function to reduce boilerplate in tests.
 // It initializes a gin test context, a response recorder, our handler, and the mock table.
 func setupTest[T interface{}, PT interface {
@@ -195,6 +220,54 @@ func TestCRUDHandler_Create(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotEmpty(t, errResponse["error"])
 	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		w, c, _, handler := setupTest[testModel, *testModel](t)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name": "a", "color": "red"}`))
+
+		handler.Create(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "color")
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		for _, body := range []string{`{"name": "a"}{"bogus": 1}`, `{"name": "a"} garbage`, `{"name": "a"}]`} {
+			w, c, _, handler := setupTest[testModel, *testModel](t)
+			c.Request, _ = http.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+
+			handler.Create(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+			assert.Contains(t, w.Body.String(), errTrailingData.Error(), body)
+		}
+
+		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/items", strings.NewReader("{\"name\": \"a\"}\n\t "))
+		mockTable.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+		handler.Create(c)
+		assert.Equal(t, http.StatusOK, w.Code, "trailing whitespace is fine")
+	})
+
+	t.Run("oversized body", func(t *testing.T) {
+		w, c, _, handler := setupTest[testModel, *testModel](t)
+		handler.Env.Server.MaxBodyBytes = 8
+		c.Request, _ = http.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name": "too long for the limit"}`))
+
+		handler.Create(c)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		w, c, _, handler := setupTest[testModel, *testModel](t)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name": "a name well over twenty characters"}`))
+
+		handler.Create(c)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"error": {"fields": [{"field": "name", "message": "must be at most 20 characters"}]}}`, w.Body.String())
+	})
 }
 
 func TestCRUDHandler_GetByID(t *testing.T) {
@@ -232,6 +305,7 @@ func TestCRUDHandler_Update(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
 		c.Params = gin.Params{gin.Param{Key: "id", Value: "123"}}
+		c.Request, _ = http.NewRequest(http.MethodPut, "/items/123", strings.NewReader(`{}`))
 
 		itemToUpdate := &testModel{ID: "123"}
 		mockTable.On("Update", mock.Anything, itemToUpdate).Return(nil).Once()
@@ -245,6 +319,19 @@ func TestCRUDHandler_Update(t *testing.T) {
 		assert.Equal(t, *itemToUpdate, response["data"])
 		mockTable.AssertExpectations(t)
 	})
+
+	t.Run("body cannot override id", func(t *testing.T) {
+		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+		c.Params = gin.Params{gin.Param{Key: "id", Value: "123"}}
+		c.Request, _ = http.NewRequest(http.MethodPut, "/items/123", strings.NewReader(`{"id": "456", "name": "Renamed"}`))
+
+		itemToUpdate := &testModel{ID: "123", Name: "Renamed"}
+		mockTable.On("Update", mock.Anything, itemToUpdate).Return(nil).Once()
+		handler.Update(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockTable.AssertExpectations(t)
+	})
 }
 
 func TestCRUDHandler_Delete(t *testing.T) {
@@ -278,3 +365,179 @@ func TestCRUDHandler_Delete(t *testing.T) {
 		mockTable.AssertExpectations(t)
 	})
 }
+
+func TestCRUDHandler_Delete_NotFound(t *testing.T) {
+	w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "404"}}
+
+	mockTable.On("Delete", mock.Anything, "404").Return(sql.ErrNoRows).Once()
+	handler.Delete(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockTable.AssertExpectations(t)
+}
+
+func TestCRUDHandler_Trash(t *testing.T) {
+	setupSoftDelete := func(t *testing.T) (*httptest.ResponseRecorder, *gin.Context, *MockSoftDeleteTable[testModel], *CRUDHandler[testModel, *testModel]) {
+		w, c, _, handler := setupTest[testModel, *testModel](t)
+		mockTable := new(MockSoftDeleteTable[testModel])
+		handler.Table = mockTable
+		return w, c, mockTable, handler
+	}
+
+	t.Run("routes registered", func(t *testing.T) {
+		_, _, _, handler := setupSoftDelete(t)
+		r := gin.New()
+		handler.RegisterRoutes(r, "/items")
+
+		var paths []string
+		for _, route := range r.Routes() {
+			paths = append(paths, route.Method+" "+route.Path)
+		}
+		assert.Contains(t, paths, "GET /items/trash")
+		assert.Contains(t, paths, "POST /items/:id/restore")
+	})
+
+	t.Run("get trash", func(t *testing.T) {
+		w, c, mockTable, handler := setupSoftDelete(t)
+
+		trashed := []testModel{{ID: "1", Name: "Deleted Plant"}}
+		mockTable.On("GetTrash", mock.Anything).Return(trashed, nil).Once()
+		handler.GetTrash(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string][]testModel
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, trashed, response["data"])
+		mockTable.AssertExpectations(t)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		w, c, mockTable, handler := setupSoftDelete(t)
+		c.Params = gin.Params{gin.Param{Key: "id", Value: "123"}}
+
+		mockTable.On("Restore", mock.Anything, "123").Return(nil).Once()
+		handler.Restore(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":"123"}`, w.Body.String())
+		mockTable.AssertExpectations(t)
+	})
+
+	t.Run("restore with deleted parent", func(t *testing.T) {
+		w, c, mockTable, handler := setupSoftDelete(t)
+		c.Params = gin.Params{gin.Param{Key: "id", Value: "123"}}
+
+		mockTable.On("Restore", mock.Anything, "123").Return(db.ErrParentDeleted).Once()
+		handler.Restore(c)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockTable.AssertExpectations(t)
+	})
+}
+
+// recordingTransactor is a db.Transactor that records the outcome of each unit of work.
+type recordingTransactor struct {
+	results []error
+}
+
+func (r *recordingTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := fn(ctx)
+	r.results = append(r.results, err)
+	return err
+}
+
+func TestCRUDHandler_Transactions(t *testing.T) {
+	t.Run("write runs in a unit of work", func(t *testing.T) {
+		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+		tx := &recordingTransactor{}
+		handler.Tx = tx
+		c.Request, _ = http.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name": "New Plant"}`))
+
+		mockTable.On("Create", mock.Anything, &testModel{Name: "New Plant"}).Return(nil).Once()
+		handler.Create(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []error{nil}, tx.results)
+	})
+
+	t.Run("failed write is rolled back", func(t *testing.T) {
+		w, c, mockTable, handler := setupTest[testModel, *testModel](t)
+		tx := &recordingTransactor{}
+		handler.Tx = tx
+		c.Params = gin.Params{gin.Param{Key: "id", Value: "123"}}
+
+		dbErr := errors.New("delete failed")
+		mockTable.On("Delete", mock.Anything, "123").Return(dbErr).Once()
+		handler.Delete(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, []error{dbErr}, tx.results)
+	})
+}
+
+func TestCRUDHandler_MemoryStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	env := &constants.EnvConfig{}
+	env.Server.ReadTimeout = 5 * time.Second
+	env.Server.WriteTimeout = 5 * time.Second
+
+	// setup serves a CRUDHandler over an empty memory store
+	setup := func() (*db.MemoryStore[testModel], func(method, path, body string) *httptest.ResponseRecorder) {
+		mem := db.NewMemory()
+		store := db.NewMemoryStore[testModel](mem, "test")
+		handler := NewCRUDHandler(mem, env, newTestModel, db.CRUDTable[testModel](store))
+		router := gin.New()
+		handler.RegisterRoutes(router, "/items")
+		return store, func(method, path, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, strings.NewReader(body))
+			router.ServeHTTP(w, req)
+			return w
+		}
+	}
+
+	t.Run("crud", func(t *testing.T) {
+		_, serve := setup()
+
+		w := serve(http.MethodPost, "/items/", `{"name": "Plant A"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var created map[string]testModel
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		id := created["data"].ID
+		assert.NotEmpty(t, id)
+
+		w = serve(http.MethodPut, "/items/"+id, `{"name": "Plant B"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = serve(http.MethodGet, "/items/"+id, "")
+		assert.JSONEq(t, fmt.Sprintf(`{"data": {"id": %q, "name": "Plant B"}}`, id), w.Body.String())
+
+		w = serve(http.MethodDelete, "/items/"+id, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(http.MethodGet, "/items/"+id, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("client chosen ids are ignored", func(t *testing.T) {
+		store, serve := setup()
+		chosen := "00000000-0000-0000-0000-00000000000a"
+
+		w := serve(http.MethodPost, "/items/", fmt.Sprintf(`{"id": %q, "name": "Plant A"}`, chosen))
+		require.Equal(t, http.StatusOK, w.Code)
+		var created map[string]testModel
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.NotEmpty(t, created["data"].ID)
+		assert.NotEqual(t, chosen, created["data"].ID, "the store picks the ID")
+
+		w = serve(http.MethodPost, "/items/batch", fmt.Sprintf(
+			`{"operations": [{"op": "create", "data": {"id": %q, "name": "Plant B"}}]}`, chosen))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		_, err := store.GetByID(context.Background(), chosen)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		all, err := store.GetAll(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/pkg/responses"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+	formatXLSX   = "xlsx"
+
+	mimeCSV  = "text/csv"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	queryFormat = "format"
+
+	// xlsxSheet is the sheet exports are written to
+	xlsxSheet = "Sheet1"
+)
+
+// listFormats maps each format a list can be responded with to its media type, in order of preference
+var listFormats = []struct{ format, mime string }{
+	{formatJSON, gin.MIMEJSON},
+	{formatNDJSON, mimeNDJSON},
+	{formatCSV, mimeCSV},
+	{formatXLSX, mimeXLSX},
+}
+
+// listFormat picks the format to respond to a list request with: ?format= when given,
+// otherwise the best match for the Accept header, defaulting to JSON
+func listFormat(c *gin.Context) (string, error) {
+	if format := strings.ToLower(c.Query(queryFormat)); format != "" {
+		for _, f := range listFormats {
+			if f.format == format {
+				return format, nil
+			}
+		}
+		return "", fmt.Errorf("format must be one of json, ndjson, csv or xlsx, not %q", format)
+	}
+
+	offered := make([]string, len(listFormats))
+	for i, f := range listFormats {
+		offered[i] = f.mime
+	}
+	accepted := c.NegotiateFormat(offered...)
+	for _, f := range listFormats {
+		if f.mime == accepted {
+			return f.format, nil
+		}
+	}
+	return formatJSON, nil
+}
+
+// export responds with items, the records of the table, as a CSV or XLSX file.
+// Object columns such as genetics and labels are flattened into a column per key, named
+// <column>.<key>, so each trait gets its own spreadsheet column; nested values are written as JSON.
+// CSV text a spreadsheet would run as a formula is written with a leading apostrophe; XLSX
+// cells are typed, so their text is never run and is written as it is.
+func (h *CRUDHandler[T, PT]) export(c *gin.Context, format string, items []T) {
+	header, rows := flattenRecords(items)
+	name := path.Base(strings.TrimSuffix(c.FullPath(), "/"))
+	if name == "." || name == "/" {
+		name = "export"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+
+	switch format {
+	case formatCSV:
+		c.Header("Content-Type", mimeCSV+"; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = writeCSV(c.Writer, header, rows)
+	case formatXLSX:
+		f, err := writeXLSX(header, rows)
+		if err != nil {
+			c.Header("Content-Disposition", "")
+			responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		c.Header("Content-Type", mimeXLSX)
+		c.Status(http.StatusOK)
+		_ = f.Write(c.Writer)
+	}
+}
+
+// writeCSV writes header and rows to w as CSV
+func writeCSV(w io.Writer, header []string, rows [][]any) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(header)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = formatCell(cell)
+		}
+		_ = cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeXLSX writes header and rows to the first sheet of a new workbook
+func writeXLSX(header []string, rows [][]any) (*excelize.File, error) {
+	f := excelize.NewFile()
+	sw, err := f.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	write := func(i int, row []any) error {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		return sw.SetRow(cell, row)
+	}
+
+	headerRow := make([]any, len(header))
+	for i, col := range header {
+		headerRow[i] = col
+	}
+	if err := write(0, headerRow); err != nil {
+		f.Close()
+		return nil, err
+	}
+	for i, row := range rows {
+		if err := write(i+1, row); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// flattenRecords lays items out as a table: a header of column names and a row of cells per item.
+// Every column of T is included in field order; object columns are replaced by a column per key
+// found across all items, sorted by key, plus the bare column when an item holds a non-object there.
+func flattenRecords[T any](items []T) ([]string, [][]any) {
+	fields := db.FieldsOf[T]()
+	values := make([]reflect.Value, len(items))
+	for i := range items {
+		values[i] = reflect.ValueOf(&items[i]).Elem()
+	}
+
+	type column struct {
+		name, key string
+		bare      bool
+		object    bool
+	}
+	var columns []column
+	for _, col := range fields.Columns() {
+		if !isObjectType(fields.FieldType(col)) {
+			columns = append(columns, column{name: col, bare: true})
+			continue
+		}
+		keys := map[string]bool{}
+		bare := false
+		for _, v := range values {
+			switch obj := indirect(fields.Field(v, col)).(type) {
+			case nil:
+			case map[string]any:
+				for key := range obj {
+					keys[key] = true
+				}
+			default:
+				bare = true
+			}
+		}
+		if bare || len(keys) == 0 {
+			columns = append(columns, column{name: col, bare: true, object: true})
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			columns = append(columns, column{name: col, key: key})
+		}
+	}
+
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+		if !col.bare {
+			header[i] += "." + col.key
+		}
+	}
+
+	rows := make([][]any, len(values))
+	for r, v := range values {
+		row := make([]any, len(columns))
+		for i, col := range columns {
+			value := indirect(fields.Field(v, col.name))
+			obj, isObj := value.(map[string]any)
+			switch {
+			case col.bare && col.object && isObj:
+				// the object's keys have their own columns
+			case col.bare:
+				row[i] = cellValue(value)
+			case isObj:
+				row[i] = cellValue(obj[col.key])
+			}
+		}
+		rows[r] = row
+	}
+	return header, rows
+}
+
+// isObjectType reports whether a field of type t holds a JSON object, like a JSONB column does
+func isObjectType(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface || t.Kind() == reflect.Map
+}
+
+// indirect returns the value v holds, following pointers and interfaces, or nil when there is none
+func indirect(v reflect.Value) any {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+// formulaTriggers are the characters that make a spreadsheet read a CSV cell starting with them
+// as a formula
+const formulaTriggers = "=+-@\t\r"
+
+// escapeCell makes a spreadsheet show CSV text starting with one of formulaTriggers as it is
+// rather than run it as a formula, by prefixing it with an apostrophe. Text that only starts with
+// apostrophes before one gets another, so that CSV import, which removes it, gets the text back.
+func escapeCell(text string) string {
+	if needsEscape(text) {
+		return "'" + text
+	}
+	return text
+}
+
+// needsEscape reports whether escapeCell prefixes text with an apostrophe
+func needsEscape(text string) bool {
+	switch {
+	case text == "":
+		return false
+	case strings.ContainsRune(formulaTriggers, rune(text[0])):
+		return true
+	default:
+		return text[0] == '\'' && needsEscape(text[1:])
+	}
+}
+
+// cellValue converts a value to something a spreadsheet cell holds: a string, number or bool.
+// Times are written in RFC 3339 and anything else is written as JSON.
+func cellValue(value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string, bool, float32, float64,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case sql.NullTime:
+		if !v.Valid {
+			return nil
+		}
+		return v.Time.Format(time.RFC3339Nano)
+	case json.Number:
+		return v.String()
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// formatCell is the text of a cell as written to a CSV, text escaped with escapeCell
+func formatCell(cell any) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return escapeCell(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testTraitModel has an object column, like genetics and labels
+type testTraitModel struct {
+	ID     string `db:"id" json:"id"`
+	Name   string `db:"name" json:"name" validate:"required"`
+	Count  int    `db:"count" json:"count"`
+	Traits any    `db:"traits" json:"traits"`
+}
+
+func (m *testTraitModel) SetID(id string) { m.ID = id }
+
+func (m *testTraitModel) GetID() string { return m.ID }
+
+func TestListFormat(t *testing.T) {
+	tests := []struct {
+		query, accept, want string
+		wantErr             bool
+	}{
+		{"", "", formatJSON, false},
+		{"", "*/*", formatJSON, false},
+		{"", mimeCSV, formatCSV, false},
+		{"", mimeXLSX, formatXLSX, false},
+		{"", mimeNDJSON, formatNDJSON, false},
+		{"", "text/html", formatJSON, false},
+		{"CSV", gin.MIMEJSON, formatCSV, false},
+		{"pdf", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+"|"+tt.accept, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request, _ = http.NewRequest(http.MethodGet, "/?format="+tt.query, nil)
+			c.Request.Header.Set("Accept", tt.accept)
+
+			got, err := listFormat(c)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFlattenRecords(t *testing.T) {
+	items := []testTraitModel{
+		{ID: "1", Name: "a", Count: 2, Traits: map[string]any{"height": 1.5, "color": "red"}},
+		{ID: "2", Name: "b", Traits: map[string]any{"alleles": []any{"A", "a"}}},
+		{ID: "3", Name: "c"},
+	}
+
+	header, rows := flattenRecords(items)
+
+	assert.Equal(t, []string{"id", "name", "count", "traits.alleles", "traits.color", "traits.height"}, header)
+	assert.Equal(t, [][]any{
+		{"1", "a", 2, nil, "red", 1.5},
+		{"2", "b", 0, `["A","a"]`, nil, nil},
+		{"3", "c", 0, nil, nil, nil},
+	}, rows)
+
+	header, _ = flattenRecords([]testTraitModel{{ID: "1", Traits: "not an object"}, {ID: "2", Traits: map[string]any{"k": 1.0}}})
+	assert.Equal(t, []string{"id", "name", "count", "traits", "traits.k"}, header, "non-objects keep the bare column")
+
+	_, rows = flattenRecords([]testTraitModel{{ID: "1", Name: "=1+1", Count: -1, Traits: map[string]any{"a": "+1", "b": "@x"}}})
+	assert.Equal(t, []any{"1", "=1+1", -1, "+1", "@x"}, rows[0], "cells hold text as it is")
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, writeCSV(&b, []string{"a", "b", "c", "d", "e", "f", "g", "h"}, [][]any{{"=1+1", -1, "+1", "@x", "-", "\tx", "a=b", "'=x"}}))
+
+	records, err := csv.NewReader(&b).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"'=1+1", "-1", "'+1", "'@x", "'-", "'\tx", "a=b", "''=x"}, records[1], "text is never written as a formula")
+}
+
+func TestCRUDHandler_GetAll_Export(t *testing.T) {
+	items := []testTraitModel{{ID: "1", Name: "Plant A", Count: 3, Traits: map[string]any{"height": 12.0}}}
+
+	setup := func(t *testing.T, format string) (*httptest.ResponseRecorder, *MockCRUDTable[testTraitModel], func()) {
+		w, c, mockTable, handler := setupTest[testTraitModel, *testTraitModel](t)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/items/?format="+format, nil)
+		return w, mockTable, func() { handler.GetAll(c) }
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		w, mockTable, run := setup(t, formatCSV)
+		mockTable.On("GetAll", mock.Anything).Return(items, nil).Once()
+
+		run()
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, mimeCSV+"; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"id", "name", "count", "traits.height"}, {"1", "Plant A", "3", "12"}}, records)
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		w, mockTable, run := setup(t, formatXLSX)
+		mockTable.On("GetAll", mock.Anything).Return(items, nil).Once()
+
+		run()
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, mimeXLSX, w.Header().Get("Content-Type"))
+		rows, err := readSheet(formatXLSX, bytes.NewReader(w.Body.Bytes()), 0)
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"id", "name", "count", "traits.height"}, {"1", "Plant A", "3", "12"}}, rows)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		w, mockTable, run := setup(t, formatCSV)
+		mockTable.On("GetAll", mock.Anything).Return([]testTraitModel{}, errors.New("query failed")).Once()
+
+		run()
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+		assert.Contains(t, w.Body.String(), "query failed")
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		w, mockTable, run := setup(t, "pdf")
+
+		run()
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockTable.AssertNotCalled(t, "GetAll", mock.Anything)
+	})
+}
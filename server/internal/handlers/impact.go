@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+const (
+	queryDryRun  = "dry_run"
+	queryConfirm = "confirm"
+)
+
+// deleteImpact describes what deleting a record would affect
+//
+//	RequiresConfirmation: the delete must be sent with ?confirm=ConfirmationToken
+//	ConfirmationToken: changes whenever the set of affected records does
+type deleteImpact struct {
+	db.Impact
+	Total                int    `json:"total"`
+	RequiresConfirmation bool   `json:"requires_confirmation"`
+	ConfirmationToken    string `json:"confirmation_token,omitempty"`
+}
+
+// GetImpact responds to a request with what deleting the requested record from a db.ImpactTable would affect
+func (h *CRUDHandler[T, PT]) GetImpact(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	impact, err := h.impact(ctx, h.Table.(db.ImpactTable), c.Param("id"))
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, impact, http.StatusOK)
+}
+
+// checkDelete handles ?dry_run and the confirmation of large deletes before Delete runs.
+// It returns false if it has already responded.
+func (h *CRUDHandler[T, PT]) checkDelete(ctx context.Context, c *gin.Context, id string) bool {
+	if dryRun, _ := strconv.ParseBool(c.Query(queryDryRun)); dryRun {
+		table, ok := h.Table.(db.ImpactTable)
+		if !ok {
+			responses.RespondError(c, "dry run is not supported for this component", http.StatusBadRequest)
+			return false
+		}
+		impact, err := h.impact(ctx, table, id)
+		if err != nil {
+			respondTableError(c, err)
+			return false
+		}
+		responses.RespondData(c, impact, http.StatusOK)
+		return false
+	}
+
+	if err := h.confirmDelete(ctx, id, c.Query(queryConfirm)); err != nil {
+		var required *confirmationRequiredError
+		if errors.As(err, &required) {
+			responses.RespondError(c, gin.H{"message": err.Error(), "impact": required.impact}, http.StatusPreconditionRequired)
+		} else {
+			respondTableError(c, err)
+		}
+		return false
+	}
+	return true
+}
+
+// confirmationRequiredError is returned for a delete affecting more than App.DeleteConfirmThreshold
+// records that was not sent with its confirmation token
+type confirmationRequiredError struct {
+	impact deleteImpact
+}
+
+func (e *confirmationRequiredError) Error() string {
+	return "this delete affects " + strconv.Itoa(e.impact.Total) + " records; repeat it with confirm=<confirmation_token>"
+}
+
+// confirmDelete checks that a delete of id needing confirmation was sent with token
+func (h *CRUDHandler[T, PT]) confirmDelete(ctx context.Context, id, token string) error {
+	table, ok := h.Table.(db.ImpactTable)
+	if !ok || h.Env.App.DeleteConfirmThreshold <= 0 {
+		return nil
+	}
+
+	impact, err := h.impact(ctx, table, id)
+	if err != nil {
+		return err
+	}
+	if impact.RequiresConfirmation && token != impact.ConfirmationToken {
+		return &confirmationRequiredError{impact: impact}
+	}
+	return nil
+}
+
+func (h *CRUDHandler[T, PT]) impact(ctx context.Context, table db.ImpactTable, id string) (deleteImpact, error) {
+	impact, err := table.GetImpact(ctx, id)
+	if err != nil {
+		return deleteImpact{}, err
+	}
+
+	resp := deleteImpact{Impact: impact, Total: impact.Total()}
+	threshold := h.Env.App.DeleteConfirmThreshold
+	if threshold > 0 && resp.Total > threshold {
+		resp.RequiresConfirmation = true
+		resp.ConfirmationToken = confirmationToken(id, impact)
+	}
+	return resp, nil
+}
+
+// confirmationToken fingerprints the records a delete affects
+func confirmationToken(id string, impact db.Impact) string {
+	hash := sha256.New()
+	hash.Write([]byte(id))
+	for _, group := range []map[string][]string{impact.Removed, impact.Orphaned} {
+		tables := make([]string, 0, len(group))
+		for table := range group {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+
+		for _, table := range tables {
+			ids := append([]string(nil), group[table]...)
+			sort.Strings(ids)
+			hash.Write([]byte{0})
+			hash.Write([]byte(table))
+			for _, affected := range ids {
+				hash.Write([]byte{0})
+				hash.Write([]byte(affected))
+			}
+		}
+		hash.Write([]byte{1})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:32]
+}
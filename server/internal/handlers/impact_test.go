@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockImpactTable extends MockCRUDTable with the db.ImpactTable method.
+type MockImpactTable[T any] struct {
+	MockCRUDTable[T]
+}
+
+func (m *MockImpactTable[T]) GetImpact(ctx context.Context, id string) (db.Impact, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(db.Impact), args.Error(1)
+}
+
+func TestCRUDHandler_DeleteImpact(t *testing.T) {
+	impact := db.Impact{
+		Removed:  map[string][]string{"plant_cultivar": {"123"}, "plant": {"p1", "p2"}},
+		Orphaned: map[string][]string{"plant": {"p3"}},
+	}
+
+	setupImpact := func(t *testing.T, query string, threshold int) (*httptest.ResponseRecorder, *gin.Context, *MockImpactTable[testModel], *CRUDHandler[testModel, *testModel]) {
+		w, c, _, handler := setupTest[testModel, *testModel](t)
+		mockTable := new(MockImpactTable[testModel])
+		handler.Table = mockTable
+		handler.Env.App.DeleteConfirmThreshold = threshold
+		c.Params = gin.Params{gin.Param{Key: "id", Value: "123"}}
+		c.Request, _ = http.NewRequest(http.MethodDelete, "/items/123"+query, nil)
+		return w, c, mockTable, handler
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		w, c, mockTable, handler := setupImpact(t, "?dry_run=true", 0)
+
+		mockTable.On("GetImpact", mock.Anything, "123").Return(impact, nil).Once()
+		handler.Delete(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockTable.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		mockTable.AssertExpectations(t)
+	})
+
+	t.Run("under threshold", func(t *testing.T) {
+		w, c, mockTable, handler := setupImpact(t, "", 10)
+
+		mockTable.On("GetImpact", mock.Anything, "123").Return(impact, nil).Once()
+		mockTable.On("Delete", mock.Anything, "123").Return(nil).Once()
+		handler.Delete(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockTable.AssertExpectations(t)
+	})
+
+	t.Run("over threshold needs confirmation", func(t *testing.T) {
+		w, c, mockTable, handler := setupImpact(t, "", 2)
+
+		mockTable.On("GetImpact", mock.Anything, "123").Return(impact, nil).Once()
+		handler.Delete(c)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		mockTable.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+
+		var response struct {
+			Error struct {
+				Impact deleteImpact `json:"impact"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 4, response.Error.Impact.Total)
+		assert.True(t, response.Error.Impact.RequiresConfirmation)
+		assert.Equal(t, confirmationToken("123", impact), response.Error.Impact.ConfirmationToken)
+	})
+
+	t.Run("over threshold with confirmation", func(t *testing.T) {
+		w, c, mockTable, handler := setupImpact(t, "?confirm="+confirmationToken("123", impact), 2)
+
+		mockTable.On("GetImpact", mock.Anything, "123").Return(impact, nil).Once()
+		mockTable.On("Delete", mock.Anything, "123").Return(nil).Once()
+		handler.Delete(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockTable.AssertExpectations(t)
+	})
+}
+
+func TestConfirmationToken(t *testing.T) {
+	a := db.Impact{Removed: map[string][]string{"plant": {"1", "2"}}}
+	b := db.Impact{Removed: map[string][]string{"plant": {"2", "1"}}}
+	c := db.Impact{Removed: map[string][]string{"plant": {"1"}}, Orphaned: map[string][]string{"plant": {"2"}}}
+
+	assert.Equal(t, confirmationToken("x", a), confirmationToken("x", b))
+	assert.NotEqual(t, confirmationToken("x", a), confirmationToken("x", c))
+	assert.NotEqual(t, confirmationToken("x", a), confirmationToken("y", a))
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/components"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/pkg/responses"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	formFile    = "file"
+	formMapping = "mapping"
+	formFormat  = "format"
+)
+
+// errTooManyRows stops reading a file with more than App.ImportMaxRows rows
+var errTooManyRows = errors.New("too many rows")
+
+// importReport describes how a file was, or would be, imported
+//
+//	Columns: the column of the file each field was read from, by field
+//	Ignored: columns of the file that were not read, such as id and the timestamps
+//	Unknown: columns of the file that match no field and were not mapped
+//	Errors: why rows could not be imported, by row number, counting the header as row 1
+//	Items: the records created, or those that would be on a dry run
+type importReport[T any] struct {
+	Message string            `json:"message,omitempty"`
+	DryRun  bool              `json:"dry_run"`
+	Rows    int               `json:"rows"`
+	Columns map[string]string `json:"columns"`
+	Ignored []string          `json:"ignored"`
+	Unknown []string          `json:"unknown"`
+	Errors  []importRowError  `json:"errors"`
+	Items   []T               `json:"items"`
+}
+
+// importRowError explains why a row of an imported file could not be imported
+type importRowError struct {
+	Row    int                     `json:"row"`
+	Error  string                  `json:"error"`
+	Fields []components.FieldError `json:"fields,omitempty"`
+}
+
+// importColumn is where the cells of a column of an imported file go
+//
+//	Field: the field they fill, or empty when the column is not read
+//	Key: the key of the object Field they fill, or empty to fill Field itself
+type importColumn struct {
+	Field string
+	Key   string
+}
+
+// Import responds to a multipart upload of a CSV or XLSX file by creating a record per row,
+// all together or not at all. The first row names the columns; they match fields by name, and
+// object fields like genetics and labels take a column per key named <field>.<key>, as exported.
+//
+//	file: the CSV or XLSX file; only the first sheet of a workbook is read
+//	format: csv or xlsx, when the file name's extension does not tell
+//	mapping: a JSON object renaming the file's columns to fields, or to "" to skip them
+//	?dry_run=true: respond with what would be created and any row errors instead of writing
+func (h *CRUDHandler[T, PT]) Import(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	if max := h.Env.App.ImportMaxBytes; max > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+	}
+	upload, err := c.FormFile(formFile)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			responses.RespondError(c, fmt.Sprintf("file exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		responses.RespondError(c, "a file is required: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mapping := map[string]string{}
+	if raw := c.PostForm(formMapping); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			responses.RespondError(c, "mapping must be a JSON object of column names: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := strings.ToLower(c.PostForm(formFormat))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(upload.Filename)), ".")
+	}
+	if format != formatCSV && format != formatXLSX {
+		responses.RespondError(c, "file must be csv or xlsx", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	file, err := upload.Open()
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	rows, err := readSheet(format, file, h.Env.App.ImportMaxRows)
+	if errors.Is(err, errTooManyRows) {
+		responses.RespondError(c, fmt.Sprintf("a file may hold at most %d rows", h.Env.App.ImportMaxRows), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		responses.RespondError(c, "could not read "+format+" file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(rows) == 0 {
+		responses.RespondError(c, "file has no header row", http.StatusBadRequest)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.Query(queryDryRun))
+	report := importReport[T]{DryRun: dryRun, Items: []T{}, Errors: []importRowError{}}
+	columns := h.importColumns(rows[0], mapping, &report)
+
+	var (
+		items   []PT
+		itemRow []int
+	)
+	for i, record := range rows[1:] {
+		if isBlankRow(record) {
+			continue
+		}
+		report.Rows++
+		item, err := h.decodeImportRow(columns, record, format)
+		if err != nil {
+			rowErr := importRowError{Row: i + 2, Error: err.Error()}
+			var invalid *components.ValidationError
+			if errors.As(err, &invalid) {
+				rowErr.Fields = invalid.Fields
+			}
+			report.Errors = append(report.Errors, rowErr)
+			continue
+		}
+		items = append(items, item)
+		itemRow = append(itemRow, i+2)
+	}
+
+	if dryRun {
+		for _, item := range items {
+			report.Items = append(report.Items, *item)
+		}
+		responses.RespondData(c, report, http.StatusOK)
+		return
+	}
+	if len(report.Unknown) > 0 || len(report.Errors) > 0 {
+		report.Message = "file is invalid; nothing was imported"
+		responses.RespondError(c, report, http.StatusUnprocessableEntity)
+		return
+	}
+
+	var failedCode int
+	err = db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		for i, item := range items {
+			if err := h.create(ctx, c, item); err != nil {
+				code, msg := tableErrorStatus(err)
+				failedCode = code
+				report.Errors = append(report.Errors, importRowError{Row: itemRow[i], Error: msg})
+				return err
+			}
+			report.Items = append(report.Items, *item)
+		}
+		return nil
+	})
+	if err != nil {
+		if failedCode == 0 {
+			responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		report.Message = "import was rolled back; nothing was imported"
+		report.Items = []T{}
+		responses.RespondError(c, report, failedCode)
+		return
+	}
+	responses.RespondData(c, report, http.StatusOK)
+}
+
+// importColumns works out which field each column of header fills, noting them in report.
+// A column takes the name mapping gives it, or keeps its own when mapping has none.
+func (h *CRUDHandler[T, PT]) importColumns(header []string, mapping map[string]string, report *importReport[T]) []importColumn {
+	fields := db.FieldsOf[T]()
+	writable := map[string]bool{}
+	for _, col := range fields.Writable() {
+		writable[col] = true
+	}
+
+	report.Columns, report.Ignored, report.Unknown = map[string]string{}, []string{}, []string{}
+	columns := make([]importColumn, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		target, mapped := mapping[name]
+		if !mapped {
+			target = name
+		}
+		field, key, _ := strings.Cut(target, ".")
+		switch {
+		case name == "":
+		case target == "" || (fields.Has(target) && !writable[target]):
+			report.Ignored = append(report.Ignored, name)
+		case writable[field] && (target == field || (key != "" && isObjectType(fields.FieldType(field)))):
+			columns[i] = importColumn{Field: field, Key: key}
+			report.Columns[target] = name
+		default:
+			report.Unknown = append(report.Unknown, name)
+		}
+	}
+	return columns
+}
+
+// decodeImportRow decodes and validates the record a row of cells of a format file describes.
+// Empty cells are left out and, in CSV files, the apostrophe export puts before formula
+// characters is removed.
+// String fields take a cell as it is; other cells are read as JSON when they can be, so numbers
+// and booleans keep their type, and as text otherwise.
+func (h *CRUDHandler[T, PT]) decodeImportRow(columns []importColumn, record []string, format string) (PT, error) {
+	fields := db.FieldsOf[T]()
+	values := map[string]any{}
+	for _, col := range fields.Writable() {
+		if isObjectType(fields.FieldType(col)) {
+			values[col] = map[string]any{}
+		}
+	}
+
+	for i, col := range columns {
+		if col.Field == "" || i >= len(record) {
+			continue
+		}
+		cell := strings.TrimSpace(record[i])
+		if format == formatCSV {
+			cell = unescapeCell(cell)
+		}
+		if cell == "" {
+			continue
+		}
+
+		t := fields.FieldType(col.Field)
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch {
+		case col.Key != "":
+			obj, ok := values[col.Field].(map[string]any)
+			if !ok {
+				obj = map[string]any{}
+				values[col.Field] = obj
+			}
+			obj[col.Key] = cellJSON(cell)
+		case t.Kind() == reflect.String:
+			values[col.Field] = cell
+		default:
+			values[col.Field] = cellJSON(cell)
+		}
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	item := h.New()
+	if err := decodeJSON(bytes.NewReader(b), item); err != nil {
+		var wrongType *json.UnmarshalTypeError
+		if errors.As(err, &wrongType) {
+			return nil, &components.ValidationError{Fields: []components.FieldError{
+				{Field: wrongType.Field, Message: "must be a " + wrongType.Type.Kind().String()},
+			}}
+		}
+		return nil, err
+	}
+	if err := components.Validate(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// unescapeCell undoes escapeCell, so CSV text exported with a leading formula character is
+// imported as it was
+func unescapeCell(cell string) string {
+	if strings.HasPrefix(cell, "'") && needsEscape(cell[1:]) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// cellJSON reads cell as a JSON value, or as a string when it is not one.
+// Spreadsheets write booleans in capitals, so TRUE and FALSE are booleans too.
+func cellJSON(cell string) any {
+	switch {
+	case strings.EqualFold(cell, "true"):
+		return true
+	case strings.EqualFold(cell, "false"):
+		return false
+	}
+	var v any
+	if err := json.Unmarshal([]byte(cell), &v); err != nil {
+		return cell
+	}
+	return v
+}
+
+// readSheet reads the rows of a CSV file, or of the first sheet of an XLSX file.
+// It fails with errTooManyRows once more than maxRows rows follow the header, unless maxRows is 0.
+func readSheet(format string, r io.Reader, maxRows int) ([][]string, error) {
+	var rows [][]string
+	add := func(row []string) error {
+		if maxRows > 0 && len(rows) > maxRows {
+			return errTooManyRows
+		}
+		rows = append(rows, row)
+		return nil
+	}
+
+	switch format {
+	case formatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		for {
+			row, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(rows) == 0 && len(row) > 0 {
+				row[0] = strings.TrimPrefix(row[0], "\ufeff")
+			}
+			if err := add(row); err != nil {
+				return nil, err
+			}
+		}
+	case formatXLSX:
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, nil
+		}
+		it, err := f.Rows(sheets[0])
+		if err != nil {
+			return nil, err
+		}
+		defer it.Close()
+		for it.Next() {
+			row, err := it.Columns()
+			if err != nil {
+				return nil, err
+			}
+			if err := add(row); err != nil {
+				return nil, err
+			}
+		}
+		if err := it.Error(); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// isBlankRow reports whether every cell of row is empty
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
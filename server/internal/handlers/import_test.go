@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRUDHandler_Import(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setup := func(t *testing.T) (*db.MemoryStore[testTraitModel], func(query, filename string, file []byte, form map[string]string) (int, importReport[testTraitModel])) {
+		env := &constants.EnvConfig{}
+		env.Server.ReadTimeout = 5 * time.Second
+		env.Server.WriteTimeout = 5 * time.Second
+		env.App.ImportMaxBytes = 1 << 20
+		env.App.ImportMaxRows = 3
+
+		mem := db.NewMemory()
+		store := db.NewMemoryStore[testTraitModel](mem, "test")
+		handler := NewCRUDHandler(mem, env, func() *testTraitModel { return &testTraitModel{} }, db.CRUDTable[testTraitModel](store))
+		router := gin.New()
+		handler.RegisterRoutes(router, "/items")
+
+		return store, func(query, filename string, file []byte, form map[string]string) (int, importReport[testTraitModel]) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			if file != nil {
+				part, err := mw.CreateFormFile(formFile, filename)
+				require.NoError(t, err)
+				_, _ = part.Write(file)
+			}
+			for k, v := range form {
+				require.NoError(t, mw.WriteField(k, v))
+			}
+			require.NoError(t, mw.Close())
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/items/import"+query, &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			router.ServeHTTP(w, req)
+
+			var resp struct {
+				Data  importReport[testTraitModel] `json:"data"`
+				Error json.RawMessage              `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			if w.Code != http.StatusOK {
+				_ = json.Unmarshal(resp.Error, &resp.Data)
+			}
+			return w.Code, resp.Data
+		}
+	}
+
+	t.Run("csv with mapping", func(t *testing.T) {
+		store, upload := setup(t)
+		file := "\ufeffid,Plant,count,traits.height,Colour,notes\n" +
+			"x,Plant A,2,1.5,red,skipped\n" +
+			",,,,,\n" +
+			"y,Plant B,,,TRUE,\n"
+
+		code, report := upload("", "plants.csv", []byte(file), map[string]string{
+			formMapping: `{"Plant": "name", "Colour": "traits.color", "notes": ""}`,
+		})
+
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, report.Rows)
+		assert.Equal(t, []string{"id", "notes"}, report.Ignored)
+		assert.Empty(t, report.Unknown)
+		assert.Equal(t, "Plant", report.Columns["name"])
+		require.Len(t, report.Items, 2)
+		assert.NotEqual(t, "x", report.Items[0].ID)
+		assert.Equal(t, map[string]any{"height": 1.5, "color": "red"}, report.Items[0].Traits)
+		assert.Equal(t, map[string]any{"color": true}, report.Items[1].Traits)
+
+		all, err := store.GetAll(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("xlsx round trip", func(t *testing.T) {
+		store, upload := setup(t)
+		f, err := writeXLSX([]string{"name", "count", "traits.height"}, [][]any{{"Plant A", 3, 12.5}})
+		require.NoError(t, err)
+		var file bytes.Buffer
+		require.NoError(t, f.Write(&file))
+
+		code, report := upload("", "plants.xlsx", file.Bytes(), nil)
+
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, report.Items, 1)
+		assert.Equal(t, 3, report.Items[0].Count)
+		all, _ := store.GetAll(context.Background())
+		assert.Len(t, all, 1)
+	})
+
+	t.Run("formulas round trip as text", func(t *testing.T) {
+		header, rows := flattenRecords([]testTraitModel{{Name: "=HYPERLINK(\"http://x\")", Traits: map[string]any{"color": "@red", "note": "'=raw"}}})
+		for _, format := range []string{formatCSV, formatXLSX} {
+			t.Run(format, func(t *testing.T) {
+				_, upload := setup(t)
+				var file bytes.Buffer
+				if format == formatCSV {
+					require.NoError(t, writeCSV(&file, header, rows))
+				} else {
+					f, err := writeXLSX(header, rows)
+					require.NoError(t, err)
+					require.NoError(t, f.Write(&file))
+				}
+
+				code, report := upload("", "plants."+format, file.Bytes(), nil)
+
+				require.Equal(t, http.StatusOK, code)
+				require.Len(t, report.Items, 1)
+				assert.Equal(t, `=HYPERLINK("http://x")`, report.Items[0].Name)
+				assert.Equal(t, map[string]any{"color": "@red", "note": "'=raw"}, report.Items[0].Traits)
+			})
+		}
+
+		_, upload := setup(t)
+		code, report := upload("", "plants.csv", []byte("name\n'-1\n"), nil)
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "-1", report.Items[0].Name, "csv cells typed with an apostrophe lose it")
+	})
+
+	t.Run("row errors import nothing", func(t *testing.T) {
+		store, upload := setup(t)
+		file := "name,count\nPlant A,1\n,2\nPlant C,many\n"
+
+		code, report := upload("", "plants.csv", []byte(file), nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+		require.Len(t, report.Errors, 2)
+		assert.Equal(t, 3, report.Errors[0].Row)
+		assert.Equal(t, "name", report.Errors[0].Fields[0].Field)
+		assert.Equal(t, 4, report.Errors[1].Row)
+		assert.Equal(t, "count", report.Errors[1].Fields[0].Field)
+		all, _ := store.GetAll(context.Background())
+		assert.Empty(t, all)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		store, upload := setup(t)
+		file := "name,colour\nPlant A,red\n,blue\n"
+
+		code, report := upload("?dry_run=true", "plants.csv", []byte(file), nil)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, []string{"colour"}, report.Unknown)
+		assert.Len(t, report.Items, 1)
+		assert.Len(t, report.Errors, 1)
+		all, _ := store.GetAll(context.Background())
+		assert.Empty(t, all)
+	})
+
+	t.Run("unknown columns import nothing", func(t *testing.T) {
+		_, upload := setup(t)
+
+		code, report := upload("", "plants.csv", []byte("name,colour\nPlant A,red\n"), nil)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+		assert.Equal(t, []string{"colour"}, report.Unknown)
+	})
+
+	t.Run("rejected uploads", func(t *testing.T) {
+		_, upload := setup(t)
+
+		code, _ := upload("", "", nil, nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = upload("", "plants.pdf", []byte("name\n"), nil)
+		assert.Equal(t, http.StatusUnsupportedMediaType, code)
+
+		code, _ = upload("", "plants", []byte("name\nPlant A\n"), map[string]string{formFormat: formatXLSX})
+		assert.Equal(t, http.StatusBadRequest, code, "not a workbook")
+
+		code, _ = upload("", "plants.csv", []byte("name\na\nb\nc\nd\n"), nil)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+
+		code, _ = upload("", "plants.csv", []byte("name\na\n"), map[string]string{formMapping: `["name"]`})
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
@@ -2,25 +2,54 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/openapi"
 	"github.com/kylep342/mendel/pkg/responses"
 )
 
+// Pinger reports whether a database is reachable; it is satisfied by *pgxpool.Pool and *db.Memory
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 type InternalHandler struct {
-	pool      *pgxpool.Pool
+	pool      Pinger
 	envConfig *constants.EnvConfig
+
+	// Schema is the version of the database schema, reported by the health check; nil for
+	// databases without one
+	Schema *Schema
+}
+
+// Schema is the version of the database schema against the version the server was built for
+type Schema struct {
+	// Version is the version of the last migration applied to the database, or -1 for none
+	Version int `json:"version"`
+	// Expected is the version of the newest migration built into the server
+	Expected int `json:"expected"`
+	// Dirty is true when the last migration failed part way
+	Dirty bool `json:"dirty"`
+	// ReadOnly is true when the server refuses writes because the schema is not Expected
+	ReadOnly bool `json:"read_only"`
+}
+
+// Compatible reports whether the stores of the server can use the schema
+func (s *Schema) Compatible() bool {
+	return !s.Dirty && s.Version == s.Expected
 }
 
 const (
-	healthDb   = "db"
-	healthHttp = "http"
+	healthDb     = "db"
+	healthHttp   = "http"
+	healthSchema = "schema"
 )
 
-func NewInternalHandler(pool *pgxpool.Pool, envConfig *constants.EnvConfig) *InternalHandler {
+func NewInternalHandler(pool Pinger, envConfig *constants.EnvConfig) *InternalHandler {
 	return &InternalHandler{
 		pool:      pool,
 		envConfig: envConfig,
@@ -33,6 +62,38 @@ func (h *InternalHandler) RegisterRoutes(g *gin.Engine, basePath string) {
 	rg.GET(constants.RouteEnv, h.EnvCheck)
 }
 
+// Describe documents the routes RegisterRoutes serves at basePath
+func (h *InternalHandler) Describe(doc *openapi.Document, basePath string) {
+	base := strings.TrimSuffix(basePath, "/")
+	health := &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			healthHttp:   {Type: "boolean"},
+			healthDb:     {Type: "boolean"},
+			healthSchema: doc.SchemaFor(Schema{}),
+		},
+	}
+
+	doc.AddOperation(http.MethodGet, base+constants.RouteHealth, openapi.Operation{
+		OperationID: "internal.healthcheck",
+		Summary:     "Report the health of each component",
+		Tags:        []string{"internal"},
+		Responses: map[string]openapi.Response{
+			"200": openapi.DataResponse("every component is healthy", health),
+			"500": openapi.ErrorResponse("a component is unhealthy"),
+		},
+	})
+	doc.AddOperation(http.MethodGet, base+constants.RouteEnv, openapi.Operation{
+		OperationID: "internal.env",
+		Summary:     "Expose the server configuration outside of production",
+		Tags:        []string{"internal"},
+		Responses: map[string]openapi.Response{
+			"200": openapi.DataResponse("the server configuration", doc.NamedSchemaFor("EnvConfig", h.envConfig)),
+			"404": openapi.ErrorResponse("running in production"),
+		},
+	})
+}
+
 func (h *InternalHandler) Healthcheck(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), h.envConfig.Server.ReadTimeout)
 
@@ -50,14 +111,39 @@ func (h *InternalHandler) Healthcheck(c *gin.Context) {
 		componentStats[healthDb] = true
 	}
 
+	health := map[string]any{}
+	for key, healthy := range componentStats {
+		health[key] = healthy
+	}
+	if h.Schema != nil {
+		health[healthSchema] = h.Schema
+	}
+
 	// Respond
 	for key := range componentStats {
 		if !componentStats[key] {
-			responses.RespondError(c, componentStats, http.StatusInternalServerError)
+			responses.RespondError(c, health, http.StatusInternalServerError)
+			return
 		}
 	}
 
-	responses.RespondData(c, componentStats, http.StatusOK)
+	responses.RespondData(c, health, http.StatusOK)
+}
+
+// readOnlyWrites are the routes ReadOnly lets write: logging in and out
+var readOnlyWrites = map[string]bool{
+	constants.RouteAuth + "/login":  true,
+	constants.RouteAuth + "/logout": true,
+}
+
+// ReadOnly is middleware refusing writes but to readOnlyWrites, for a server whose database
+// schema is not the version it was built for. See AuthHandler.ReadOnly for what those write.
+func (h *InternalHandler) ReadOnly(c *gin.Context) {
+	if isReadOnlyMethod(c.Request.Method) || readOnlyWrites[c.FullPath()] {
+		c.Next()
+		return
+	}
+	responses.RespondError(c, fmt.Sprintf("the server is read-only: the database schema is at version %d but the server expects version %d", h.Schema.Version, h.Schema.Expected), http.StatusServiceUnavailable)
 }
 
 // EnvConfig responds to a request to expose the internal server config
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pingerFunc adapts a function to Pinger
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+// newInternalTest serves the routes of an InternalHandler pinging with ping
+func newInternalTest(ping pingerFunc, schema *Schema) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	env := &constants.EnvConfig{}
+	env.Server.ReadTimeout = time.Second
+	h := NewInternalHandler(ping, env)
+	h.Schema = schema
+	router := gin.New()
+	if schema != nil && schema.ReadOnly {
+		router.Use(h.ReadOnly)
+	}
+	h.RegisterRoutes(router, constants.RouteIndex)
+	return router
+}
+
+func TestInternalHandler_Healthcheck(t *testing.T) {
+	healthy := func(ctx context.Context) error { return nil }
+	schema := &Schema{Version: 10, Expected: 10}
+
+	router := newInternalTest(healthy, schema)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, constants.RouteHealth, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data": {"http": true, "db": true, "schema": {"version": 10, "expected": 10, "dirty": false, "read_only": false}}}`, w.Body.String())
+
+	router = newInternalTest(func(ctx context.Context) error { return errors.New("down") }, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, constants.RouteHealth, nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	var body map[string]map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), "a single response is written: %s", w.Body.String())
+	assert.Equal(t, map[string]map[string]any{"error": {"http": true, "db": false}}, body)
+}
+
+func TestSchema_Compatible(t *testing.T) {
+	assert.True(t, (&Schema{Version: 3, Expected: 3}).Compatible())
+	assert.False(t, (&Schema{Version: 2, Expected: 3}).Compatible())
+	assert.False(t, (&Schema{Version: 4, Expected: 3}).Compatible())
+	assert.False(t, (&Schema{Version: -1, Expected: 3}).Compatible())
+	assert.False(t, (&Schema{Version: 3, Expected: 3, Dirty: true}).Compatible())
+}
+
+func TestInternalHandler_ReadOnly(t *testing.T) {
+	router := newInternalTest(func(ctx context.Context) error { return nil }, &Schema{Version: 9, Expected: 10, ReadOnly: true})
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.POST("/plant/", ok)
+	router.GET("/plant/", ok)
+	router.POST(constants.RouteAuth+"/login", ok)
+	router.POST(constants.RouteAuth+"/logout", ok)
+	router.POST(constants.RouteAuth+"/tokens", ok)
+
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/plant/", http.StatusNoContent},
+		{http.MethodPost, "/plant/", http.StatusServiceUnavailable},
+		{http.MethodPost, constants.RouteAuth + "/login", http.StatusNoContent},
+		{http.MethodPost, constants.RouteAuth + "/logout", http.StatusNoContent},
+		{http.MethodPost, constants.RouteAuth + "/tokens", http.StatusServiceUnavailable},
+		{http.MethodGet, constants.RouteHealth, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader("{}")))
+		assert.Equal(t, tc.want, w.Code, "%s %s", tc.method, tc.path)
+		if tc.want == http.StatusServiceUnavailable {
+			assert.Contains(t, w.Body.String(), "version 9")
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/components/app/auth"
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+const (
+	// OIDCFlowCookie names the cookie holding a sign in through the identity provider while it is away
+	OIDCFlowCookie = "mendel_oidc"
+
+	// oidcFlowTTL is how long a sign in may spend at the identity provider
+	oidcFlowTTL = 10 * time.Minute
+
+	// usernameAttempts bounds the numbered usernames tried for a new user whose name is taken
+	usernameAttempts = 100
+)
+
+// errReadOnlySignIn refuses a first sign in through the identity provider, which would create a user,
+// while the server is read-only
+var errReadOnlySignIn = errors.New("the server is read-only: users signing in for the first time cannot be created")
+
+// OIDCLogin responds by sending the browser to the identity provider to sign in.
+// The optional redirect query parameter is the path of the web app to return to afterwards, and
+// login_hint is passed on to the identity provider.
+//
+//	302: to the identity provider
+//	502: the identity provider cannot be discovered
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	flow, err := auth.NewOIDCFlow(webAppPath(c.Query("redirect")))
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	to, err := h.OIDC.AuthCodeURL(ctx, flow, c.Query("login_hint"))
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusBadGateway)
+		return
+	}
+	b, err := json.Marshal(flow)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.setFlowCookie(c, base64.RawURLEncoding.EncodeToString(b), int(oidcFlowTTL.Seconds()))
+	c.Redirect(http.StatusFound, to)
+}
+
+// OIDCCallback responds to the identity provider returning the browser by validating the sign in,
+// creating the user on their first, and starting a session before returning to the web app
+//
+//	302: to the web app, logged in
+//	401: the sign in was refused, expired, or was not started by this browser
+//	502: the identity provider cannot be reached
+//	503: a first sign in while the server is read-only
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	flow, err := h.flowFrom(c)
+	h.setFlowCookie(c, "", -1)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if reason := c.Query("error"); reason != "" {
+		if description := c.Query("error_description"); description != "" {
+			reason += ": " + description
+		}
+		responses.RespondError(c, "identity provider refused the sign in: "+reason, http.StatusUnauthorized)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(flow.State)) != 1 {
+		responses.RespondError(c, "state does not match the sign in this browser started", http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := h.OIDC.Exchange(ctx, flow, c.Query("code"))
+	switch {
+	case errors.Is(err, auth.ErrProviderUnavailable):
+		responses.RespondError(c, err.Error(), http.StatusBadGateway)
+		return
+	case errors.Is(err, auth.ErrSignIn):
+		responses.RespondError(c, err.Error(), http.StatusUnauthorized)
+		return
+	case err != nil:
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userID, err := h.identify(ctx, claims)
+	if errors.Is(err, errReadOnlySignIn) {
+		responses.RespondError(c, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	if _, err := h.enabledUser(ctx, userID); err != nil {
+		if errors.Is(err, errUnauthenticated) {
+			responses.RespondError(c, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		respondTableError(c, err)
+		return
+	}
+	if _, err := h.startSession(ctx, c, userID); err != nil {
+		respondTableError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, strings.TrimSuffix(h.Env.App.WebHost, "/")+flow.Redirect)
+}
+
+// identify returns the id of the user claims are about, creating the user and linking them to
+// the identity on their first sign in. It returns errReadOnlySignIn for a first sign in while ReadOnly.
+func (h *AuthHandler) identify(ctx context.Context, claims auth.Claims) (string, error) {
+	var userID string
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		identity, err := h.Stores.Identities.GetBySubject(ctx, claims.Issuer, claims.Subject)
+		if err == nil {
+			userID = identity.UserID
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if h.ReadOnly {
+			return errReadOnlySignIn
+		}
+
+		username, err := h.freeUsername(ctx, claims)
+		if err != nil {
+			return err
+		}
+		u := user.User{Username: username, Enabled: true}
+		if err := h.Users.Create(ctx, &u); err != nil {
+			return err
+		}
+		userID = u.ID
+		return h.Stores.Identities.Create(ctx, &auth.Identity{UserID: u.ID, Issuer: claims.Issuer, Subject: claims.Subject})
+	})
+	return userID, err
+}
+
+// freeUsername returns a username no user has for the user claims are about: the first of the
+// claim OIDC.UsernameClaim names, preferred_username, the email's local part or "user" that makes a
+// valid username, numbered when taken
+func (h *AuthHandler) freeUsername(ctx context.Context, claims auth.Claims) (string, error) {
+	configured, _ := claims.Raw[h.Env.OIDC.UsernameClaim].(string)
+	local, _, _ := strings.Cut(claims.Email, "@")
+	base := "user"
+	for _, name := range []string{configured, claims.PreferredUsername, local} {
+		if name = user.UsernameFrom(name); name != "" {
+			base = name
+			break
+		}
+	}
+
+	for i := 1; i <= usernameAttempts; i++ {
+		name := base
+		if i > 1 {
+			suffix := fmt.Sprintf("-%d", i)
+			name = base[:min(len(base), user.UsernameMaxLength-len(suffix))] + suffix
+		}
+		_, err := h.Users.GetByUsername(ctx, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%w: usernames %s to %s-%d are taken", db.ErrDuplicate, base, base, usernameAttempts)
+}
+
+// flowFrom reads the sign in the flow cookie holds
+func (h *AuthHandler) flowFrom(c *gin.Context) (auth.OIDCFlow, error) {
+	var flow auth.OIDCFlow
+	value, err := c.Cookie(OIDCFlowCookie)
+	if err != nil || value == "" {
+		return flow, errors.New("no sign in was started by this browser, or it took too long")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(b, &flow)
+	}
+	if err != nil || flow.State == "" {
+		return auth.OIDCFlow{}, errors.New("malformed " + OIDCFlowCookie + " cookie")
+	}
+	return flow, nil
+}
+
+// setFlowCookie sets the flow cookie, scoped to the OIDC routes, to value for maxAge seconds, or
+// clears it when maxAge is negative. It is Lax so the identity provider's redirect carries it.
+func (h *AuthHandler) setFlowCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OIDCFlowCookie, value, maxAge, path.Dir(c.FullPath()), "", h.Env.Auth.CookieSecure, true)
+}
+
+// webAppPath returns redirect if it is a path of the web app, or its root, so a sign in can only
+// return to the web app
+func webAppPath(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.Contains(redirect, `\`) {
+		return "/"
+	}
+	return redirect
+}
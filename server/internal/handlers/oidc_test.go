@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kylep342/mendel/internal/components/app/auth"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/pkg/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oidcTest is an authTest signing in through a mock identity provider
+type oidcTest struct {
+	*authTest
+	idp    *oidctest.Provider
+	server *httptest.Server
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	idp, err := oidctest.New("mendel", "client secret")
+	require.NoError(t, err)
+	server := httptest.NewServer(idp)
+	t.Cleanup(server.Close)
+	idp.Issuer = server.URL
+
+	a := newAuthTest(t, func(env *constants.EnvConfig) {
+		env.App.WebHost = "http://web.example"
+		env.OIDC.Issuer = server.URL
+		env.OIDC.ClientID = "mendel"
+		env.OIDC.ClientSecret = "client secret"
+		env.OIDC.RedirectURL = "http://mendel.example/auth/oidc/callback"
+		env.OIDC.Scopes = []string{"profile", "email"}
+		env.OIDC.UsernameClaim = "preferred_username"
+	})
+	return &oidcTest{authTest: a, idp: idp, server: server}
+}
+
+// start begins signing in as login, returning the flow cookie and where the identity provider
+// sends the browser back to
+func (o *oidcTest) start(login, redirect string) (*http.Cookie, *url.URL) {
+	w := o.do(http.MethodGet, "/auth/oidc/login?"+url.Values{"redirect": {redirect}, "login_hint": {login}}.Encode(), "", "")
+	require.Equal(o.t, http.StatusFound, w.Code, w.Body.String())
+	flow := o.cookie(w, OIDCFlowCookie)
+	assert.Equal(o.t, "/auth/oidc", flow.Path)
+
+	authorize, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(o.t, err)
+	assert.Equal(o.t, "S256", authorize.Query().Get("code_challenge_method"))
+	assert.NotEmpty(o.t, authorize.Query().Get("nonce"))
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authorize.String())
+	require.NoError(o.t, err)
+	resp.Body.Close()
+	require.Equal(o.t, http.StatusFound, resp.StatusCode)
+	back, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(o.t, err)
+	return flow, back
+}
+
+// callback returns to the server from the identity provider with the flow cookie, if any
+func (o *oidcTest) callback(flow *http.Cookie, back *url.URL) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, back.RequestURI(), nil)
+	if flow != nil {
+		req.AddCookie(flow)
+	}
+	o.router.ServeHTTP(w, req)
+	return w
+}
+
+// signIn signs in as login, returning to redirect, and returns the session cookie's secret
+func (o *oidcTest) signIn(login, redirect string) (string, *httptest.ResponseRecorder) {
+	w := o.callback(o.start(login, redirect))
+	require.Equal(o.t, http.StatusFound, w.Code, w.Body.String())
+	return o.cookie(w, SessionCookie).Value, w
+}
+
+// me returns who session is logged in as
+func (o *oidcTest) me(session string) Principal {
+	var me Principal
+	o.data(o.do(http.MethodGet, "/auth/me", "", session), &me)
+	return me
+}
+
+func TestAuthHandler_OIDC(t *testing.T) {
+	o := newOIDCTest(t)
+
+	session, w := o.signIn("alice", "/plants?page=2")
+	assert.Equal(t, "http://web.example/plants?page=2", w.Header().Get("Location"))
+	assert.Equal(t, -1, o.cookie(w, OIDCFlowCookie).MaxAge, "the flow cookie is cleared")
+	alice := o.me(session).User
+	assert.Equal(t, "alice", alice.Username)
+	assert.True(t, alice.Enabled)
+	assert.NotNil(t, alice.LastLogin)
+	assert.Equal(t, http.StatusOK, o.do(http.MethodGet, "/items/", "", session).Code)
+
+	again, _ := o.signIn("alice", "/")
+	assert.Equal(t, alice.ID, o.me(again).User.ID, "later sign ins find the same user")
+	users, err := o.users.GetAll(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, users, 2, "the bootstrap user and alice")
+
+	admin, _ := o.signIn("admin", "/")
+	assert.Equal(t, "admin-2", o.me(admin).User.Username, "identities are never linked to existing users by name")
+
+	assert.Equal(t, http.StatusUnauthorized, o.do(http.MethodPost, "/auth/login", `{"username": "alice", "password": "`+testPassword+`"}`, "").Code,
+		"users created by single sign-on have no password")
+}
+
+func TestAuthHandler_OIDCUsername(t *testing.T) {
+	o := newOIDCTest(t)
+	o.idp.Claims = func(login string) map[string]any {
+		return map[string]any{"email": login + "@example.com"}
+	}
+
+	session, _ := o.signIn("jane.doe", "/")
+	assert.Equal(t, "jane.doe", o.me(session).User.Username, "the email's local part is used without preferred_username")
+
+	o.idp.Claims = func(string) map[string]any { return map[string]any{"preferred_username": "Jane Doe"} }
+	session, _ = o.signIn("jane", "/")
+	assert.Equal(t, "Jane-Doe", o.me(session).User.Username)
+
+	o.idp.Claims = func(string) map[string]any { return map[string]any{} }
+	session, _ = o.signIn("nameless", "/")
+	assert.Equal(t, "user", o.me(session).User.Username)
+}
+
+func TestAuthHandler_OIDCReadOnly(t *testing.T) {
+	o := newOIDCTest(t)
+	session, _ := o.signIn("alice", "/")
+	alice := o.me(session).User
+	o.readOnly()
+
+	again, _ := o.signIn("alice", "/")
+	assert.Equal(t, alice.LastLogin, o.me(again).User.LastLogin, "logins are not recorded")
+
+	w := o.callback(o.start("bob", "/"))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	users, err := o.users.GetAll(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, users, 2, "no user is created")
+}
+
+func TestAuthHandler_OIDCRefused(t *testing.T) {
+	o := newOIDCTest(t)
+
+	_, w := o.signIn("mallory", "//evil.example/")
+	assert.Equal(t, "http://web.example/", w.Header().Get("Location"), "only paths of the web app are returned to")
+
+	flow, back := o.start("bob", "/")
+	assert.Equal(t, http.StatusUnauthorized, o.callback(nil, back).Code, "the browser that started the sign in must finish it")
+	forged := *back
+	q := forged.Query()
+	q.Set("state", "forged")
+	forged.RawQuery = q.Encode()
+	assert.Equal(t, http.StatusUnauthorized, o.callback(flow, &forged).Code)
+	denied := *back
+	denied.RawQuery = url.Values{"state": {q.Get("state")}, "error": {"access_denied"}}.Encode()
+	assert.Equal(t, http.StatusUnauthorized, o.callback(flow, &denied).Code)
+
+	flow, back = o.start("bob", "/")
+	var f auth.OIDCFlow
+	b, err := base64.RawURLEncoding.DecodeString(flow.Value)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &f))
+	f.Verifier = "a verifier that is not the one challenged"
+	b, _ = json.Marshal(f)
+	w = o.callback(&http.Cookie{Name: OIDCFlowCookie, Value: base64.RawURLEncoding.EncodeToString(b)}, back)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "PKCE: the code is only redeemed with the verifier")
+	assert.Contains(t, w.Body.String(), "code_verifier")
+
+	flow, back = o.start("bob", "/")
+	assert.Equal(t, http.StatusFound, o.callback(flow, back).Code)
+	assert.Equal(t, http.StatusUnauthorized, o.callback(flow, back).Code, "codes are redeemed once")
+
+	bob, err := o.users.GetByUsername(context.Background(), "bob")
+	require.NoError(t, err)
+	bob.Enabled = false
+	require.NoError(t, o.users.Update(context.Background(), &bob))
+	assert.Equal(t, http.StatusUnauthorized, o.callback(o.start("bob", "/")).Code)
+
+	o.server.Close()
+	o.handler.OIDC = auth.NewOIDC(o.server.URL, "mendel", "", "http://mendel.example/auth/oidc/callback", nil)
+	assert.Equal(t, http.StatusBadGateway, o.do(http.MethodGet, "/auth/oidc/login", "", "").Code)
+}
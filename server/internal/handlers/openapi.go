@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/components/app/audit"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/openapi"
+)
+
+// OpenAPIHandler serves the API's OpenAPI document and a page to browse it
+type OpenAPIHandler struct {
+	doc *openapi.Document
+}
+
+func NewOpenAPIHandler(doc *openapi.Document) *OpenAPIHandler {
+	return &OpenAPIHandler{doc: doc}
+}
+
+func (h *OpenAPIHandler) RegisterRoutes(g *gin.Engine, basePath string) {
+	rg := g.Group(basePath)
+	rg.GET(constants.RouteOpenAPI, h.Spec)
+	rg.GET(constants.RouteDocs, h.Docs)
+	rg.StaticFS(constants.RouteDocsAssets, http.FS(openapi.DocsAssets))
+}
+
+// Spec responds with the OpenAPI document
+func (h *OpenAPIHandler) Spec(c *gin.Context) {
+	c.JSON(http.StatusOK, h.doc)
+}
+
+// docsPolicy only lets the docs page load scripts, styles and the document from the API itself;
+// Swagger UI needs its inline styles, the page's inline script and data: images
+const docsPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:"
+
+// Docs responds with an HTML page rendering the OpenAPI document
+func (h *OpenAPIHandler) Docs(c *gin.Context) {
+	c.Header("Content-Security-Policy", docsPolicy)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage(constants.RouteOpenAPI, constants.RouteDocsAssets))
+}
+
+// Describe documents the routes RegisterRoutes serves at basePath
+func (h *CRUDHandler[T, PT]) Describe(doc *openapi.Document, basePath string) {
+	tag := strings.TrimPrefix(basePath, "/")
+	model := doc.SchemaFor(h.New())
+	list := &openapi.Schema{Type: "array", Items: model}
+	idParam := []openapi.Parameter{openapi.PathParam("id", "ID of the record")}
+	op := func(name, summary string) openapi.Operation {
+		return openapi.Operation{
+			OperationID: tag + "." + name,
+			Summary:     summary,
+			Tags:        []string{tag},
+			Responses:   map[string]openapi.Response{"500": openapi.ErrorResponse("unexpected error")},
+		}
+	}
+	invalid := map[string]openapi.Response{
+		"400": openapi.ErrorResponse("malformed body or unknown fields"),
+		"413": openapi.ErrorResponse("body too large"),
+		"422": openapi.ErrorResponse("one or more fields are invalid"),
+	}
+	notFound := openapi.ErrorResponse("not found")
+	_, history := h.Table.(db.HistoryTable[T])
+
+	spreadsheet := &openapi.Schema{Type: "string", Format: "binary"}
+	getAll := op("getAll", "List all records")
+	getAll.Parameters = []openapi.Parameter{
+		openapi.QueryParam(queryFormat, "format of the response, overriding the Accept header",
+			&openapi.Schema{Type: "string", Enum: []string{formatJSON, formatNDJSON, formatCSV, formatXLSX}}),
+	}
+	getAll.Responses["200"] = openapi.DataResponse("all records", list)
+	getAll.Responses["200"].Content[mimeCSV] = openapi.MediaType{Schema: &openapi.Schema{Type: "string"}}
+	getAll.Responses["200"].Content[mimeXLSX] = openapi.MediaType{Schema: spreadsheet}
+	getAll.Responses["400"] = openapi.ErrorResponse("unknown format")
+	if history {
+		getAll.Parameters = append(getAll.Parameters, asOfParam("every record"))
+		getAll.Responses["400"] = openapi.ErrorResponse("unknown format or invalid as_of")
+	}
+	if _, ok := h.Table.(db.StreamTable[T]); ok {
+		getAll.Summary += "; send Accept: " + mimeNDJSON + " to stream them one per line"
+		getAll.Responses["200"].Content[mimeNDJSON] = openapi.MediaType{Schema: model}
+	}
+	doc.AddOperation(http.MethodGet, basePath+"/", getAll)
+
+	getByID := op("getByID", "Get a record")
+	getByID.Parameters = idParam
+	getByID.Responses["200"] = openapi.DataResponse("the record", model)
+	getByID.Responses["404"] = notFound
+	if history {
+		getByID.Parameters = append(idParam, asOfParam("the record"))
+		getByID.Responses["400"] = openapi.ErrorResponse("invalid as_of")
+	}
+	doc.AddOperation(http.MethodGet, basePath+"/:id", getByID)
+
+	create := op("create", "Create a record")
+	create.RequestBody = openapi.JSONBody(model)
+	create.Responses["200"] = openapi.DataResponse("the created record", model)
+	create.Responses["409"] = openapi.ErrorResponse("a record with the same unique values exists")
+	for code, resp := range invalid {
+		create.Responses[code] = resp
+	}
+	doc.AddOperation(http.MethodPost, basePath+"/", create)
+
+	update := op("update", "Replace a record")
+	update.Parameters = idParam
+	update.RequestBody = openapi.JSONBody(model)
+	update.Responses["200"] = openapi.DataResponse("the updated record", model)
+	update.Responses["404"] = notFound
+	update.Responses["409"] = openapi.ErrorResponse("a record with the same unique values exists")
+	for code, resp := range invalid {
+		update.Responses[code] = resp
+	}
+	doc.AddOperation(http.MethodPut, basePath+"/:id", update)
+
+	del := op("delete", "Delete a record")
+	del.Parameters = idParam
+	del.Responses["200"] = openapi.DataResponse("ID of the deleted record", &openapi.Schema{Type: "string"})
+	del.Responses["404"] = notFound
+
+	if _, ok := h.Table.(db.ImpactTable); ok {
+		impact := doc.NamedSchemaFor("DeleteImpact", deleteImpact{})
+		del.Parameters = append(del.Parameters,
+			openapi.QueryParam(queryDryRun, "respond with what the delete would affect instead of deleting", &openapi.Schema{Type: "boolean"}),
+			openapi.QueryParam(queryConfirm, "confirmation_token from the impact of a large delete", &openapi.Schema{Type: "string"}),
+		)
+		del.Responses["428"] = openapi.ErrorResponse("the delete affects too many records and must be confirmed")
+
+		getImpact := op("getImpact", "Preview what deleting a record would affect")
+		getImpact.Parameters = idParam
+		getImpact.Responses["200"] = openapi.DataResponse("records affected by the delete", impact)
+		getImpact.Responses["404"] = notFound
+		doc.AddOperation(http.MethodGet, basePath+"/:id/impact", getImpact)
+	}
+	doc.AddOperation(http.MethodDelete, basePath+"/:id", del)
+
+	if _, ok := h.Table.(db.SoftDeleteTable[T]); ok {
+		getTrash := op("getTrash", "List deleted records")
+		getTrash.Responses["200"] = openapi.DataResponse("deleted records", list)
+		doc.AddOperation(http.MethodGet, basePath+"/trash", getTrash)
+
+		restore := op("restore", "Restore a deleted record")
+		restore.Parameters = idParam
+		restore.Responses["200"] = openapi.DataResponse("ID of the restored record", &openapi.Schema{Type: "string"})
+		restore.Responses["404"] = notFound
+		restore.Responses["409"] = openapi.ErrorResponse("the record's parent is deleted")
+		doc.AddOperation(http.MethodPost, basePath+"/:id/restore", restore)
+	}
+
+	getBatch := op("getBatch", "Get many records by ID")
+	getBatch.Parameters = []openapi.Parameter{
+		openapi.QueryParam(queryIDs, "comma separated IDs of the records", &openapi.Schema{Type: "string"}),
+	}
+	getBatch.Parameters[0].Required = true
+	getBatch.Responses["200"] = openapi.DataResponse("the records found and the IDs that were not",
+		doc.NamedSchemaFor(path.Base(model.Ref)+"BulkRead", bulkRead[T]{}))
+	getBatch.Responses["400"] = openapi.ErrorResponse("missing, invalid or too many IDs")
+	doc.AddOperation(http.MethodGet, basePath+"/batch", getBatch)
+
+	batch := op("batch", "Create, update and delete many records at once, all or nothing")
+	batch.RequestBody = openapi.JSONBody(doc.NamedSchemaFor("BatchRequest", batchRequest{}))
+	batch.Responses["200"] = openapi.DataResponse("the outcome of each operation, in order",
+		&openapi.Schema{Type: "array", Items: doc.NamedSchemaFor("BatchResult", batchResult{})})
+	batch.Responses["400"] = openapi.ErrorResponse("malformed body, unknown fields or no operations")
+	batch.Responses["404"] = openapi.ErrorResponse("an operation's record was not found; nothing was applied")
+	batch.Responses["409"] = openapi.ErrorResponse("an operation conflicted with a deleted parent; nothing was applied")
+	batch.Responses["413"] = openapi.ErrorResponse("body too large or too many operations")
+	batch.Responses["422"] = openapi.ErrorResponse("one or more operations are invalid; nothing was applied")
+	batch.Responses["428"] = openapi.ErrorResponse("a delete affects too many records and must be confirmed; nothing was applied")
+	doc.AddOperation(http.MethodPost, basePath+"/batch", batch)
+
+	imp := op("import", "Create a record per row of a CSV or XLSX file, all or nothing")
+	imp.Parameters = []openapi.Parameter{
+		openapi.QueryParam(queryDryRun, "respond with what would be imported instead of importing", &openapi.Schema{Type: "boolean"}),
+	}
+	imp.RequestBody = &openapi.RequestBody{
+		Required: true,
+		Content: map[string]openapi.MediaType{"multipart/form-data": {Schema: &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				formFile:    spreadsheet,
+				formFormat:  {Type: "string", Enum: []string{formatCSV, formatXLSX}, Description: "format of the file when its name does not tell"},
+				formMapping: {Type: "string", Description: `JSON object renaming the file's columns to fields, or to "" to skip them`},
+			},
+			Required: []string{formFile},
+		}}},
+	}
+	imp.Responses["200"] = openapi.DataResponse("the records imported, or that would be on a dry run, and any row errors",
+		doc.NamedSchemaFor(path.Base(model.Ref)+"ImportReport", importReport[T]{}))
+	imp.Responses["400"] = openapi.ErrorResponse("no file, or a file or mapping that cannot be read")
+	imp.Responses["413"] = openapi.ErrorResponse("file too large or with too many rows")
+	imp.Responses["415"] = openapi.ErrorResponse("file is not csv or xlsx")
+	imp.Responses["422"] = openapi.ErrorResponse("unknown columns or invalid rows; nothing was imported")
+	doc.AddOperation(http.MethodPost, basePath+"/import", imp)
+
+	if _, ok := h.Table.(db.LineageTable[T]); ok {
+		lineage := op("getLineage", "Get a record and all of its ancestors")
+		lineage.Parameters = idParam
+		lineage.Responses["200"] = openapi.DataResponse("the record and its ancestors", list)
+		lineage.Responses["404"] = notFound
+		if _, ok := h.Table.(db.LineageHistoryTable[T]); ok {
+			lineage.Parameters = append(idParam, asOfParam("the record and its ancestors, each"))
+			lineage.Responses["400"] = openapi.ErrorResponse("invalid as_of")
+		}
+		doc.AddOperation(http.MethodGet, basePath+"/:id/lineage", lineage)
+	}
+
+	if h.Audit != nil {
+		history := op("getHistory", "Get the audit entries of a record, newest first")
+		history.Parameters = append(idParam, auditParams(queryComponent, queryRecordID)...)
+		history.Responses["200"] = openapi.DataResponse("the record's audit entries",
+			&openapi.Schema{Type: "array", Items: doc.SchemaFor(&audit.Entry{})})
+		history.Responses["400"] = openapi.ErrorResponse("an invalid ID or query parameter")
+		history.Responses["403"] = openapi.ErrorResponse("your role does not allow reading the audit log")
+		doc.AddOperation(http.MethodGet, basePath+"/:id/history", history)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIHandler_Docs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewOpenAPIHandler(openapi.NewDocument("test", "1.0.0")).RegisterRoutes(router, constants.RouteIndex)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, constants.RouteDocs, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), "default-src 'self'")
+	assert.NotContains(t, w.Body.String(), "https://", "the page loads nothing from other origins")
+	assert.Contains(t, w.Body.String(), `src="`+constants.RouteDocsAssets+`/swagger-ui-bundle.js"`)
+	assert.Contains(t, w.Body.String(), `url: "`+constants.RouteOpenAPI+`"`)
+
+	for _, name := range []string{"swagger-ui-bundle.js", "swagger-ui.css"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, constants.RouteDocsAssets+"/"+name, nil))
+		assert.Equal(t, http.StatusOK, w.Code, name)
+		assert.NotZero(t, w.Body.Len(), name)
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader names the header identifying a request, which every response carries
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key RequestID stores the request's ID under
+const requestIDKey = "request_id"
+
+// requestIDPattern is what a request ID chosen by the client, or a proxy in front of the server,
+// must look like to be kept
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID is middleware identifying each request by the X-Request-ID header it arrives with,
+// if it is at most 128 letters, digits and ._:- characters, or a new UUID otherwise
+func RequestID(c *gin.Context) {
+	id := c.GetHeader(RequestIDHeader)
+	if !requestIDPattern.MatchString(id) {
+		id = uuid.NewString()
+	}
+	c.Set(requestIDKey, id)
+	c.Header(RequestIDHeader, id)
+	c.Next()
+}
+
+// RequestIDFrom returns the ID RequestID gave the request, or "" if it is not behind RequestID
+func RequestIDFrom(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/components"
+	"github.com/kylep342/mendel/internal/components/app/share"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/openapi"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+// ShareSource reads the records share links expose from one component
+//
+//	Fields: the JSON names of the fields of its records, which shares may choose among
+//	Lineage: whether shares may include the seed and pollen ancestors of a record
+type ShareSource struct {
+	Fields  []string
+	Lineage bool
+
+	read func(ctx context.Context, id string, lineage bool) ([]any, error)
+}
+
+// ShareSource returns how share links read records from Table
+func (h *CRUDHandler[T, PT]) ShareSource() ShareSource {
+	fields, err := jsonFieldsOf(h.New())
+	if err != nil {
+		panic("handlers: cannot share " + h.Component + ": " + err.Error())
+	}
+	lineageTable, hasLineage := h.Table.(db.LineageTable[T])
+	return ShareSource{
+		Fields:  fields,
+		Lineage: hasLineage,
+		read: func(ctx context.Context, id string, lineage bool) ([]any, error) {
+			// a shared record must be live, though its ancestors may be in the trash
+			item, err := h.Table.GetByID(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if !lineage {
+				return []any{item}, nil
+			}
+			items, err := lineageTable.GetLineage(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			records := make([]any, len(items))
+			for i, item := range items {
+				records[i] = item
+			}
+			return records, nil
+		},
+	}
+}
+
+// jsonFieldsOf returns the JSON names of the fields of record, sorted
+func jsonFieldsOf(record any) ([]string, error) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ShareHandler manages the share links of the request's workspace, and serves the records they
+// expose to anyone holding their token
+//
+//	Env: for config values
+//	Table: the share links
+//	Tx: runs each write request as a single unit of work; writes are not grouped when nil
+//	Signer: signs and verifies the tokens of share links
+//	Sources: the components records may be shared from, named by their tables
+//	Access: decides who may manage share links; anyone may when nil
+type ShareHandler struct {
+	Env     *constants.EnvConfig
+	Table   db.CRUDTable[share.Share]
+	Tx      db.Transactor
+	Signer  *share.Signer
+	Sources map[string]ShareSource
+	Access  Authorizer
+}
+
+// NewShareHandler is the constructor for ShareHandler
+func NewShareHandler(tx db.Transactor, env *constants.EnvConfig, table db.CRUDTable[share.Share], signer *share.Signer) *ShareHandler {
+	return &ShareHandler{
+		Env:     env,
+		Table:   table,
+		Tx:      tx,
+		Signer:  signer,
+		Sources: map[string]ShareSource{},
+	}
+}
+
+// shareRequest is the body of a request for a new share link.
+// Fields are the JSON names of the fields shown; every field when empty.
+type shareRequest struct {
+	Component string    `json:"component" validate:"required"`
+	RecordID  string    `json:"record_id" validate:"required,uuid"`
+	Lineage   bool      `json:"lineage"`
+	Fields    []string  `json:"fields"`
+	ExpiresAt time.Time `json:"expires_at" validate:"required"`
+}
+
+// newShare is a share link as it is created: the only time its token is shown
+type newShare struct {
+	share.Share
+	Token string `json:"token"`
+}
+
+// sharedRecords are the records a share link exposes
+type sharedRecords struct {
+	Component string                       `json:"component"`
+	RecordID  string                       `json:"record_id"`
+	Lineage   bool                         `json:"lineage"`
+	ExpiresAt time.Time                    `json:"expires_at"`
+	Records   []map[string]json.RawMessage `json:"records"`
+}
+
+// RegisterRoutes connects the routes managing share links to an HTTP server.
+// Its routes must be behind WorkspaceHandler.Scope.
+func (h *ShareHandler) RegisterRoutes(g gin.IRouter, basePath string) {
+	rg := g.Group(basePath)
+	rg.GET("/", h.GetAll)
+	rg.POST("/", h.Create)
+	rg.DELETE("/:id", h.Revoke)
+}
+
+// RegisterPublicRoutes connects the route serving shared records to an HTTP server.
+// Its route must not be behind authentication: the token is the only credential.
+func (h *ShareHandler) RegisterPublicRoutes(g gin.IRouter, basePath string) {
+	rg := g.Group(basePath)
+	rg.GET("/:token", h.GetShared)
+}
+
+// GetAll responds to a request with the share links of the request's workspace, revoked and
+// expired ones included
+//
+//	403: Access does not allow reading share links
+func (h *ShareHandler) GetAll(c *gin.Context) {
+	if !authorized(c, h.Access, constants.TableShare, workspace.ActionRead) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	shares, err := h.Table.GetAll(ctx)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	responses.RespondData(c, shares, http.StatusOK)
+}
+
+// Create responds to a request for a new share link of a record of the request's workspace.
+// The response holds the link's token, which cannot be retrieved again.
+//
+//	403: Access does not allow sharing, or reading the record's component
+//	404: the record does not exist
+//	422: an unknown component or field, lineage of a component without one, or an expiry that
+//		is past or further away than Auth.ShareMaxTTL
+func (h *ShareHandler) Create(c *gin.Context) {
+	if !authorized(c, h.Access, constants.TableShare, workspace.ActionCreate) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	var req shareRequest
+	if !bindRequest(c, h.Env, &req) {
+		return
+	}
+	source, ok := h.Sources[req.Component]
+	if invalid := h.checkRequest(req, source, ok); invalid != nil {
+		responses.RespondError(c, invalid, http.StatusUnprocessableEntity)
+		return
+	}
+	if !authorized(c, h.Access, req.Component, workspace.ActionRead) {
+		return
+	}
+
+	s := share.Share{
+		Component: req.Component,
+		RecordID:  req.RecordID,
+		Lineage:   req.Lineage,
+		Fields:    req.Fields,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if s.Fields == nil {
+		s.Fields = []string{}
+	}
+	if p, ok := PrincipalFrom(c); ok {
+		s.CreatedBy = &p.User.ID
+	}
+	var token string
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		if _, err := source.read(ctx, req.RecordID, false); err != nil {
+			return err
+		}
+		if err := h.Table.Create(ctx, &s); err != nil {
+			return err
+		}
+		var err error
+		token, err = h.Signer.Sign(&s)
+		return err
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, newShare{Share: s, Token: token}, http.StatusOK)
+}
+
+// checkRequest returns what is invalid about req for the component source reads, which ok
+// reports is shareable, or nil if nothing is
+func (h *ShareHandler) checkRequest(req shareRequest, source ShareSource, ok bool) *components.ValidationError {
+	var invalid []components.FieldError
+	if !ok {
+		shareable := make([]string, 0, len(h.Sources))
+		for name := range h.Sources {
+			shareable = append(shareable, name)
+		}
+		sort.Strings(shareable)
+		invalid = append(invalid, components.FieldError{Field: "component", Message: "must be one of " + strings.Join(shareable, ", ")})
+	}
+	if ok && req.Lineage && !source.Lineage {
+		invalid = append(invalid, components.FieldError{Field: "lineage", Message: req.Component + " records have no lineage"})
+	}
+	for _, f := range req.Fields {
+		if ok && !slices.Contains(source.Fields, f) {
+			invalid = append(invalid, components.FieldError{Field: "fields", Message: "unknown field " + f})
+		}
+	}
+	now := time.Now()
+	switch {
+	case !req.ExpiresAt.After(now):
+		invalid = append(invalid, components.FieldError{Field: "expires_at", Message: "must be in the future"})
+	case h.Env.Auth.ShareMaxTTL > 0 && req.ExpiresAt.After(now.Add(h.Env.Auth.ShareMaxTTL)):
+		invalid = append(invalid, components.FieldError{Field: "expires_at", Message: "must be within " + h.Env.Auth.ShareMaxTTL.String()})
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	return &components.ValidationError{Fields: invalid}
+}
+
+// Revoke responds to a request to revoke a share link of the request's workspace, so its token
+// stops working. Revoking a revoked link changes nothing.
+//
+//	403: Access does not allow revoking share links
+//	404: the share link does not exist
+func (h *ShareHandler) Revoke(c *gin.Context) {
+	if !authorized(c, h.Access, constants.TableShare, workspace.ActionDelete) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	id := c.Param("id")
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		s, err := h.Table.GetByID(ctx, id)
+		if err != nil || s.RevokedAt != nil {
+			return err
+		}
+		now := time.Now()
+		s.RevokedAt = &now
+		return h.Table.Update(ctx, &s)
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, id, http.StatusOK)
+}
+
+// GetShared responds to anyone holding the token of a share link with the records it exposes,
+// limited to its fields. A token that is forged, unknown, revoked or expired, or whose record has
+// since been deleted, finds nothing.
+//
+//	404: nothing is shared by the token
+func (h *ShareHandler) GetShared(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	// shared records change and links are revoked, so responses are never kept
+	c.Header("Cache-Control", "no-store")
+	id, workspaceID, err := h.Signer.Verify(c.Param("token"))
+	if err != nil {
+		responses.RespondError(c, "not found", http.StatusNotFound)
+		return
+	}
+	ctx = db.WithWorkspace(ctx, workspaceID)
+
+	s, err := h.Table.GetByID(ctx, id)
+	source, ok := h.Sources[s.Component]
+	if err == nil && (!ok || !s.Active(time.Now())) {
+		err = sql.ErrNoRows
+	}
+	var records []any
+	if err == nil {
+		records, err = source.read(ctx, s.RecordID, s.Lineage)
+	}
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+
+	shared := sharedRecords{
+		Component: s.Component,
+		RecordID:  s.RecordID,
+		Lineage:   s.Lineage,
+		ExpiresAt: s.ExpiresAt,
+		Records:   make([]map[string]json.RawMessage, len(records)),
+	}
+	for i, record := range records {
+		if shared.Records[i], err = shareFields(record, s.Fields); err != nil {
+			responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	responses.RespondData(c, shared, http.StatusOK)
+}
+
+// shareFields returns the JSON fields of record named in fields, or all of them when fields is
+// empty. The id is always kept so lineages can be followed.
+func shareFields(record any, fields []string) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return all, nil
+	}
+	kept := map[string]json.RawMessage{}
+	for name, value := range all {
+		if name == "id" || slices.Contains(fields, name) {
+			kept[name] = value
+		}
+	}
+	return kept, nil
+}
+
+// Describe documents the routes RegisterRoutes serves at basePath
+func (h *ShareHandler) Describe(doc *openapi.Document, basePath string) {
+	tag := strings.TrimPrefix(basePath, "/")
+	op := func(name, summary string) openapi.Operation {
+		return openapi.Operation{
+			OperationID: tag + "." + name,
+			Summary:     summary,
+			Tags:        []string{tag},
+			Responses: map[string]openapi.Response{
+				"403": openapi.ErrorResponse("your role does not allow it"),
+				"500": openapi.ErrorResponse("unexpected error"),
+			},
+		}
+	}
+	model := doc.SchemaFor(&share.Share{})
+
+	getAll := op("getAll", "List the share links of the workspace, revoked and expired ones included")
+	getAll.Responses["200"] = openapi.DataResponse("the share links", &openapi.Schema{Type: "array", Items: model})
+	doc.AddOperation(http.MethodGet, basePath+"/", getAll)
+
+	create := op("create", "Share a record, or a plant and its ancestors, read only; the link's token is only ever shown in this response")
+	create.RequestBody = openapi.JSONBody(doc.NamedSchemaFor("ShareRequest", &shareRequest{}))
+	create.Responses["200"] = openapi.DataResponse("the share link and its token", doc.NamedSchemaFor("NewShare", &newShare{}))
+	create.Responses["400"] = openapi.ErrorResponse("malformed body or unknown fields")
+	create.Responses["404"] = openapi.ErrorResponse("the record does not exist")
+	create.Responses["422"] = openapi.ErrorResponse(fmt.Sprintf("one or more fields are invalid; links expire within %s", h.Env.Auth.ShareMaxTTL))
+	doc.AddOperation(http.MethodPost, basePath+"/", create)
+
+	revoke := op("revoke", "Revoke a share link, so its token stops working")
+	revoke.Parameters = []openapi.Parameter{openapi.PathParam("id", "ID of the share link")}
+	revoke.Responses["200"] = openapi.DataResponse("the id of the revoked share link", &openapi.Schema{Type: "string"})
+	revoke.Responses["404"] = openapi.ErrorResponse("not found")
+	doc.AddOperation(http.MethodDelete, basePath+"/:id", revoke)
+}
+
+// DescribePublic documents the route RegisterPublicRoutes serves at basePath
+func (h *ShareHandler) DescribePublic(doc *openapi.Document, basePath string) {
+	tag := strings.TrimPrefix(basePath, "/")
+	shared := openapi.Operation{
+		OperationID: "shared.get",
+		Summary:     "Get the records a share link exposes; no account is needed",
+		Tags:        []string{tag},
+		Parameters:  []openapi.Parameter{openapi.PathParam("token", "the token of the share link")},
+		Responses: map[string]openapi.Response{
+			"200": openapi.DataResponse("the shared records, with the fields the link shows", doc.NamedSchemaFor("SharedRecords", &sharedRecords{})),
+			"404": openapi.ErrorResponse("the token is invalid, unknown, revoked or expired, or the record was deleted"),
+			"500": openapi.ErrorResponse("unexpected error"),
+		},
+	}
+	doc.AddOperation(http.MethodGet, basePath+"/:token", shared)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kylep342/mendel/internal/components/app/share"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shareBody returns the body of a request sharing the note id for an hour, with the given fields
+func shareBody(id string, fields ...string) string {
+	b, _ := json.Marshal(map[string]any{
+		"component":  constants.TablePlant,
+		"record_id":  id,
+		"fields":     fields,
+		"expires_at": time.Now().Add(time.Hour),
+	})
+	return string(b)
+}
+
+// public requests the records shared by token without credentials
+func (w *workspaceTest) public(token string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/public/shares/"+token, nil)
+	w.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestShareHandler(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+
+	var note testNote
+	w.data(w.do(http.MethodPost, "/notes/", `{"name": "F2"}`, admin), &note)
+
+	var created newShare
+	w.data(w.do(http.MethodPost, "/shares/", shareBody(note.ID, "name"), admin), &created)
+	assert.Equal(t, workspace.DefaultID, created.WorkspaceID)
+	require.NotNil(t, created.CreatedBy)
+	require.NotEmpty(t, created.Token)
+
+	res := w.public(created.Token)
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Equal(t, "no-store", res.Header().Get("Cache-Control"))
+	var shared sharedRecords
+	w.data(res, &shared)
+	require.Len(t, shared.Records, 1)
+	assert.Equal(t, map[string]json.RawMessage{
+		"id":   json.RawMessage(`"` + note.ID + `"`),
+		"name": json.RawMessage(`"F2"`),
+	}, shared.Records[0], "only the chosen fields and the id are shown")
+
+	var all newShare
+	w.data(w.do(http.MethodPost, "/shares/", shareBody(note.ID), admin), &all)
+	w.data(w.public(all.Token), &shared)
+	assert.Contains(t, shared.Records[0], "workspace_id", "every field is shown when none are chosen")
+
+	var shares []share.Share
+	w.data(w.do(http.MethodGet, "/shares/", "", admin), &shares)
+	assert.Len(t, shares, 2)
+
+	w.data(w.do(http.MethodDelete, "/shares/"+created.ID, "", admin), new(string))
+	assert.Equal(t, http.StatusNotFound, w.public(created.Token).Code, "revoked links stop working")
+	assert.Equal(t, http.StatusOK, w.do(http.MethodDelete, "/shares/"+created.ID, "", admin).Code, "revoking again changes nothing")
+	assert.Equal(t, http.StatusOK, w.public(all.Token).Code)
+
+	forged, err := share.NewSigner([]byte("other")).Sign(&all.Share)
+	require.NoError(t, err)
+	for _, token := range []string{forged, "nonsense", all.Token[:len(all.Token)-1]} {
+		assert.Equal(t, http.StatusNotFound, w.public(token).Code, token)
+	}
+
+	w.data(w.do(http.MethodDelete, "/notes/"+note.ID, "", admin), new(string))
+	assert.Equal(t, http.StatusNotFound, w.public(all.Token).Code, "deleted records are no longer shared")
+}
+
+func TestShareHandler_Create(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+	w.handler.Env.Auth.ShareMaxTTL = 24 * time.Hour
+
+	var note testNote
+	w.data(w.do(http.MethodPost, "/notes/", `{"name": "F2"}`, admin), &note)
+
+	for name, body := range map[string]string{
+		"unknown component": `{"component": "seeds", "record_id": "` + note.ID + `", "expires_at": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`,
+		"unknown field":     shareBody(note.ID, "secret"),
+		"no lineage":        `{"component": "` + constants.TablePlant + `", "record_id": "` + note.ID + `", "lineage": true, "expires_at": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`,
+		"expired":           `{"component": "` + constants.TablePlant + `", "record_id": "` + note.ID + `", "expires_at": "2020-01-01T00:00:00Z"}`,
+		"too long":          `{"component": "` + constants.TablePlant + `", "record_id": "` + note.ID + `", "expires_at": "` + time.Now().Add(48*time.Hour).Format(time.RFC3339) + `"}`,
+	} {
+		res := w.do(http.MethodPost, "/shares/", body, admin)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code, "%s: %s", name, res.Body.String())
+	}
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodPost, "/shares/", shareBody(uuid.NewString()), admin).Code)
+	assert.Equal(t, http.StatusBadRequest, w.do(http.MethodPost, "/shares/", `{"unknown": true}`, admin).Code)
+}
+
+func TestShareHandler_Access(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+	adminUser, err := w.users.GetByUsername(context.Background(), "admin")
+	require.NoError(t, err)
+	_, bob := w.newUser(admin, "bob")
+
+	var lab workspace.Workspace
+	w.data(w.do(http.MethodPost, "/workspaces/", `{"name": "lab"}`, bob), &lab)
+	var note testNote
+	w.data(w.in(lab.ID, http.MethodPost, "/notes/", `{"name": "bob's"}`, bob), &note)
+	var created newShare
+	w.data(w.in(lab.ID, http.MethodPost, "/shares/", shareBody(note.ID), bob), &created)
+	assert.Equal(t, lab.ID, created.WorkspaceID)
+
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodPost, "/shares/", shareBody(note.ID), admin).Code, "records of other workspaces cannot be shared")
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodDelete, "/shares/"+created.ID, "", admin).Code)
+
+	members := "/workspaces/" + lab.ID + "/members/"
+	w.data(w.do(http.MethodPost, members, `{"user_id": "`+adminUser.ID+`", "role": "observer"}`, bob), &workspace.Member{})
+	var shares []share.Share
+	w.data(w.in(lab.ID, http.MethodGet, "/shares/", "", admin), &shares)
+	assert.Len(t, shares, 1)
+	assert.Equal(t, http.StatusForbidden, w.in(lab.ID, http.MethodPost, "/shares/", shareBody(note.ID), admin).Code, "observers cannot share")
+	assert.Equal(t, http.StatusForbidden, w.in(lab.ID, http.MethodDelete, "/shares/"+created.ID, "", admin).Code)
+
+	w.data(w.do(http.MethodPut, members+adminUser.ID, `{"role": "guest"}`, bob), &workspace.Member{})
+	assert.Equal(t, http.StatusForbidden, w.in(lab.ID, http.MethodGet, "/shares/", "", admin).Code)
+
+	var shared sharedRecords
+	w.data(w.public(created.Token), &shared)
+	assert.Equal(t, note.ID, shared.RecordID, "anyone holding the token reads the record")
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/db"
+)
+
+const (
+	// mimeNDJSON is the media type of newline delimited JSON, one record per line
+	mimeNDJSON = "application/x-ndjson"
+
+	// streamFlushEvery is how many records are written between flushes of a stream
+	streamFlushEvery = 100
+)
+
+// streamError is the last line of a stream that failed after records were sent.
+// By then the 200 status is already on the wire, so clients must check for it.
+type streamError struct {
+	Error string `json:"error"`
+}
+
+// streamAll responds with every record of stream as NDJSON, writing each as it is read.
+// Writes block while the client is slow to read, which in turn stops reading rows, so a
+// stream holds no more than what the table reads at a time in memory.
+//
+// Streams outlive the read and write timeouts of other requests: they run for up to
+// Server.StreamTimeout, and the write deadline of the connection moves Server.WriteTimeout
+// ahead on every flush, so only a client that stops reading is cut off. A stream cut short by
+// an error, including running out of time, ends with a streamError line, unless the client
+// went away.
+func (h *CRUDHandler[T, PT]) streamAll(c *gin.Context, stream db.StreamTable[T]) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if h.Env.Server.StreamTimeout > 0 {
+		ctx, cancel = context.WithTimeout(c.Request.Context(), h.Env.Server.StreamTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(c.Request.Context())
+	}
+	defer cancel()
+
+	rc := http.NewResponseController(c.Writer)
+	extend := func() {
+		if h.Env.Server.WriteTimeout > 0 {
+			_ = rc.SetWriteDeadline(time.Now().Add(h.Env.Server.WriteTimeout))
+		}
+	}
+	flush := func() {
+		extend()
+		c.Writer.Flush()
+	}
+	extend()
+	enc := json.NewEncoder(c.Writer)
+	written := 0
+	err := stream.StreamAll(ctx, func(item T) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if written == 0 {
+			c.Header("Content-Type", mimeNDJSON)
+			c.Header("X-Content-Type-Options", "nosniff")
+			c.Status(http.StatusOK)
+		}
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+		written++
+		if written%streamFlushEvery == 0 {
+			flush()
+		}
+		return nil
+	})
+
+	switch {
+	case err != nil && written == 0:
+		respondTableError(c, err)
+		return
+	case err != nil:
+		if c.Request.Context().Err() == nil {
+			_ = enc.Encode(streamError{Error: err.Error()})
+		}
+	case written == 0:
+		c.Header("Content-Type", mimeNDJSON)
+		c.Status(http.StatusOK)
+		c.Writer.WriteHeaderNow()
+	}
+	flush()
+}
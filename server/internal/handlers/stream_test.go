@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStreamTable extends MockCRUDTable with the db.StreamTable method.
+// StreamAll hands fn the records given to On("StreamAll"), then returns the error given.
+type MockStreamTable[T any] struct {
+	MockCRUDTable[T]
+}
+
+func (m *MockStreamTable[T]) StreamAll(ctx context.Context, fn func(item T) error) error {
+	args := m.Called(ctx)
+	for _, item := range args.Get(0).([]T) {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func TestCRUDHandler_GetAll_Stream(t *testing.T) {
+	items := []testModel{{ID: "1", Name: "Plant A"}, {ID: "2", Name: "Plant B"}}
+
+	setupStreamEnv := func(t *testing.T, accept string, env func(env *constants.EnvConfig, c *gin.Context)) (*MockStreamTable[testModel], func() (int, string, []string)) {
+		w, c, _, handler := setupTest[testModel, *testModel](t)
+		mockTable := new(MockStreamTable[testModel])
+		handler.Table = mockTable
+		c.Request, _ = http.NewRequest(http.MethodGet, "/items/", nil)
+		c.Request.Header.Set("Accept", accept)
+		if env != nil {
+			env(handler.Env, c)
+		}
+		return mockTable, func() (int, string, []string) {
+			handler.GetAll(c)
+			var lines []string
+			scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			return w.Code, w.Header().Get("Content-Type"), lines
+		}
+	}
+	setupStream := func(t *testing.T, accept string) (*MockStreamTable[testModel], func() (int, string, []string)) {
+		return setupStreamEnv(t, accept, nil)
+	}
+
+	t.Run("streams one record per line", func(t *testing.T) {
+		mockTable, run := setupStream(t, mimeNDJSON)
+		mockTable.On("StreamAll", mock.Anything).Return(items, nil).Once()
+
+		code, contentType, lines := run()
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, mimeNDJSON, contentType)
+		require.Len(t, lines, 2)
+		var first testModel
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+		assert.Equal(t, items[0], first)
+		mockTable.AssertNotCalled(t, "GetAll", mock.Anything)
+	})
+
+	t.Run("empty table", func(t *testing.T) {
+		mockTable, run := setupStream(t, mimeNDJSON)
+		mockTable.On("StreamAll", mock.Anything).Return([]testModel{}, nil).Once()
+
+		code, contentType, lines := run()
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, mimeNDJSON, contentType)
+		assert.Empty(t, lines)
+	})
+
+	t.Run("error before any record", func(t *testing.T) {
+		mockTable, run := setupStream(t, mimeNDJSON)
+		mockTable.On("StreamAll", mock.Anything).Return([]testModel{}, errors.New("query failed")).Once()
+
+		code, _, lines := run()
+
+		assert.Equal(t, http.StatusInternalServerError, code)
+		assert.JSONEq(t, `{"error": "query failed"}`, lines[0])
+	})
+
+	t.Run("error after records ends the stream with an error line", func(t *testing.T) {
+		mockTable, run := setupStream(t, mimeNDJSON)
+		mockTable.On("StreamAll", mock.Anything).Return(items, errors.New("connection lost")).Once()
+
+		code, _, lines := run()
+
+		assert.Equal(t, http.StatusOK, code)
+		require.Len(t, lines, 3)
+		assert.JSONEq(t, `{"error": "connection lost"}`, lines[2])
+	})
+
+	t.Run("running out of time before any record", func(t *testing.T) {
+		mockTable, run := setupStreamEnv(t, mimeNDJSON, func(env *constants.EnvConfig, c *gin.Context) {
+			env.Server.StreamTimeout = time.Nanosecond
+		})
+		mockTable.On("StreamAll", mock.Anything).Return(items, nil).Once()
+
+		code, _, lines := run()
+
+		assert.Equal(t, http.StatusInternalServerError, code, "nothing was sent before the deadline")
+		assert.JSONEq(t, `{"error": "context deadline exceeded"}`, lines[0])
+	})
+
+	// sendThen streams items[0] to a handler, then returns what then does
+	sendThen := func(t *testing.T, ctx context.Context, env func(env *constants.EnvConfig), then func(ctx context.Context) error) (int, []string) {
+		w, c, _, handler := setupTest[testModel, *testModel](t)
+		c.Request, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/items/", nil)
+		c.Request.Header.Set("Accept", mimeNDJSON)
+		env(handler.Env)
+		handler.Table = &funcStream[testModel]{streamAll: func(ctx context.Context, fn func(item testModel) error) error {
+			if err := fn(items[0]); err != nil {
+				return err
+			}
+			return then(ctx)
+		}}
+		handler.GetAll(c)
+		return w.Code, strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	}
+
+	t.Run("running out of time after records ends the stream with an error line", func(t *testing.T) {
+		code, lines := sendThen(t, context.Background(), func(env *constants.EnvConfig) {
+			env.Server.StreamTimeout = 10 * time.Millisecond
+		}, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		assert.Equal(t, http.StatusOK, code)
+		require.Len(t, lines, 2)
+		assert.JSONEq(t, `{"error": "context deadline exceeded"}`, lines[1])
+	})
+
+	t.Run("no error line once the client went away", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		code, lines := sendThen(t, ctx, func(env *constants.EnvConfig) {}, func(context.Context) error {
+			cancel()
+			return context.Canceled
+		})
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, lines, 1, "only the record sent before the client left is written")
+	})
+
+	t.Run("json is still the default", func(t *testing.T) {
+		mockTable, run := setupStream(t, "*/*")
+		mockTable.On("GetAll", mock.Anything).Return(items, nil).Once()
+
+		code, contentType, _ := run()
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, contentType, "application/json")
+		mockTable.AssertNotCalled(t, "StreamAll", mock.Anything)
+	})
+}
+
+// funcStream is a db.StreamTable[T] whose StreamAll is streamAll
+type funcStream[T any] struct {
+	MockCRUDTable[T]
+	streamAll func(ctx context.Context, fn func(item T) error) error
+}
+
+func (f *funcStream[T]) StreamAll(ctx context.Context, fn func(item T) error) error {
+	return f.streamAll(ctx, fn)
+}
@@ -0,0 +1,516 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kylep342/mendel/internal/components"
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/kylep342/mendel/internal/openapi"
+	"github.com/kylep342/mendel/pkg/responses"
+)
+
+// WorkspaceHeader names the request header choosing the workspace a request reads and writes.
+// Responses of scoped routes carry it too, naming the workspace that was used.
+const WorkspaceHeader = "X-Workspace-ID"
+
+// memberKey is the gin context key Scope stores the membership of the request's user under
+const memberKey = "member"
+
+// errLastOwner is returned when removing or demoting a member would leave their workspace without an owner
+var errLastOwner = errors.New("a workspace must keep at least one owner")
+
+// WorkspaceHandler manages workspaces and their members. Its Scope middleware confines the
+// routes of other handlers to one workspace, and as an Authorizer it lets members do what their
+// role in it allows.
+//
+//	Env: for config values
+//	Stores: workspaces and their members
+//	Users: the users who may be members
+//	Tx: runs each write request as a single unit of work; writes are not grouped when nil
+type WorkspaceHandler struct {
+	Env    *constants.EnvConfig
+	Stores workspace.Stores
+	Users  user.Table
+	Tx     db.Transactor
+}
+
+// NewWorkspaceHandler is the constructor for WorkspaceHandler
+func NewWorkspaceHandler(tx db.Transactor, env *constants.EnvConfig, users user.Table, stores workspace.Stores) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		Env:    env,
+		Stores: stores,
+		Users:  users,
+		Tx:     tx,
+	}
+}
+
+// workspaceRequest is the body of a request for a new workspace
+type workspaceRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// memberRequest is the body of a request to add a member to a workspace
+type memberRequest struct {
+	UserID string         `json:"user_id" validate:"required,uuid"`
+	Role   workspace.Role `json:"role" validate:"required,oneof=owner breeder observer guest"`
+}
+
+// roleRequest is the body of a request to change a member's role
+type roleRequest struct {
+	Role workspace.Role `json:"role" validate:"required,oneof=owner breeder observer guest"`
+}
+
+// permissions is what the user may do in a workspace
+//
+//	Role: their role in it, unset without authentication
+//	Permissions: the actions they may take on each component
+type permissions struct {
+	Role        workspace.Role        `json:"role,omitempty"`
+	Permissions workspace.Permissions `json:"permissions"`
+}
+
+// RegisterRoutes connects the handlers to an HTTP server.
+// Only members of a workspace may use its routes, as their role allows.
+func (h *WorkspaceHandler) RegisterRoutes(g gin.IRouter, basePath string) {
+	rg := g.Group(basePath)
+	rg.GET("/", h.GetAll)
+	rg.POST("/", h.Create)
+
+	scoped := rg.Group("/:id", h.scopePath)
+	scoped.GET("/permissions", h.GetPermissions)
+
+	members := scoped.Group("/members")
+	members.GET("/", h.authorize(workspace.ActionRead), h.GetMembers)
+	members.POST("/", h.authorize(workspace.ActionCreate), h.AddMember)
+	members.PUT("/:user_id", h.authorize(workspace.ActionUpdate), h.UpdateMember)
+	members.DELETE("/:user_id", h.authorize(workspace.ActionDelete), h.RemoveMember)
+}
+
+// authorize is middleware refusing requests whose user's role does not allow them to take action on members
+func (h *WorkspaceHandler) authorize(action workspace.Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authorized(c, h, constants.TableWorkspaceMember, action) {
+			c.Next()
+		}
+	}
+}
+
+// Describe documents the routes RegisterRoutes serves at basePath
+func (h *WorkspaceHandler) Describe(doc *openapi.Document, basePath string) {
+	tag := strings.TrimPrefix(basePath, "/")
+	op := func(name, summary string) openapi.Operation {
+		return openapi.Operation{
+			OperationID: tag + "." + name,
+			Summary:     summary,
+			Tags:        []string{tag},
+			Responses:   map[string]openapi.Response{"500": openapi.ErrorResponse("unexpected error")},
+		}
+	}
+	invalid := func(o openapi.Operation) openapi.Operation {
+		o.Responses["400"] = openapi.ErrorResponse("malformed body or unknown fields")
+		o.Responses["422"] = openapi.ErrorResponse("one or more fields are invalid")
+		return o
+	}
+	member := func(o openapi.Operation) openapi.Operation {
+		o.Parameters = append(o.Parameters, openapi.PathParam("id", "ID of the workspace"))
+		o.Responses["400"] = openapi.ErrorResponse("the workspace ID is not a UUID")
+		o.Responses["403"] = openapi.ErrorResponse("not a member of the workspace, or your role does not allow it")
+		return o
+	}
+	ws := doc.SchemaFor(&workspace.Workspace{})
+	members := &openapi.Schema{Type: "array", Items: doc.SchemaFor(&workspace.Member{})}
+
+	getAll := op("getAll", "List the workspaces you are a member of")
+	getAll.Responses["200"] = openapi.DataResponse("the workspaces", &openapi.Schema{Type: "array", Items: ws})
+	doc.AddOperation(http.MethodGet, basePath+"/", getAll)
+
+	create := invalid(op("create", "Create a workspace, which you become the owner of"))
+	create.RequestBody = openapi.JSONBody(doc.NamedSchemaFor("WorkspaceRequest", &workspaceRequest{}))
+	create.Responses["200"] = openapi.DataResponse("the workspace", ws)
+	doc.AddOperation(http.MethodPost, basePath+"/", create)
+
+	perms := member(op("getPermissions", "Get what you may do in a workspace, by component and action"))
+	perms.Responses["200"] = openapi.DataResponse("your role and permissions", doc.NamedSchemaFor("WorkspacePermissions", &permissions{}))
+	doc.AddOperation(http.MethodGet, basePath+"/:id/permissions", perms)
+
+	getMembers := member(op("getMembers", "List the members of a workspace"))
+	getMembers.Responses["200"] = openapi.DataResponse("the members", members)
+	doc.AddOperation(http.MethodGet, basePath+"/:id/members/", getMembers)
+
+	add := member(invalid(op("addMember", "Add a user to a workspace with a role")))
+	add.RequestBody = openapi.JSONBody(doc.NamedSchemaFor("MemberRequest", &memberRequest{}))
+	add.Responses["200"] = openapi.DataResponse("the membership", doc.SchemaFor(&workspace.Member{}))
+	add.Responses["409"] = openapi.ErrorResponse("the user is already a member")
+	doc.AddOperation(http.MethodPost, basePath+"/:id/members/", add)
+
+	update := member(invalid(op("updateMember", "Change the role of a member of a workspace")))
+	update.Parameters = append(update.Parameters, openapi.PathParam("user_id", "ID of the user"))
+	update.RequestBody = openapi.JSONBody(doc.NamedSchemaFor("RoleRequest", &roleRequest{}))
+	update.Responses["200"] = openapi.DataResponse("the membership", doc.SchemaFor(&workspace.Member{}))
+	update.Responses["404"] = openapi.ErrorResponse("the user is not a member")
+	update.Responses["409"] = openapi.ErrorResponse(errLastOwner.Error())
+	doc.AddOperation(http.MethodPut, basePath+"/:id/members/:user_id", update)
+
+	remove := member(op("removeMember", "Remove a user from a workspace"))
+	remove.Parameters = append(remove.Parameters, openapi.PathParam("user_id", "ID of the user"))
+	remove.Responses["200"] = openapi.DataResponse("the id of the removed user", &openapi.Schema{Type: "string"})
+	remove.Responses["404"] = openapi.ErrorResponse("the user is not a member")
+	remove.Responses["409"] = openapi.ErrorResponse(errLastOwner.Error())
+	doc.AddOperation(http.MethodDelete, basePath+"/:id/members/:user_id", remove)
+}
+
+// Scope is middleware confining a request to the workspace its X-Workspace-ID header names or,
+// without one, the oldest workspace its user is a member of. Requests are only admitted to
+// workspaces their user is a member of. Without authentication every workspace is open, and
+// requests without the header use the default workspace.
+//
+//	400: the header is not a UUID
+//	403: the user is not a member of the workspace, or of any
+func (h *WorkspaceHandler) Scope(c *gin.Context) {
+	h.scope(c, c.GetHeader(WorkspaceHeader))
+}
+
+// scopePath is Scope for routes naming the workspace in their path
+func (h *WorkspaceHandler) scopePath(c *gin.Context) {
+	h.scope(c, c.Param("id"))
+}
+
+// scope confines the request to the workspace identified by id, or the one it defaults to when empty
+func (h *WorkspaceHandler) scope(c *gin.Context, id string) {
+	if id != "" {
+		if _, err := uuid.Parse(id); err != nil {
+			responses.RespondError(c, "workspace ID must be a UUID", http.StatusBadRequest)
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	m, err := h.resolve(ctx, c, id)
+	cancel()
+	if errors.Is(err, sql.ErrNoRows) {
+		responses.RespondError(c, "not a member of the workspace; create one or ask a member to add you", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.Set(memberKey, &m)
+	c.Request = c.Request.WithContext(db.WithWorkspace(c.Request.Context(), m.WorkspaceID))
+	c.Header(WorkspaceHeader, m.WorkspaceID)
+	c.Next()
+}
+
+// resolve returns the membership a request asking for the workspace identified by id uses, or
+// sql.ErrNoRows if it may not use it. Without authentication it is a membership of no one.
+func (h *WorkspaceHandler) resolve(ctx context.Context, c *gin.Context, id string) (workspace.Member, error) {
+	p, ok := PrincipalFrom(c)
+	if !ok {
+		if id == "" {
+			id = workspace.DefaultID
+		}
+		_, err := h.Stores.Workspaces.GetByID(ctx, id)
+		return workspace.Member{WorkspaceID: id}, err
+	}
+
+	memberships, err := h.Stores.Members.GetByUser(ctx, p.User.ID)
+	if err != nil {
+		return workspace.Member{}, err
+	}
+	for _, m := range memberships {
+		if id == "" || strings.EqualFold(m.WorkspaceID, id) {
+			return m, nil
+		}
+	}
+	return workspace.Member{}, sql.ErrNoRows
+}
+
+// Authorize lets the request take action on the records of component if the role of its user
+// allows it: their role in the workspace Scope confined the request to or, for global components,
+// in the default workspace. Users may always read and update their own user record.
+// Every request is allowed without authentication.
+func (h *WorkspaceHandler) Authorize(c *gin.Context, component string, action workspace.Action) error {
+	p, ok := PrincipalFrom(c)
+	if !ok {
+		return nil
+	}
+	if component == constants.TableUser && c.Param("id") == p.User.ID &&
+		(action == workspace.ActionRead || action == workspace.ActionUpdate) {
+		return nil
+	}
+
+	role, err := h.role(c, component)
+	if err != nil {
+		return err
+	}
+	if !role.Can(component, action) {
+		return fmt.Errorf("%w: a workspace %s cannot %s %s", ErrForbidden, role, action, component)
+	}
+	return nil
+}
+
+// role returns the role of the request's user governing component: their role in the workspace
+// Scope chose, or in the default workspace for global components and routes not behind Scope,
+// such as the history of a user
+func (h *WorkspaceHandler) role(c *gin.Context, component string) (workspace.Role, error) {
+	if m, ok := c.Get(memberKey); ok && !workspace.IsGlobal(component) {
+		return m.(*workspace.Member).Role, nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+	memberships, err := h.Stores.Members.GetByUser(ctx, MustPrincipal(c).User.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, m := range memberships {
+		if m.WorkspaceID == workspace.DefaultID {
+			return m.Role, nil
+		}
+	}
+	return "", fmt.Errorf("%w: only members of the default workspace may use %s", ErrForbidden, component)
+}
+
+// mustMember returns the membership of the request's user on a route behind Scope
+func mustMember(c *gin.Context) *workspace.Member {
+	m, ok := c.Get(memberKey)
+	if !ok {
+		panic("handlers: route is not behind Scope")
+	}
+	return m.(*workspace.Member)
+}
+
+// GetPermissions responds with the role of the user in the requested workspace, and the
+// actions it allows on each component
+func (h *WorkspaceHandler) GetPermissions(c *gin.Context) {
+	res := permissions{Role: mustMember(c).Role, Permissions: workspace.Permissions{}}
+	for _, component := range workspace.Components {
+		allowed := []workspace.Action{}
+		for _, action := range workspace.Actions {
+			err := h.Authorize(c, component, action)
+			if err != nil && !errors.Is(err, ErrForbidden) {
+				responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if err == nil {
+				allowed = append(allowed, action)
+			}
+		}
+		res.Permissions[component] = allowed
+	}
+	responses.RespondData(c, res, http.StatusOK)
+}
+
+// GetAll responds with the workspaces the user is a member of, or every workspace without authentication
+func (h *WorkspaceHandler) GetAll(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	p, ok := PrincipalFrom(c)
+	if !ok {
+		workspaces, err := h.Stores.Workspaces.GetAll(ctx)
+		if err != nil {
+			responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		responses.RespondData(c, workspaces, http.StatusOK)
+		return
+	}
+
+	memberships, err := h.Stores.Members.GetByUser(ctx, p.User.ID)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	workspaces := make([]workspace.Workspace, 0, len(memberships))
+	for _, m := range memberships {
+		w, err := h.Stores.Workspaces.GetByID(ctx, m.WorkspaceID)
+		if err != nil {
+			responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		workspaces = append(workspaces, w)
+	}
+	responses.RespondData(c, workspaces, http.StatusOK)
+}
+
+// Create responds to a request for a new workspace, making the user its owner
+func (h *WorkspaceHandler) Create(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	var req workspaceRequest
+	if !bindRequest(c, h.Env, &req) {
+		return
+	}
+	w := workspace.Workspace{Name: req.Name}
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		if err := h.Stores.Workspaces.Create(ctx, &w); err != nil {
+			return err
+		}
+		if p, ok := PrincipalFrom(c); ok {
+			return h.Stores.Members.Create(db.WithWorkspace(ctx, w.ID), &workspace.Member{UserID: p.User.ID, Role: workspace.RoleOwner})
+		}
+		return nil
+	})
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, w, http.StatusOK)
+}
+
+// GetMembers responds with the members of the requested workspace
+func (h *WorkspaceHandler) GetMembers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.ReadTimeout)
+	defer cancel()
+
+	members, err := h.Stores.Members.GetAll(ctx)
+	if err != nil {
+		responses.RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	responses.RespondData(c, members, http.StatusOK)
+}
+
+// AddMember responds to a request to add a user to the requested workspace with a role
+func (h *WorkspaceHandler) AddMember(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	var req memberRequest
+	if !bindRequest(c, h.Env, &req) {
+		return
+	}
+	m := workspace.Member{UserID: req.UserID, Role: req.Role}
+	err := db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		if _, err := h.Users.GetByID(ctx, req.UserID); err != nil {
+			return err
+		}
+		return h.Stores.Members.Create(ctx, &m)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		responses.RespondError(c, &components.ValidationError{Fields: []components.FieldError{
+			{Field: "user_id", Message: "no such user"},
+		}}, http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		respondTableError(c, err)
+		return
+	}
+	responses.RespondData(c, m, http.StatusOK)
+}
+
+// UpdateMember responds to a request to change the role of a member of the requested workspace.
+// A workspace always keeps at least one owner.
+func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	var req roleRequest
+	if !bindRequest(c, h.Env, &req) {
+		return
+	}
+	var m workspace.Member
+	err := h.changeMember(ctx, c.Param("user_id"), req.Role, func(ctx context.Context, member workspace.Member) error {
+		member.Role = req.Role
+		m = member
+		return h.Stores.Members.Update(ctx, &m)
+	})
+	if err != nil {
+		respondMemberError(c, err)
+		return
+	}
+	responses.RespondData(c, m, http.StatusOK)
+}
+
+// RemoveMember responds to a request to remove a user from the requested workspace.
+// A workspace always keeps at least one owner.
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Env.Server.WriteTimeout)
+	defer cancel()
+
+	userID := c.Param("user_id")
+	err := h.changeMember(ctx, userID, "", func(ctx context.Context, member workspace.Member) error {
+		return h.Stores.Members.Delete(ctx, member.ID)
+	})
+	if err != nil {
+		respondMemberError(c, err)
+		return
+	}
+	responses.RespondData(c, userID, http.StatusOK)
+}
+
+// changeMember calls change, in a single unit of work, with the membership of the user identified
+// by userID in the workspace of ctx, which will have role after it, or none when role is empty.
+// It fails with sql.ErrNoRows if the user is not a member, or errLastOwner if the workspace
+// would be left without an owner.
+func (h *WorkspaceHandler) changeMember(ctx context.Context, userID string, role workspace.Role, change func(context.Context, workspace.Member) error) error {
+	return db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		members, err := h.Stores.Members.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		var target *workspace.Member
+		owners := 0
+		for i, m := range members {
+			if m.UserID == userID {
+				target = &members[i]
+			}
+			if m.Role == workspace.RoleOwner {
+				owners++
+			}
+		}
+		if target == nil {
+			return sql.ErrNoRows
+		}
+		if target.Role == workspace.RoleOwner && role != workspace.RoleOwner && owners == 1 {
+			return errLastOwner
+		}
+		return change(ctx, *target)
+	})
+}
+
+// respondMemberError responds with the status for an error changing a member
+func respondMemberError(c *gin.Context, err error) {
+	if errors.Is(err, errLastOwner) {
+		responses.RespondError(c, err.Error(), http.StatusConflict)
+		return
+	}
+	respondTableError(c, err)
+}
+
+// Bootstrap creates the default workspace if it is missing and, when username is set, makes the
+// user it names an owner
+func (h *WorkspaceHandler) Bootstrap(ctx context.Context, username string) error {
+	return db.InTx(ctx, h.Tx, func(ctx context.Context) error {
+		_, err := h.Stores.Workspaces.GetByID(ctx, workspace.DefaultID)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = h.Stores.Workspaces.Create(ctx, &workspace.Workspace{ID: workspace.DefaultID, Name: workspace.DefaultName})
+		}
+		if err != nil || username == "" {
+			return err
+		}
+
+		u, err := h.Users.GetByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		err = h.Stores.Members.Create(db.WithWorkspace(ctx, workspace.DefaultID), &workspace.Member{UserID: u.ID, Role: workspace.RoleOwner})
+		if errors.Is(err, db.ErrDuplicate) {
+			return nil
+		}
+		return err
+	})
+}
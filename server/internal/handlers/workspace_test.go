@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kylep342/mendel/internal/components/app/audit"
+	"github.com/kylep342/mendel/internal/components/app/share"
+	"github.com/kylep342/mendel/internal/components/app/user"
+	"github.com/kylep342/mendel/internal/components/app/workspace"
+	"github.com/kylep342/mendel/internal/constants"
+	"github.com/kylep342/mendel/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNote is a record scoped to workspaces
+type testNote struct {
+	ID          string     `db:"id" json:"id"`
+	WorkspaceID string     `db:"workspace_id" json:"workspace_id"`
+	Name        string     `db:"name" json:"name"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+	DeletedAt   *time.Time `db:"deleted_at" json:"deleted_at"`
+}
+
+func (n *testNote) GetID() string   { return n.ID }
+func (n *testNote) SetID(id string) { n.ID = id }
+
+// workspaceTest is an authTest also serving a WorkspaceHandler at /workspaces and, behind its
+// Scope middleware, a CRUDHandler at /notes whose writes are audited, an AuditHandler at /audit
+// and a ShareHandler at /shares sharing notes, whose shared records are public at /public/shares
+type workspaceTest struct {
+	*authTest
+	handler *WorkspaceHandler
+}
+
+func newWorkspaceTest(t *testing.T) *workspaceTest {
+	a := newAuthTest(t)
+	mem := a.handler.Tx.(*db.Memory)
+	h := NewWorkspaceHandler(mem, a.handler.Env, a.users, workspace.NewMemoryStores(mem))
+	require.NoError(t, h.Bootstrap(context.Background(), "admin"))
+	require.NoError(t, h.Bootstrap(context.Background(), "admin"), "bootstrapping again changes nothing")
+
+	authed := a.router.Group("", RequestID, a.handler.Authenticate)
+	h.RegisterRoutes(authed, "/workspaces")
+	notes := NewCRUDHandler(mem, a.handler.Env, func() *testNote { return &testNote{} },
+		db.CRUDTable[testNote](db.NewMemoryHistoryStore[testNote](mem, "notes")))
+	notes.Access, notes.Component = h, constants.TablePlant
+	notes.Audit = audit.NewMemoryStore(mem)
+	scoped := authed.Group("", h.Scope)
+	notes.RegisterRoutes(scoped, "/notes")
+	auditLog := NewAuditHandler(a.handler.Env, notes.Audit)
+	auditLog.Access = h
+	auditLog.RegisterRoutes(scoped, "/audit")
+	shares := NewShareHandler(mem, a.handler.Env, share.NewMemoryStore(mem), share.NewSigner([]byte("test")))
+	shares.Access = h
+	shares.Sources[constants.TablePlant] = notes.ShareSource()
+	shares.RegisterRoutes(scoped, "/shares")
+	shares.RegisterPublicRoutes(a.router, "/public/shares")
+	a.handler.Access = h
+	return &workspaceTest{authTest: a, handler: h}
+}
+
+// in sends a request like do, choosing the workspace identified by workspaceID
+func (w *workspaceTest) in(workspaceID, method, path, body, credential string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: credential})
+	req.Header.Set(WorkspaceHeader, workspaceID)
+	w.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// newUser creates a user named username who can log in, returning them and their session
+func (w *workspaceTest) newUser(admin, username string) (user.User, string) {
+	u := &user.User{Username: username, Enabled: true}
+	require.NoError(w.t, w.users.Create(context.Background(), u))
+	require.Equal(w.t, http.StatusOK, w.do(http.MethodPut, "/auth/users/"+u.ID+"/password", `{"password": "`+testPassword+`"}`, admin).Code)
+	return *u, w.login(username, testPassword)
+}
+
+func TestWorkspaceHandler_Scope(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+	_, bob := w.newUser(admin, "bob")
+
+	var workspaces []workspace.Workspace
+	w.data(w.do(http.MethodGet, "/workspaces/", "", admin), &workspaces)
+	require.Len(t, workspaces, 1)
+	assert.Equal(t, workspace.DefaultID, workspaces[0].ID)
+
+	var note testNote
+	res := w.do(http.MethodPost, "/notes/", `{"name": "admin's", "workspace_id": "ignored"}`, admin)
+	w.data(res, &note)
+	assert.Equal(t, workspace.DefaultID, res.Header().Get(WorkspaceHeader), "requests without the header use the oldest workspace")
+	assert.Equal(t, workspace.DefaultID, note.WorkspaceID)
+
+	assert.Equal(t, http.StatusForbidden, w.do(http.MethodGet, "/notes/", "", bob).Code, "bob is not a member of any workspace")
+	var lab workspace.Workspace
+	w.data(w.do(http.MethodPost, "/workspaces/", `{"name": "lab"}`, bob), &lab)
+	var notes []testNote
+	w.data(w.do(http.MethodGet, "/notes/", "", bob), &notes)
+	assert.Empty(t, notes, "records of other workspaces are never seen")
+	assert.Equal(t, http.StatusOK, w.do(http.MethodPost, "/notes/", `{"name": "bob's"}`, bob).Code)
+
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodGet, "/notes/"+note.ID, "", bob).Code)
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodPut, "/notes/"+note.ID, `{"name": "mine"}`, bob).Code)
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodDelete, "/notes/"+note.ID, "", bob).Code)
+	assert.Equal(t, http.StatusForbidden, w.in(workspace.DefaultID, http.MethodGet, "/notes/", "", bob).Code)
+	assert.Equal(t, http.StatusForbidden, w.in(lab.ID, http.MethodGet, "/notes/", "", admin).Code)
+	assert.Equal(t, http.StatusForbidden, w.in(uuid.NewString(), http.MethodGet, "/notes/", "", admin).Code)
+	assert.Equal(t, http.StatusBadRequest, w.in("lab", http.MethodGet, "/notes/", "", admin).Code)
+
+	w.data(w.in(workspace.DefaultID, http.MethodGet, "/notes/", "", admin), &notes)
+	require.Len(t, notes, 1)
+	assert.Equal(t, "admin's", notes[0].Name)
+}
+
+func TestWorkspaceHandler_Members(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+	bobUser, bob := w.newUser(admin, "bob")
+	adminUser, err := w.users.GetByUsername(context.Background(), "admin")
+	require.NoError(t, err)
+
+	var lab workspace.Workspace
+	w.data(w.do(http.MethodPost, "/workspaces/", `{"name": "lab"}`, bob), &lab)
+	w.data(w.in(lab.ID, http.MethodPost, "/notes/", `{"name": "bob's"}`, bob), &testNote{})
+	members := "/workspaces/" + lab.ID + "/members/"
+
+	assert.Equal(t, http.StatusForbidden, w.do(http.MethodGet, members, "", admin).Code, "only members see the members")
+	assert.Equal(t, http.StatusForbidden, w.do(http.MethodPost, members, `{"user_id": "`+adminUser.ID+`", "role": "breeder"}`, admin).Code)
+	assert.Equal(t, http.StatusBadRequest, w.do(http.MethodGet, "/workspaces/lab/members/", "", bob).Code)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.do(http.MethodPost, members, `{"user_id": "`+uuid.NewString()+`", "role": "breeder"}`, bob).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.do(http.MethodPost, members, `{"user_id": "`+adminUser.ID+`", "role": "admin"}`, bob).Code)
+	assert.Equal(t, http.StatusOK, w.do(http.MethodPost, members, `{"user_id": "`+adminUser.ID+`", "role": "breeder"}`, bob).Code)
+	assert.Equal(t, http.StatusConflict, w.do(http.MethodPost, members, `{"user_id": "`+adminUser.ID+`", "role": "breeder"}`, bob).Code)
+
+	var listed []workspace.Member
+	w.data(w.do(http.MethodGet, members, "", admin), &listed)
+	assert.Len(t, listed, 2)
+	var workspaces []workspace.Workspace
+	w.data(w.do(http.MethodGet, "/workspaces/", "", admin), &workspaces)
+	assert.Len(t, workspaces, 2)
+	var notes []testNote
+	w.data(w.in(lab.ID, http.MethodGet, "/notes/", "", admin), &notes)
+	assert.Len(t, notes, 1, "members see the workspace's records")
+
+	assert.Equal(t, http.StatusOK, w.do(http.MethodDelete, members+adminUser.ID, "", bob).Code)
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodDelete, members+adminUser.ID, "", bob).Code)
+	assert.Equal(t, http.StatusForbidden, w.in(lab.ID, http.MethodGet, "/notes/", "", admin).Code, "removed members lose access")
+	assert.Equal(t, http.StatusConflict, w.do(http.MethodDelete, members+bobUser.ID, "", bob).Code)
+}
+
+func TestWorkspaceHandler_Roles(t *testing.T) {
+	w := newWorkspaceTest(t)
+	admin := w.login("admin", testPassword)
+	bobUser, bob := w.newUser(admin, "bob")
+	adminUser, err := w.users.GetByUsername(context.Background(), "admin")
+	require.NoError(t, err)
+
+	var lab workspace.Workspace
+	w.data(w.do(http.MethodPost, "/workspaces/", `{"name": "lab"}`, bob), &lab)
+	var note testNote
+	w.data(w.in(lab.ID, http.MethodPost, "/notes/", `{"name": "bob's"}`, bob), &note)
+	members := "/workspaces/" + lab.ID + "/members/"
+	w.data(w.do(http.MethodPost, members, `{"user_id": "`+adminUser.ID+`", "role": "guest"}`, bob), &workspace.Member{})
+
+	var perms permissions
+	w.data(w.do(http.MethodGet, "/workspaces/"+lab.ID+"/permissions", "", admin), &perms)
+	assert.Equal(t, workspace.RoleGuest, perms.Role)
+	assert.Equal(t, []workspace.Action{workspace.ActionRead}, perms.Permissions[constants.TablePlant])
+	assert.Empty(t, perms.Permissions[constants.TableWorkspaceMember])
+	assert.Equal(t, workspace.Actions, perms.Permissions[constants.TableUser], "users are governed by the role in the default workspace")
+
+	assert.Equal(t, http.StatusOK, w.in(lab.ID, http.MethodGet, "/notes/", "", admin).Code)
+	assert.Equal(t, http.StatusOK, w.in(lab.ID, http.MethodGet, "/notes/"+note.ID, "", admin).Code)
+	for _, req := range [][3]string{
+		{http.MethodPost, "/notes/", `{"name": "admin's"}`},
+		{http.MethodPut, "/notes/" + note.ID, `{"name": "admin's"}`},
+		{http.MethodDelete, "/notes/" + note.ID, ""},
+		{http.MethodPost, "/notes/" + note.ID + "/restore", ""},
+		{http.MethodPost, "/notes/batch", `{"operations": [{"op": "update", "id": "` + note.ID + `", "data": {"name": "x"}}]}`},
+		{http.MethodGet, members, ""},
+	} {
+		res := w.in(lab.ID, req[0], req[1], req[2], admin)
+		assert.Equal(t, http.StatusForbidden, res.Code, "%s %s: %s", req[0], req[1], res.Body.String())
+	}
+
+	assert.Equal(t, http.StatusOK, w.do(http.MethodPut, members+adminUser.ID, `{"role": "breeder"}`, bob).Code)
+	assert.Equal(t, http.StatusOK, w.in(lab.ID, http.MethodPut, "/notes/"+note.ID, `{"name": "admin's"}`, admin).Code)
+	assert.Equal(t, http.StatusOK, w.in(lab.ID, http.MethodPost, "/notes/batch",
+		`{"operations": [{"op": "create", "data": {"name": "x"}}, {"op": "delete", "id": "`+note.ID+`"}]}`, admin).Code)
+	assert.Equal(t, http.StatusOK, w.do(http.MethodGet, members, "", admin).Code)
+	assert.Equal(t, http.StatusForbidden, w.do(http.MethodPut, members+bobUser.ID, `{"role": "guest"}`, admin).Code, "breeders cannot manage members")
+
+	assert.Equal(t, http.StatusConflict, w.do(http.MethodPut, members+bobUser.ID, `{"role": "guest"}`, bob).Code)
+	assert.Equal(t, http.StatusOK, w.do(http.MethodPut, members+adminUser.ID, `{"role": "owner"}`, bob).Code)
+	assert.Equal(t, http.StatusOK, w.do(http.MethodPut, members+bobUser.ID, `{"role": "observer"}`, bob).Code, "another owner remains")
+	assert.Equal(t, http.StatusForbidden, w.in(lab.ID, http.MethodPost, "/notes/", `{"name": "bob's"}`, bob).Code)
+	assert.Equal(t, http.StatusNotFound, w.do(http.MethodPut, members+uuid.NewString(), `{"role": "guest"}`, admin).Code)
+
+	assert.Equal(t, http.StatusForbidden, w.do(http.MethodPut, "/auth/users/"+adminUser.ID+"/password", `{"password": "bob's password"}`, bob).Code,
+		"only owners of the default workspace set others' passwords")
+	assert.Equal(t, http.StatusOK, w.do(http.MethodPut, "/auth/users/"+bobUser.ID+"/password", `{"password": "bob's password"}`, bob).Code)
+}
@@ -0,0 +1,25 @@
+package openapi
+
+import (
+	"embed"
+	"io/fs"
+	"strings"
+)
+
+//go:embed docs.html
+var docsPage string
+
+// swaggerUI holds the JavaScript and CSS of Swagger UI 5.18.2, copied unchanged from the dist
+// folder of its release so that the docs page loads nothing from other origins
+//
+//go:embed swagger-ui
+var swaggerUI embed.FS
+
+// DocsAssets are the files the docs page loads, to be served at the assetsURL given to DocsPage
+var DocsAssets, _ = fs.Sub(swaggerUI, "swagger-ui")
+
+// DocsPage renders an HTML page that displays the document served at specURL, loading Swagger UI
+// from DocsAssets served at assetsURL
+func DocsPage(specURL, assetsURL string) []byte {
+	return []byte(strings.NewReplacer("{{SPEC_URL}}", specURL, "{{ASSETS_URL}}", assetsURL).Replace(docsPage))
+}
@@ -0,0 +1,435 @@
+// Package openapi builds an OpenAPI 3.1 document from the routes and models registered with it
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Version = "3.1.0"
+
+// Document is the root of an OpenAPI document
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	mu sync.Mutex
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes a way requests authenticate, such as a cookie or a bearer token
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// SecurityRequirement names the security schemes an operation accepts, with their scopes
+type SecurityRequirement map[string][]string
+
+// PathItem maps lowercase HTTP methods to the operation served on a path
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is the subset of JSON Schema used to describe mendel's models
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+// NewDocument creates an empty Document
+func NewDocument(title, version string) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: title, Version: version},
+		Paths:      map[string]*PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+}
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// AddOperation documents the operation served for method on path.
+// path may use gin syntax; /plant/:id is documented as /plant/{id}.
+func (d *Document) AddOperation(method, path string, op Operation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path = ginParam.ReplaceAllString(path, "{$1}")
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	if op.Responses == nil {
+		op.Responses = map[string]Response{}
+	}
+	(*item)[strings.ToLower(method)] = &op
+}
+
+// AddSecurityScheme registers scheme under name
+func (d *Document) AddSecurityScheme(name string, scheme *SecurityScheme) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.Components.SecuritySchemes == nil {
+		d.Components.SecuritySchemes = map[string]*SecurityScheme{}
+	}
+	d.Components.SecuritySchemes[name] = scheme
+}
+
+// Secure documents that the operations on basePath and below accept any one of schemes,
+// adding 401 and 403 responses to those that do not have them
+func (d *Document) Secure(basePath string, schemes ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	basePath = strings.TrimSuffix(ginParam.ReplaceAllString(basePath, "{$1}"), "/")
+	for path, item := range d.Paths {
+		if path != basePath && !strings.HasPrefix(path, basePath+"/") {
+			continue
+		}
+		for _, op := range *item {
+			op.Security = nil
+			for _, scheme := range schemes {
+				op.Security = append(op.Security, SecurityRequirement{scheme: {}})
+			}
+			if _, ok := op.Responses["401"]; !ok {
+				op.Responses["401"] = ErrorResponse("not logged in, or the API token is unknown, expired or revoked")
+			}
+			if _, ok := op.Responses["403"]; !ok {
+				op.Responses["403"] = ErrorResponse("the API token lacks the scope")
+			}
+		}
+	}
+}
+
+// AddParameter documents that the operations on basePath and below accept p, and may answer
+// with responses. A response an operation already has is described as either reason.
+func (d *Document) AddParameter(basePath string, p Parameter, responses map[string]Response) {
+	d.eachOperation(basePath, func(op *Operation) {
+		op.Parameters = append(op.Parameters, p)
+		addResponses(op, responses)
+	})
+}
+
+// AddResponses documents that the operations on basePath and below may answer with responses.
+// A response an operation already has is described as either reason.
+func (d *Document) AddResponses(basePath string, responses map[string]Response) {
+	d.eachOperation(basePath, func(op *Operation) { addResponses(op, responses) })
+}
+
+// eachOperation calls f with every operation on basePath and below
+func (d *Document) eachOperation(basePath string, f func(op *Operation)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	basePath = strings.TrimSuffix(ginParam.ReplaceAllString(basePath, "{$1}"), "/")
+	for path, item := range d.Paths {
+		if path != basePath && !strings.HasPrefix(path, basePath+"/") {
+			continue
+		}
+		for _, op := range *item {
+			f(op)
+		}
+	}
+}
+
+// addResponses adds responses to op, joining the descriptions of those it already has
+func addResponses(op *Operation, responses map[string]Response) {
+	for status, r := range responses {
+		if existing, ok := op.Responses[status]; ok {
+			existing.Description += ", or " + r.Description
+			r = existing
+		}
+		op.Responses[status] = r
+	}
+}
+
+// SchemaFor returns a reference to the schema of v's type, registering it under its Go type name
+func (d *Document) SchemaFor(v any) *Schema {
+	return d.NamedSchemaFor("", v)
+}
+
+// NamedSchemaFor returns a reference to the schema of v's type, registering it under name
+func (d *Document) NamedSchemaFor(name string, v any) *Schema {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return d.schema(t)
+	}
+	if name == "" {
+		name = t.Name()
+	}
+	return d.component(name, t)
+}
+
+// component registers the struct t under name and returns a reference to it
+func (d *Document) component(name string, t reflect.Type) *Schema {
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := d.Components.Schemas[name]; ok {
+		return ref
+	}
+
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	// registered before its properties so self-referencing types terminate
+	d.Components.Schemas[name] = s
+	d.properties(s, t)
+	sort.Strings(s.Required)
+	return ref
+}
+
+// properties adds the json-visible fields of struct t to s
+func (d *Document) properties(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				d.properties(s, ft)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := d.schema(f.Type)
+		rules := strings.Split(f.Tag.Get("validate"), ",")
+		applyRules(prop, rules)
+		s.Properties[name] = prop
+		if contains(rules, "required") && !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	durationTyp = reflect.TypeOf(time.Duration(0))
+	rawJSONType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schema describes t inline, except for named structs which become references
+func (d *Document) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == durationTyp:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case t == rawJSONType:
+		// already encoded JSON, of any value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := d.schema(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		if typ, ok := s.Type.(string); ok {
+			s.Type = []string{typ, "null"}
+		}
+		return s
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: intFormat(t)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			d.properties(s, t)
+			return s
+		}
+		return d.component(t.Name(), t)
+	default:
+		// interface{} holds any JSON value
+		return &Schema{}
+	}
+}
+
+func intFormat(t reflect.Type) string {
+	if t.Bits() == 64 || t.Kind() == reflect.Int || t.Kind() == reflect.Uint {
+		return "int64"
+	}
+	return "int32"
+}
+
+// applyRules reflects `validate` struct tag rules in s
+func applyRules(s *Schema, rules []string) {
+	for _, rule := range rules {
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		case "max", "min":
+			if n, err := strconv.Atoi(param); err == nil && isString(s) {
+				if key == "max" {
+					s.MaxLength = &n
+				} else {
+					s.MinLength = &n
+				}
+			}
+		}
+	}
+}
+
+func isString(s *Schema) bool {
+	switch typ := s.Type.(type) {
+	case string:
+		return typ == "string"
+	case []string:
+		return contains(typ, "string")
+	}
+	return false
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// DataResponse describes a successful response body of the form {"data": schema}
+func DataResponse(description string, schema *Schema) Response {
+	return Response{
+		Description: description,
+		Content: map[string]MediaType{
+			"application/json": {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"data": schema},
+				Required:   []string{"data"},
+			}},
+		},
+	}
+}
+
+// ErrorResponse describes an error response body of the form {"error": ...}
+func ErrorResponse(description string) Response {
+	return Response{
+		Description: description,
+		Content: map[string]MediaType{
+			"application/json": {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"error": {}},
+				Required:   []string{"error"},
+			}},
+		},
+	}
+}
+
+// JSONBody describes a required JSON request body
+func JSONBody(schema *Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// PathParam describes a required path parameter
+func PathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: &Schema{Type: "string"}}
+}
+
+// HeaderParam describes an optional request header
+func HeaderParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// QueryParam describes an optional query parameter
+func QueryParam(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
@@ -0,0 +1,115 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testParent struct {
+	ID string `json:"id"`
+}
+
+type testModel struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name" validate:"required,max=20"`
+	ParentID  *string         `json:"parent_id" validate:"omitempty,uuid"`
+	Parent    *testParent     `json:"parent"`
+	Tags      []string        `json:"tags"`
+	Labels    map[string]any  `json:"labels"`
+	Genetics  interface{}     `json:"genetics"`
+	Raw       json.RawMessage `json:"raw"`
+	Count     uint32          `json:"count"`
+	CreatedAt time.Time       `json:"created_at"`
+	Hidden    string          `json:"-"`
+	internal  string
+}
+
+func TestDocument_SchemaFor(t *testing.T) {
+	doc := NewDocument("test", "1.0.0")
+
+	ref := doc.SchemaFor(&testModel{})
+	assert.Equal(t, "#/components/schemas/testModel", ref.Ref)
+
+	s := doc.Components.Schemas["testModel"]
+	require.NotNil(t, s)
+	assert.Equal(t, []string{"name"}, s.Required)
+	assert.NotContains(t, s.Properties, "Hidden")
+	assert.NotContains(t, s.Properties, "internal")
+
+	assert.Equal(t, "string", s.Properties["name"].Type)
+	assert.Equal(t, 20, *s.Properties["name"].MaxLength)
+	assert.Equal(t, []string{"string", "null"}, s.Properties["parent_id"].Type)
+	assert.Equal(t, "uuid", s.Properties["parent_id"].Format)
+	assert.Equal(t, "#/components/schemas/testParent", s.Properties["parent"].Ref)
+	assert.Equal(t, "array", s.Properties["tags"].Type)
+	assert.Equal(t, "object", s.Properties["labels"].Type)
+	assert.Equal(t, &Schema{}, s.Properties["genetics"])
+	assert.Equal(t, &Schema{}, s.Properties["raw"], "raw JSON holds any value")
+	assert.Equal(t, "int32", s.Properties["count"].Format)
+	assert.Equal(t, "date-time", s.Properties["created_at"].Format)
+	assert.Contains(t, doc.Components.Schemas, "testParent")
+}
+
+func TestDocument_AddOperation(t *testing.T) {
+	doc := NewDocument("test", "1.0.0")
+	doc.AddOperation("GET", "/plant/:id/lineage", Operation{OperationID: "plant.getLineage"})
+	doc.AddOperation("DELETE", "/plant/:id", Operation{OperationID: "plant.delete"})
+
+	require.Contains(t, doc.Paths, "/plant/{id}/lineage")
+	assert.Equal(t, "plant.getLineage", (*doc.Paths["/plant/{id}/lineage"])["get"].OperationID)
+	assert.Equal(t, "plant.delete", (*doc.Paths["/plant/{id}"])["delete"].OperationID)
+
+	_, err := json.Marshal(doc)
+	assert.NoError(t, err)
+}
+
+func TestDocument_Secure(t *testing.T) {
+	doc := NewDocument("test", "1.0.0")
+	doc.AddOperation("GET", "/plant/:id", Operation{OperationID: "plant.getByID"})
+	doc.AddOperation("GET", "/plant-cultivar/", Operation{OperationID: "plant-cultivar.getAll"})
+	doc.AddOperation("POST", "/auth/login", Operation{OperationID: "auth.login"})
+
+	doc.Secure("/plant", "session", "token")
+
+	op := (*doc.Paths["/plant/{id}"])["get"]
+	assert.Equal(t, []SecurityRequirement{{"session": {}}, {"token": {}}}, op.Security)
+	assert.Contains(t, op.Responses, "401")
+	assert.Contains(t, op.Responses, "403")
+	assert.Empty(t, (*doc.Paths["/plant-cultivar/"])["get"].Security, "sibling paths sharing a prefix are not secured")
+	assert.Empty(t, (*doc.Paths["/auth/login"])["post"].Security)
+}
+
+func TestDocument_AddParameter(t *testing.T) {
+	doc := NewDocument("test", "1.0.0")
+	doc.AddOperation("GET", "/plant/:id", Operation{OperationID: "plant.getByID"})
+	doc.AddOperation("GET", "/plant-cultivar/", Operation{OperationID: "plant-cultivar.getAll"})
+	doc.Secure("/plant", "session")
+
+	doc.AddParameter("/plant", HeaderParam("X-Test", "a header"), map[string]Response{
+		"403": ErrorResponse("not allowed"),
+		"409": ErrorResponse("conflict"),
+	})
+
+	op := (*doc.Paths["/plant/{id}"])["get"]
+	assert.Equal(t, []Parameter{{Name: "X-Test", In: "header", Description: "a header", Schema: &Schema{Type: "string"}}}, op.Parameters)
+	assert.Equal(t, "the API token lacks the scope, or not allowed", op.Responses["403"].Description)
+	assert.Equal(t, "conflict", op.Responses["409"].Description)
+	assert.Empty(t, (*doc.Paths["/plant-cultivar/"])["get"].Parameters, "sibling paths sharing a prefix are not changed")
+}
+
+func TestDocument_AddResponses(t *testing.T) {
+	doc := NewDocument("test", "1.0.0")
+	doc.AddOperation("GET", "/user/", Operation{OperationID: "user.getAll", Responses: map[string]Response{"403": ErrorResponse("no scope")}})
+	doc.AddOperation("GET", "/users/", Operation{OperationID: "users.getAll"})
+
+	doc.AddResponses("/user", map[string]Response{"403": ErrorResponse("no role")})
+
+	op := (*doc.Paths["/user/"])["get"]
+	assert.Empty(t, op.Parameters)
+	assert.Equal(t, "no scope, or no role", op.Responses["403"].Description)
+	assert.Empty(t, (*doc.Paths["/users/"])["get"].Responses)
+}
@@ -0,0 +1,269 @@
+// Package oidctest is a minimal OpenID Connect identity provider for tests and local development.
+// It signs in whoever the authorization request's login_hint names without a password, asking for
+// a name when there is no hint. Never expose it beyond localhost.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// codeTTL is how long an authorization code can be redeemed for
+const codeTTL = time.Minute
+
+// Provider is an http.Handler serving discovery, keys, authorization and token endpoints at the
+// root of Issuer
+//
+//	Issuer: the URL the provider is served at, which its ID tokens name
+//	ClientID, ClientSecret: the one client allowed; the secret is not checked when empty
+//	Claims: the claims of whoever signs in as login, besides iss, sub, aud, exp, iat and nonce;
+//		DefaultClaims when nil
+//	TokenTTL: how long ID tokens live
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Claims       func(login string) map[string]any
+	TokenTTL     time.Duration
+
+	key  jose.JSONWebKey
+	mux  *http.ServeMux
+	mu   sync.Mutex
+	code map[string]grant
+}
+
+// grant is what an authorization code was issued for
+type grant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	login       string
+	expires     time.Time
+}
+
+// New creates a Provider for the client identified by clientID and clientSecret.
+// Set Issuer before serving it.
+func New(clientID, clientSecret string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenTTL:     5 * time.Minute,
+		key:          jose.JSONWebKey{Key: key, KeyID: kid, Algorithm: string(jose.RS256), Use: "sig"},
+		mux:          http.NewServeMux(),
+		code:         map[string]grant{},
+	}
+	p.mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	p.mux.HandleFunc("GET /keys", p.keys)
+	p.mux.HandleFunc("GET /authorize", p.authorize)
+	p.mux.HandleFunc("POST /token", p.token)
+	return p, nil
+}
+
+// DefaultClaims names the user signing in as login after it, with an example.com email address
+func DefaultClaims(login string) map[string]any {
+	return map[string]any{
+		"preferred_username": login,
+		"name":               login,
+		"email":              login + "@example.com",
+		"email_verified":     true,
+	}
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{string(jose.RS256)},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+	})
+}
+
+func (p *Provider) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{p.key.Public()}})
+}
+
+// loginForm asks who to sign in as, repeating the authorization request
+var loginForm = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<title>Mock identity provider</title>
+<form method="get" action="authorize">
+{{range $name, $values := .}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+{{end}}{{end}}<label>Sign in as <input name="login_hint" autofocus required></label>
+<button>Sign in</button>
+</form>
+`))
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "redirect_uri must be an absolute URL", http.StatusBadRequest)
+		return
+	}
+	if q.Get("client_id") != p.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+
+	reply := redirectURI.Query()
+	reply.Set("state", q.Get("state"))
+	switch {
+	case q.Get("response_type") != "code":
+		reply.Set("error", "unsupported_response_type")
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		reply.Set("error", "invalid_request")
+		reply.Set("error_description", "an S256 code_challenge is required")
+	case q.Get("login_hint") == "":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginForm.Execute(w, q)
+		return
+	default:
+		code, err := randomString()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p.mu.Lock()
+		p.code[code] = grant{
+			redirectURI: q.Get("redirect_uri"),
+			challenge:   q.Get("code_challenge"),
+			nonce:       q.Get("nonce"),
+			login:       q.Get("login_hint"),
+			expires:     time.Now().Add(codeTTL),
+		}
+		p.mu.Unlock()
+		reply.Set("code", code)
+	}
+	redirectURI.RawQuery = reply.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || p.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(p.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.code[code]
+	delete(p.code, code)
+	p.mu.Unlock()
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !ok || time.Now().After(g.expires):
+		tokenError(w, "invalid_grant", "unknown, used or expired code")
+		return
+	case g.redirectURI != r.PostForm.Get("redirect_uri"):
+		tokenError(w, "invalid_grant", "redirect_uri does not match")
+		return
+	case base64.RawURLEncoding.EncodeToString(challenge[:]) != g.challenge:
+		tokenError(w, "invalid_grant", "code_verifier does not match")
+		return
+	}
+
+	idToken, err := p.sign(g)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	accessToken, err := randomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(p.TokenTTL.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+// sign issues the ID token for g
+func (p *Provider) sign(g grant) (string, error) {
+	claimsOf := p.Claims
+	if claimsOf == nil {
+		claimsOf = DefaultClaims
+	}
+	now := time.Now()
+	claims := claimsOf(g.login)
+	claims["iss"] = p.Issuer
+	claims["sub"] = g.login
+	claims["aud"] = p.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(p.TokenTTL).Unix()
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: p.key}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+	return jwt.Signed(signer).Claims(claims).Serialize()
+}
+
+// randomString returns 128 random bits, base64url encoded
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// tokenError responds to a token request as RFC 6749 section 5.2 does
+func tokenError(w http.ResponseWriter, code, description string) {
+	body := map[string]string{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	writeJSON(w, http.StatusBadRequest, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
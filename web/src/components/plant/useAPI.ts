@@ -7,8 +7,8 @@ import { type JSONable } from '@/types/app';
 export interface PlantRequest {
   cultivar_id: string;
   species_id: string;
-  seed_id: string;
-  pollen_id: string;
+  seed_id: string | null;
+  pollen_id: string | null;
   genetics: Record<string, JSONable>;
   labels: Record<string, JSONable>;
 }
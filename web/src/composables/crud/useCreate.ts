@@ -28,6 +28,7 @@ export function useCreate<T>(apiPath: string) {
     try {
       const response = await fetch(fullApiUrl, {
         method: 'POST',
+        credentials: 'include',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(payload),
       });
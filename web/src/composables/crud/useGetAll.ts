@@ -22,7 +22,7 @@ export function useGetAll<T>(apiPath: string) {
     isLoading.value = true;
     error.value = null;
     try {
-      const response = await fetch(fullApiUrl);
+      const response = await fetch(fullApiUrl, { credentials: 'include' });
       const jsonResponse = await response.json();
 
       if (!response.ok) {